@@ -0,0 +1,283 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rapidloop/rtop/pkg/alert"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagAlertsSince string
+	flagAlertsHost  string
+	flagAlertsJSON  bool
+
+	alertsCmd = &cobra.Command{
+		Use:   "alerts",
+		Short: "Look into the history of the alerts fired by --alert rules.",
+	}
+
+	alertsListCmd = &cobra.Command{
+		Use:   "list [--since 24h]",
+		Short: "List the alerts fired and resolved since a time, oldest first.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAlertsList()
+		},
+	}
+)
+
+func init() {
+	alertsListCmd.Flags().StringVar(&flagAlertsSince, "since", "24h", "list the alerts since this long ago, or since an RFC 3339 time")
+	alertsListCmd.Flags().StringVar(&flagAlertsHost, "host", "", "only list the alerts of the hosts matching this pattern, e.g. 'db-*'")
+	alertsListCmd.Flags().BoolVar(&flagAlertsJSON, "json", false, "print the alerts as JSON lines")
+	alertsCmd.AddCommand(alertsListCmd)
+	cmd.AddCommand(alertsCmd)
+}
+
+// addAlertFlags adds the flags of the alert rules to a command collecting
+// stats.
+func addAlertFlags(c *cobra.Command) {
+	c.Flags().StringArrayVar(&flagAlerts, "alert", nil, "alert when 'metric op threshold' holds, e.g. 'mem.used_percent > 90' or 'fs./.free < 5GiB'; repeatable")
+	c.Flags().StringVar(&flagAlertLog, "alert-log", "", "append fired and resolved alerts to this file (default: stderr unless a TUI is shown)")
+}
+
+func runAlertsList() error {
+	since, err := alert.ParseSince(flagAlertsSince, time.Now())
+	if err != nil {
+		return err
+	}
+	store, err := alertStore()
+	if err != nil {
+		return err
+	}
+	records, err := store.Since(since)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if !flagAlertsJSON {
+		fmt.Fprintln(w, "TIME\tHOST\tSTATE\tMETRIC\tVALUE\tRULE")
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range records {
+		if flagAlertsHost != "" {
+			if ok, err := path.Match(flagAlertsHost, r.Host); err != nil {
+				return fmt.Errorf("invalid host pattern %q: %s", flagAlertsHost, err)
+			} else if !ok {
+				continue
+			}
+		}
+		if flagAlertsJSON {
+			enc.Encode(r)
+			continue
+		}
+		state := r.State
+		if r.Silenced {
+			state += " (silenced)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Time.Local().Format("2006-01-02 15:04:05"),
+			r.Host, state, r.Metric, r.Formatted, r.Rule)
+	}
+	return w.Flush()
+}
+
+// exitCodeError makes the program exit with the given status.
+type exitCodeError struct {
+	code int
+	msg  string
+}
+
+func (e exitCodeError) Error() string {
+	return e.msg
+}
+
+// alerts passes the events of the alert engine on to the alert log and,
+// once it runs, to the TUI.
+type alerts struct {
+	engine *alert.Engine
+	log    *log.Logger
+	store  *alert.Store
+
+	mu      sync.Mutex
+	program *tea.Program
+}
+
+// newAlerts returns the alerts of the --alert rules, or nil if there are
+// none. Events are kept in the alert history, and logged to --alert-log,
+// or to stderr if not given and the stats are not shown in a TUI.
+func newAlerts(tty bool) (*alerts, error) {
+	if len(flagAlerts) == 0 {
+		if flagFailOnAlert {
+			return nil, fmt.Errorf("--fail-on-alert needs --alert")
+		}
+		return nil, nil
+	}
+	rules := make([]alert.Rule, 0, len(flagAlerts))
+	for _, s := range flagAlerts {
+		r, err := alert.ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	var w io.Writer = io.Discard
+	if flagAlertLog != "" {
+		f, err := os.OpenFile(flagAlertLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	} else if !tty {
+		w = os.Stderr
+	}
+	store, err := alertStore()
+	if err != nil {
+		return nil, err
+	}
+	silences, err := silenceStore()
+	if err != nil {
+		return nil, err
+	}
+	var windows []alert.Window
+	if config != nil {
+		windows = config.Maintenance
+	}
+	engine := alert.NewEngine(rules, alert.WithSilencer(alert.NewSilences(silences, windows)))
+	return &alerts{engine: engine, log: log.New(w, "", log.LstdFlags), store: store}, nil
+}
+
+// alertStore returns the alert history kept in the data directory.
+func alertStore() (*alert.Store, error) {
+	path, err := dataDir("alerts.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	return alert.NewStore(path), nil
+}
+
+// attach sends the events to the TUI run by the program from now on.
+func (a *alerts) attach(p *tea.Program) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.program = p
+}
+
+// check wraps getStats so that every sample of the host is checked against
+// the rules, even if some collectors failed.
+func (a *alerts) check(host string, getStats func(context.Context) (types.Stats, error)) func(context.Context) (types.Stats, error) {
+	return func(ctx context.Context) (types.Stats, error) {
+		stats, err := getStats(ctx)
+		if stats.Hostname == "" {
+			return stats, err
+		}
+		for _, e := range a.engine.Check(host, stats) {
+			a.notify(e)
+		}
+		return stats, err
+	}
+}
+
+// notify keeps the event in the alert history and, unless it is silenced,
+// logs it and shows it in the TUI.
+func (a *alerts) notify(e alert.Event) {
+	if err := a.store.Add(alert.NewRecord(e, time.Now())); err != nil {
+		a.log.Printf("keep alert history: %s", err)
+	}
+	if e.Silenced {
+		return
+	}
+	a.log.Print(e)
+	if e.Resolved {
+		return
+	}
+	a.mu.Lock()
+	p := a.program
+	a.mu.Unlock()
+	if p == nil {
+		return
+	}
+	p.Send(tui.AlertMsg{Alert: tui.Alert{
+		Host:    e.Host,
+		Metric:  tuiMetric(e.Metric),
+		Message: fmt.Sprintf("%s = %s (%s)", e.Metric, e.FormatValue(), e.Rule),
+		Time:    time.Now(),
+	}})
+}
+
+// tuiMetric returns the metric of the TUI whose sparkline an alert on the
+// given metric is marked on.
+func tuiMetric(metric string) string {
+	switch {
+	case strings.HasPrefix(metric, "cpu."):
+		return "cpu"
+	case strings.HasPrefix(metric, "mem."):
+		return "mem"
+	case strings.HasPrefix(metric, "net."):
+		return metric[:strings.LastIndex(metric, ".")]
+	}
+	return metric
+}
+
+// onceGap is the time between the warm-up sample of --once and the one
+// shown, so that cpu usage and rates cover a short period rather than the
+// time since boot.
+const onceGap = time.Second
+
+// runOnce prints the stats of every host once, with status 2 if an alert
+// fired and --fail-on-alert is given.
+func runOnce(hosts []tui.Host, a *alerts) error {
+	failed := false
+	for _, h := range hosts {
+		stats, err := h.GetStats(context.Background())
+		tui.RenderPlain(os.Stdout, h.Name, stats, err)
+		failed = failed || err != nil
+	}
+	if failed {
+		return exitCodeError{code: 1, msg: "some hosts could not be collected"}
+	}
+	if a != nil && flagFailOnAlert && a.engine.Fired() > 0 {
+		return exitCodeError{code: 2, msg: fmt.Sprintf("%d alerts fired", a.engine.Fired())}
+	}
+	return nil
+}
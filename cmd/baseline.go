@@ -0,0 +1,221 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagBaselineDir string
+
+	baselineCmd = &cobra.Command{
+		Use:   "baseline",
+		Short: "Save reference snapshots of hosts and compare them for drift.",
+	}
+
+	baselineSaveCmd = &cobra.Command{
+		Use:   "save [user@]host[:port]",
+		Short: "Capture the mounts, interfaces, kernel and package count of a host.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBaselineSave(args[0])
+		},
+	}
+
+	baselineDiffCmd = &cobra.Command{
+		Use:   "diff [user@]host[:port]",
+		Short: "Compare a host against its saved baseline, exiting non-zero on drift.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBaselineDiff(args[0])
+		},
+	}
+)
+
+func init() {
+	baselineCmd.PersistentFlags().StringVar(&flagBaselineDir, "dir", "", "directory holding the baselines (default: $XDG_DATA_HOME/rtop/baselines)")
+	baselineCmd.AddCommand(baselineSaveCmd, baselineDiffCmd)
+	cmd.AddCommand(baselineCmd)
+}
+
+func runBaselineSave(target string) error {
+	client, err := newClient(target)
+	if err != nil {
+		return err
+	}
+	baseline, err := client.GetBaseline(context.Background())
+	if err != nil {
+		return err
+	}
+
+	path, err := baselinePath(target)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("saved baseline of %s to %s\n", target, path)
+	return nil
+}
+
+func runBaselineDiff(target string) error {
+	path, err := baselinePath(target)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read baseline: %s", err)
+	}
+	var saved types.Baseline
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("parse baseline %s: %s", path, err)
+	}
+
+	client, err := newClient(target)
+	if err != nil {
+		return err
+	}
+	current, err := client.GetBaseline(context.Background())
+	if err != nil {
+		return err
+	}
+
+	drift := diffBaselines(saved, current)
+	for _, line := range drift {
+		fmt.Println(line)
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("%s drifted from its baseline of %s", target, saved.Time.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Printf("%s matches its baseline of %s\n", target, saved.Time.Format("2006-01-02 15:04"))
+	return nil
+}
+
+// diffBaselines describes the differences between the saved and the current
+// baseline, one per line: + for additions, - for removals and ~ for changes.
+func diffBaselines(saved, current types.Baseline) []string {
+	var res []string
+
+	if saved.KernelRelease != current.KernelRelease {
+		res = append(res, fmt.Sprintf("~ kernel: %s -> %s", saved.KernelRelease, current.KernelRelease))
+	}
+	if saved.Packages != current.Packages {
+		res = append(res, fmt.Sprintf("~ packages: %d -> %d", saved.Packages, current.Packages))
+	}
+
+	for _, m := range sortedKeys(saved.Mounts, current.Mounts) {
+		was, inSaved := saved.Mounts[m]
+		is, inCurrent := current.Mounts[m]
+		switch {
+		case !inCurrent:
+			res = append(res, fmt.Sprintf("- mount %s (%s)", m, was))
+		case !inSaved:
+			res = append(res, fmt.Sprintf("+ mount %s (%s)", m, is))
+		case was != is:
+			res = append(res, fmt.Sprintf("~ mount %s: %s -> %s", m, was, is))
+		}
+	}
+
+	for _, name := range sortedKeys(saved.Interfaces, current.Interfaces) {
+		was, inSaved := saved.Interfaces[name]
+		is, inCurrent := current.Interfaces[name]
+		switch {
+		case !inCurrent:
+			res = append(res, fmt.Sprintf("- interface %s (%s %s)", name, was.IPv4, was.IPv6))
+		case !inSaved:
+			res = append(res, fmt.Sprintf("+ interface %s (%s %s)", name, is.IPv4, is.IPv6))
+		case was != is:
+			res = append(res, fmt.Sprintf("~ interface %s: %s %s -> %s %s", name, was.IPv4, was.IPv6, is.IPv4, is.IPv6))
+		}
+	}
+
+	return res
+}
+
+// sortedKeys returns the union of the keys of the given maps in order.
+func sortedKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// baselinePath returns the file the baseline of the given target is kept
+// in.
+func baselinePath(target string) (string, error) {
+	dir := flagBaselineDir
+	if dir == "" {
+		var err error
+		if dir, err = dataDir("baselines"); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, snapshotFileName(target)), nil
+}
+
+// dataDir returns the directory below $XDG_DATA_HOME/rtop to keep the given
+// kind of data in.
+func dataDir(name string) (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "rtop", name), nil
+}
@@ -0,0 +1,99 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rapidloop/rtop/pkg/bot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagBotListen  string
+	flagDiscordKey string
+
+	botCmd = &cobra.Command{
+		Use:   "bot [--listen addr] [user@]host[:port]...",
+		Short: "Answer status <host> in Slack or Discord with a summary of the host.",
+		Long: `Answer status <host> in Slack or Discord with a summary of the host.
+
+Only the hosts given can be asked about. Slack events are received on
+/slack/events, which needs the signing secret and bot token of the app in
+SLACK_SIGNING_SECRET and SLACK_BOT_TOKEN. Discord interactions are received
+on /discord/interactions, which needs the public key of the application in
+--discord-public-key.
+`,
+		Args: targetArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := targetsOf(args)
+			if err != nil {
+				return err
+			}
+			return runBot(targets)
+		},
+	}
+)
+
+func init() {
+	botCmd.Flags().StringVar(&flagBotListen, "listen", "localhost:8081", "address to receive the requests of Slack and Discord on")
+	botCmd.Flags().StringVar(&flagDiscordKey, "discord-public-key", "", "hex encoded public key of the Discord application")
+	cmd.AddCommand(botCmd)
+}
+
+func runBot(targets []string) error {
+	hosts := make([]bot.Host, 0, len(targets))
+	for _, addr := range targets {
+		client, err := newClient(addr)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, bot.Host{Name: addr, GetStats: client.GetStats})
+	}
+	b := bot.New(hosts)
+
+	mux := http.NewServeMux()
+	secret, token := os.Getenv("SLACK_SIGNING_SECRET"), os.Getenv("SLACK_BOT_TOKEN")
+	if secret != "" || token != "" {
+		if secret == "" || token == "" {
+			return fmt.Errorf("slack needs both SLACK_SIGNING_SECRET and SLACK_BOT_TOKEN")
+		}
+		mux.Handle("/slack/events", bot.NewSlack(b, secret, token))
+	}
+	if flagDiscordKey != "" {
+		d, err := bot.NewDiscord(b, flagDiscordKey)
+		if err != nil {
+			return err
+		}
+		mux.Handle("/discord/interactions", d)
+	}
+	if secret == "" && flagDiscordKey == "" {
+		return fmt.Errorf("set SLACK_SIGNING_SECRET and SLACK_BOT_TOKEN, or --discord-public-key")
+	}
+	return http.ListenAndServe(flagBotListen, mux)
+}
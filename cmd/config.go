@@ -0,0 +1,455 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/rapidloop/rtop/pkg/alert"
+	"github.com/rapidloop/rtop/pkg/client"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the configuration file. Its hosts are monitored when no
+// targets are given on the command line, its groups are the named sets of
+// hosts of rtop group, its themes can be picked with --theme besides the
+// built-in ones, its maintenance windows silence alerts, and every other
+// key is the name of a long flag, e.g.
+//
+//	hosts:
+//	  - web1
+//	  - target: admin@db1:2222
+//	    private-key-file: ~/.ssh/db_ed25519
+//	    labels: {role: db}
+//	groups:
+//	  prod-db: [admin@db1:2222, admin@db2:2222]
+//	themes:
+//	  mine: {base: light, heading: "#AF00AF", value: "21"}
+//	theme: mine
+//	maintenance:
+//	  - {match: "db*", days: [sun], start: "02:00", duration: 2h}
+//	interval: 10s
+//	collect: [redis]
+//	alert: ["mem.used_percent > 90"]
+//	layout: wide
+//
+// Flags given on the command line override the values of the file.
+type configFile struct {
+	path   string
+	Hosts  []configHost
+	Groups map[string][]configHost
+	Themes map[string]tui.Theme
+	// Maintenance are the windows during which alerts are silenced
+	Maintenance []alert.Window
+	flags       []configFlag
+}
+
+// configHost is a host of the configuration file, either just the target
+// or a mapping with the key and labels to use for it.
+type configHost struct {
+	Target         string            `yaml:"target"`
+	PrivateKeyFile string            `yaml:"private-key-file"`
+	Labels         map[string]string `yaml:"labels"`
+}
+
+func (h *configHost) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		return n.Decode(&h.Target)
+	}
+	type plain configHost
+	if err := n.Decode((*plain)(h)); err != nil {
+		return err
+	}
+	if h.Target == "" {
+		return fmt.Errorf("line %d: host without target", n.Line)
+	}
+	return nil
+}
+
+// configFlag is a flag value of the configuration file, with several values
+// for flags which can be repeated.
+type configFlag struct {
+	name   string
+	values []string
+	line   int
+}
+
+var (
+	flagConfig string
+
+	config     *configFile
+	configErr  error
+	configRead bool
+
+	// hostKeys are the private key files of the hosts of the configuration
+	// file, unless -i is given on the command line
+	hostKeys map[string]string
+)
+
+func init() {
+	cmd.PersistentFlags().StringVar(&flagConfig, "config", "", "configuration file (default: $XDG_CONFIG_HOME/rtop/config.yaml or ~/.config/rtop/config.yaml if present)")
+}
+
+// defaultConfigPath returns the path of the configuration file used without
+// --config.
+func defaultConfigPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "rtop", "config.yaml"), nil
+}
+
+// readConfig reads the configuration file once, returning nil if there is
+// none.
+func readConfig() (*configFile, error) {
+	if configRead {
+		return config, configErr
+	}
+	configRead = true
+
+	path, explicit := flagConfig, true
+	if path == "" {
+		if path, configErr = defaultConfigPath(); configErr != nil {
+			return nil, configErr
+		}
+		explicit = false
+	} else if path, configErr = homedir.Expand(path); configErr != nil {
+		return nil, configErr
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return nil, nil
+	} else if err != nil {
+		configErr = err
+		return nil, err
+	}
+	config, configErr = parseConfig(path, data)
+	return config, configErr
+}
+
+func parseConfig(path string, data []byte) (*configFile, error) {
+	c := &configFile{path: path}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("config %s: %s", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return c, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config %s: line %d: expected a mapping of flag names to values", path, root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "hosts":
+			if err := value.Decode(&c.Hosts); err != nil {
+				return nil, fmt.Errorf("config %s: hosts: %s", path, err)
+			}
+			continue
+		case "groups":
+			if err := value.Decode(&c.Groups); err != nil {
+				return nil, fmt.Errorf("config %s: groups: %s", path, err)
+			}
+			continue
+		case "themes":
+			if err := value.Decode(&c.Themes); err != nil {
+				return nil, fmt.Errorf("config %s: themes: %s", path, err)
+			}
+			continue
+		case "maintenance":
+			if err := value.Decode(&c.Maintenance); err != nil {
+				return nil, fmt.Errorf("config %s: maintenance: %s", path, err)
+			}
+			for _, w := range c.Maintenance {
+				if err := w.Validate(); err != nil {
+					return nil, fmt.Errorf("config %s: %s", path, err)
+				}
+			}
+			continue
+		}
+
+		f := configFlag{name: key.Value, line: key.Line}
+		switch value.Kind {
+		case yaml.ScalarNode:
+			f.values = []string{value.Value}
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("config %s: line %d: %s: expected a list of values", path, item.Line, key.Value)
+				}
+				f.values = append(f.values, item.Value)
+			}
+		default:
+			return nil, fmt.Errorf("config %s: line %d: %s: expected a value or a list of values", path, value.Line, key.Value)
+		}
+		c.flags = append(c.flags, f)
+	}
+	return c, nil
+}
+
+// apply sets the flags of the command c which are not given on the command
+// line to the values of the configuration file. Keys which are flags of
+// other commands only are skipped, unknown ones are an error.
+func (cf *configFile) apply(c *cobra.Command) error {
+	changed := make(map[string]bool)
+	c.Flags().Visit(func(f *pflag.Flag) {
+		changed[f.Name] = true
+	})
+
+	for _, f := range cf.flags {
+		if !isFlag(c.Root(), f.name) {
+			return fmt.Errorf("config %s: line %d: unknown key %q", cf.path, f.line, f.name)
+		}
+		flag := c.Flags().Lookup(f.name)
+		if flag == nil || changed[f.name] {
+			continue
+		}
+		if len(f.values) > 1 && !strings.HasSuffix(flag.Value.Type(), "Slice") && !strings.HasSuffix(flag.Value.Type(), "Array") {
+			return fmt.Errorf("config %s: line %d: %s takes a single value", cf.path, f.line, f.name)
+		}
+		for _, v := range f.values {
+			if err := c.Flags().Set(f.name, v); err != nil {
+				return fmt.Errorf("config %s: line %d: %s", cf.path, f.line, err)
+			}
+		}
+	}
+
+	// labels of the hosts go first, so that --label flags can override them
+	hosts := append([]configHost(nil), cf.Hosts...)
+	for _, name := range cf.groupNames() {
+		hosts = append(hosts, cf.Groups[name]...)
+	}
+	var labels []string
+	for _, h := range hosts {
+		for k, v := range h.Labels {
+			labels = append(labels, h.Target+":"+k+"="+v)
+		}
+		if h.PrivateKeyFile != "" && !changed["private-key-file"] {
+			key, err := homedir.Expand(h.PrivateKeyFile)
+			if err != nil {
+				return fmt.Errorf("config %s: host %s: %s", cf.path, h.Target, err)
+			}
+			if hostKeys == nil {
+				hostKeys = make(map[string]string)
+			}
+			hostKeys[h.Target] = key
+		}
+	}
+	if len(labels) > 0 {
+		flagLabels = append(labels, flagLabels...)
+	}
+	return nil
+}
+
+const themeUsage = "color theme: dark, light, solarized, deuteranopia, protanopia or one of the themes of the config file"
+
+const glyphsUsage = "mark warning and critical values with " + tui.WarningGlyph + " and " + tui.CriticalGlyph + " besides their color"
+
+// parseTheme returns the styles of the theme given with --theme, which may
+// be one of the config file, with the glyphs of --glyphs.
+func parseTheme() (tui.Styles, error) {
+	var custom map[string]tui.Theme
+	if config != nil {
+		custom = config.Themes
+	}
+	styles, err := tui.ParseTheme(flagTheme, custom)
+	if err == nil && flagGlyphs {
+		styles.WarningGlyph, styles.CriticalGlyph = tui.WarningGlyph, tui.CriticalGlyph
+	}
+	return styles, err
+}
+
+// groupNames returns the names of the groups in order.
+func (cf *configFile) groupNames() []string {
+	names := make([]string, 0, len(cf.Groups))
+	for name := range cf.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isFlag reports whether name is a long flag of c or any of its
+// subcommands.
+func isFlag(c *cobra.Command, name string) bool {
+	if c.Flags().Lookup(name) != nil || c.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range c.Commands() {
+		if isFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetArgs accepts the targets of a command, which may be left out if the
+// configuration file lists hosts.
+func targetArgs(c *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return nil
+	}
+	if cf, err := readConfig(); err != nil {
+		return err
+	} else if cf == nil || len(cf.Hosts) == 0 {
+		return fmt.Errorf("no hosts given, pass them as arguments or list them under hosts in the config file")
+	}
+	return nil
+}
+
+// targetsOf returns the targets of configTargets, expanded with
+// expandTargets.
+func targetsOf(args []string) ([]string, error) {
+	return expandTargets(configTargets(args))
+}
+
+// configTargets returns the targets given on the command line or, without
+// any, the hosts of the configuration file.
+func configTargets(args []string) []string {
+	if len(args) == 0 && config != nil {
+		for _, h := range config.Hosts {
+			args = append(args, h.Target)
+		}
+	}
+	return args
+}
+
+// configPath returns the path of the configuration file, whether it exists
+// or not.
+func configPath() (string, error) {
+	if flagConfig != "" {
+		return homedir.Expand(flagConfig)
+	}
+	return defaultConfigPath()
+}
+
+// saveCollectors writes the collectors turned on and off in the TUI to the
+// configuration file, as the collect, skip, processes, routes and
+// cloud-metadata keys. The rest of the file is kept.
+func saveCollectors(states []types.CollectorState) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("config %s: %s", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config %s: line %d: expected a mapping of flag names to values", path, root.Line)
+	}
+
+	extra := client.ExtraMetrics()
+	var collect, skip []string
+	processes := strconv.Itoa(flagProcs)
+	routes, cloud := "false", "false"
+	for _, s := range states {
+		switch {
+		case s.Name == "processes":
+			if !s.Enabled {
+				processes = "0"
+			} else if flagProcs == 0 {
+				processes = strconv.Itoa(client.DefaultProcesses)
+			}
+		case s.Name == "routes":
+			routes = strconv.FormatBool(s.Enabled)
+		case s.Name == "cloud":
+			cloud = strconv.FormatBool(s.Enabled)
+		case extra[s.Name] != nil || s.Name == "gpu" || s.Name == "zfs":
+			if s.Enabled {
+				collect = append(collect, s.Name)
+			}
+		case !s.Enabled:
+			skip = append(skip, s.Name)
+		}
+	}
+	setConfigKey(root, "collect", yamlList(collect))
+	setConfigKey(root, "skip", yamlList(skip))
+	setConfigKey(root, "processes", yamlScalar(processes))
+	setConfigKey(root, "routes", yamlScalar(routes))
+	setConfigKey(root, "cloud-metadata", yamlScalar(cloud))
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// setConfigKey sets the key of the mapping to the value.
+func setConfigKey(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+func yamlScalar(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: v}
+}
+
+func yamlList(values []string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, v := range values {
+		n.Content = append(n.Content, yamlScalar(v))
+	}
+	return n
+}
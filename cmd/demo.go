@@ -0,0 +1,96 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/demo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagSeed        int64
+	flagSpikes      []string
+	flagSpikeChance float64
+	flagSpikeLength int
+
+	demoCmd = &cobra.Command{
+		Use:   "demo [name]...",
+		Short: "Monitor made-up hosts, to explore the TUI, try alert rules or develop sinks.",
+		Long: `Monitor made-up hosts, to explore the TUI, try alert rules or develop sinks.
+
+The stats of the hosts, demo unless named, wander randomly around typical
+levels, with spikes of the cpu, mem, load, net, disk, fs or temp kind
+starting at random with --spike-chance or at given times with --spike. They
+are shown as with rtop, so --alert, --sink, --once and the other flags of
+rtop work as usual. The same --seed gives the same stats every time.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"demo"}
+			}
+			return run(args, connectDemo, nil)
+		},
+	}
+)
+
+func init() {
+	demoCmd.Flags().Int64Var(&flagSeed, "seed", 1, "seed of the made-up stats, each host using the next one")
+	demoCmd.Flags().StringArrayVar(&flagSpikes, "spike", nil, "spike as kind@offset, e.g. cpu@30s; kinds are "+strings.Join(demo.SpikeKinds, ", ")+"; repeatable")
+	demoCmd.Flags().Float64Var(&flagSpikeChance, "spike-chance", 0.01, "probability of each kind of spike starting at any refresh")
+	demoCmd.Flags().IntVar(&flagSpikeLength, "spike-length", 5, "refreshes a spike lasts")
+	cmd.AddCommand(demoCmd)
+}
+
+// connectDemo returns made-up hosts of the given names.
+func connectDemo(names []string) ([]source, error) {
+	opts := []demo.Option{
+		demo.WithInterval(flagInterval),
+		demo.WithSpikeChance(flagSpikeChance),
+		demo.WithSpikeLength(flagSpikeLength),
+	}
+	for _, s := range flagSpikes {
+		kind, offset, ok := strings.Cut(s, "@")
+		d, err := time.ParseDuration(offset)
+		if !ok || err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid spike %q, expected kind@offset, e.g. cpu@30s", s)
+		}
+		opts = append(opts, demo.WithSpikeAt(kind, int(d/flagInterval)))
+	}
+
+	sources := make([]source, len(names))
+	for i, name := range names {
+		h, err := demo.New(name, append(opts, demo.WithSeed(flagSeed+int64(i)))...)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = h
+	}
+	return sources, nil
+}
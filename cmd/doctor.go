@@ -0,0 +1,83 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [user@]host[:port]",
+	Short: "Check which collectors can work with the permissions of the remote user.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDoctor(args[0])
+	},
+}
+
+func init() {
+	cmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(addr string) error {
+	client, err := newClient(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := client.CheckShell(context.Background()); err != nil {
+		return err
+	}
+	fmt.Printf("%-10s ok\n", "shell")
+
+	compat, err := client.GetCompat(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s %s\n\n", "commands", compat)
+
+	reqs, err := client.Preflight(context.Background())
+	if err != nil {
+		return err
+	}
+
+	missing := 0
+	for _, r := range reqs {
+		status := "ok"
+		if !r.OK {
+			status = "disabled"
+			missing++
+		}
+		fmt.Printf("%-10s %-9s %s\n", r.Collector, status, r)
+	}
+	if missing > 0 {
+		fmt.Printf("\n%d collectors will be skipped\n", missing)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/rapidloop/rtop/pkg/client"
+)
+
+var (
+	flagNoCache  bool
+	flagCacheTTL time.Duration
+)
+
+func init() {
+	cmd.PersistentFlags().BoolVar(&flagNoCache, "no-cache", false, "detect the OS, command variants and missing commands of the hosts again instead of using the cached results")
+	cmd.PersistentFlags().DurationVar(&flagCacheTTL, "cache-ttl", 24*time.Hour, "how long the detection results of a host are cached in $XDG_CACHE_HOME/rtop")
+}
+
+// cacheDir returns $XDG_CACHE_HOME/rtop, or ~/.cache/rtop.
+func cacheDir() (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "rtop"), nil
+}
+
+// fingerprintOptions returns the options which reuse the cached detection
+// results of the target, unless expired or --no-cache is given, and cache
+// them once detected. Caching is best effort: a cache which can't be read
+// or written just means detecting again.
+func fingerprintOptions(target string) []client.Option {
+	dir, err := cacheDir()
+	if err != nil || flagCacheTTL <= 0 {
+		return nil
+	}
+	path := filepath.Join(dir, snapshotFileName(target))
+
+	var opts []client.Option
+	if !flagNoCache {
+		if data, err := os.ReadFile(path); err == nil {
+			var fp client.Fingerprint
+			if json.Unmarshal(data, &fp) == nil && fp.OS != "" && time.Since(fp.Detected) < flagCacheTTL {
+				opts = append(opts, client.WithFingerprint(fp))
+			}
+		}
+	}
+	return append(opts, client.WithFingerprintFunc(func(fp client.Fingerprint) {
+		data, err := json.MarshalIndent(fp, "", "  ")
+		if err != nil {
+			return
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return
+		}
+		os.WriteFile(path, append(data, '\n'), 0600)
+	}))
+}
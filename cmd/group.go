@@ -0,0 +1,88 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var groupCmd = &cobra.Command{
+	Use:   "group name...",
+	Short: "Monitor the hosts of groups defined in the config file.",
+	Long: `Monitor the hosts of groups defined in the config file.
+
+The hosts of all given groups are connected to in parallel and shown as with
+rtop: in tabs, side by side with --split or ranked in a table with --ui
+table. Each host is labelled group=<name>, so that --ui table --group-by
+group aggregates the hosts of each group.
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		members, err := groupTargets(args)
+		if err != nil {
+			return err
+		}
+		targets, err := expandTargets(members)
+		if err != nil {
+			return err
+		}
+		return run(targets, connectClients, resolverOf(members))
+	},
+}
+
+func init() {
+	groupCmd.Flags().IntVarP(&flagConcurrency, "concurrency", "c", 8, "number of hosts to connect to concurrently")
+	cmd.AddCommand(groupCmd)
+}
+
+// groupTargets returns the hosts of the named groups of the config file,
+// each once, and labels them with their group.
+func groupTargets(names []string) ([]string, error) {
+	if config == nil || len(config.Groups) == 0 {
+		return nil, fmt.Errorf("no groups defined, list them under groups in the config file")
+	}
+
+	var targets, labels []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		members, ok := config.Groups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %q, expected one of %s", name, strings.Join(config.groupNames(), ", "))
+		}
+		for _, h := range members {
+			if !seen[h.Target] {
+				seen[h.Target] = true
+				targets = append(targets, h.Target)
+			}
+			labels = append(labels, h.Target+":group="+name)
+		}
+	}
+	flagLabels = append(labels, flagLabels...)
+	return targets, nil
+}
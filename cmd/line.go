@@ -0,0 +1,156 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagWatch bool
+
+	lineCmd = &cobra.Command{
+		Use:   "line [user@]host[:port]",
+		Short: "Print a compact single line summary, e.g. for tmux status bars.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLine(args[0])
+		},
+	}
+)
+
+func init() {
+	lineCmd.Flags().BoolVarP(&flagWatch, "watch", "w", false, "keep refreshing the line in place every interval")
+	cmd.AddCommand(lineCmd)
+}
+
+func runLine(addr string) error {
+	client, err := newClient(addr)
+	if err != nil {
+		return err
+	}
+
+	// a line needs some stats, not all collectors
+	stats, err := client.GetStats(context.Background())
+	if stats.Hostname == "" {
+		return err
+	}
+
+	if !flagWatch {
+		fmt.Println(fmtLine(stats))
+		return nil
+	}
+
+	fmt.Printf("\r%s\033[K", fmtLine(stats))
+	for range time.Tick(flagInterval) {
+		stats, err := client.GetStats(context.Background())
+		if stats.Hostname == "" {
+			fmt.Println()
+			return err
+		}
+		fmt.Printf("\r%s\033[K", fmtLine(stats))
+	}
+
+	return nil
+}
+
+// fmtLine formats the given stats as a single line in the form of:
+// web-1 up 34d load 0.42 cpu 12% mem 63% disk 71%
+func fmtLine(stats types.Stats) string {
+	host := stats.Hostname
+	if i := strings.Index(host, "."); i > 0 {
+		host = host[:i]
+	}
+
+	// the values of collectors which produced nothing are shown as -
+	line := host
+	if stats.Collected("uptime") {
+		line += " up " + fmtShortUptime(stats.Uptime)
+	} else {
+		line += " up -"
+	}
+	if stats.Collected("load") {
+		line += " load " + stats.Loads.Load1
+	} else {
+		line += " load -"
+	}
+	if stats.Collected("cpu") {
+		line += fmt.Sprintf(" cpu %.0f%%", 100-stats.CPU.Idle)
+	} else {
+		line += " cpu -"
+	}
+	if stats.Collected("mem") {
+		line += fmt.Sprintf(" mem %.0f%%", percent(stats.MEM.Used(), stats.MEM.Total))
+	} else {
+		line += " mem -"
+	}
+
+	if fs, ok := rootFS(stats.FSInfos); ok {
+		line += fmt.Sprintf(" disk %.0f%%", percent(fs.Used, fs.Total))
+	}
+
+	return line
+}
+
+// fmtShortUptime formats the uptime using its largest unit only.
+func fmtShortUptime(uptime time.Duration) string {
+	switch {
+	case uptime >= 24*time.Hour:
+		return fmt.Sprintf("%dd", uptime/(24*time.Hour))
+	case uptime >= time.Hour:
+		return fmt.Sprintf("%dh", uptime/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", uptime/time.Minute)
+	}
+}
+
+// rootFS returns the filesystem mounted at /, or the fullest one if there is
+// no such filesystem.
+func rootFS(fsInfos []types.FSInfo) (types.FSInfo, bool) {
+	var fullest types.FSInfo
+	for _, fs := range fsInfos {
+		if fs.MountPoint == "/" {
+			return fs, true
+		}
+		if percent(fs.Used, fs.Total) > percent(fullest.Used, fullest.Total) {
+			fullest = fs
+		}
+	}
+	return fullest, len(fsInfos) > 0
+}
+
+func percent(val, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(val) / float64(total) * 100
+}
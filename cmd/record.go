@@ -0,0 +1,158 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/replay"
+	"github.com/rapidloop/rtop/pkg/sink"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagRecordOut string
+	flagDelta     bool
+	flagTolerance float64
+	flagSpeed     float64
+
+	recordCmd = &cobra.Command{
+		Use:   "record [user@]host[:port]... -o file",
+		Short: "Record every sample of the hosts to a file for rtop replay.",
+		Args:  targetArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := targetsOf(args)
+			if err != nil {
+				return err
+			}
+			return runRecord(targets)
+		},
+	}
+
+	replayCmd = &cobra.Command{
+		Use:   "replay file...",
+		Short: "Play recorded sessions back in the TUI, side by side and in sync if there are several.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReplay(args)
+		},
+	}
+)
+
+func init() {
+	recordCmd.Flags().StringVarP(&flagRecordOut, "output", "o", "", "file to append the samples to, e.g. session.rtop")
+	recordCmd.MarkFlagRequired("output")
+	recordCmd.Flags().BoolVar(&flagDelta, "delta", false, "only record the fields which changed since the previous sample of a host, to keep long recordings small")
+	recordCmd.Flags().Float64Var(&flagTolerance, "tolerance", 0, "with --delta, leave out numbers which changed by up to this fraction, e.g. 0.01 for 1%")
+	replayCmd.Flags().Float64Var(&flagSpeed, "speed", 1, "playback speed, e.g. 10 for ten times faster")
+	replayCmd.Flags().StringVar(&flagTheme, "theme", "dark", themeUsage)
+	replayCmd.Flags().BoolVar(&flagGlyphs, "glyphs", false, glyphsUsage)
+	cmd.AddCommand(recordCmd, replayCmd)
+}
+
+func runRecord(targets []string) error {
+	if flagTolerance < 0 || flagTolerance > 0 && !flagDelta {
+		return fmt.Errorf("--tolerance must not be negative and needs --delta")
+	}
+	var out interface {
+		sink.Sink
+		Close() error
+	}
+	var err error
+	if flagDelta {
+		out, err = sink.NewDelta(flagRecordOut, flagTolerance)
+	} else {
+		out, err = sink.NewJSON(flagRecordOut)
+	}
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hosts := make([]tui.Host, 0, len(targets))
+	for _, addr := range targets {
+		client, err := newClient(addr)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, tui.Host{Name: addr, GetStats: client.GetStats})
+	}
+
+	fmt.Fprintf(os.Stderr, "recording %d hosts to %s, press ctrl+c to stop\n", len(hosts), flagRecordOut)
+	for {
+		for _, h := range hosts {
+			stats, err := h.GetStats(context.Background())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", h.Name, err)
+			}
+			if stats.Hostname == "" {
+				continue
+			}
+			warnIntervalFloor(h.Name, stats)
+			s := sink.HostStats{Host: h.Name, Time: time.Now(), Stats: stats}
+			if err := out.Write(context.Background(), s); err != nil {
+				return err
+			}
+		}
+		time.Sleep(flagInterval)
+	}
+}
+
+func runReplay(paths []string) error {
+	if flagSpeed <= 0 {
+		return fmt.Errorf("speed must be positive")
+	}
+	session, err := replay.Load(paths...)
+	if err != nil {
+		return err
+	}
+
+	styles, err := parseTheme()
+	if err != nil {
+		return err
+	}
+
+	player := replay.NewPlayer(session, flagSpeed)
+	hosts := make([]tui.Host, 0, len(session.Hosts))
+	for _, name := range session.Hosts {
+		hosts = append(hosts, tui.Host{Name: name, GetStats: player.GetStats(name)})
+	}
+
+	interval := session.Interval()
+	if interval == 0 {
+		interval = flagInterval
+	}
+	return tui.NewRenderingState(hosts, time.Duration(float64(interval)/flagSpeed),
+		tui.WithPlayer(player, interval),
+		tui.WithSplitView(len(paths) > 1),
+		tui.WithStyles(styles),
+		tui.WithVersion(buildVersion()),
+	).Start()
+}
@@ -26,17 +26,29 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"github.com/rapidloop/rtop/internal/tui"
-	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/rapidloop/rtop/pkg/tableui"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"io"
+	"net"
 	"os"
+	"os/signal"
 	"os/user"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/fatih/semgroup"
 	"github.com/rapidloop/rtop/internal/ssh"
+	"github.com/rapidloop/rtop/pkg/budget"
 	"github.com/rapidloop/rtop/pkg/client"
+	"github.com/rapidloop/rtop/pkg/sink"
+	"github.com/rapidloop/rtop/pkg/types"
 	"github.com/spf13/cobra"
 )
 
@@ -45,17 +57,93 @@ var (
 
 	flagKeyPath  string
 	flagInterval time.Duration
+	flagCollect  []string
+	flagSkip     []string
+	flagListen   []string
+	flagRoutes   bool
+	flagCloud    bool
+	flagControl  string
+	flagPlain    bool
+	flagSplit    bool
+	flagUI       string
+	flagLayout   string
+	flagLocale   string
+	flagTheme    string
+	flagGlyphs   bool
+	flagUptime   string
+	flagProcs    int
+	flagFSDevice bool
+	flagFSSort   string
+	flagTempWarn float64
+	flagTempCrit float64
+	flagHistory  int
+	flagBudgets  []string
+	flagFSProbe  []string
+	flagFSFilter client.FSFilter
+	flagBatch    bool
+	flagCompat   []string
+	flagWrapper  string
+	flagDeadline time.Duration
+	flagBudget   time.Duration
+	flagBreaker  int
+	flagBackoff  time.Duration
+	flagNice     bool
+	flagLabels   []string
+	flagGroupBy  string
+	flagSinks    []string
+	flagSinkBuf  int
+	flagSinkDrop string
+	flagSinkAggK int
+	flagSinkAgg  string
+	flagLogFile  string
+	flagHeadless bool
+	flagAlerts   []string
+	flagAlertLog string
+	flagOnce     bool
+	flagSRVEvery time.Duration
+
+	flagFailOnAlert  bool
+	flagMetricPrefix []string
+
+	flagInsecure   bool
+	flagKnownHosts string
 
 	cmd = &cobra.Command{
-		Use:   "xdsl-exporter",
-		Short: "rtop monitors server statistics over an ssh connection.",
-		Long: `rtop monitors server statistics over an ssh connection." +
-Usage: rtop [-i private-key-file] [-t interval] [user@]host[:port]
+		Use:     "rtop [user@]host[:port]...",
+		Short:   "rtop monitors server statistics over an ssh connection.",
+		Version: buildVersion(),
+		Long: `rtop monitors server statistics over an ssh connection.
+
+Usage: rtop [-i private-key-file] [-t interval] [user@]host[:port]...
+       rtop [-i private-key-file] [-t interval] dns+srv://[user@]name
+       rtop [-t interval] local
+
+The target local monitors this machine directly, without ssh. Without
+targets, the hosts listed in the configuration file are monitored.
 `,
+		Args:          targetArgs,
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if cf, err := readConfig(); err != nil {
+				return err
+			} else if cf != nil {
+				if err := cf.apply(cmd); err != nil {
+					return err
+				}
+			}
+			if flagNice && !cmd.Flags().Changed("interval") {
+				flagInterval = niceInterval
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(args[0])
+			args = configTargets(args)
+			targets, err := expandTargets(args)
+			if err != nil {
+				return err
+			}
+			return run(targets, connectClients, resolverOf(args))
 		},
 	}
 )
@@ -63,26 +151,452 @@ Usage: rtop [-i private-key-file] [-t interval] [user@]host[:port]
 func Execute() {
 	if err := cmd.Execute(); err != nil {
 		fmt.Println(err)
+		if e, ok := err.(exitCodeError); ok {
+			os.Exit(e.code)
+		}
 		os.Exit(1)
 	}
 }
 
 func init() {
-	cmd.PersistentFlags().StringVarP(&flagKeyPath, "private-key-file", "i", "~/.ssh/id_rsa", "PEM-encoded private key file to use (default: ~/.ssh/id_rsa if present)")
+	cmd.PersistentFlags().StringVarP(&flagKeyPath, "private-key-file", "i", "", "private key file to use (default: ~/.ssh/id_rsa, id_ecdsa and id_ed25519 if present)")
 	cmd.PersistentFlags().DurationVarP(&flagInterval, "interval", "t", 5*time.Second, "refresh interval in seconds")
+	cmd.PersistentFlags().StringSliceVar(&flagCollect, "collect", nil, "optional collectors to enable: mysql, postgres, redis, memcached, jvm, rpi, neigh, gpu, zfs")
+	cmd.PersistentFlags().StringSliceVar(&flagSkip, "skip", nil, "collectors not to run, e.g. systemd,sensors; the c key of the TUI turns them on and off")
+	cmd.PersistentFlags().StringSliceVar(&flagListen, "listen-sockets", nil, "tcp ports or unix socket paths whose accept queues to report")
+	cmd.PersistentFlags().BoolVar(&flagRoutes, "routes", false, "report the route count and whether the default gateways answer a ping")
+	cmd.PersistentFlags().StringSliceVar(&flagFSProbe, "fs-probe", nil, "mount points to time a small synced write and a read on, needs write access")
+	cmd.PersistentFlags().StringSliceVar(&flagFSFilter.Types, "fs-types", nil, "only report filesystems of these types, e.g. ext4,xfs")
+	cmd.PersistentFlags().StringSliceVar(&flagFSFilter.SkipTypes, "fs-skip-types", nil, "leave out filesystems of these types, e.g. tmpfs,overlay,squashfs")
+	cmd.PersistentFlags().StringSliceVar(&flagFSFilter.Mounts, "fs-mounts", nil, "only report filesystems mounted at these globs, e.g. '/,/home/*'")
+	cmd.PersistentFlags().StringSliceVar(&flagFSFilter.SkipMounts, "fs-skip-mounts", nil, "leave out filesystems mounted at these globs, e.g. '/snap/*,/run/*'")
+	cmd.PersistentFlags().BoolVar(&flagBatch, "batch", false, "run the commands of the core collectors in a single ssh session per refresh")
+	cmd.PersistentFlags().StringArrayVar(&flagCompat, "compat", nil, "command variants as [host-pattern=]auto|gnu|busybox, e.g. 'alpine-*=busybox'; repeatable, the last match wins")
+	cmd.PersistentFlags().StringVar(&flagWrapper, "command-wrapper", "", "run every command as a quoted argument of this, e.g. 'sh -c', for restricted login shells like rbash")
+	cmd.PersistentFlags().DurationVar(&flagDeadline, "command-timeout", 30*time.Second, "kill remote commands running longer than this, on the host too if it has timeout(1); 0 to disable")
+	cmd.PersistentFlags().DurationVar(&flagBudget, "collection-budget", 0, "time a refresh of a host may take before it counts as failed, 0 to disable")
+	cmd.PersistentFlags().IntVar(&flagBreaker, "breaker-failures", 3, "back off from a host after this many failed refreshes in a row, starting at twice the interval; 0 to disable")
+	cmd.PersistentFlags().DurationVar(&flagBackoff, "breaker-max-backoff", 5*time.Minute, "longest time to back off from a failing host")
+	cmd.PersistentFlags().BoolVar(&flagNice, "nice", false, "low impact mode for overloaded hosts: run collectors one at a time at the lowest cpu and io priority, every 30s unless -t is given")
+	cmd.PersistentFlags().StringArrayVar(&flagLabels, "label", nil, "label hosts as [host-pattern:]key=value, e.g. 'db-*:role=db'; repeatable")
+	cmd.PersistentFlags().BoolVar(&flagInsecure, "insecure", false, "do not verify host keys")
+	cmd.PersistentFlags().StringVar(&flagKnownHosts, "known-hosts-file", ssh.DefaultKnownHostsFile, "known_hosts file to verify host keys against")
+	cmd.PersistentFlags().BoolVar(&flagCloud, "cloud-metadata", false, "label hosts with their cloud instance id, type, region and zone")
+	cmd.PersistentFlags().IntVar(&flagProcs, "processes", 10, "list the n processes using the most cpu and memory, 0 to disable")
+	cmd.Flags().StringVar(&flagLocale, "locale", "en", "number format and section headings: en, de, es or fr")
+	cmd.Flags().StringVar(&flagUptime, "uptime", "short", "uptime format: short, long (with years and weeks) or iso")
+	cmd.Flags().StringVar(&flagLayout, "layout", "normal", "screen layout: compact, normal or wide (toggle with l)")
+	cmd.Flags().StringVar(&flagTheme, "theme", "dark", themeUsage)
+	cmd.Flags().BoolVar(&flagGlyphs, "glyphs", false, glyphsUsage)
+	cmd.Flags().BoolVar(&flagSplit, "split", false, "show all hosts side by side instead of as tabs (toggle with s)")
+	cmd.Flags().StringVar(&flagUI, "ui", "viewport", "user interface: viewport, or table for sortable tables with selectable rows")
+	cmd.Flags().BoolVar(&flagPlain, "plain", false, "print plain labeled lines instead of the TUI, for screen readers and logs")
+	cmd.Flags().StringVar(&flagControl, "control-socket", "", "unix socket to accept commands for driving the TUI on")
+	cmd.Flags().Float64Var(&flagTempWarn, "temp-warn", 70, "temperature in degrees Celsius above which sensors are highlighted")
+	cmd.Flags().Float64Var(&flagTempCrit, "temp-crit", 85, "temperature in degrees Celsius above which sensors are shown as critical")
+	cmd.Flags().IntVar(&flagHistory, "history", tui.DefaultHistorySize, "samples shown in the cpu, memory and network sparklines, 0 to hide them")
+	cmd.Flags().StringVar(&flagGroupBy, "group-by", "", "group the hosts of the table ui by this label, e.g. role or cloud.region")
+	cmd.Flags().StringArrayVar(&flagBudgets, "budget", nil, "data budget as [interface:]day|month:size, e.g. wwan0:month:20GB, tracked in $XDG_DATA_HOME/rtop/usage")
+	cmd.Flags().StringArrayVar(&flagSinks, "sink", nil, "also write every sample to the given output, as kind:target, e.g. json:stats.jsonl, influx:http://localhost:8086/write?db=rtop or graphite:localhost:2003; repeatable")
+	cmd.Flags().StringVar(&flagLogFile, "log-file", "", "append a row of metrics per refresh to this csv file, tab separated if it ends in .tsv")
+	cmd.Flags().BoolVar(&flagHeadless, "headless", false, "show nothing, only write the stats to --log-file and --sink")
+	addAlertFlags(cmd)
+	cmd.Flags().BoolVar(&flagOnce, "once", false, "print the stats of every host once, as with --plain, and exit")
+	cmd.Flags().BoolVar(&flagFailOnAlert, "fail-on-alert", false, "with --once, exit with status 2 if an alert fired")
+	cmd.Flags().IntVar(&flagSinkBuf, "sink-buffer", sink.DefaultBufferSize, "samples buffered per sink before dropping some")
+	cmd.Flags().StringArrayVar(&flagMetricPrefix, "metric-prefix", nil, "prefix of the graphite and statsd metrics as [host-pattern=]template, default "+sink.DefaultPrefix+"; {host}, {hostname} and {label-key} are expanded; repeatable, the last match wins")
+	cmd.Flags().StringVar(&flagSinkDrop, "sink-drop", "newest", "samples to drop when a sink cannot keep up: newest or oldest")
+	cmd.Flags().IntVar(&flagSinkAggK, "sink-aggregate", 1, "write one sample per host for every this many refreshes to the sinks, combining their percentages and rates with --sink-aggregate-by")
+	cmd.Flags().StringVar(&flagSinkAgg, "sink-aggregate-by", "avg", "how --sink-aggregate combines the samples: avg or max")
+	cmd.Flags().DurationVar(&flagSRVEvery, "srv-refresh", time.Minute, "how often to resolve dns+srv:// targets again, adding and removing their hosts; 0 to resolve them once")
+	cmd.Flags().BoolVar(&flagFSDevice, "fs-by-device", false, "list filesystems by device instead of mount point")
+	cmd.Flags().StringVar(&flagFSSort, "fs-sort", "used", "order of the filesystems: used (fullest first), free (least free first) or mount (change with f)")
+
+	// rtop group and rtop demo show their hosts as rtop does
+	groupCmd.Flags().AddFlagSet(cmd.Flags())
+	demoCmd.Flags().AddFlagSet(cmd.Flags())
 }
 
-func run(addr string) error {
-	username, host, port, err := parseAddrAsUserHostAddrPort(addr)
+// source is where the stats of a host come from: a client.Client, or a
+// demo.Host for rtop demo.
+type source interface {
+	GetStats(ctx context.Context) (types.Stats, error)
+	tui.Collectors
+	tui.Banner
+}
+
+// run monitors the targets, whose sources connect returns, in the TUI or
+// as the flags say. Unless resolve is nil, the targets are resolved again
+// every --srv-refresh, adding and removing hosts.
+func run(targets []string, connect func([]string) ([]source, error), resolve func() ([]string, error)) error {
+	layout, err := tui.ParseLayout(flagLayout)
+	if err != nil {
+		return err
+	}
+	locale, err := tui.ParseLocale(flagLocale)
+	if err != nil {
+		return err
+	}
+	uptime, err := tui.ParseUptimeFormat(flagUptime)
+	if err != nil {
+		return err
+	}
+	styles, err := parseTheme()
+	if err != nil {
+		return err
+	}
+	fsSort, err := tui.ParseFSSort(flagFSSort)
 	if err != nil {
 		return err
 	}
 
-	keyPath := flagKeyPath
-	shost, sport, suser, skeyPath, err := ssh.GetSshConfig(host, flagKeyPath)
+	budgets := make([]budget.Budget, 0, len(flagBudgets))
+	for _, s := range flagBudgets {
+		b, err := budget.Parse(s)
+		if err != nil {
+			return err
+		}
+		budgets = append(budgets, b)
+	}
+
+	specs := flagSinks
+	if flagLogFile != "" {
+		specs = append(specs, logFileSink(flagLogFile))
+	}
+	if flagHeadless && len(specs) == 0 {
+		return fmt.Errorf("--headless needs --log-file or --sink")
+	}
+
+	var fan *sink.Fanout
+	if len(specs) > 0 {
+		sinks := make([]sink.Sink, 0, len(specs))
+		for _, spec := range specs {
+			s, err := newSink(spec)
+			if err != nil {
+				return err
+			}
+			sinks = append(sinks, s)
+		}
+		policy, err := sink.ParseDropPolicy(flagSinkDrop)
+		if err != nil {
+			return err
+		}
+		agg, err := sink.ParseAggregation(flagSinkAgg)
+		if err != nil {
+			return err
+		}
+		fan = sink.NewFanout(sinks,
+			sink.WithBufferSize(flagSinkBuf),
+			sink.WithDropPolicy(policy),
+			sink.WithAggregation(flagSinkAggK, agg),
+		)
+		defer fan.Close()
+	}
+
+	if flagFailOnAlert && !flagOnce {
+		return fmt.Errorf("--fail-on-alert needs --once")
+	}
+	tty := !flagOnce && !flagHeadless && !flagPlain
+	alerts, err := newAlerts(tty)
 	if err != nil {
 		return err
 	}
+
+	sources, err := connect(targets)
+	if err != nil {
+		return err
+	}
+	hostOf := func(addr string, src source) (tui.Host, error) {
+		getStats := src.GetStats
+		if len(budgets) > 0 {
+			var err error
+			if getStats, err = trackBudgets(addr, getStats, budgets); err != nil {
+				return tui.Host{}, err
+			}
+		}
+		if alerts != nil {
+			getStats = alerts.check(addr, getStats)
+		}
+		if fan != nil {
+			getStats = writeSinks(addr, getStats, fan)
+		}
+		host := tui.Host{
+			Name:       addr,
+			GetStats:   getStats,
+			Collectors: src,
+			Banner:     src,
+		}
+		if pd, ok := src.(tui.ProcessDetails); ok {
+			host.ProcessDetails = pd
+		}
+		return host, nil
+	}
+	hosts := make([]tui.Host, 0, len(targets))
+	closers := make(map[string]io.Closer, len(targets))
+	for i, addr := range targets {
+		src := sources[i]
+		closers[addr], _ = src.(io.Closer)
+		if flagOnce {
+			src.GetStats(context.Background())
+		}
+		host, err := hostOf(addr, src)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, host)
+	}
+
+	// changes carries the tui.AddHostMsg and tui.RemoveHostMsg of targets
+	// resolved again
+	var changes chan interface{}
+	if resolve != nil && flagSRVEvery > 0 && !flagOnce {
+		changes = make(chan interface{})
+		go watchTargets(resolve, closers, flagSRVEvery, func(addr string) (io.Closer, error) {
+			srcs, err := connect([]string{addr})
+			if err != nil {
+				return nil, err
+			}
+			host, err := hostOf(addr, srcs[0])
+			if err != nil {
+				return nil, err
+			}
+			changes <- tui.AddHostMsg{Host: host}
+			c, _ := srcs[0].(io.Closer)
+			return c, nil
+		}, func(addr string) {
+			changes <- tui.RemoveHostMsg{Name: addr}
+		})
+	}
+
+	if flagOnce {
+		time.Sleep(onceGap)
+		return runOnce(hosts, alerts)
+	}
+	if flagHeadless || flagPlain {
+		// stop on a signal rather than being killed, so that closing fan
+		// flushes the sinks
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if flagHeadless {
+			return runHeadless(ctx, hosts, changes, fan)
+		}
+		return runPlain(ctx, hosts, changes, fan)
+	}
+	switch flagUI {
+	case "viewport":
+	case "table":
+		opts := []tableui.Option{tableui.WithGroupBy(flagGroupBy)}
+		if changes != nil {
+			opts = append(opts, tableui.WithHostChanges(changes))
+		}
+		if fan != nil {
+			opts = append(opts, tableui.WithStatus(sinkStatus(fan)))
+		}
+		return tableui.Run(hosts, flagInterval, opts...)
+	default:
+		return fmt.Errorf("unknown ui %q, expected viewport or table", flagUI)
+	}
+
+	opts := []tui.Option{
+		tui.WithLayout(layout),
+		tui.WithLocale(locale),
+		tui.WithUptimeFormat(uptime),
+		tui.WithFSByDevice(flagFSDevice),
+		tui.WithFSSort(fsSort),
+		tui.WithSplitView(flagSplit),
+		tui.WithTempThresholds(flagTempWarn, flagTempCrit),
+		tui.WithHistorySize(flagHistory),
+		tui.WithStyles(styles),
+		tui.WithVersion(buildVersion()),
+		tui.WithCollectorsSave(saveCollectors),
+	}
+	if fan != nil {
+		opts = append(opts, tui.WithStatus(sinkStatus(fan)))
+	}
+	renderer := tui.NewRenderingState(hosts, flagInterval, opts...)
+
+	if flagControl != "" {
+		l, err := net.Listen("unix", flagControl)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		go tui.ServeControl(renderer, l)
+	}
+	if alerts != nil {
+		alerts.attach(renderer)
+	}
+	if changes != nil {
+		go func() {
+			for msg := range changes {
+				renderer.Send(msg)
+			}
+		}()
+	}
+
+	if err := renderer.Start(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// trackBudgets wraps getStats so that the stats carry the usage of the
+// given budgets, which is kept in a file per target.
+func trackBudgets(target string, getStats func(context.Context) (types.Stats, error), budgets []budget.Budget) (func(context.Context) (types.Stats, error), error) {
+	dir, err := dataDir("usage")
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := budget.NewTracker(filepath.Join(dir, snapshotFileName(target)), budgets)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) (types.Stats, error) {
+		stats, err := getStats(ctx)
+		if stats.Hostname == "" {
+			return stats, err
+		}
+		if terr := tracker.Update(&stats); terr != nil && err == nil {
+			err = fmt.Errorf("track data budgets: %s", terr)
+		}
+		return stats, err
+	}, nil
+}
+
+// runPlain prints the stats of all hosts every interval without any
+// styling, adding and removing the hosts of changes between rounds. Errors
+// of the sinks of fan, if any, are printed to stderr. Once ctx is done, fan
+// is closed and runPlain returns.
+func runPlain(ctx context.Context, hosts []tui.Host, changes <-chan interface{}, fan *sink.Fanout) error {
+	var report func()
+	if fan != nil {
+		report = logSinks(os.Stderr, fan)
+	}
+	for {
+		hosts = applyHostChanges(hosts, changes)
+		for _, h := range hosts {
+			stats, err := h.GetStats(ctx)
+			warnIntervalFloor(h.Name, stats)
+			tui.RenderPlain(os.Stdout, h.Name, stats, err)
+		}
+		if report != nil {
+			report()
+		}
+		select {
+		case <-ctx.Done():
+			return closeSinks(fan)
+		case <-time.After(flagInterval):
+		}
+	}
+}
+
+// floorWarned holds the hosts whose interval floor was warned about.
+var floorWarned sync.Map
+
+// warnIntervalFloor tells once per host that it is refreshed less often than
+// the interval, because collecting its stats takes too long.
+func warnIntervalFloor(host string, stats types.Stats) {
+	floor := stats.Meta.IntervalFloor
+	if floor <= flagInterval {
+		return
+	}
+	if _, warned := floorWarned.LoadOrStore(host, true); !warned {
+		fmt.Fprintf(os.Stderr, "%s: collecting takes %s, refreshing every %s instead of %s\n",
+			host, stats.Meta.Collection.Round(time.Millisecond), floor.Round(time.Millisecond), flagInterval)
+	}
+}
+
+// localTarget is the target monitoring this machine without ssh.
+const localTarget = "local"
+
+// niceInterval is the default interval of --nice.
+const niceInterval = 30 * time.Second
+
+// connectAll connects to the targets in parallel, up to --concurrency at a
+// time.
+func connectAll(targets []string) ([]*client.Client, error) {
+	if len(targets) == 1 {
+		c, err := newClient(targets[0])
+		return []*client.Client{c}, err
+	}
+
+	clients := make([]*client.Client, len(targets))
+	s := semgroup.NewGroup(context.Background(), int64(flagConcurrency))
+	for i, target := range targets {
+		i, target := i, target
+		s.Go(func() error {
+			c, err := newClient(target)
+			if err != nil {
+				return fmt.Errorf("%s: %s", target, err)
+			}
+			clients[i] = c
+			return nil
+		})
+	}
+	return clients, s.Wait()
+}
+
+// connectClients connects to the targets as connectAll does.
+func connectClients(targets []string) ([]source, error) {
+	clients, err := connectAll(targets)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]source, len(clients))
+	for i, c := range clients {
+		sources[i] = c
+	}
+	return sources, nil
+}
+
+// newClient connects to the given [user@]host[:port] address, filling in
+// the missing parts from the ssh config. The address local monitors this
+// machine instead.
+func newClient(addr string) (*client.Client, error) {
+	opts := []client.Option{
+		client.WithCollectors(flagCollect...),
+		client.WithoutCollectors(flagSkip...),
+		client.WithListenSockets(flagListen...),
+		client.WithCloudMetadata(flagCloud),
+		client.WithProcesses(flagProcs),
+		client.WithRoutes(flagRoutes),
+		client.WithFSProbe(flagFSProbe...),
+		client.WithFSFilter(flagFSFilter),
+		client.WithBatch(flagBatch),
+		client.WithCommandWrapper(flagWrapper),
+		client.WithCommandTimeout(flagDeadline),
+		client.WithCollectionBudget(flagBudget),
+		client.WithCircuitBreaker(flagBreaker, 2*flagInterval, flagBackoff),
+		client.WithNice(flagNice),
+	}
+	compat, err := compatFor(addr)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := labelsFor(addr)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, client.WithCompat(compat), client.WithLabels(labels))
+	if addr == localTarget {
+		return client.New(append(opts, client.WithLocalExecutor())...)
+	}
+	opts = append(opts, fingerprintOptions(addr)...)
+
+	username, host, port, err := parseAddrAsUserHostAddrPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPath := flagKeyPath
+	if k, ok := hostKeys[addr]; ok {
+		keyPath = k
+	}
+	shost, sport, suser, skeyPath, err := ssh.GetSshConfig(host, keyPath)
+	if err != nil {
+		return nil, err
+	}
 	if len(shost) > 0 {
 		host = shost
 	}
@@ -95,41 +609,76 @@ func run(addr string) error {
 	if len(skeyPath) > 0 {
 		keyPath = skeyPath
 	}
-
-	client, err := client.New(client.WithUser(username), client.WithHost(host), client.WithPort(port), client.WithKeyPath(keyPath))
+	hostKeyCallback, err := ssh.HostKeyCallback(flagKnownHosts, flagInsecure)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	stats, err := client.GetStats()
-	if err != nil {
-		return err
-	}
+	return client.New(append(opts,
+		client.WithUser(username),
+		client.WithHost(host),
+		client.WithPort(port),
+		client.WithKeyPath(keyPath),
+		client.WithHostKeyCallback(hostKeyCallback),
+	)...)
+}
 
-	getStats := func() (types.Stats, error) {
-		stats, err := client.GetStats()
+// compatFor returns the compatibility mode for the given target from the
+// --compat flags, matching the patterns against the target as given.
+func compatFor(addr string) (client.Compat, error) {
+	compat := client.CompatAuto
+	for _, s := range flagCompat {
+		pattern, mode := "*", s
+		if i := strings.LastIndex(s, "="); i != -1 {
+			pattern, mode = s[:i], s[i+1:]
+		}
+		c, err := client.ParseCompat(mode)
 		if err != nil {
-			return types.Stats{}, err
+			return "", err
+		}
+		if ok, err := path.Match(pattern, addr); err != nil {
+			return "", fmt.Errorf("invalid compat host pattern %q: %s", pattern, err)
+		} else if ok {
+			compat = c
 		}
-		return stats, nil
 	}
+	return compat, nil
+}
 
-	renderer := tui.NewRenderingState(getStats, stats, flagInterval)
-	err = renderer.Start()
-	if err != nil {
-		return err
+// labelsFor returns the labels for the given target from the --label flags,
+// matching the patterns against the target as given.
+func labelsFor(addr string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, s := range flagLabels {
+		pattern, label := "*", s
+		eq := strings.Index(s, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid label %q, expected [host-pattern:]key=value", s)
+		}
+		if i := strings.LastIndex(s[:eq], ":"); i != -1 {
+			pattern, label = s[:i], s[i+1:]
+		}
+		key, value, _ := strings.Cut(label, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected [host-pattern:]key=value", s)
+		}
+		if ok, err := path.Match(pattern, addr); err != nil {
+			return nil, fmt.Errorf("invalid label host pattern %q: %s", pattern, err)
+		} else if ok {
+			labels[key] = value
+		}
 	}
-
-	return nil
+	return labels, nil
 }
 
 // parseAddrAsUserHostAddrPort parses the given address user@host:port into
 // username, host and port, respectively.
 func parseAddrAsUserHostAddrPort(flagHost string) (string, string, int, error) {
-	var user, host string
+	var user string
 	var port int
 
 	// user, addr
+	host := flagHost
 	if i := strings.Index(flagHost, "@"); i != -1 {
 		user = flagHost[:i]
 		host = flagHost[i+1:]
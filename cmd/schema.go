@@ -0,0 +1,124 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/rapidloop/rtop/pkg/client"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagSchemaJSON bool
+
+	schemaCmd = &cobra.Command{
+		Use:   "schema [--json]",
+		Short: "Print every metric of the stats with its type, unit and JSON path.",
+		Long: `Print every metric of the stats with its type, unit and JSON path.
+
+The catalog is generated from the stats types and the optional collectors,
+as written by --sink json, rtop snapshot and the HTTP API. Lists are marked
+with [] and the keys of maps are given in angle brackets. The metrics of the
+optional collectors are in extra, keyed by their names.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSchema()
+		},
+	}
+)
+
+func init() {
+	schemaCmd.Flags().BoolVar(&flagSchemaJSON, "json", false, "print the catalog as a JSON array, for tooling")
+	cmd.AddCommand(schemaCmd)
+}
+
+// schemaField is a metric of the catalog, along with the optional collector
+// reporting it.
+type schemaField struct {
+	types.Field
+	Collector string `json:"collector,omitempty"`
+}
+
+func runSchema() error {
+	var fields []schemaField
+	for _, f := range types.Schema() {
+		if f.Path == "extra.<metric>" {
+			fields = append(fields, extraFields()...)
+			continue
+		}
+		fields = append(fields, schemaField{Field: f})
+	}
+
+	if flagSchemaJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(fields)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tTYPE\tUNIT\tCOLLECTOR")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Path, f.Type, dash(string(f.Unit)), dash(f.Collector))
+	}
+	return w.Flush()
+}
+
+// extraFields lists the metrics of the optional collectors, ordered by
+// collector.
+func extraFields() []schemaField {
+	metrics := client.ExtraMetrics()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fields []schemaField
+	for _, name := range names {
+		for _, m := range metrics[name] {
+			// the names contain dots themselves
+			fields = append(fields, schemaField{
+				Field:     types.Field{Path: "extra[\"" + m.Name + "\"]", Type: "number", Unit: m.Unit},
+				Collector: name,
+			})
+		}
+	}
+	return fields
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
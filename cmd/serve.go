@@ -0,0 +1,179 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagServeListen string
+	flagBasicAuth   string
+	flagOIDCIssuer  string
+	flagOIDCAud     string
+	flagOIDCClaims  []string
+	flagRateLimit   float64
+	flagRateBurst   int
+	flagMaxConc     int
+	flagKeepSummary time.Duration
+
+	serveCmd = &cobra.Command{
+		Use:   "serve [--listen addr] [user@]host[:port]...",
+		Short: "Serve the stats of the hosts over an HTTP API.",
+		Args:  targetArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := targetsOf(args)
+			if err != nil {
+				return err
+			}
+			return runServe(targets, false)
+		},
+	}
+
+	webCmd = &cobra.Command{
+		Use:   "web [--listen addr] [user@]host[:port]...",
+		Short: "Serve a live web dashboard of the hosts, along with the HTTP API.",
+		Args:  targetArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := targetsOf(args)
+			if err != nil {
+				return err
+			}
+			return runServe(targets, true)
+		},
+	}
+)
+
+func init() {
+	serveCmd.Flags().StringVar(&flagServeListen, "listen", "localhost:8080", "address to listen on")
+	addAuthFlags(serveCmd)
+	addLimitFlags(serveCmd)
+	addAlertFlags(serveCmd)
+	serveCmd.Flags().DurationVar(&flagKeepSummary, "summary-history", server.DefaultHistory, "how long the metrics are kept for the summaries of /api/v1/hosts/<host>/summary")
+	webCmd.Flags().StringVar(&flagServeListen, "listen", "localhost:8080", "address to listen on")
+	addAuthFlags(webCmd)
+	addLimitFlags(webCmd)
+	addAlertFlags(webCmd)
+	webCmd.Flags().DurationVar(&flagKeepSummary, "summary-history", server.DefaultHistory, "how long the metrics are kept for the summaries of /api/v1/hosts/<host>/summary")
+	cmd.AddCommand(serveCmd, webCmd)
+}
+
+func runServe(targets []string, dashboard bool) error {
+	alerts, err := newAlerts(false)
+	if err != nil {
+		return err
+	}
+	hosts := make([]server.Host, 0, len(targets))
+	for _, addr := range targets {
+		client, err := newClient(addr)
+		if err != nil {
+			return err
+		}
+		getStats := client.GetStats
+		if alerts != nil {
+			getStats = alerts.check(addr, getStats)
+		}
+		hosts = append(hosts, server.Host{Name: addr, GetStats: getStats})
+	}
+	store, err := alertStore()
+	if err != nil {
+		return err
+	}
+
+	auths, err := serverAuth(context.Background())
+	if err != nil {
+		return err
+	}
+	srv := server.New(hosts, flagInterval,
+		server.WithAuth(auths...),
+		server.WithRateLimit(flagRateLimit, flagRateBurst),
+		server.WithMaxConcurrent(flagMaxConc),
+		server.WithDashboard(dashboard),
+		server.WithAlertHistory(store),
+		server.WithHistory(flagKeepSummary),
+	)
+	go srv.Run(context.Background())
+	return http.ListenAndServe(flagServeListen, srv)
+}
+
+// addAuthFlags adds the flags of the authentication of the HTTP API to a
+// command serving it.
+func addAuthFlags(c *cobra.Command) {
+	c.Flags().StringVar(&flagBasicAuth, "basic-auth-file", "", "require basic auth with the users of this htpasswd file, with bcrypt hashes (htpasswd -B)")
+	c.Flags().StringVar(&flagOIDCIssuer, "oidc-issuer", "", "accept bearer tokens of this OpenID Connect issuer, e.g. https://accounts.google.com")
+	c.Flags().StringVar(&flagOIDCAud, "oidc-audience", "", "audience the tokens must be issued for, required with --oidc-issuer")
+	c.Flags().StringArrayVar(&flagOIDCClaims, "oidc-claim", nil, "claim the tokens must have, as name=value, e.g. groups=ops; repeatable")
+}
+
+// serverAuth returns the authenticators given on the command line. The API
+// is open when there are none.
+func serverAuth(ctx context.Context) ([]server.Authenticator, error) {
+	var auths []server.Authenticator
+	if flagBasicAuth != "" {
+		b, err := server.LoadBasicAuth(flagBasicAuth)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, b)
+	}
+	if flagOIDCIssuer == "" {
+		if flagOIDCAud != "" || len(flagOIDCClaims) > 0 {
+			return nil, fmt.Errorf("--oidc-audience and --oidc-claim need --oidc-issuer")
+		}
+		return auths, nil
+	}
+	if flagOIDCAud == "" {
+		return nil, fmt.Errorf("--oidc-issuer needs --oidc-audience")
+	}
+	claims := make(map[string]string, len(flagOIDCClaims))
+	for _, c := range flagOIDCClaims {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid claim %q, expected name=value", c)
+		}
+		claims[name] = value
+	}
+	o, err := server.NewOIDC(ctx, flagOIDCIssuer, flagOIDCAud, claims)
+	if err != nil {
+		return nil, err
+	}
+	return append(auths, o), nil
+}
+
+// addLimitFlags adds the flags limiting the requests to the HTTP API to a
+// command serving it.
+func addLimitFlags(c *cobra.Command) {
+	c.Flags().Float64Var(&flagRateLimit, "rate-limit", 2, "requests per second allowed per client ip on average, 0 for no limit")
+	c.Flags().IntVar(&flagRateBurst, "rate-burst", 20, "requests a client ip may make in a burst above --rate-limit")
+	c.Flags().IntVar(&flagMaxConc, "max-concurrent", 8, "requests handled at the same time, further ones are answered with 503; 0 for no limit")
+}
@@ -0,0 +1,127 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/alert"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagSilenceFor time.Duration
+
+	silenceCmd = &cobra.Command{
+		Use:   "silence",
+		Short: "Silence the alerts of hosts or rules for a while.",
+		Long: `Silence the alerts of hosts or rules for a while.
+
+While silenced, alerts are still checked and kept in the alert history,
+marked as silenced, but neither logged nor shown in the TUI. Silences apply
+to every rtop process sharing the data directory, including those already
+running. Recurring windows are configured under maintenance in the config
+file.
+`,
+	}
+
+	silenceAddCmd = &cobra.Command{
+		Use:   "add <host|rule> --for 2h",
+		Short: "Silence the alerts of the hosts matching a pattern like 'db-*', or of a rule.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSilenceAdd(args[0])
+		},
+	}
+
+	silenceListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the silences which have not ended yet.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSilenceList()
+		},
+	}
+)
+
+func init() {
+	silenceAddCmd.Flags().DurationVar(&flagSilenceFor, "for", time.Hour, "how long to silence the alerts")
+	silenceCmd.AddCommand(silenceAddCmd, silenceListCmd)
+	cmd.AddCommand(silenceCmd)
+}
+
+func runSilenceAdd(match string) error {
+	if flagSilenceFor <= 0 {
+		return fmt.Errorf("invalid --for %s, expected a positive duration", flagSilenceFor)
+	}
+	// a rule is kept as parsed, so that it matches however it is spaced
+	if r, err := alert.ParseRule(match); err == nil {
+		match = r.String()
+	} else if len(strings.Fields(match)) == 3 {
+		return err
+	}
+	store, err := silenceStore()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	s := alert.Silence{Match: match, Until: now.Add(flagSilenceFor), Created: now}
+	if err := store.Add(s); err != nil {
+		return err
+	}
+	fmt.Printf("silenced %s until %s\n", match, s.Until.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func runSilenceList() error {
+	store, err := silenceStore()
+	if err != nil {
+		return err
+	}
+	silences, err := store.Active(time.Now())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MATCH\tUNTIL")
+	for _, s := range silences {
+		fmt.Fprintf(w, "%s\t%s\n", s.Match, s.Until.Local().Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// silenceStore returns the silences kept in the data directory.
+func silenceStore() (*alert.SilenceStore, error) {
+	path, err := dataDir("silences.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	return alert.NewSilenceStore(path), nil
+}
@@ -0,0 +1,205 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/sink"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// newSink creates the sink given as kind:target on the command line.
+func newSink(spec string) (sink.Sink, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid sink %q, expected kind:target", spec)
+	}
+	switch kind {
+	case "json":
+		return sink.NewJSON(target)
+	case "csv":
+		return sink.NewCSV(target, ',')
+	case "tsv":
+		return sink.NewCSV(target, '\t')
+	case "influx":
+		return sink.NewInflux(target, os.Getenv("INFLUX_TOKEN"))
+	case "graphite", "statsd":
+		prefix, err := metricPrefix()
+		if err != nil {
+			return nil, err
+		}
+		if kind == "graphite" {
+			return sink.NewGraphite(target, prefix)
+		}
+		return sink.NewStatsD(target, prefix)
+	}
+	return nil, fmt.Errorf("unknown sink %q, expected json, csv, tsv, influx, graphite or statsd", kind)
+}
+
+// metricPrefix returns the prefix of the Graphite and StatsD metrics of a
+// sample from the --metric-prefix flags, matching the patterns against the
+// target as given. The last match wins.
+func metricPrefix() (sink.Prefixer, error) {
+	type prefix struct {
+		pattern string
+		expand  sink.Prefixer
+	}
+	prefixes := []prefix{{"*", sink.PrefixTemplate(sink.DefaultPrefix)}}
+	for _, s := range flagMetricPrefix {
+		pattern, tmpl := "*", s
+		if i := strings.LastIndex(s, "="); i != -1 {
+			pattern, tmpl = s[:i], s[i+1:]
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metric prefix host pattern %q: %s", pattern, err)
+		}
+		prefixes = append(prefixes, prefix{pattern, sink.PrefixTemplate(tmpl)})
+	}
+	return func(s sink.HostStats) string {
+		expand := prefixes[0].expand
+		for _, p := range prefixes[1:] {
+			if ok, _ := path.Match(p.pattern, s.Host); ok {
+				expand = p.expand
+			}
+		}
+		return expand(s)
+	}, nil
+}
+
+// logFileSink returns the sink spec of --log-file, tab separated for files
+// ending in .tsv and comma separated otherwise.
+func logFileSink(path string) string {
+	if strings.HasSuffix(path, ".tsv") {
+		return "tsv:" + path
+	}
+	return "csv:" + path
+}
+
+// writeSinks wraps getStats so that every sample of the host is also
+// written to the sinks, even if some collectors failed. Errors of the sinks
+// are not the host's; see sinkStatus and logSinks.
+func writeSinks(host string, getStats func(context.Context) (types.Stats, error), fan *sink.Fanout) func(context.Context) (types.Stats, error) {
+	return func(ctx context.Context) (types.Stats, error) {
+		stats, err := getStats(ctx)
+		if stats.Hostname == "" {
+			// nothing was collected; a failing collector alone still
+			// leaves a sample worth writing
+			return stats, err
+		}
+		fan.Write(ctx, sink.HostStats{Host: host, Time: time.Now(), Stats: stats})
+		return stats, err
+	}
+}
+
+// sinkStatus returns a summary of the sinks for the status bar: the samples
+// queued and dropped, and the sinks whose latest write failed. It is empty
+// while the sinks keep up.
+func sinkStatus(fan *sink.Fanout) func() string {
+	return func() string {
+		var queued, dropped int
+		var failing []string
+		for _, m := range fan.Metrics() {
+			queued += m.Queued
+			dropped += m.Dropped
+			if m.Failing {
+				failing = append(failing, m.Name)
+			}
+		}
+		if queued == 0 && dropped == 0 && len(failing) == 0 {
+			return ""
+		}
+		s := fmt.Sprintf("sinks queued %d, dropped %d", queued, dropped)
+		if len(failing) > 0 {
+			s += ", failing " + strings.Join(failing, ", ")
+		}
+		return s
+	}
+}
+
+// logSinks returns a function printing a line for every sink which failed
+// or dropped samples since the previous call, with the depth of its queue.
+func logSinks(w io.Writer, fan *sink.Fanout) func() {
+	var prev []sink.Metrics
+	return func() {
+		metrics := fan.Metrics()
+		for i, m := range metrics {
+			var p sink.Metrics
+			if i < len(prev) {
+				p = prev[i]
+			}
+			failed, dropped := m.Failed-p.Failed, m.Dropped-p.Dropped
+			if failed == 0 && dropped == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "%s: failed %d, dropped %d, queued %d", m.Name, failed, dropped, m.Queued)
+			if failed > 0 {
+				fmt.Fprintf(w, ", last error: %s", m.LastError)
+			}
+			fmt.Fprintln(w)
+		}
+		prev = metrics
+	}
+}
+
+// runHeadless refreshes the hosts every interval without showing them, for
+// writing their stats to the sinks of fan only. Errors, also those of the
+// sinks, are printed to stderr. The hosts of changes are added and removed
+// between rounds. Once ctx is done, fan is closed and runHeadless returns.
+func runHeadless(ctx context.Context, hosts []tui.Host, changes <-chan interface{}, fan *sink.Fanout) error {
+	report := logSinks(os.Stderr, fan)
+	for {
+		hosts = applyHostChanges(hosts, changes)
+		for _, h := range hosts {
+			stats, err := h.GetStats(ctx)
+			if err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", h.Name, err)
+			}
+			warnIntervalFloor(h.Name, stats)
+		}
+		report()
+		select {
+		case <-ctx.Done():
+			return closeSinks(fan)
+		case <-time.After(flagInterval):
+		}
+	}
+}
+
+// closeSinks closes fan, if any, once the buffered samples are written.
+func closeSinks(fan *sink.Fanout) error {
+	if fan == nil {
+		return nil
+	}
+	return fan.Close()
+}
@@ -0,0 +1,191 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fatih/semgroup"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagHostsFile   string
+	flagOutDir      string
+	flagConcurrency int
+	flagTimeout     time.Duration
+	flagISOUptime   bool
+
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot [--hosts-file file] [--out dir] [[user@]host[:port]...]",
+		Short: "Collect one JSON snapshot per host into a directory.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := args
+			if flagHostsFile != "" {
+				hosts, err := readHostsFile(flagHostsFile)
+				if err != nil {
+					return err
+				}
+				targets = append(targets, hosts...)
+			}
+			if len(targets) == 0 && (config == nil || len(config.Hosts) == 0) {
+				return fmt.Errorf("no hosts given, pass them as arguments, with --hosts-file or in the config file")
+			}
+			targets, err := targetsOf(targets)
+			if err != nil {
+				return err
+			}
+			return runSnapshot(targets)
+		},
+	}
+)
+
+// snapshot is the JSON document written for each host.
+type snapshot struct {
+	Host      string      `json:"host"`
+	Time      time.Time   `json:"time"`
+	UptimeISO string      `json:"uptime_iso,omitempty"`
+	Stats     types.Stats `json:"stats"`
+}
+
+func init() {
+	snapshotCmd.Flags().StringVar(&flagHostsFile, "hosts-file", "", "file listing one [user@]host[:port] per line")
+	snapshotCmd.Flags().StringVarP(&flagOutDir, "out", "o", ".", "directory to write the snapshots to")
+	snapshotCmd.Flags().IntVarP(&flagConcurrency, "concurrency", "c", 8, "number of hosts to collect from concurrently")
+	snapshotCmd.Flags().DurationVar(&flagTimeout, "timeout", 30*time.Second, "time limit for connecting to and collecting from a single host")
+	snapshotCmd.Flags().BoolVar(&flagISOUptime, "iso-uptime", false, "also write the uptime as an ISO-8601 duration")
+	cmd.AddCommand(snapshotCmd)
+}
+
+func runSnapshot(targets []string) error {
+	if err := os.MkdirAll(flagOutDir, 0755); err != nil {
+		return err
+	}
+
+	s := semgroup.NewGroup(context.Background(), int64(flagConcurrency))
+	for _, target := range targets {
+		target := target
+		s.Go(func() error {
+			if err := writeSnapshot(target); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", target, err)
+				return fmt.Errorf("%s: %s", target, err)
+			}
+			return nil
+		})
+	}
+
+	if err := s.Wait(); err != nil {
+		return fmt.Errorf("snapshot failed for some hosts")
+	}
+	return nil
+}
+
+// writeSnapshot collects the stats of the given target within the timeout
+// and writes them into the output directory.
+func writeSnapshot(target string) error {
+	type result struct {
+		stats types.Stats
+		err   error
+	}
+
+	// the collection can't be interrupted, so on timeout the goroutine is
+	// left behind to finish on its own.
+	done := make(chan result, 1)
+	go func() {
+		client, err := newClient(target)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		stats, err := client.GetStats(context.Background())
+		done <- result{stats: stats, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+		if res.err != nil {
+			return res.err
+		}
+	case <-time.After(flagTimeout):
+		return fmt.Errorf("timed out after %s", flagTimeout)
+	}
+
+	snap := snapshot{
+		Host:  target,
+		Time:  time.Now(),
+		Stats: res.stats,
+	}
+	if flagISOUptime {
+		snap.UptimeISO = tui.FormatUptime(res.stats.Uptime, tui.UptimeISO)
+	}
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(flagOutDir, snapshotFileName(target))
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+
+// snapshotFileName derives a file name from the given target.
+func snapshotFileName(target string) string {
+	return unsafeFileChars.ReplaceAllString(target, "_") + ".json"
+}
+
+// readHostsFile reads the targets listed in the given file, one per line.
+// Empty lines and lines starting with # are ignored.
+func readHostsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+
+	return hosts, s.Err()
+}
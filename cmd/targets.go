@@ -0,0 +1,158 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/tui"
+)
+
+const srvScheme = "dns+srv://"
+
+// expandTargets expands the given command line targets into a list of
+// [user@]host[:port] addresses. Targets in the form dns+srv://[user@]name
+// are resolved into one address per SRV record of the given name.
+func expandTargets(args []string) ([]string, error) {
+	var targets []string
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, srvScheme) {
+			targets = append(targets, arg)
+			continue
+		}
+
+		addrs, err := lookupSRVTargets(strings.TrimPrefix(arg, srvScheme))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, addrs...)
+	}
+
+	return targets, nil
+}
+
+// resolverOf returns a function expanding the given targets again, or nil
+// if none of them is resolved from SRV records, so that they cannot change.
+func resolverOf(args []string) func() ([]string, error) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, srvScheme) {
+			return func() ([]string, error) {
+				return expandTargets(args)
+			}
+		}
+	}
+	return nil
+}
+
+// watchTargets resolves the targets again every interval, adding the new
+// ones with add and removing those gone with remove, after closing what
+// add returned for them, or what current holds for the initial targets, if
+// anything. Failed lookups leave the targets as they are; targets add
+// fails for are tried again with the next lookup. The last target is kept,
+// as applyHostChanges and the UIs keep the last host.
+func watchTargets(resolve func() ([]string, error), current map[string]io.Closer, every time.Duration, add func(string) (io.Closer, error), remove func(string)) {
+	for range time.Tick(every) {
+		resolved, err := resolve()
+		if err != nil {
+			continue
+		}
+		seen := make(map[string]bool, len(resolved))
+		for _, t := range resolved {
+			seen[t] = true
+			if _, ok := current[t]; ok {
+				continue
+			}
+			if c, err := add(t); err == nil {
+				current[t] = c
+			}
+		}
+		for t, c := range current {
+			if seen[t] || len(current) == 1 {
+				continue
+			}
+			remove(t)
+			if c != nil {
+				c.Close()
+			}
+			delete(current, t)
+		}
+	}
+}
+
+// applyHostChanges returns the hosts with those of the pending
+// tui.AddHostMsg of changes added and those of its tui.RemoveHostMsg
+// removed, keeping at least one.
+func applyHostChanges(hosts []tui.Host, changes <-chan interface{}) []tui.Host {
+	for {
+		select {
+		case msg := <-changes:
+			switch msg := msg.(type) {
+			case tui.AddHostMsg:
+				hosts = append(hosts, msg.Host)
+			case tui.RemoveHostMsg:
+				for i, h := range hosts {
+					if h.Name == msg.Name && len(hosts) > 1 {
+						hosts = append(hosts[:i:i], hosts[i+1:]...)
+						break
+					}
+				}
+			}
+		default:
+			return hosts
+		}
+	}
+}
+
+// lookupSRVTargets resolves the SRV records of the given [user@]name into
+// addresses, ordered by priority and weight as returned by the resolver.
+func lookupSRVTargets(name string) ([]string, error) {
+	var user string
+	if i := strings.Index(name, "@"); i != -1 {
+		user = name[:i+1]
+		name = name[i+1:]
+	}
+
+	_, srvs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup srv %s: %s", name, err)
+	}
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("lookup srv %s: no records found", name)
+	}
+
+	addrs := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, fmt.Sprintf("%s%s:%d", user, host, srv.Port))
+	}
+
+	return addrs, nil
+}
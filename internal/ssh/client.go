@@ -28,15 +28,16 @@ package ssh
 import (
 	"bufio"
 	"bytes"
-	"crypto/x509"
-	"encoding/pem"
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"golang.org/x/crypto/ssh"
@@ -45,14 +46,54 @@ import (
 )
 
 type Client struct {
-	conn   net.Conn
-	client *ssh.Client
+	conn net.Conn
+
+	// addr and config are used to reconnect; config is nil for clients
+	// created from an existing ssh client, which are not reconnected
+	addr   string
+	config *ssh.ClientConfig
+
+	// connMu guards client, the reconnect backoff and closed, which is set
+	// once Close was called
+	connMu  sync.Mutex
+	client  *ssh.Client
+	retryAt time.Time
+	backoff time.Duration
+	closed  bool
+
+	mu      sync.Mutex
+	timings Timings
+
+	// banner is the authentication banner the server sent, nil for
+	// clients created from an existing ssh client
+	banner *bannerRecorder
 }
 
-func NewClient(user, host string, port int, keypath string, client *ssh.Client) (*Client, error) {
+// Timings holds the cumulative time spent on executing remote commands.
+type Timings struct {
+	// Sessions is the number of sessions opened, including those of failed
+	// commands
+	Sessions int
+	// Commands is the number of commands executed successfully
+	Commands int
+	// BytesReceived is the total size of the command output
+	BytesReceived uint64
+	// SessionOpen is the time spent opening sessions
+	SessionOpen time.Duration
+	// RoundTrip is the time from opening a session until the command output
+	// was received
+	RoundTrip time.Duration
+	// Reconnects is the number of times the connection was set up again
+	// after it was lost
+	Reconnects int
+}
+
+func NewClient(user, host string, port int, keypath string, hostKeyCallback ssh.HostKeyCallback, client *ssh.Client) (*Client, error) {
 	// if an ssh client is provided, use it. otherwise, try to initialize one.
 	if client != nil {
-		return &Client{client: client}, nil
+		c := &Client{client: client}
+		go c.keepalive(client)
+		return c, nil
 	}
 
 	if port == 0 {
@@ -60,11 +101,24 @@ func NewClient(user, host string, port int, keypath string, client *ssh.Client)
 	}
 
 	addr := fmt.Sprintf("%s:%d", host, port)
+	banner := &bannerRecorder{}
+
+	if hostKeyCallback == nil {
+		var err error
+		hostKeyCallback, err = HostKeyCallback(DefaultKnownHostsFile, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hostKeyAlgorithms := hostKeyAlgorithms(hostKeyCallback, addr)
 
 	// try connecting via agent first
-	sshClient := tryAgentConnect(user, addr)
+	sshClient, config := tryAgentConnect(user, addr, hostKeyCallback, hostKeyAlgorithms, banner.record)
 	if sshClient != nil {
-		return nil, nil
+		c := &Client{addr: addr, config: config, client: sshClient, banner: banner}
+		go c.keepalive(sshClient)
+		return c, nil
 	}
 
 	// if that failed try with the key and password methods
@@ -72,46 +126,119 @@ func NewClient(user, host string, port int, keypath string, client *ssh.Client)
 	auths = addKeyAuth(auths, keypath)
 	auths = addPasswordAuth(user, addr, auths)
 
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: auths,
-		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error {
-			return nil
-		},
+	config = &ssh.ClientConfig{
+		User:              user,
+		Auth:              auths,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		BannerCallback:    banner.record,
+		Timeout:           dialTimeout,
 	}
 	sshClient, err := ssh.Dial("tcp", addr, config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
+	c := &Client{
+		addr:   addr,
+		config: config,
 		client: sshClient,
-	}, nil
+		banner: banner,
+	}
+	go c.keepalive(sshClient)
+	return c, nil
 }
 
-func (c *Client) Execute(command string) (string, error) {
-	session, err := c.client.NewSession()
+// Execute runs the command on the remote host and returns its output. When
+// ctx is done before the command finishes, its session is closed.
+func (c *Client) Execute(ctx context.Context, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	session, err := c.newSession()
 	if err != nil {
 		return "", err
 	}
 	defer session.Close()
+	opened := time.Now()
+
+	c.mu.Lock()
+	c.timings.Sessions++
+	c.mu.Unlock()
 
 	var buf bytes.Buffer
 	session.Stdout = &buf
-	err = session.Run(command)
+	if err := session.Start(command); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		return "", ctx.Err()
+	}
 
 	if err != nil {
 		return "", err
 	}
 
+	c.mu.Lock()
+	c.timings.Commands++
+	c.timings.SessionOpen += opened.Sub(start)
+	c.timings.RoundTrip += time.Since(start)
+	c.timings.BytesReceived += uint64(buf.Len())
+	c.mu.Unlock()
+
 	return string(buf.Bytes()), nil
 }
 
-func tryAgentConnect(user, addr string) (client *ssh.Client) {
+// Timings returns the cumulative timings of the commands executed so far.
+func (c *Client) Timings() Timings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timings
+}
+
+// Banner returns the authentication banner the server sent on the last
+// connect, such as a compliance notice, or "" if it sent none.
+func (c *Client) Banner() string {
+	if c.banner == nil {
+		return ""
+	}
+	c.banner.mu.Lock()
+	defer c.banner.mu.Unlock()
+	return c.banner.text
+}
+
+// bannerRecorder keeps the banner of the last connect, reconnects included.
+type bannerRecorder struct {
+	mu   sync.Mutex
+	text string
+}
+
+func (b *bannerRecorder) record(message string) error {
+	b.mu.Lock()
+	b.text = message
+	b.mu.Unlock()
+	return nil
+}
+
+func tryAgentConnect(user, addr string, hostKeyCallback ssh.HostKeyCallback, hostKeyAlgorithms []string, bannerCallback ssh.BannerCallback) (client *ssh.Client, config *ssh.ClientConfig) {
 	if auth, ok := getAgentAuth(); ok {
-		config := &ssh.ClientConfig{
-			User: user,
-			Auth: []ssh.AuthMethod{auth},
+		config = &ssh.ClientConfig{
+			User:              user,
+			Auth:              []ssh.AuthMethod{auth},
+			HostKeyCallback:   hostKeyCallback,
+			HostKeyAlgorithms: hostKeyAlgorithms,
+			BannerCallback:    bannerCallback,
+			Timeout:           dialTimeout,
 		}
 		client, _ = ssh.Dial("tcp", addr, config)
 	}
@@ -130,62 +257,76 @@ func getAgentAuth() (auth ssh.AuthMethod, ok bool) {
 	return
 }
 
+// defaultKeyFiles are the private keys tried when none is given, in the
+// order OpenSSH tries them.
+var defaultKeyFiles = []string{"~/.ssh/id_rsa", "~/.ssh/id_ecdsa", "~/.ssh/id_ed25519"}
+
 func addKeyAuth(auths []ssh.AuthMethod, keypath string) []ssh.AuthMethod {
+	var signers []ssh.Signer
 	if len(keypath) == 0 {
-		return auths
+		for _, p := range defaultKeyFiles {
+			p, err := homedir.Expand(p)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(p); err != nil {
+				continue
+			}
+			if signer, err := readKey(p); err != nil {
+				log.Print(err)
+			} else {
+				signers = append(signers, signer)
+			}
+		}
+	} else {
+		keypath, err := homedir.Expand(keypath)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(keypath); err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		signer, err := readKey(keypath)
+		if err != nil {
+			log.Print(err)
+			return auths
+		}
+		signers = append(signers, signer)
 	}
 
-	keypath, err := homedir.Expand(keypath)
-	if err != nil {
-		log.Print(err)
-		os.Exit(1)
+	if len(signers) == 0 {
+		return auths
 	}
+	return append(auths, ssh.PublicKeys(signers...))
+}
 
-	// read the file
-	pemBytes, err := os.ReadFile(keypath)
+// readKey reads a private key in PEM or OpenSSH format, asking for the
+// passphrase if it is encrypted.
+func readKey(keypath string) (ssh.Signer, error) {
+	keyBytes, err := os.ReadFile(keypath)
 	if err != nil {
-		log.Print(err)
-		os.Exit(1)
-	}
-
-	// get first pem block
-	block, _ := pem.Decode(pemBytes)
-	if block == nil {
-		log.Printf("no key found in %s", keypath)
-		return auths
+		return nil, err
 	}
 
-	// handle plain and encrypted keyfiles
-	if x509.IsEncryptedPEMBlock(block) {
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
 		prompt := fmt.Sprintf("Enter passphrase for key '%s': ", keypath)
 		pass, err := readPass(prompt)
 		if err != nil {
-			return auths
-		}
-		block.Bytes, err = x509.DecryptPEMBlock(block, []byte(pass))
-		if err != nil {
-			log.Print(err)
-			return auths
-		}
-		key, err := ParsePemBlock(block)
-		if err != nil {
-			log.Print(err)
-			return auths
+			return nil, err
 		}
-		signer, err := ssh.NewSignerFromKey(key)
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(pass))
 		if err != nil {
-			log.Print(err)
-			return auths
+			return nil, fmt.Errorf("%s: %s", keypath, err)
 		}
-		return append(auths, ssh.PublicKeys(signer))
-	} else {
-		signer, err := ssh.ParsePrivateKey(pemBytes)
-		if err != nil {
-			log.Print(err)
-			return auths
-		}
-		return append(auths, ssh.PublicKeys(signer))
+		return signer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", keypath, err)
 	}
+	return signer, nil
 }
 
 func addPasswordAuth(user, addr string, auths []ssh.AuthMethod) []ssh.AuthMethod {
@@ -197,8 +338,19 @@ func addPasswordAuth(user, addr string, auths []ssh.AuthMethod) []ssh.AuthMethod
 		host = host[:i]
 	}
 	prompt := fmt.Sprintf("%s@%s's password: ", user, host)
+	// the password is remembered so that reconnecting does not prompt for
+	// it while the UI is running
+	var password string
 	passwordCallback := func() (string, error) {
-		return readPass(prompt)
+		if password != "" {
+			return password, nil
+		}
+		pass, err := readPass(prompt)
+		if err != nil {
+			return "", err
+		}
+		password = pass
+		return pass, nil
 	}
 	return append(auths, ssh.PasswordCallback(passwordCallback))
 }
@@ -27,9 +27,6 @@ package ssh
 
 import (
 	"bufio"
-	"crypto/x509"
-	"encoding/pem"
-	"fmt"
 	"github.com/mitchellh/go-homedir"
 	"log"
 	"os"
@@ -37,8 +34,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-
-	"golang.org/x/crypto/ssh"
+	"sync"
 )
 
 type Section struct {
@@ -81,6 +77,10 @@ func (s *Section) getFull(name string, def Section) (host string, port int, user
 
 // GetSshConfig returns the host, port, user and keyfile for the given host.
 func GetSshConfig(flagHost, flagKeyPath string) (host string, port int, username string, keyPath string, error error) {
+	// HostInfo is filled in by the parsing, for clients connecting at once
+	configMu.Lock()
+	defer configMu.Unlock()
+
 	home, err := homedir.Dir()
 	if err != nil {
 		error = err
@@ -100,18 +100,13 @@ func GetSshConfig(flagHost, flagKeyPath string) (host string, port int, username
 		}
 	}
 
-	// if keyPath is still empty, try fallback to ~/.ssh/config.
-	if len(flagKeyPath) == 0 {
-		idrsap := filepath.Join(home, ".ssh", "id_rsa")
-		if _, err := os.Stat(idrsap); err == nil {
-			keyPath = idrsap
-		}
-	}
-
 	return
 }
 
-var HostInfo = make(map[string]Section)
+var (
+	HostInfo = make(map[string]Section)
+	configMu sync.Mutex
+)
 
 func GetSshEntry(name string) (host string, port int, user, keyfile string) {
 	def := Section{Hostname: name}
@@ -185,18 +180,3 @@ func ParseSshConfig(path string) bool {
 	}
 	return true
 }
-
-// ParsePemBlock parses given PEM block.
-// ref golang.org/x/crypto/ssh/keys.go#ParseRawPrivateKey.
-func ParsePemBlock(block *pem.Block) (interface{}, error) {
-	switch block.Type {
-	case "RSA PRIVATE KEY":
-		return x509.ParsePKCS1PrivateKey(block.Bytes)
-	case "EC PRIVATE KEY":
-		return x509.ParseECPrivateKey(block.Bytes)
-	case "DSA PRIVATE KEY":
-		return ssh.ParseDSAPrivateKey(block.Bytes)
-	default:
-		return nil, fmt.Errorf("rtop: unsupported key type %q", block.Type)
-	}
-}
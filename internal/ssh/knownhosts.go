@@ -0,0 +1,215 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// DefaultKnownHostsFile is the known_hosts file used when none is given.
+const DefaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// HostKeyCallback returns a callback which verifies host keys against the
+// given known_hosts file, or accepts any host key if insecure is set. Keys
+// of unknown hosts are, like OpenSSH does, offered to be accepted and saved
+// when running on a terminal.
+func HostKeyCallback(knownHostsFile string, insecure bool) (ssh.HostKeyCallback, error) {
+	if insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if knownHostsFile == "" {
+		knownHostsFile = DefaultKnownHostsFile
+	}
+	path, err := homedir.Expand(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	known, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %s", path, err)
+	}
+
+	h := &hostKeys{path: path, known: known, accepted: map[string]ssh.PublicKey{}}
+	return h.check, nil
+}
+
+// hostKeys checks host keys against a known_hosts file and the keys
+// accepted since it was read.
+type hostKeys struct {
+	path  string
+	known ssh.HostKeyCallback
+
+	mu       sync.Mutex
+	accepted map[string]ssh.PublicKey
+}
+
+func (h *hostKeys) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	err := h.known(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	host := knownhosts.Normalize(hostname)
+	accepted, isAccepted := h.accepted[host]
+	if _, ok := key.(probeKey); ok {
+		if isAccepted {
+			keyErr.Want = append(keyErr.Want, knownhosts.KnownKey{Key: accepted})
+		}
+		return keyErr
+	}
+	// a key of another type than the known ones is not a changed key, but
+	// an unknown one, as the host may have been given a new type of key
+	for _, want := range keyErr.Want {
+		if want.Key.Type() == key.Type() {
+			return fmt.Errorf("host key for %s has changed, this could mean that someone is doing something nasty; "+
+				"if the change is expected, remove the old key at %s:%d", hostname, want.Filename, want.Line)
+		}
+	}
+	if isAccepted {
+		if string(accepted.Marshal()) == string(key.Marshal()) {
+			return nil
+		}
+		if accepted.Type() == key.Type() {
+			return fmt.Errorf("host key for %s has changed since it was accepted", hostname)
+		}
+	}
+
+	if !terminal.IsTerminal(0) {
+		return fmt.Errorf("host key for %s is not known, add it to %s or use --insecure", hostname, h.path)
+	}
+	fmt.Printf("The authenticity of host '%s (%s)' can't be established.\n", host, remote)
+	fmt.Printf("%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+	fmt.Print("Are you sure you want to continue connecting (yes/no)? ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+		return fmt.Errorf("host key for %s was not accepted", hostname)
+	}
+	h.accepted[host] = key
+
+	if err := h.save(host, key); err != nil {
+		fmt.Printf("Failed to add the host to the list of known hosts (%s): %s\n", h.path, err)
+		return nil
+	}
+	fmt.Printf("Warning: Permanently added '%s' (%s) to the list of known hosts.\n", host, key.Type())
+	return nil
+}
+
+// probeKey is passed to a host key callback to learn the keys known for a
+// host from the error it returns.
+type probeKey struct{}
+
+func (probeKey) Type() string                                 { return "rtop-probe" }
+func (probeKey) Marshal() []byte                              { return nil }
+func (probeKey) Verify(data []byte, sig *ssh.Signature) error { return errors.New("probe key") }
+
+// hostKeyAlgorithms lists the host key algorithms of the keys known for the
+// host by the callback, in the order OpenSSH prefers them, so that the
+// server presents a key which can be checked rather than one of another
+// type. It returns nil, leaving the choice to the server, if no key is
+// known or the callback does not tell them, like one accepting any key.
+func hostKeyAlgorithms(cb ssh.HostKeyCallback, addr string) []string {
+	remote, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		remote = &net.TCPAddr{}
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(cb(addr, remote, probeKey{}), &keyErr) {
+		return nil
+	}
+	known := make(map[string]bool, len(keyErr.Want))
+	for _, want := range keyErr.Want {
+		known[want.Key.Type()] = true
+	}
+	var algos []string
+	for _, a := range hostKeyPreference {
+		if known[a.keyType] {
+			algos = append(algos, a.algo)
+		}
+	}
+	return algos
+}
+
+// hostKeyPreference maps host key algorithms to the type of their keys, in
+// order of preference.
+var hostKeyPreference = []struct{ algo, keyType string }{
+	{ssh.CertAlgoED25519v01, ssh.CertAlgoED25519v01},
+	{ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA256v01},
+	{ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA384v01},
+	{ssh.CertAlgoECDSA521v01, ssh.CertAlgoECDSA521v01},
+	{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSAv01},
+	{ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01},
+	{ssh.CertAlgoRSAv01, ssh.CertAlgoRSAv01},
+	{ssh.KeyAlgoED25519, ssh.KeyAlgoED25519},
+	{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA256},
+	{ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA384},
+	{ssh.KeyAlgoECDSA521, ssh.KeyAlgoECDSA521},
+	{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSA},
+	{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA},
+	{ssh.KeyAlgoRSA, ssh.KeyAlgoRSA},
+	{ssh.KeyAlgoDSA, ssh.KeyAlgoDSA},
+}
+
+// save appends the key of host to the known_hosts file.
+func (h *hostKeys) save(host string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{host}, key)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,170 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package ssh
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// dialTimeout limits how long setting up the TCP connection may take
+	dialTimeout = 15 * time.Second
+	// keepaliveInterval is how often the server is asked whether the
+	// connection is still alive, and keepaliveTimeout how long it may take
+	// to answer
+	keepaliveInterval = 15 * time.Second
+	keepaliveTimeout  = 10 * time.Second
+	// maxBackoff is the longest wait between reconnect attempts
+	maxBackoff = time.Minute
+)
+
+// keepalive sends keepalive requests over the given connection until it is
+// closed, and closes it if the server stops answering so that the next
+// command reconnects instead of hanging.
+func (c *Client) keepalive(client *ssh.Client) {
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			c.drop(client)
+			return
+		case <-ticker.C:
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			errc <- err
+		}()
+		select {
+		case err := <-errc:
+			if err == nil {
+				continue
+			}
+		case <-time.After(keepaliveTimeout):
+		}
+		client.Close()
+		c.drop(client)
+		return
+	}
+}
+
+// Close closes the connection for good: later commands fail instead of
+// reconnecting.
+func (c *Client) Close() error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	c.closed = true
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// drop forgets the given connection if it is still the current one.
+func (c *Client) drop(client *ssh.Client) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.client == client {
+		c.client = nil
+	}
+}
+
+// newSession opens a session, reconnecting first if the connection was
+// lost. A failing session on a connection not yet known to be lost is
+// retried once on a new connection, unless the server merely refused the
+// session, e.g. because of MaxSessions.
+func (c *Client) newSession() (*ssh.Session, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if _, refused := err.(*ssh.OpenChannelError); err == nil || refused || c.config == nil {
+		return session, err
+	}
+
+	client.Close()
+	c.drop(client)
+	if client, err = c.connect(); err != nil {
+		return nil, err
+	}
+	return client.NewSession()
+}
+
+// connect returns the current connection, setting up a new one if it was
+// lost. Failed attempts are retried with exponential backoff, failing fast
+// in between.
+func (c *Client) connect() (*ssh.Client, error) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("connection closed")
+	}
+	if c.client != nil {
+		return c.client, nil
+	}
+	if c.config == nil {
+		return nil, fmt.Errorf("connection lost")
+	}
+	if wait := time.Until(c.retryAt); wait > 0 {
+		return nil, fmt.Errorf("connection lost, reconnecting in %s", wait.Round(time.Second))
+	}
+
+	client, err := ssh.Dial("tcp", c.addr, c.config)
+	if err != nil {
+		c.backoff *= 2
+		if c.backoff == 0 {
+			c.backoff = time.Second
+		} else if c.backoff > maxBackoff {
+			c.backoff = maxBackoff
+		}
+		c.retryAt = time.Now().Add(c.backoff)
+		return nil, fmt.Errorf("reconnect: %s", err)
+	}
+	c.client = client
+	c.backoff = 0
+
+	c.mu.Lock()
+	c.timings.Reconnects++
+	c.mu.Unlock()
+
+	go c.keepalive(client)
+	return client, nil
+}
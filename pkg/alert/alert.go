@@ -0,0 +1,464 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package alert evaluates threshold rules on the stats of hosts.
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/budget"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// hostMetrics are the metrics of the host as a whole. Percentages are 0 to
+// 100.
+var hostMetrics = map[string]func(types.Stats) float64{
+	"cpu.used_percent":    func(s types.Stats) float64 { return float64(100 - s.CPU.Idle) },
+	"cpu.user":            func(s types.Stats) float64 { return float64(s.CPU.User) },
+	"cpu.system":          func(s types.Stats) float64 { return float64(s.CPU.System) },
+	"cpu.iowait":          func(s types.Stats) float64 { return float64(s.CPU.IOWait) },
+	"cpu.steal":           func(s types.Stats) float64 { return float64(s.CPU.Steal) },
+	"load.1":              func(s types.Stats) float64 { return parseFloat(s.Loads.Load1) },
+	"load.5":              func(s types.Stats) float64 { return parseFloat(s.Loads.Load5) },
+	"load.15":             func(s types.Stats) float64 { return parseFloat(s.Loads.Load15) },
+	"procs.running":       func(s types.Stats) float64 { return parseFloat(s.Loads.RunningProcs) },
+	"procs.total":         func(s types.Stats) float64 { return parseFloat(s.Loads.TotalProcs) },
+	"mem.used":            func(s types.Stats) float64 { return float64(s.MEM.Used()) },
+	"mem.available":       func(s types.Stats) float64 { return float64(s.MEM.Total - s.MEM.Used()) },
+	"mem.used_percent":    func(s types.Stats) float64 { return percent(s.MEM.Used(), s.MEM.Total) },
+	"swap.used":           func(s types.Stats) float64 { return float64(s.MEM.SwapTotal - s.MEM.SwapFree) },
+	"swap.used_percent":   func(s types.Stats) float64 { return percent(s.MEM.SwapTotal-s.MEM.SwapFree, s.MEM.SwapTotal) },
+	"swap.in_rate":        func(s types.Stats) float64 { return s.SwapActivity.InRate },
+	"swap.out_rate":       func(s types.Stats) float64 { return s.SwapActivity.OutRate },
+	"fs.max_used_percent": maxFSUsage,
+}
+
+// fsMetrics are the metrics of each filesystem, named fs.<mount>.<metric>.
+var fsMetrics = map[string]func(types.FSInfo) float64{
+	"free":                func(fs types.FSInfo) float64 { return float64(fs.Free) },
+	"used":                func(fs types.FSInfo) float64 { return float64(fs.Used) },
+	"used_percent":        func(fs types.FSInfo) float64 { return percent(fs.Used, fs.Total) },
+	"inodes_used_percent": func(fs types.FSInfo) float64 { return fs.InodeUsage() },
+}
+
+// netMetrics are the metrics of each interface, named
+// net.<interface>.<metric>, in bytes per second.
+var netMetrics = map[string]func(types.NetInterface) float64{
+	"rx_rate": func(n types.NetInterface) float64 { return n.RxRate },
+	"tx_rate": func(n types.NetInterface) float64 { return n.TxRate },
+}
+
+// gpuMetrics are the metrics of each GPU, named gpu.<index>.<metric>.
+var gpuMetrics = map[string]func(types.GPU) float64{
+	"utilization":         func(g types.GPU) float64 { return g.Utilization },
+	"memory_used":         func(g types.GPU) float64 { return float64(g.MemoryUsed) },
+	"memory_used_percent": func(g types.GPU) float64 { return g.MemoryUsage() },
+	"temp":                func(g types.GPU) float64 { return g.Temp },
+}
+
+// zfsMetrics are the metrics of each ZFS pool, named zfs.<pool>.<metric>;
+// healthy is 1 for ONLINE pools and 0 for the others.
+var zfsMetrics = map[string]func(types.ZPool) float64{
+	"free":          func(p types.ZPool) float64 { return float64(p.Free) },
+	"capacity":      func(p types.ZPool) float64 { return p.Capacity },
+	"fragmentation": func(p types.ZPool) float64 { return p.Fragmentation },
+	"healthy": func(p types.ZPool) float64 {
+		if p.Healthy() {
+			return 1
+		}
+		return 0
+	},
+}
+
+// sizeMetrics are the metrics in bytes, or bytes per second, which are
+// shown with a unit.
+var sizeMetrics = map[string]bool{
+	"mem.used": true, "mem.available": true, "swap.used": true,
+	"free": true, "used": true, "rx_rate": true, "tx_rate": true,
+	"memory_used": true,
+}
+
+var ops = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Rule is a threshold on a metric, such as mem.used_percent > 90.
+type Rule struct {
+	Metric    string
+	Op        string
+	Threshold float64
+
+	text string
+}
+
+func (r Rule) String() string {
+	return r.text
+}
+
+// ParseRule parses a rule given as "metric op threshold", where op is one
+// of >, >=, <, <=, == and != and the threshold may have a size unit like
+// GiB. The metrics are:
+//
+//	cpu.used_percent, cpu.user, cpu.system, cpu.iowait, cpu.steal
+//	load.1, load.5, load.15, procs.running, procs.total
+//	mem.used, mem.available, mem.used_percent
+//	swap.used, swap.used_percent, swap.in_rate, swap.out_rate
+//	fs.<mount>.free, fs.<mount>.used, fs.<mount>.used_percent,
+//	fs.<mount>.inodes_used_percent, fs.max_used_percent
+//	net.<interface>.rx_rate, net.<interface>.tx_rate
+//	gpu.<index>.utilization, gpu.<index>.memory_used,
+//	gpu.<index>.memory_used_percent, gpu.<index>.temp
+//	zfs.<pool>.free, zfs.<pool>.capacity, zfs.<pool>.fragmentation,
+//	zfs.<pool>.healthy
+//	<collector>.<metric> of the optional collectors, e.g. redis.used_memory
+//
+// A mount, interface, GPU index or pool of * matches all of them, e.g.
+// fs.*.free < 1GiB.
+func ParseRule(s string) (Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Rule{}, fmt.Errorf("invalid alert rule %q, expected 'metric op threshold'", s)
+	}
+	r := Rule{Metric: fields[0], Op: fields[1], text: strings.Join(fields, " ")}
+	if _, ok := ops[r.Op]; !ok {
+		return Rule{}, fmt.Errorf("invalid alert rule %q: unknown operator %q", s, r.Op)
+	}
+	if !known(r.Metric) {
+		return Rule{}, fmt.Errorf("invalid alert rule %q: unknown metric %q", s, r.Metric)
+	}
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		size, serr := budget.ParseSize(fields[2])
+		if serr != nil {
+			return Rule{}, fmt.Errorf("invalid alert rule %q: invalid threshold %q", s, fields[2])
+		}
+		threshold = float64(size)
+	}
+	r.Threshold = threshold
+	return r, nil
+}
+
+// known reports whether the metric exists, without knowing the stats.
+// Metrics of the optional collectors are accepted as long as they have a
+// collector and a name.
+func known(metric string) bool {
+	if _, ok := hostMetrics[metric]; ok {
+		return true
+	}
+	prefix, rest, _ := strings.Cut(metric, ".")
+	switch prefix {
+	case "fs", "net", "gpu", "zfs":
+		i := strings.LastIndex(rest, ".")
+		if i <= 0 {
+			return false
+		}
+		switch prefix {
+		case "fs":
+			_, ok := fsMetrics[rest[i+1:]]
+			return ok
+		case "gpu":
+			_, ok := gpuMetrics[rest[i+1:]]
+			return ok
+		case "zfs":
+			_, ok := zfsMetrics[rest[i+1:]]
+			return ok
+		}
+		_, ok := netMetrics[rest[i+1:]]
+		return ok
+	case "cpu", "load", "procs", "mem", "swap":
+		return false
+	}
+	return prefix != "" && rest != ""
+}
+
+// collectorOf returns the collector which fills in the metric.
+func collectorOf(metric string) string {
+	switch metric {
+	case "swap.used", "swap.used_percent":
+		return "mem"
+	}
+	prefix, _, _ := strings.Cut(metric, ".")
+	switch prefix {
+	case "procs":
+		return "load"
+	case "net":
+		return "netdev"
+	}
+	return prefix
+}
+
+// values returns the values of the metric in the stats, keyed by the name
+// of the metric with the wildcard, if any, filled in. Metrics the stats do
+// not have, such as those of unmounted filesystems, are left out. If the
+// collector of the metric produced nothing, its values are unknown rather
+// than zero, and ok is false.
+func values(stats types.Stats, metric string) (vals map[string]float64, ok bool) {
+	if !stats.Collected(collectorOf(metric)) {
+		return nil, false
+	}
+	if f, ok := hostMetrics[metric]; ok {
+		return map[string]float64{metric: f(stats)}, true
+	}
+	vals = make(map[string]float64)
+	prefix, rest, _ := strings.Cut(metric, ".")
+	i := strings.LastIndex(rest, ".")
+	switch prefix {
+	case "fs":
+		mount, name := rest[:i], rest[i+1:]
+		for _, fs := range stats.FSInfos {
+			if mount == "*" || mount == fs.MountPoint {
+				vals["fs."+fs.MountPoint+"."+name] = fsMetrics[name](fs)
+			}
+		}
+	case "net":
+		iface, name := rest[:i], rest[i+1:]
+		for n, ni := range stats.NetInterface {
+			if iface == "*" || iface == n {
+				vals["net."+n+"."+name] = netMetrics[name](ni)
+			}
+		}
+	case "gpu":
+		index, name := rest[:i], rest[i+1:]
+		for _, g := range stats.GPUs {
+			if index == "*" || index == strconv.Itoa(g.Index) {
+				vals["gpu."+strconv.Itoa(g.Index)+"."+name] = gpuMetrics[name](g)
+			}
+		}
+	case "zfs":
+		pool, name := rest[:i], rest[i+1:]
+		for _, p := range stats.ZFS {
+			if pool == "*" || pool == p.Name {
+				vals["zfs."+p.Name+"."+name] = zfsMetrics[name](p)
+			}
+		}
+	default:
+		if v, ok := stats.Extra[metric]; ok {
+			vals[metric] = v
+		}
+	}
+	return vals, true
+}
+
+// Metrics returns the values of all metrics of the stats, with those of
+// each filesystem, interface, GPU and pool named as in rules, e.g.
+// fs./var.free. Metrics of collectors which produced nothing are left out.
+func Metrics(stats types.Stats) map[string]float64 {
+	res := make(map[string]float64)
+	add := func(metric string) {
+		vals, _ := values(stats, metric)
+		for name, v := range vals {
+			res[name] = v
+		}
+	}
+	for metric := range hostMetrics {
+		add(metric)
+	}
+	for name := range fsMetrics {
+		add("fs.*." + name)
+	}
+	for name := range netMetrics {
+		add("net.*." + name)
+	}
+	for name := range gpuMetrics {
+		add("gpu.*." + name)
+	}
+	for name := range zfsMetrics {
+		add("zfs.*." + name)
+	}
+	for metric := range stats.Extra {
+		add(metric)
+	}
+	return res
+}
+
+// Event is an alert firing or resolving on a host.
+type Event struct {
+	Host string
+	Rule Rule
+	// Metric is the metric of the rule with the wildcard filled in, e.g.
+	// fs./var.free for fs.*.free.
+	Metric   string
+	Value    float64
+	Resolved bool
+	// Silenced is set if its notifications are to be suppressed, see
+	// WithSilencer
+	Silenced bool
+}
+
+func (e Event) String() string {
+	state := "firing"
+	if e.Resolved {
+		state = "resolved"
+	}
+	if e.Silenced {
+		state += " silenced"
+	}
+	return fmt.Sprintf("%s %s: %s = %s (%s)", e.Host, state, e.Metric, e.FormatValue(), e.Rule)
+}
+
+// FormatValue returns the value with the unit of its metric.
+func (e Event) FormatValue() string {
+	name := e.Metric
+	if strings.HasPrefix(name, "fs.") || strings.HasPrefix(name, "net.") {
+		name = name[strings.LastIndex(name, ".")+1:]
+	}
+	if sizeMetrics[name] {
+		return fmtSize(e.Value)
+	}
+	return strconv.FormatFloat(e.Value, 'f', 1, 64)
+}
+
+// Engine evaluates rules against the stats of hosts, reporting each breach
+// once when it starts and once when it ends.
+type Engine struct {
+	rules    []Rule
+	silencer Silencer
+
+	mu     sync.Mutex
+	active map[string]bool
+	fired  int
+}
+
+type Option func(e *Engine)
+
+// WithSilencer marks the events the silencer silences as Silenced. Their
+// alerts still start and end as usual, but do not count as fired.
+func WithSilencer(s Silencer) Option {
+	return func(e *Engine) {
+		e.silencer = s
+	}
+}
+
+// NewEngine returns an engine evaluating the rules.
+func NewEngine(rules []Rule, opts ...Option) *Engine {
+	e := &Engine{rules: rules, active: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Check evaluates the rules against the stats of the host and returns the
+// alerts which started or ended since the previous check of the host.
+func (e *Engine) Check(host string, stats types.Stats) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []Event
+	for _, r := range e.rules {
+		prefix := host + "\x00" + r.text + "\x00"
+		vals, ok := values(stats, r.Metric)
+		if !ok {
+			// alerts neither fire nor end while their metric is unknown
+			continue
+		}
+		metrics := make([]string, 0, len(vals))
+		for m := range vals {
+			metrics = append(metrics, m)
+		}
+		sort.Strings(metrics)
+		for _, m := range metrics {
+			key := prefix + m
+			breached := ops[r.Op](vals[m], r.Threshold)
+			if breached == e.active[key] {
+				continue
+			}
+			ev := Event{Host: host, Rule: r, Metric: m, Value: vals[m], Resolved: !breached}
+			ev.Silenced = e.silencer != nil && e.silencer.Silenced(ev, time.Now())
+			events = append(events, ev)
+			if breached {
+				e.active[key] = true
+				if !ev.Silenced {
+					e.fired++
+				}
+			} else {
+				delete(e.active, key)
+			}
+		}
+		// breaches of metrics which went away, like unmounted filesystems,
+		// end silently
+		for key := range e.active {
+			if m := strings.TrimPrefix(key, prefix); m != key {
+				if _, ok := vals[m]; !ok {
+					delete(e.active, key)
+				}
+			}
+		}
+	}
+	return events
+}
+
+// Fired returns how many alerts fired so far, not counting silenced ones.
+func (e *Engine) Fired() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fired
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func percent(val, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(val) / float64(total) * 100
+}
+
+func maxFSUsage(s types.Stats) float64 {
+	var max float64
+	for _, fs := range s.FSInfos {
+		if p := percent(fs.Used, fs.Total); p > max {
+			max = p
+		}
+	}
+	return max
+}
+
+func fmtSize(v float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", v, units[i])
+	}
+	return fmt.Sprintf("%.2f%s", v, units[i])
+}
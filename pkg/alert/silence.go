@@ -0,0 +1,232 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package alert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Silencer decides whether the notifications of an event are suppressed.
+type Silencer interface {
+	Silenced(e Event, t time.Time) bool
+}
+
+// matches reports whether the host or rule of the event matches match,
+// which is a host pattern like db-* or the text of a rule.
+func matches(match string, e Event) bool {
+	if strings.Join(strings.Fields(match), " ") == e.Rule.String() {
+		return true
+	}
+	ok, _ := path.Match(match, e.Host)
+	return ok
+}
+
+// Silence suppresses the notifications of the alerts of the hosts or rule
+// it matches until a time.
+type Silence struct {
+	Match   string    `json:"match"`
+	Until   time.Time `json:"until"`
+	Created time.Time `json:"created"`
+}
+
+// Window is a maintenance window recurring every day, or on the given
+// days, during which the notifications of the alerts of the hosts or rule
+// it matches are suppressed. Start is the local time of day, e.g. 02:30.
+type Window struct {
+	Match    string        `yaml:"match"`
+	Days     []string      `yaml:"days"`
+	Start    string        `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate reports the first invalid field of the window.
+func (w Window) Validate() error {
+	if w.Match == "" {
+		return fmt.Errorf("maintenance window without match")
+	}
+	if _, err := time.Parse("15:04", w.Start); err != nil {
+		return fmt.Errorf("maintenance window %q: invalid start %q, expected a time like 02:30", w.Match, w.Start)
+	}
+	if w.Duration <= 0 {
+		return fmt.Errorf("maintenance window %q: invalid duration %s", w.Match, w.Duration)
+	}
+	for _, d := range w.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("maintenance window %q: invalid day %q, expected mon, tue, wed, thu, fri, sat or sun", w.Match, d)
+		}
+	}
+	return nil
+}
+
+// active reports whether t falls into one of the occurrences of the
+// window, which may have started on an earlier day.
+func (w Window) active(t time.Time) bool {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false
+	}
+	for back := 0; back <= int(w.Duration/(24*time.Hour))+1; back++ {
+		day := t.AddDate(0, 0, -back)
+		from := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, t.Location())
+		if !w.on(from.Weekday()) {
+			continue
+		}
+		if !t.Before(from) && t.Before(from.Add(w.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// on reports whether the window occurs on the day.
+func (w Window) on(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Silences suppresses the notifications of the alerts matching one of the
+// silences of a store or one of the maintenance windows.
+type Silences struct {
+	store   *SilenceStore
+	windows []Window
+}
+
+// NewSilences returns the silences of the store, which may be nil, and the
+// windows.
+func NewSilences(store *SilenceStore, windows []Window) *Silences {
+	return &Silences{store: store, windows: windows}
+}
+
+// Silenced reports whether the event is silenced at t. The store is read
+// again every time, so that silences added while running take effect; a
+// store which cannot be read silences nothing.
+func (s *Silences) Silenced(e Event, t time.Time) bool {
+	for _, w := range s.windows {
+		if w.active(t) && matches(w.Match, e) {
+			return true
+		}
+	}
+	if s.store == nil {
+		return false
+	}
+	silences, _ := s.store.Active(t)
+	for _, sl := range silences {
+		if matches(sl.Match, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// SilenceStore keeps the silences in a file of JSON lines, one silence per
+// line, so that silences added by one rtop process apply to the others.
+type SilenceStore struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewSilenceStore returns the store kept in the file at path, which is
+// created along with its directory on the first silence.
+func NewSilenceStore(path string) *SilenceStore {
+	return &SilenceStore{path: path}
+}
+
+// Add appends a silence to the store.
+func (s *SilenceStore) Add(sl Silence) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(sl); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Active returns the silences which have not ended at t, in the order they
+// were added.
+func (s *SilenceStore) Active(t time.Time) ([]Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var silences []Silence
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var sl Silence
+		if err := json.Unmarshal(scanner.Bytes(), &sl); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", s.path, n, err)
+		}
+		if t.Before(sl.Until) {
+			silences = append(silences, sl)
+		}
+	}
+	return silences, scanner.Err()
+}
@@ -0,0 +1,115 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+func TestWindowActive(t *testing.T) {
+	// 2026-10-18 is a Sunday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 10, day, hour, min, 0, 0, time.Local)
+	}
+	nightly := Window{Match: "*", Start: "23:00", Duration: 2 * time.Hour}
+	sundays := Window{Match: "*", Days: []string{"Sun"}, Start: "02:00", Duration: 2 * time.Hour}
+	weekend := Window{Match: "*", Days: []string{"sat"}, Start: "12:00", Duration: 48 * time.Hour}
+	for _, tt := range []struct {
+		name string
+		w    Window
+		t    time.Time
+		want bool
+	}{
+		{"before", nightly, at(16, 22, 59), false},
+		{"start", nightly, at(16, 23, 0), true},
+		{"past midnight", nightly, at(17, 0, 59), true},
+		{"end", nightly, at(17, 1, 0), false},
+		{"on the day", sundays, at(18, 3, 0), true},
+		{"other day", sundays, at(17, 3, 0), false},
+		{"two days long", weekend, at(19, 11, 59), true},
+		{"after two days", weekend, at(19, 12, 0), false},
+	} {
+		if err := tt.w.Validate(); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := tt.w.active(tt.t); got != tt.want {
+			t.Errorf("%s: active at %s = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestEngineSilenced(t *testing.T) {
+	rule, err := ParseRule("cpu.used_percent > 50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewSilenceStore(filepath.Join(t.TempDir(), "silences.jsonl"))
+	e := NewEngine([]Rule{rule}, WithSilencer(NewSilences(store, nil)))
+	stats := func(idle float32) types.Stats {
+		var s types.Stats
+		s.CPU.Idle = idle
+		s.CPURaw.Total = 100
+		return s
+	}
+
+	now := time.Now()
+	if err := store.Add(Silence{Match: "db-*", Until: now.Add(time.Hour), Created: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(Silence{Match: "web-*", Until: now.Add(-time.Minute), Created: now.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		host     string
+		silenced bool
+	}{
+		{"db-1", true},
+		{"web-1", false},
+	} {
+		fired := e.Check(tt.host, stats(10))
+		if len(fired) != 1 || fired[0].Silenced != tt.silenced {
+			t.Errorf("%s: fired %+v, want silenced %v", tt.host, fired, tt.silenced)
+		}
+		// the silenced alert is still active, so it resolves
+		resolved := e.Check(tt.host, stats(90))
+		if len(resolved) != 1 || !resolved[0].Resolved {
+			t.Errorf("%s: got %+v, want it resolved", tt.host, resolved)
+		}
+	}
+	if n := e.Fired(); n != 1 {
+		t.Errorf("fired %d alerts, want only the one not silenced", n)
+	}
+
+	e = NewEngine([]Rule{rule}, WithSilencer(NewSilences(nil, []Window{{Match: "cpu.used_percent  >  50", Start: "00:00", Duration: 24 * time.Hour}})))
+	if ev := e.Check("web-1", stats(10)); len(ev) != 1 || !ev[0].Silenced {
+		t.Errorf("rule window: got %+v, want it silenced", ev)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package alert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is a fired or resolved alert as kept in the store.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host"`
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Formatted string    `json:"formatted"`
+	State     string    `json:"state"` // firing or resolved
+	Silenced  bool      `json:"silenced,omitempty"`
+}
+
+// NewRecord returns the record of an event which happened at t.
+func NewRecord(e Event, t time.Time) Record {
+	state := "firing"
+	if e.Resolved {
+		state = "resolved"
+	}
+	return Record{
+		Time:      t,
+		Host:      e.Host,
+		Rule:      e.Rule.String(),
+		Metric:    e.Metric,
+		Value:     e.Value,
+		Formatted: e.FormatValue(),
+		State:     state,
+		Silenced:  e.Silenced,
+	}
+}
+
+// Store keeps the alert history in a file of JSON lines, one record per
+// line, appended to by every rtop process using it.
+type Store struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewStore returns the store kept in the file at path, which is created
+// along with its directory on the first record.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Add appends a record to the store.
+func (s *Store) Add(r Record) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write per line, so that lines of concurrent processes do
+	// not interleave
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Since returns the records at or after t, oldest first. A store without
+// a file has no records.
+func (s *Store) Since(t time.Time) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", s.path, n, err)
+		}
+		if !r.Time.Before(t) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+// ParseSince parses the start of a period of the history, given as a
+// duration back from now, e.g. 24h, or as an RFC 3339 time.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q, expected a duration like 24h or an RFC 3339 time", s)
+}
@@ -0,0 +1,191 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package bot answers questions about the monitored hosts in Slack and
+// Discord.
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Host is a host the bot may report on, identified by name, whose stats are
+// collected using the given function.
+type Host struct {
+	Name     string
+	GetStats func(context.Context) (types.Stats, error)
+}
+
+// host serializes the collections of a host, as rates are computed from
+// the previous one.
+type host struct {
+	Host
+	mu sync.Mutex
+}
+
+// collectTimeout bounds a collection triggered by a chat message.
+const collectTimeout = 30 * time.Second
+
+// Bot answers chat commands about the hosts. Only the hosts it is given
+// can be asked about. The commands are:
+//
+//	status <host>  a summary of the stats of the host
+//	hosts          the hosts which can be asked about
+//	help           the commands
+type Bot struct {
+	hosts []*host
+}
+
+// New returns a bot for the hosts.
+func New(hosts []Host) *Bot {
+	b := &Bot{}
+	for _, h := range hosts {
+		b.hosts = append(b.hosts, &host{Host: h})
+	}
+	return b
+}
+
+// Reply returns the answer to a chat message, in markdown understood by
+// both Slack and Discord.
+func (b *Bot) Reply(ctx context.Context, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return helpText
+	}
+	switch strings.ToLower(fields[0]) {
+	case "status":
+		if len(fields) != 2 {
+			return "usage: status <host>"
+		}
+		h := b.lookup(fields[1])
+		if h == nil {
+			return fmt.Sprintf("unknown host %s, try hosts", fields[1])
+		}
+		ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+		defer cancel()
+		h.mu.Lock()
+		stats, err := h.GetStats(ctx)
+		h.mu.Unlock()
+		if err != nil {
+			return fmt.Sprintf("%s: %s", h.Name, err)
+		}
+		return Summary(h.Name, stats)
+	case "hosts":
+		names := make([]string, 0, len(b.hosts))
+		for _, h := range b.hosts {
+			names = append(names, h.Name)
+		}
+		return strings.Join(names, "\n")
+	}
+	return helpText
+}
+
+const helpText = "commands: status <host>, hosts, help"
+
+// lookup returns the host with the given name, which may also leave out
+// the user and port of the target.
+func (b *Bot) lookup(name string) *host {
+	for _, h := range b.hosts {
+		if h.Name == name {
+			return h
+		}
+	}
+	for _, h := range b.hosts {
+		bare := h.Name
+		if i := strings.Index(bare, "@"); i != -1 {
+			bare = bare[i+1:]
+		}
+		if i := strings.LastIndex(bare, ":"); i != -1 {
+			bare = bare[:i]
+		}
+		if bare == name {
+			return h
+		}
+	}
+	return nil
+}
+
+// Summary formats the most important stats of a host as a code block.
+func Summary(name string, stats types.Stats) string {
+	var b bytes.Buffer
+	line := func(label, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%-6s %s\n", label, fmt.Sprintf(format, args...))
+	}
+
+	fmt.Fprintf(&b, "```\n%s (%s), up %s\n", name, stats.Hostname, tui.FormatUptime(stats.Uptime, tui.UptimeShort))
+	line("load", "%s %s %s, %s/%s procs running", stats.Loads.Load1, stats.Loads.Load5, stats.Loads.Load15,
+		stats.Loads.RunningProcs, stats.Loads.TotalProcs)
+	line("cpu", "%.1f%% used, %.1f%% iowait", 100-stats.CPU.Idle, stats.CPU.IOWait)
+	line("mem", "%s of %s used (%.0f%%)", fmtBytes(stats.MEM.Used()), fmtBytes(stats.MEM.Total),
+		percent(stats.MEM.Used(), stats.MEM.Total))
+	if stats.MEM.SwapTotal > 0 {
+		swapUsed := stats.MEM.SwapTotal - stats.MEM.SwapFree
+		line("swap", "%s of %s used, %.1f pages/s in, %.1f out", fmtBytes(swapUsed), fmtBytes(stats.MEM.SwapTotal),
+			stats.SwapActivity.InRate, stats.SwapActivity.OutRate)
+	}
+	for _, fs := range stats.FSInfos {
+		line("disk", "%s %.0f%% used, %s free", fs.MountPoint, percent(fs.Used, fs.Total), fmtBytes(fs.Free))
+	}
+	names := make([]string, 0, len(stats.NetInterface))
+	for n := range stats.NetInterface {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		ni := stats.NetInterface[n]
+		line("net", "%s rx %s/s, tx %s/s", n, fmtBytes(uint64(ni.RxRate)), fmtBytes(uint64(ni.TxRate)))
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+func percent(val, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(val) / float64(total) * 100
+}
+
+func fmtBytes(val uint64) string {
+	const unit = 1024
+	if val < unit {
+		return fmt.Sprintf("%d B", val)
+	}
+	div, exp := uint64(unit), 0
+	for n := val / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(val)/float64(div), "KMGTPE"[exp])
+}
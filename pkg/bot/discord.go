@@ -0,0 +1,175 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Discord answers the slash commands of a Discord application, received on
+// its interactions endpoint. Register a command per bot command, e.g.
+// status with a string option host, or a single rtop command with a
+// string option taking the whole command.
+type Discord struct {
+	bot       *Bot
+	publicKey ed25519.PublicKey
+	client    *http.Client
+	apiURL    string
+}
+
+// NewDiscord returns the handler of the interactions endpoint URL of the
+// application with the given hex encoded public key.
+func NewDiscord(bot *Bot, publicKey string) (*Discord, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid discord public key")
+	}
+	return &Discord{
+		bot:       bot,
+		publicKey: key,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		apiURL:    "https://discord.com/api/v10/",
+	}, nil
+}
+
+// Interaction types and responses, see
+// https://discord.com/developers/docs/interactions/receiving-and-responding.
+const (
+	interactionPing    = 1
+	interactionCommand = 2
+
+	responsePong     = 1
+	responseDeferred = 5
+)
+
+type discordInteraction struct {
+	Type          int    `json:"type"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"`
+	Data          struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Value interface{} `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+func (d *Discord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !d.verify(r.Header, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var in discordInteraction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch in.Type {
+	case interactionPing:
+		fmt.Fprintf(w, `{"type":%d}`, responsePong)
+	case interactionCommand:
+		// the response is due within 3 seconds, so defer it and edit it
+		// once the stats are in
+		fmt.Fprintf(w, `{"type":%d}`, responseDeferred)
+		go func() {
+			reply := d.bot.Reply(context.Background(), commandText(in))
+			if err := d.edit(in.ApplicationID, in.Token, reply); err != nil {
+				log.Printf("discord: reply to /%s: %s", in.Data.Name, err)
+			}
+		}()
+	default:
+		http.Error(w, "unsupported interaction", http.StatusBadRequest)
+	}
+}
+
+// commandText returns the bot command of a slash command: its name and
+// option values, with a leading rtop left out.
+func commandText(in discordInteraction) string {
+	var words []string
+	if in.Data.Name != "rtop" {
+		words = append(words, in.Data.Name)
+	}
+	for _, o := range in.Data.Options {
+		words = append(words, fmt.Sprint(o.Value))
+	}
+	return strings.Join(words, " ")
+}
+
+// verify checks the signature of a request with the public key of the
+// application.
+func (d *Discord) verify(h http.Header, body []byte) bool {
+	sig, err := hex.DecodeString(h.Get("X-Signature-Ed25519"))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	msg := append([]byte(h.Get("X-Signature-Timestamp")), body...)
+	return ed25519.Verify(d.publicKey, msg, sig)
+}
+
+// edit replaces the deferred response of an interaction with the reply.
+func (d *Discord) edit(appID, token, content string) error {
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%swebhooks/%s/%s/messages/@original", d.apiURL, appID, token)
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("edit response: %s", resp.Status)
+	}
+	return nil
+}
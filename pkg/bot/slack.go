@@ -0,0 +1,195 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// maxBody is the largest request body accepted from the chat services.
+const maxBody = 1 << 20
+
+// maxRequestAge is how old a signed request may be, to limit replays.
+const maxRequestAge = 5 * time.Minute
+
+// Slack receives the messages mentioning the bot, or sent to it directly,
+// from the Slack Events API and replies in the same channel and thread.
+// The app needs the app_mentions:read, im:history and chat:write scopes.
+type Slack struct {
+	bot           *Bot
+	signingSecret []byte
+	token         string
+	client        *http.Client
+	apiURL        string
+}
+
+// NewSlack returns the handler of the Slack Events API request URL. The
+// signing secret verifies the requests, the bot token is used to reply.
+func NewSlack(bot *Bot, signingSecret, token string) *Slack {
+	return &Slack{
+		bot:           bot,
+		signingSecret: []byte(signingSecret),
+		token:         token,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		apiURL:        "https://slack.com/api/",
+	}
+}
+
+// slackEvent is an event callback or URL verification request.
+type slackEvent struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+		BotID       string `json:"bot_id"`
+		Subtype     string `json:"subtype"`
+	} `json:"event"`
+}
+
+// slackMention matches mentions of users, such as the bot, in messages.
+var slackMention = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+func (s *Slack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.verify(r.Header, body, time.Now()) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var ev slackEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch ev.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, ev.Challenge)
+		return
+	case "event_callback":
+	default:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Slack retries events not acknowledged within 3 seconds, so reply
+	// after acknowledging, and ignore the retries of slow replies
+	w.WriteHeader(http.StatusOK)
+	e := ev.Event
+	if r.Header.Get("X-Slack-Retry-Num") != "" || e.BotID != "" || e.Subtype != "" {
+		return
+	}
+	if e.Type != "app_mention" && !(e.Type == "message" && e.ChannelType == "im") {
+		return
+	}
+	thread := e.ThreadTS
+	if thread == "" && e.Type == "app_mention" {
+		thread = e.TS
+	}
+	go func() {
+		reply := s.bot.Reply(context.Background(), slackMention.ReplaceAllString(e.Text, ""))
+		if err := s.post(e.Channel, thread, reply); err != nil {
+			log.Printf("slack: reply in %s: %s", e.Channel, err)
+		}
+	}()
+}
+
+// verify checks the signature of a request, see
+// https://api.slack.com/authentication/verifying-requests-from-slack.
+func (s *Slack) verify(h http.Header, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(h.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.signingSecret)
+	fmt.Fprintf(mac, "v0:%d:", ts)
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(h.Get("X-Slack-Signature")))
+}
+
+// post sends a message to the channel, in the thread if not empty.
+func (s *Slack) post(channel, thread, text string) error {
+	msg := map[string]string{"channel": channel, "text": text}
+	if thread != "" {
+		msg["thread_ts"] = thread
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.apiURL+"chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("chat.postMessage: %s", resp.Status)
+	}
+	if !res.OK {
+		return fmt.Errorf("chat.postMessage: %s", res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package budget tracks the data transferred over the network interfaces of
+// a host per day and month, for hosts on metered links.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Period is the period a budget applies to.
+type Period string
+
+const (
+	Day   Period = "day"
+	Month Period = "month"
+)
+
+// Budget limits the bytes received and transmitted over an interface, or
+// over all interfaces but loopback if Interface is empty, in a period.
+type Budget struct {
+	Interface string
+	Period    Period
+	Limit     uint64
+}
+
+// Parse parses a budget given as [interface:]period:size, e.g.
+// "wwan0:month:20GB" or "day:500MiB".
+func Parse(s string) (Budget, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		parts = append([]string{""}, parts...)
+	}
+	if len(parts) != 3 {
+		return Budget{}, fmt.Errorf("invalid budget %q, expected [interface:]day|month:size", s)
+	}
+
+	b := Budget{Interface: parts[0], Period: Period(parts[1])}
+	if b.Period != Day && b.Period != Month {
+		return Budget{}, fmt.Errorf("invalid budget period %q, expected day or month", parts[1])
+	}
+	limit, err := ParseSize(parts[2])
+	if err != nil {
+		return Budget{}, err
+	}
+	if limit == 0 {
+		return Budget{}, fmt.Errorf("invalid budget %q, size must not be zero", s)
+	}
+	b.Limit = limit
+	return b, nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes with an optional decimal (KB, MB, GB,
+// TB) or binary (KiB, MiB, GiB, TiB) unit.
+func ParseSize(s string) (uint64, error) {
+	mult := uint64(1)
+	num := s
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			mult = u.size
+			num = strings.TrimSuffix(s, u.suffix)
+			break
+		}
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return uint64(val * float64(mult)), nil
+}
+
+// counters are the bytes transferred over an interface in a period.
+type counters struct {
+	Rx uint64 `json:"rx"`
+	Tx uint64 `json:"tx"`
+}
+
+// usage is what is persisted, keyed by period key, e.g. 2006-01-02 for
+// days and 2006-01 for months, and then by interface.
+type usage struct {
+	Days   map[string]map[string]counters `json:"days"`
+	Months map[string]map[string]counters `json:"months"`
+}
+
+// keepDays and keepMonths are how many days and months of usage are kept.
+const (
+	keepDays   = 62
+	keepMonths = 24
+)
+
+// Tracker accumulates the transfer of a host's interfaces from consecutive
+// stats and persists it in a file, so that it survives restarts.
+type Tracker struct {
+	path    string
+	budgets []Budget
+
+	mu    sync.Mutex
+	usage usage
+	prev  map[string]types.NetDevInfo
+	prevT time.Time
+}
+
+// NewTracker returns a tracker of the given budgets, keeping its data in
+// the file at path.
+func NewTracker(path string, budgets []Budget) (*Tracker, error) {
+	t := &Tracker{
+		path:    path,
+		budgets: budgets,
+		usage: usage{
+			Days:   make(map[string]map[string]counters),
+			Months: make(map[string]map[string]counters),
+		},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &t.usage); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return t, nil
+}
+
+// Update adds the transfer since the previous stats to the usage, saves it
+// and sets the state of each budget in the stats. The transfer before the
+// first stats is unknown and not counted.
+func (t *Tracker) Update(stats *types.Stats) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	if t.prev != nil {
+		maxWrap := types.MaxWrap(types.WrapByteRate, now.Sub(t.prevT).Seconds())
+		for name, info := range stats.NetInterface {
+			if info.Rx == 0 && info.Tx == 0 {
+				// no counters, e.g. the netdev collector failed
+				continue
+			}
+			prev, ok := t.prev[name]
+			if !ok {
+				continue
+			}
+			delta := counters{
+				Rx: types.CounterDelta(info.Rx, prev.Rx, maxWrap),
+				Tx: types.CounterDelta(info.Tx, prev.Tx, maxWrap),
+			}
+			add(t.usage.Days, day, name, delta)
+			add(t.usage.Months, month, name, delta)
+		}
+	}
+	if t.prev == nil {
+		t.prev = make(map[string]types.NetDevInfo, len(stats.NetInterface))
+	}
+	for name, info := range stats.NetInterface {
+		if info.Rx != 0 || info.Tx != 0 {
+			t.prev[name] = info.NetDevInfo
+		}
+	}
+	t.prevT = now
+
+	prune(t.usage.Days, now.AddDate(0, 0, -keepDays).Format("2006-01-02"))
+	prune(t.usage.Months, now.AddDate(0, -keepMonths, 0).Format("2006-01"))
+
+	stats.Budgets = stats.Budgets[:0]
+	for _, b := range t.budgets {
+		periods, key := t.usage.Days, day
+		if b.Period == Month {
+			periods, key = t.usage.Months, month
+		}
+		var used uint64
+		for name, c := range periods[key] {
+			if name == b.Interface || (b.Interface == "" && name != "lo") {
+				used += c.Rx + c.Tx
+			}
+		}
+		stats.Budgets = append(stats.Budgets, types.BudgetUsage{
+			Interface: b.Interface,
+			Period:    string(b.Period),
+			Used:      used,
+			Limit:     b.Limit,
+			Exceeded:  used > b.Limit,
+		})
+	}
+
+	return t.save()
+}
+
+func add(periods map[string]map[string]counters, key, name string, delta counters) {
+	if periods[key] == nil {
+		periods[key] = make(map[string]counters)
+	}
+	c := periods[key][name]
+	c.Rx += delta.Rx
+	c.Tx += delta.Tx
+	periods[key][name] = c
+}
+
+// prune drops the periods before the given key, which sort by date.
+func prune(periods map[string]map[string]counters, before string) {
+	for key := range periods {
+		if key < before {
+			delete(periods, key)
+		}
+	}
+}
+
+// save writes the usage to a temporary file first, so that it is never left
+// half written.
+func (t *Tracker) save() error {
+	b, err := json.Marshal(t.usage)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,64 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// motdCmd prints the message of the day shown on interactive logins, which
+// Debian and Ubuntu generate into /run/motd.dynamic. Either file may be
+// missing.
+const motdCmd = "cat /run/motd.dynamic /etc/motd 2>/dev/null; true"
+
+// Banner returns the authentication banner sent by the SSH server and the
+// message of the day of the host, separated by an empty line. The message
+// of the day is read on the first call only, as logging in would show it.
+func (c *Client) Banner(ctx context.Context) (string, error) {
+	c.bannerMu.Lock()
+	defer c.bannerMu.Unlock()
+	if !c.motdRead {
+		out, err := c.execute(ctx, motdCmd)
+		if err != nil {
+			return "", fmt.Errorf("execute %s: %s", motdCmd, err)
+		}
+		c.motd = strings.Trim(out, "\n")
+		c.motdRead = true
+	}
+
+	var parts []string
+	if c.sshBanner != nil {
+		if b := strings.TrimRight(c.sshBanner(), "\r\n"); b != "" {
+			parts = append(parts, b)
+		}
+	}
+	if c.motd != "" {
+		parts = append(parts, c.motd)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
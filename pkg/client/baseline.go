@@ -0,0 +1,96 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// packageCountCmd counts the installed packages using whichever package
+// manager is available.
+const packageCountCmd = `(dpkg-query -f '.\n' -W 2>/dev/null || rpm -qa 2>/dev/null || apk info 2>/dev/null) | wc -l`
+
+// GetKernelRelease returns the kernel release of the remote host.
+func (c *Client) GetKernelRelease(ctx context.Context) (string, error) {
+	release, err := c.execute(ctx, "uname -r")
+	if err != nil {
+		return "", fmt.Errorf("execute uname -r: %s", err)
+	}
+	return strings.TrimSpace(release), nil
+}
+
+// GetPackageCount returns the number of packages installed on the remote
+// host, as reported by dpkg, rpm or apk.
+func (c *Client) GetPackageCount(ctx context.Context) (int, error) {
+	out, err := c.execute(ctx, packageCountCmd)
+	if err != nil {
+		return 0, fmt.Errorf("execute package count: %s", err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected package count format: %s", out)
+	}
+	return count, nil
+}
+
+// GetBaseline captures the mounts, interface addresses, kernel release and
+// package count of the remote host.
+func (c *Client) GetBaseline(ctx context.Context) (types.Baseline, error) {
+	var err error
+	res := types.Baseline{
+		Time:   time.Now(),
+		Mounts: make(map[string]string),
+	}
+
+	if res.KernelRelease, err = c.GetKernelRelease(ctx); err != nil {
+		return types.Baseline{}, err
+	}
+	if res.Packages, err = c.GetPackageCount(ctx); err != nil {
+		return types.Baseline{}, err
+	}
+	if res.Interfaces, err = c.GetNetIPAddrs(ctx); err != nil {
+		return types.Baseline{}, err
+	}
+
+	fsInfos, err := c.GetFSInfos(ctx)
+	if err != nil {
+		return types.Baseline{}, err
+	}
+	for _, fs := range fsInfos {
+		res.Mounts[fs.MountPoint] = fs.Device
+		for _, m := range fs.OtherMounts {
+			res.Mounts[m] = fs.Device
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,141 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// batchCommands are the commands of the collectors run by GetStats which
+// are quick enough to run one after the other in a single session. The
+// clock offset is left out as it measures the round trip of its own
+// command, and so are commands which may wait, like pinging gateways.
+// Collectors turned off are left out too.
+func (c *Client) batchCommands() []string {
+	compat := c.commands()
+	cmds := []struct{ collector, cmd string }{
+		{"uptime", "/bin/cat /proc/uptime"},
+		{"hostname", compat.hostname},
+		{"load", "/bin/cat /proc/loadavg"},
+		{"mem", "/bin/cat /proc/meminfo"},
+		{"swap", "/bin/cat /proc/vmstat"},
+		{"fs", compat.df},
+		{"fs", dfInodesCmd},
+		{"fs", mountsCmd},
+		{"diskio", "/bin/cat /proc/diskstats"},
+		{"netip", compat.ipAddr},
+		{"netdev", "/bin/cat /proc/net/dev"},
+		{"cpu", "/bin/cat /proc/stat"},
+		{"sensors", sensorsCmd},
+		{"raid", mdstatCmd},
+		{"nfs", nfsCmd},
+		{"systemd", systemdCmd},
+		{"tasks", tasksCmd},
+		{"processes", processesCmd},
+	}
+	res := make([]string, 0, len(cmds))
+	for _, b := range cmds {
+		if !c.isOff(b.collector) {
+			res = append(res, b.cmd)
+		}
+	}
+	return res
+}
+
+// batchResult is the output of a command run as part of a batch.
+type batchResult struct {
+	out string
+	err error
+}
+
+type batchKey struct{}
+
+// execute runs the command on the remote host, unless it was already run as
+// part of the batch carried by ctx.
+func (c *Client) execute(ctx context.Context, cmd string) (string, error) {
+	if results, ok := ctx.Value(batchKey{}).(map[string]batchResult); ok {
+		if res, ok := results[cmd]; ok {
+			return res.out, res.err
+		}
+	}
+	return c.runner.Execute(ctx, cmd)
+}
+
+// withBatch runs all commands in a single session and returns a context
+// from which execute takes their output.
+func (c *Client) withBatch(ctx context.Context, cmds []string) (context.Context, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return ctx, err
+	}
+	marker := "--rtop-" + hex.EncodeToString(nonce)
+
+	// every command runs in a subshell, so that an exit only ends itself,
+	// and its output is followed by a newline, the marker, its index and
+	// its exit status
+	var script strings.Builder
+	for i, cmd := range cmds {
+		fmt.Fprintf(&script, "(%s\n); printf '\\n%s %d %%d\\n' $?\n", cmd, marker, i)
+	}
+	out, err := c.runner.Execute(ctx, script.String())
+	if err != nil {
+		return ctx, fmt.Errorf("execute batch: %s", err)
+	}
+
+	results := make(map[string]batchResult, len(cmds))
+	for {
+		i := strings.Index(out, "\n"+marker+" ")
+		if i < 0 {
+			break
+		}
+		output := out[:i]
+		out = out[i+len(marker)+2:]
+		line, rest, _ := strings.Cut(out, "\n")
+		out = rest
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return ctx, fmt.Errorf("execute batch: malformed marker %q", line)
+		}
+		idx, err1 := strconv.Atoi(fields[0])
+		status, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil || idx < 0 || idx >= len(cmds) {
+			return ctx, fmt.Errorf("execute batch: malformed marker %q", line)
+		}
+		res := batchResult{out: output}
+		if status != 0 {
+			res = batchResult{err: fmt.Errorf("Process exited with status %d", status)}
+		}
+		results[cmds[idx]] = res
+	}
+
+	return context.WithValue(ctx, batchKey{}, results), nil
+}
@@ -0,0 +1,147 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BackoffError is returned by GetStats instead of collecting while the
+// circuit breaker of WithCircuitBreaker is open, without contacting the
+// host.
+type BackoffError struct {
+	// Failures is the number of consecutive failed collections.
+	Failures int
+	// Until is when the next collection is tried.
+	Until time.Time
+	// Err is the error of the last failed collection.
+	Err error
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("backing off for %s after %d failures: %s",
+		time.Until(e.Until).Round(time.Second), e.Failures, e.Err)
+}
+
+func (e *BackoffError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAt returns when the next collection is tried.
+func (e *BackoffError) RetryAt() time.Time {
+	return e.Until
+}
+
+// breaker backs off collecting from a host which failed several times in a
+// row: after failures failed collections it stays open for backoff, which
+// doubles with every further failure up to maxBackoff. Once it has passed,
+// a single collection is tried; a success closes the breaker.
+type breaker struct {
+	failures   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	// failed counts the consecutive failed collections, lastErr is the
+	// error of the last one and openUntil is zero unless the breaker is open
+	failed    int
+	lastErr   error
+	openUntil time.Time
+}
+
+// allow returns a *BackoffError while the breaker is open.
+func (b *breaker) allow() error {
+	if b.openUntil.IsZero() || time.Now().After(b.openUntil) {
+		return nil
+	}
+	return &BackoffError{Failures: b.failed, Until: b.openUntil, Err: b.lastErr}
+}
+
+// record records the result of a collection, opening the breaker after too
+// many failures and closing it after a success. It returns the
+// *BackoffError of the breaker if it opened.
+func (b *breaker) record(err error) error {
+	if err == nil {
+		b.failed, b.lastErr, b.openUntil = 0, nil, time.Time{}
+		return nil
+	}
+	b.failed++
+	b.lastErr = err
+	if b.failed < b.failures {
+		return nil
+	}
+	backoff := b.backoff
+	for i := b.failures; i < b.failed && backoff < b.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > b.maxBackoff {
+		backoff = b.maxBackoff
+	}
+	b.openUntil = time.Now().Add(backoff)
+	return b.allow()
+}
+
+// checkBreaker returns a *BackoffError while the circuit breaker is open.
+func (c *Client) checkBreaker() error {
+	if c.breaker == nil {
+		return nil
+	}
+	c.breakerMu.Lock()
+	defer c.breakerMu.Unlock()
+	return c.breaker.allow()
+}
+
+// recordBreaker records whether a collection failed: it got no stats at all,
+// e.g. because the host cannot be reached, or it overran the collection
+// budget. It returns the error to report for the collection, which is a
+// *BackoffError once the breaker opened.
+func (c *Client) recordBreaker(failed bool, err error) error {
+	if c.breaker == nil {
+		return err
+	}
+	reported := err
+	if failed && err == nil {
+		err = fmt.Errorf("no stats collected")
+	}
+	if !failed {
+		err = nil
+	}
+	c.breakerMu.Lock()
+	defer c.breakerMu.Unlock()
+	if berr := c.breaker.record(err); berr != nil {
+		return berr
+	}
+	return reported
+}
+
+// withBudget bounds ctx by the collection budget, if any.
+func (c *Client) withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.budget <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.budget)
+}
@@ -0,0 +1,135 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// GetRedisInfo returns the memory usage, operations per second and keyspace
+// hit rate of the Redis server on the remote host.
+func (c *Client) GetRedisInfo(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, "redis-cli INFO")
+	if err != nil {
+		return nil, fmt.Errorf("execute redis-cli INFO: %s", err)
+	}
+
+	res := make(map[string]float64)
+	var hits, misses float64
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "used_memory":
+			res["redis.used_memory_bytes"] = val
+		case "instantaneous_ops_per_sec":
+			res["redis.ops_per_second"] = val
+		case "connected_clients":
+			res["redis.connections"] = val
+		case "keyspace_hits":
+			hits = val
+		case "keyspace_misses":
+			misses = val
+		}
+	}
+	if hits+misses > 0 {
+		res["redis.hit_percent"] = hits / (hits + misses) * 100
+	}
+
+	return res, nil
+}
+
+const memcachedStatsCmd = `printf 'stats\r\nquit\r\n' | nc 127.0.0.1 11211`
+
+// GetMemcachedStats returns the memory usage, operations per second and get
+// hit rate of the memcached server on the remote host. The operations per
+// second are computed against the previous call.
+func (c *Client) GetMemcachedStats(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, memcachedStatsCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute memcached stats: %s", err)
+	}
+	now := time.Now()
+
+	res := make(map[string]float64)
+	var hits, misses, ops uint64
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 3 || parts[0] != "STAT" {
+			continue
+		}
+		val, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch parts[1] {
+		case "bytes":
+			res["memcached.used_memory_bytes"] = float64(val)
+		case "curr_connections":
+			res["memcached.connections"] = float64(val)
+		case "get_hits":
+			hits = val
+		case "get_misses":
+			misses = val
+		case "cmd_get", "cmd_set":
+			ops += val
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("unexpected memcached stats format: %s", lines)
+	}
+	if hits+misses > 0 {
+		res["memcached.hit_percent"] = float64(hits) / float64(hits+misses) * 100
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if secs := now.Sub(c.prevMemcachedT).Seconds(); !c.prevMemcachedT.IsZero() && secs > 0 {
+		res["memcached.ops_per_second"] = rate(ops, c.prevMemcachedOps, secs, types.WrapOpRate)
+	}
+	c.prevMemcachedOps = ops
+	c.prevMemcachedT = now
+
+	return res, nil
+}
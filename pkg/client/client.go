@@ -32,6 +32,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/semgroup"
@@ -40,9 +41,95 @@ import (
 )
 
 type Client struct {
-	// sshClient is the ssh client to use for executing commands on the remote host
-	sshClient *ssh.Client
-	workers   int
+	// runner executes the commands on the monitored host, over ssh or locally
+	runner  runner
+	workers int
+	extra   map[string]extraCollector
+
+	listenSockets []string
+	cloudMetadata bool
+	processes     int
+	routes        bool
+	fsProbe       []string
+	fsFilter      FSFilter
+	batch         bool
+
+	// mu guards the previous samples used for computing rates
+	mu        sync.Mutex
+	prevSwap  types.SwapActivity
+	prevSwapT time.Time
+
+	prevCPU map[string]types.CPURaw
+
+	prevDiskIO  map[string]types.DiskIORaw
+	prevDiskIOT time.Time
+
+	prevNetDev  map[string]types.NetDevInfo
+	prevNetDevT time.Time
+
+	prevNFS  *types.NFS
+	prevNFST time.Time
+
+	prevMemcachedOps uint64
+	prevMemcachedT   time.Time
+
+	// shellOK is set once the login shell ran the shell probe
+	shellOK bool
+	// osName is the remote operating system, once known
+	osName string
+	// compat is the compatibility mode, resolved from CompatAuto once
+	// detected if autoCompat is set
+	compat     Compat
+	autoCompat bool
+	// disabled holds the collectors skipped after the preflight, with the
+	// reason; it is nil until the preflight ran
+	disabled map[string]string
+
+	prevJVMGCTimes map[string]float64
+	prevJVMT       time.Time
+
+	// onFingerprint is called once with the detection results, unless they
+	// were given with WithFingerprint, which sets fingerprinted
+	onFingerprint func(Fingerprint)
+	fingerprinted bool
+
+	// off holds the collectors turned off with WithoutCollectors or
+	// SetCollector, guarded by mu
+	off map[string]bool
+
+	// labels are added to the labels of every stats
+	labels map[string]string
+
+	// cloud caches the cloud metadata labels
+	cloudMu sync.Mutex
+	cloud   map[string]string
+
+	// sshBanner returns the banner of the SSH server and sshClose closes
+	// the connection, both nil for local monitoring, and motd caches the
+	// message of the day once read
+	sshBanner func() string
+	sshClose  func() error
+	bannerMu  sync.Mutex
+	motd      string
+	motdRead  bool
+
+	// metricsMu guards the self-metrics returned by Metrics and the
+	// collectors which produced nothing in the current collection
+	metricsMu        sync.Mutex
+	collectorMetrics map[string]CollectorMetrics
+	missing          map[string]string
+
+	// floorMu guards the interval floor: the next time GetStats may start
+	// collecting, and the time the last collection took
+	floorMu    sync.Mutex
+	nextStart  time.Time
+	collection time.Duration
+
+	// budget bounds every collection, and breakerMu guards the circuit
+	// breaker, nil without WithCircuitBreaker
+	budget    time.Duration
+	breakerMu sync.Mutex
+	breaker   *breaker
 }
 
 func New(opts ...Option) (*Client, error) {
@@ -55,88 +142,397 @@ func New(opts ...Option) (*Client, error) {
 	if o.workers == 0 {
 		o.workers = runtime.NumCPU()
 	}
+	if o.nice {
+		o.workers = 1
+	}
 
-	sshClient, err := ssh.NewClient(o.user, o.host, o.port, o.keypath, o.sshClient)
-	if err != nil {
+	if o.compat == "" {
+		o.compat = CompatAuto
+	}
+
+	if len(o.listenSockets) > 0 {
+		o.collectors = append(o.collectors, "listen")
+	}
+
+	// every optional collector is set up, and turned off unless enabled,
+	// so that SetCollector can turn it on
+	off := make(map[string]bool)
+	extra := make(map[string]extraCollector, len(extraCollectors))
+	for name, collector := range extraCollectors {
+		extra[name] = collector
+		off[name] = true
+	}
+	for _, name := range optionalCollectors {
+		off[name] = true
+	}
+	for _, name := range o.collectors {
+		if _, ok := extraCollectors[name]; !ok && !isOptional(name) {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		delete(off, name)
+	}
+	if o.processes == 0 {
+		o.processes = DefaultProcesses
+		off["processes"] = true
+	}
+	if !o.routes {
+		o.routes = true
+		off["routes"] = true
+	}
+	if !o.cloudMetadata {
+		o.cloudMetadata = true
+		off["cloud"] = true
+	}
+	for _, name := range o.skip {
+		if !isCollector(name) {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		off[name] = true
+	}
+	if err := o.fsFilter.validate(); err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		sshClient: sshClient,
-		workers:   o.workers,
-	}, nil
+	var r runner = &localRunner{}
+	var sshBanner func() string
+	var sshClose func() error
+	if !o.local {
+		sshClient, err := ssh.NewClient(o.user, o.host, o.port, o.keypath, o.hostKey, o.sshClient)
+		if err != nil {
+			return nil, err
+		}
+		r = sshClient
+		sshBanner = sshClient.Banner
+		sshClose = sshClient.Close
+	}
+	r = wrapCommands(r, o.nice, o.wrapper)
+	if o.timeout > 0 {
+		r = &timeoutRunner{runner: r, timeout: o.timeout, remote: !o.local}
+	}
+
+	c := &Client{
+		runner:        r,
+		workers:       o.workers,
+		extra:         extra,
+		listenSockets: o.listenSockets,
+		cloudMetadata: o.cloudMetadata,
+		processes:     o.processes,
+		fsProbe:       o.fsProbe,
+		fsFilter:      o.fsFilter,
+		batch:         o.batch,
+		routes:        o.routes,
+		compat:        o.compat,
+		labels:        o.labels,
+		off:           off,
+		sshBanner:     sshBanner,
+		sshClose:      sshClose,
+		autoCompat:    o.compat == CompatAuto,
+		onFingerprint: o.onFingerprint,
+		budget:        o.budget,
+	}
+	if o.breakerFailures > 0 {
+		c.breaker = &breaker{
+			failures:   o.breakerFailures,
+			backoff:    o.breakerBackoff,
+			maxBackoff: o.breakerMaxBackoff,
+		}
+	}
+	if o.fingerprint != nil {
+		c.seed(*o.fingerprint)
+	}
+	return c, nil
+}
+
+// GetStats runs all collectors concurrently and returns their combined
+// stats. FreeBSD hosts, detected on the first call, get their own set of
+// collectors. With WithBatch, the commands of the core collectors are sent
+// as a single command first. Remote commands still running when ctx is done
+// are aborted.
+//
+// So that a short interval cannot overload a slow host, GetStats waits
+// until IntervalFloorFactor times the duration of the previous collection
+// which got any stats has passed since it started. With WithCircuitBreaker,
+// it returns a *BackoffError without collecting while backing off from a
+// host failing repeatedly.
+func (c *Client) GetStats(ctx context.Context) (types.Stats, error) {
+	if err := c.checkBreaker(); err != nil {
+		return types.Stats{}, err
+	}
+	if err := c.waitFloor(ctx); err != nil {
+		return types.Stats{}, err
+	}
+	start := time.Now()
+	bctx, cancel := c.withBudget(ctx)
+	stats, err := c.getStats(bctx)
+	cancel()
+	overBudget := c.budget > 0 && bctx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+	if overBudget {
+		err = fmt.Errorf("collection budget of %s exceeded: %v", c.budget, err)
+	}
+	err = c.recordBreaker(stats.Hostname == "" || overBudget, err)
+	c.reportFingerprint()
+	if stats.Hostname == "" {
+		// a failed collection, e.g. timing out on a lost connection, says
+		// little about how long collecting takes
+		return stats, err
+	}
+	took := time.Since(start)
+	stats.Meta.Collection = took
+	stats.Meta.IntervalFloor = c.setFloor(start, took)
+	return stats, err
+}
+
+// Close closes the ssh connection to the host, after which GetStats fails.
+// It does nothing for local monitoring.
+func (c *Client) Close() error {
+	if c.sshClose == nil {
+		return nil
+	}
+	return c.sshClose()
 }
 
-func (c *Client) GetStats() (types.Stats, error) {
-	s := semgroup.NewGroup(context.Background(), int64(c.workers))
+func (c *Client) getStats(ctx context.Context) (types.Stats, error) {
+	if err := c.CheckShell(ctx); err != nil {
+		return types.Stats{}, err
+	}
+	c.metricsMu.Lock()
+	c.missing = make(map[string]string)
+	c.metricsMu.Unlock()
+	if osName, err := c.GetOS(ctx); err == nil && osName == "FreeBSD" {
+		return c.getFreeBSDStats(ctx)
+	}
+	c.GetCompat(ctx)
+	c.preflight(ctx)
+
+	before := c.runner.Timings()
+	if c.batch {
+		// without the batch the collectors run their commands themselves
+		c.measure("batch", func() error {
+			var err error
+			ctx, err = c.withBatch(ctx, c.batchCommands())
+			return err
+		})()
+	}
+	s := semgroup.NewGroup(ctx, int64(c.workers))
 
 	var uptime time.Duration
 	var hostname string
 	var loads types.Loads
 	var mem types.MemInfo
+	var swap types.SwapActivity
 	var cpu types.CPUInfo
+	var cores []types.CPUInfo
+	var cpuRaw types.CPURaw
 	var fsInfos []types.FSInfo
+	var diskIO []types.DiskIO
+	var fsLatency []types.FSLatency
+	var sensors []types.Sensor
+	var gpus []types.GPU
+	var raid []types.RAIDInfo
+	var zpools []types.ZPool
+	var nfs *types.NFS
 	var netIpAddrs map[string]types.NetIPAddr
 	var netDevInfos map[string]types.NetDevInfo
+	var meta types.Meta
+	var labels map[string]string
+	var procs []types.Process
+	var procSummary *types.ProcessSummary
+	var routes *types.Routes
+	var systemd *types.Systemd
+	var tasks *types.Tasks
+	var extraMu sync.Mutex
+	extra := make(map[string]float64)
+	extraUnits := make(map[string]types.Unit)
 
-	s.Go(func() error {
+	s.Go(c.measure("uptime", func() error {
+		var err error
+		uptime, err = c.GetUptime(ctx)
+		return err
+	}))
+	s.Go(c.measure("hostname", func() error {
+		var err error
+		hostname, err = c.GetHostname(ctx)
+		return err
+	}))
+	s.Go(c.measure("load", func() error {
+		var err error
+		loads, err = c.GetLoad(ctx)
+		return err
+	}))
+	s.Go(c.measure("mem", func() error {
+		var err error
+		mem, err = c.GetMemInfo(ctx)
+		return err
+	}))
+	s.Go(c.measure("swap", func() error {
+		var err error
+		swap, err = c.GetSwapActivity(ctx)
+		return err
+	}))
+	s.Go(c.measure("fs", func() error {
+		var err error
+		fsInfos, err = c.GetFSInfos(ctx)
+		return err
+	}))
+	s.Go(c.measure("diskio", func() error {
+		var err error
+		diskIO, err = c.GetDiskIO(ctx)
+		return err
+	}))
+	s.Go(c.measure("sensors", func() error {
 		var err error
-		uptime, err = c.GetUptime()
+		sensors, err = c.GetSensors(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("gpu", func() error {
 		var err error
-		hostname, err = c.GetHostname()
+		gpus, err = c.GetGPUs(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("zfs", func() error {
 		var err error
-		loads, err = c.GetLoad()
+		zpools, err = c.GetZPools(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("nfs", func() error {
 		var err error
-		mem, err = c.GetMemInfo()
+		nfs, err = c.GetNFS(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("raid", func() error {
 		var err error
-		fsInfos, err = c.GetFSInfos()
+		raid, err = c.GetRAID(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("tasks", func() error {
 		var err error
-		netIpAddrs, err = c.GetNetIPAddrs()
+		tasks, err = c.GetTasks(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("systemd", func() error {
 		var err error
-		netDevInfos, err = c.GetNetDevInfos()
+		systemd, err = c.GetSystemdStatus(ctx)
 		return err
-	})
-	s.Go(func() error {
+	}))
+	s.Go(c.measure("netip", func() error {
 		var err error
-		cpu, err = c.GetCPU()
+		netIpAddrs, err = c.GetNetIPAddrs(ctx)
 		return err
-	})
+	}))
+	s.Go(c.measure("netdev", func() error {
+		var err error
+		netDevInfos, err = c.GetNetDevInfos(ctx)
+		return err
+	}))
+	s.Go(c.measure("cpu", func() error {
+		var coreRaws []types.CPURaw
+		var err error
+		cpuRaw, coreRaws, err = c.GetCPUTimes(ctx)
+		if err != nil {
+			return err
+		}
+		cpu = c.cpuRate(cpuRaw)
+		cores = c.cpuRates(coreRaws)
+		return nil
+	}))
+	s.Go(c.measure("clock", func() error {
+		var err error
+		meta.ClockOffset, meta.Latency, err = c.GetClockOffset(ctx)
+		return err
+	}))
+
+	if c.processes > 0 {
+		s.Go(c.measure("processes", func() error {
+			var err error
+			procs, procSummary, err = c.GetProcesses(ctx, c.processes)
+			return err
+		}))
+	}
+	if c.routes {
+		s.Go(c.measure("routes", func() error {
+			res, err := c.GetRoutes(ctx)
+			if err == nil {
+				routes = &res
+			}
+			return err
+		}))
+	}
+	if len(c.fsProbe) > 0 {
+		s.Go(c.measure("fsprobe", func() error {
+			var err error
+			fsLatency, err = c.GetFSLatency(ctx, c.fsProbe)
+			return err
+		}))
+	}
+	if c.cloudMetadata {
+		s.Go(c.measure("cloud", func() error {
+			var err error
+			labels, err = c.cloudLabels(ctx)
+			return err
+		}))
+	}
+	for name, collector := range c.extra {
+		collector := collector
+		s.Go(c.measure(name, func() error {
+			metrics, err := collector.collect(c, ctx)
+			extraMu.Lock()
+			for k, v := range metrics {
+				extra[k] = v
+				if u := collector.unit(k); u != types.UnitNone {
+					extraUnits[k] = u
+				}
+			}
+			extraMu.Unlock()
+			return err
+		}))
+	}
 
 	err := s.Wait()
 
+	after := c.runner.Timings()
+	meta.Reconnects = after.Reconnects
+	meta.Disabled = c.disabledCollectors()
+	meta.Missing = c.missingCollectors()
+	if n := after.Commands - before.Commands; n > 0 {
+		meta.SessionOpen = (after.SessionOpen - before.SessionOpen) / time.Duration(n)
+		meta.CommandRTT = (after.RoundTrip - before.RoundTrip) / time.Duration(n)
+	}
+
 	netInterface := types.MergeNetInterfaces(netIpAddrs, netDevInfos)
+	c.netRates(netInterface, time.Now())
 
 	return types.Stats{
-		Uptime:       uptime,
-		Hostname:     hostname,
-		Loads:        loads,
-		CPU:          cpu,
-		MEM:          mem,
-		FSInfos:      fsInfos,
-		NetInterface: netInterface,
+		Uptime:         uptime,
+		Hostname:       hostname,
+		Loads:          loads,
+		CPU:            cpu,
+		CPURaw:         cpuRaw,
+		Cores:          cores,
+		MEM:            mem,
+		SwapActivity:   swap,
+		FSInfos:        fsInfos,
+		FSLatency:      fsLatency,
+		DiskIO:         diskIO,
+		Sensors:        sensors,
+		GPUs:           gpus,
+		RAID:           raid,
+		ZFS:            zpools,
+		NFS:            nfs,
+		NetInterface:   netInterface,
+		Routes:         routes,
+		Systemd:        systemd,
+		Extra:          extra,
+		ExtraUnits:     extraUnits,
+		Processes:      procs,
+		ProcessSummary: procSummary,
+		Tasks:          tasks,
+		Labels:         c.mergeLabels(labels),
+		Meta:           meta,
 	}, err
 }
 
-func (c *Client) GetUptime() (time.Duration, error) {
-	uptime, err := c.sshClient.Execute("/bin/cat /proc/uptime")
+func (c *Client) GetUptime(ctx context.Context) (time.Duration, error) {
+	uptime, err := c.execute(ctx, "/bin/cat /proc/uptime")
 	if err != nil {
 		return 0, fmt.Errorf("execute /bin/cat /proc/uptime: %s", err)
 	}
@@ -154,20 +550,51 @@ func (c *Client) GetUptime() (time.Duration, error) {
 	return 0, fmt.Errorf("unexpected uptime format: %s", uptime)
 }
 
-func (c *Client) GetHostname() (string, error) {
-	hostname, err := c.sshClient.Execute("/bin/hostname -f")
+func (c *Client) GetHostname(ctx context.Context) (string, error) {
+	hostname, err := c.execute(ctx, c.commands().hostname)
 	if err != nil {
-		hostname, err = c.sshClient.Execute("/bin/hostname")
+		hostname, err = c.execute(ctx, "/bin/hostname")
 		if err != nil {
-			return "", fmt.Errorf("execute /bin/hostname: %s", err)
+			hostname, err = c.execute(ctx, "/bin/cat /proc/sys/kernel/hostname")
+			if err != nil {
+				return "", fmt.Errorf("execute /bin/hostname: %s", err)
+			}
 		}
 	}
 
 	return strings.TrimSpace(hostname), nil
 }
 
-func (c *Client) GetLoad() (types.Loads, error) {
-	line, err := c.sshClient.Execute("/bin/cat /proc/loadavg")
+// GetClockOffset compares the remote clock with the local one. It returns the
+// offset of the remote clock and the estimated one-way latency, assuming the
+// remote time was taken halfway through the round trip.
+func (c *Client) GetClockOffset(ctx context.Context) (time.Duration, time.Duration, error) {
+	start := time.Now()
+	out, err := c.execute(ctx, "/bin/date +%s%N")
+	if err != nil {
+		return 0, 0, fmt.Errorf("execute /bin/date: %s", err)
+	}
+	rtt := time.Since(start)
+
+	out = strings.TrimSpace(out)
+	nsecs, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		// date implementations without %N support print seconds only
+		secs, err := strconv.ParseInt(strings.TrimSuffix(out, "N"), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unexpected date format: %s", out)
+		}
+		nsecs = secs * int64(time.Second)
+	}
+
+	remote := time.Unix(0, nsecs)
+	local := start.Add(rtt / 2)
+
+	return remote.Sub(local), rtt / 2, nil
+}
+
+func (c *Client) GetLoad(ctx context.Context) (types.Loads, error) {
+	line, err := c.execute(ctx, "/bin/cat /proc/loadavg")
 	if err != nil {
 		return types.Loads{}, fmt.Errorf("execute /bin/cat /proc/loadavg: %s", err)
 	}
@@ -191,8 +618,8 @@ func (c *Client) GetLoad() (types.Loads, error) {
 	return types.Loads{}, fmt.Errorf("unexpected loadavg format: %s", line)
 }
 
-func (c *Client) GetMemInfo() (types.MemInfo, error) {
-	lines, err := c.sshClient.Execute("/bin/cat /proc/meminfo")
+func (c *Client) GetMemInfo(ctx context.Context) (types.MemInfo, error) {
+	lines, err := c.execute(ctx, "/bin/cat /proc/meminfo")
 	if err != nil {
 		return types.MemInfo{}, fmt.Errorf("execute /bin/cat /proc/meminfo: %s", err)
 	}
@@ -229,19 +656,133 @@ func (c *Client) GetMemInfo() (types.MemInfo, error) {
 	return res, nil
 }
 
-func (c *Client) GetFSInfos() ([]types.FSInfo, error) {
-	lines, err := c.sshClient.Execute("/bin/df -B1")
+// GetSwapActivity returns the pages swapped in and out, with the rates
+// computed against the previous call.
+func (c *Client) GetSwapActivity(ctx context.Context) (types.SwapActivity, error) {
+	lines, err := c.execute(ctx, "/bin/cat /proc/vmstat")
 	if err != nil {
-		lines, err = c.sshClient.Execute("/bin/df")
+		return types.SwapActivity{}, fmt.Errorf("execute /bin/cat /proc/vmstat: %s", err)
+	}
+	now := time.Now()
+
+	var res types.SwapActivity
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		val, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch parts[0] {
+		case "pswpin":
+			res.PagesIn = val
+		case "pswpout":
+			res.PagesOut = val
+		}
+	}
+
+	return c.swapRates(res, now), nil
+}
+
+// swapRates fills in the swap rates since the previous sample.
+func (c *Client) swapRates(res types.SwapActivity, now time.Time) types.SwapActivity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.prevSwapT.IsZero() {
+		secs := now.Sub(c.prevSwapT).Seconds()
+		if secs > 0 {
+			res.InRate = rate(res.PagesIn, c.prevSwap.PagesIn, secs, types.WrapOpRate)
+			res.OutRate = rate(res.PagesOut, c.prevSwap.PagesOut, secs, types.WrapOpRate)
+		}
+	}
+	c.prevSwap = res
+	c.prevSwapT = now
+	return res
+}
+
+// netRates sets the receive and transmit rates of the interfaces since the
+// previous call.
+func (c *Client) netRates(ifaces map[string]types.NetInterface, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := make(map[string]types.NetDevInfo, len(ifaces))
+	secs := now.Sub(c.prevNetDevT).Seconds()
+	for name, info := range ifaces {
+		if p, ok := c.prevNetDev[name]; ok && secs > 0 {
+			info.RxRate = rate(info.Rx, p.Rx, secs, types.WrapByteRate)
+			info.TxRate = rate(info.Tx, p.Tx, secs, types.WrapByteRate)
+			ifaces[name] = info
+		}
+		prev[name] = info.NetDevInfo
+	}
+	c.prevNetDev = prev
+	c.prevNetDevT = now
+}
+
+// rate returns the per second rate of a counter, which grows by at most
+// wrapRate per second if it wraps around at 32 bits; see
+// types.CounterDelta.
+func rate(cur, prev uint64, secs, wrapRate float64) float64 {
+	return float64(types.CounterDelta(cur, prev, types.MaxWrap(wrapRate, secs))) / secs
+}
+
+func (c *Client) GetFSInfos(ctx context.Context) ([]types.FSInfo, error) {
+	cmds := c.commands()
+	lines, err := c.execute(ctx, cmds.df)
+	if err != nil {
+		cmds = busyBoxCommands
+		lines, err = c.execute(ctx, cmds.df)
 		if err != nil {
 			return nil, fmt.Errorf("execute /bin/df: %s", err)
 		}
 	}
 
+	fsInfos := parseDF(lines, cmds.dfBlockSize)
+
+	// inodes are optional, df -i is missing from minimal BusyBox builds
+	if lines, err := c.execute(ctx, dfInodesCmd); err == nil {
+		addInodes(fsInfos, parseDF(lines, 1))
+	}
+	if lines, err := c.execute(ctx, mountsCmd); err == nil {
+		addFSTypes(fsInfos, lines)
+	}
+
+	return c.fsFilter.apply(fsInfos), nil
+}
+
+// dfInodesCmd lists the inode counts in place of the block counts.
+const dfInodesCmd = "/bin/df -i"
+
+// addInodes sets the inode counts of the filesystems from the output of
+// df -i, parsed as if the counts were blocks of one byte.
+func addInodes(fsInfos, inodes []types.FSInfo) {
+	byMount := make(map[string]types.FSInfo, len(inodes))
+	for _, fs := range inodes {
+		byMount[fs.MountPoint] = fs
+	}
+	for i, fs := range fsInfos {
+		if in, ok := byMount[fs.MountPoint]; ok {
+			fsInfos[i].InodesTotal = in.Total
+			fsInfos[i].InodesUsed = in.Used
+			fsInfos[i].InodesFree = in.Free
+		}
+	}
+}
+
+// parseDF parses the output of df, whose sizes are in blocks of the given
+// size in bytes.
+func parseDF(lines string, blockSize uint64) []types.FSInfo {
 	var res []types.FSInfo
 
 	scanner := bufio.NewScanner(strings.NewReader(lines))
 	flag := 0
+	var device string
 	for scanner.Scan() {
 		line := scanner.Text()
 		parts := strings.Fields(line)
@@ -249,9 +790,16 @@ func (c *Client) GetFSInfos() ([]types.FSInfo, error) {
 		dev := n > 0 && strings.Index(parts[0], "/dev/") == 0
 		if n == 1 && dev {
 			flag = 1
+			device = parts[0]
 		} else {
 			i := flag
 			flag = 0
+			if n < 6-i {
+				continue
+			}
+			if i == 0 {
+				device = parts[0]
+			}
 			total, err := strconv.ParseUint(parts[1-i], 10, 64)
 			if err != nil {
 				continue
@@ -265,25 +813,52 @@ func (c *Client) GetFSInfos() ([]types.FSInfo, error) {
 				continue
 			}
 			res = append(res, types.FSInfo{
+				Device:     device,
 				MountPoint: parts[5-i],
-				Total:      total,
-				Used:       used,
-				Free:       free,
+				Total:      total * blockSize,
+				Used:       used * blockSize,
+				Free:       free * blockSize,
 			})
 		}
 	}
 
-	return res, nil
+	return dedupFSInfos(res)
+}
+
+// dedupFSInfos merges filesystems backed by the same device, such as bind
+// mounts and loop devices mounted more than once, into the first one listed.
+// The other mount points are kept in OtherMounts. Pseudo filesystems like
+// tmpfs share their device name without sharing storage, so only devices
+// given as paths are merged.
+func dedupFSInfos(fsInfos []types.FSInfo) []types.FSInfo {
+	res := make([]types.FSInfo, 0, len(fsInfos))
+	seen := make(map[string]int)
+
+	for _, fs := range fsInfos {
+		if strings.HasPrefix(fs.Device, "/") {
+			if i, ok := seen[fs.Device]; ok {
+				res[i].OtherMounts = append(res[i].OtherMounts, fs.MountPoint)
+				continue
+			}
+			seen[fs.Device] = len(res)
+		}
+		res = append(res, fs)
+	}
+
+	return res
 }
 
-func (c *Client) GetNetIPAddrs() (map[string]types.NetIPAddr, error) {
-	var lines string
-	lines, err := c.sshClient.Execute("/bin/ip -o addr")
+func (c *Client) GetNetIPAddrs(ctx context.Context) (map[string]types.NetIPAddr, error) {
+	cmd := c.commands().ipAddr
+	lines, err := c.execute(ctx, cmd)
+	if err != nil && cmd != busyBoxCommands.ipAddr {
+		lines, err = c.execute(ctx, busyBoxCommands.ipAddr)
+	}
 	if err != nil {
-		lines, err = c.sshClient.Execute("/sbin/ip -o addr")
-		if err != nil {
-			return nil, fmt.Errorf("execute /bin/ip -o addr: %s", err)
+		if addrs, err := c.getIfconfigAddrs(ctx); err == nil {
+			return addrs, nil
 		}
+		return nil, fmt.Errorf("execute %s: %s", cmd, err)
 	}
 
 	res := make(map[string]types.NetIPAddr)
@@ -317,8 +892,8 @@ func (c *Client) GetNetIPAddrs() (map[string]types.NetIPAddr, error) {
 	return res, nil
 }
 
-func (c *Client) GetNetDevInfos() (map[string]types.NetDevInfo, error) {
-	lines, err := c.sshClient.Execute("/bin/cat /proc/net/dev")
+func (c *Client) GetNetDevInfos(ctx context.Context) (map[string]types.NetDevInfo, error) {
+	lines, err := c.execute(ctx, "/bin/cat /proc/net/dev")
 	if err != nil {
 		return nil, fmt.Errorf("execute /bin/cat /proc/net/dev: %s", err)
 	}
@@ -350,37 +925,99 @@ func (c *Client) GetNetDevInfos() (map[string]types.NetDevInfo, error) {
 	return res, nil
 }
 
-func (c *Client) GetCPU() (types.CPUInfo, error) {
-	lines, err := c.sshClient.Execute("/bin/cat /proc/stat")
+// GetCPUTimes returns the cumulative CPU time counters of all cores together
+// and of each core, in the order listed in /proc/stat.
+func (c *Client) GetCPUTimes(ctx context.Context) (types.CPURaw, []types.CPURaw, error) {
+	lines, err := c.execute(ctx, "/bin/cat /proc/stat")
 	if err != nil {
-		return types.CPUInfo{}, fmt.Errorf("execute /bin/cat /proc/stat: %s", err)
+		return types.CPURaw{}, nil, fmt.Errorf("execute /bin/cat /proc/stat: %s", err)
 	}
 
-	var nowCPU types.CPURaw
-
+	var total types.CPURaw
+	var cores []types.CPURaw
 	scanner := bufio.NewScanner(strings.NewReader(lines))
 	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) > 0 && fields[0] == "cpu" { // changing here if want to get every cpu-core's stats
-			parseCPUFields(&nowCPU, fields)
-			break
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] == "cpu" {
+			parseCPUFields(&total, fields)
+			continue
 		}
+		raw := types.CPURaw{Core: fields[0]}
+		parseCPUFields(&raw, fields)
+		cores = append(cores, raw)
+	}
+	return total, cores, nil
+}
+
+// GetCPU returns the CPU usage since the previous call, or since boot on the
+// first call.
+func (c *Client) GetCPU(ctx context.Context) (types.CPUInfo, error) {
+	total, _, err := c.GetCPUTimes(ctx)
+	if err != nil {
+		return types.CPUInfo{}, err
+	}
+	return c.cpuRate(total), nil
+}
+
+// GetCPUCores returns the CPU usage of each core since the previous call, or
+// since boot on the first call.
+func (c *Client) GetCPUCores(ctx context.Context) ([]types.CPUInfo, error) {
+	_, cores, err := c.GetCPUTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.cpuRates(cores), nil
+}
+
+// cpuRate computes the CPU usage from the difference to the previous sample
+// of the same core. Without a usable previous sample, e.g. after a reboot,
+// the usage since boot is returned.
+func (c *Client) cpuRate(raw types.CPURaw) types.CPUInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.prevCPU == nil {
+		c.prevCPU = make(map[string]types.CPURaw)
 	}
+	prev, ok := c.prevCPU[raw.Core]
+	c.prevCPU[raw.Core] = raw
 
-	total := float32(nowCPU.Total)
+	info := cpuInfo(raw)
+	if ok && raw.Total > prev.Total {
+		info = cpuInfo(raw.Sub(prev))
+	}
+	info.Core = raw.Core
+	return info
+}
+
+func (c *Client) cpuRates(raws []types.CPURaw) []types.CPUInfo {
+	infos := make([]types.CPUInfo, 0, len(raws))
+	for _, raw := range raws {
+		infos = append(infos, c.cpuRate(raw))
+	}
+	return infos
+}
+
+func cpuInfo(raw types.CPURaw) types.CPUInfo {
+	total := float32(raw.Total)
+	if total == 0 {
+		return types.CPUInfo{}
+	}
 
 	return types.CPUInfo{
-		User:    float32(nowCPU.User) / total * 100,
-		Nice:    float32(nowCPU.Nice) / total * 100,
-		System:  float32(nowCPU.System) / total * 100,
-		Idle:    float32(nowCPU.Idle) / total * 100,
-		IOWait:  float32(nowCPU.Iowait) / total * 100,
-		IRQ:     float32(nowCPU.Irq) / total * 100,
-		SoftIRQ: float32(nowCPU.SoftIrq) / total * 100,
-		Steal:   float32(nowCPU.Steal) / total * 100,
-		Guest:   float32(nowCPU.Guest) / total * 100,
-	}, nil
+		User:    float32(raw.User) / total * 100,
+		Nice:    float32(raw.Nice) / total * 100,
+		System:  float32(raw.System) / total * 100,
+		Idle:    float32(raw.Idle) / total * 100,
+		IOWait:  float32(raw.Iowait) / total * 100,
+		IRQ:     float32(raw.Irq) / total * 100,
+		SoftIRQ: float32(raw.SoftIrq) / total * 100,
+		Steal:   float32(raw.Steal) / total * 100,
+		Guest:   float32(raw.Guest) / total * 100,
+	}
 }
 
 func parseCPUFields(cpu *types.CPURaw, fields []string) {
@@ -0,0 +1,112 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// cloudMetadataCmd queries the instance metadata service of AWS (IMDSv2)
+// and, failing that, of GCP, printing one "key value" pair per line. It
+// prints nothing if neither is reachable.
+const cloudMetadataCmd = `t=$(curl -sf -m 2 -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token) && {
+	m() { curl -sf -m 2 -H "X-aws-ec2-metadata-token: $t" http://169.254.169.254/latest/meta-data/$1; }
+	echo "provider aws"
+	echo "instance_id $(m instance-id)"
+	echo "instance_type $(m instance-type)"
+	echo "region $(m placement/region)"
+	echo "zone $(m placement/availability-zone)"
+	exit 0
+}
+g() { curl -sf -m 2 -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/$1; }
+z=$(g zone) && {
+	z=${z##*/}
+	echo "provider gcp"
+	echo "instance_id $(g id)"
+	echo "instance_type $(g machine-type | sed 's|.*/||')"
+	echo "region ${z%-*}"
+	echo "zone $z"
+}
+exit 0`
+
+// GetCloudMetadata returns the provider, instance id, instance type, region
+// and zone of the remote host as labels, queried from the instance metadata
+// service with curl. It returns no labels if the host doesn't run on a
+// supported cloud.
+func (c *Client) GetCloudMetadata(ctx context.Context) (map[string]string, error) {
+	lines, err := c.execute(ctx, cloudMetadataCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute cloud metadata query: %s", err)
+	}
+
+	res := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok || value == "" {
+			continue
+		}
+		res["cloud."+key] = value
+	}
+
+	return res, nil
+}
+
+// cloudLabels returns the cloud metadata labels, querying them only once
+// since they don't change during the lifetime of an instance.
+func (c *Client) cloudLabels(ctx context.Context) (map[string]string, error) {
+	c.cloudMu.Lock()
+	defer c.cloudMu.Unlock()
+
+	if c.cloud == nil {
+		labels, err := c.GetCloudMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.cloud = labels
+	}
+
+	return c.cloud, nil
+}
+
+// mergeLabels returns the given labels together with those of WithLabels.
+func (c *Client) mergeLabels(labels map[string]string) map[string]string {
+	if len(c.labels) == 0 {
+		return labels
+	}
+	res := make(map[string]string, len(c.labels)+len(labels))
+	for k, v := range c.labels {
+		res[k] = v
+	}
+	for k, v := range labels {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Compat selects the variants of the commands whose arguments differ
+// between the GNU tools and BusyBox, as found on Alpine hosts.
+type Compat string
+
+const (
+	// CompatAuto detects BusyBox on the first call of GetStats.
+	CompatAuto    Compat = "auto"
+	CompatGNU     Compat = "gnu"
+	CompatBusyBox Compat = "busybox"
+)
+
+// ParseCompat parses auto, gnu or busybox.
+func ParseCompat(s string) (Compat, error) {
+	switch c := Compat(s); c {
+	case CompatAuto, CompatGNU, CompatBusyBox:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid compatibility mode %q, expected auto, gnu or busybox", s)
+}
+
+// commandSet holds the commands which need other arguments on BusyBox.
+type commandSet struct {
+	hostname string
+	// df reports sizes in blocks of dfBlockSize bytes
+	df          string
+	dfBlockSize uint64
+	ipAddr      string
+}
+
+var (
+	gnuCommands = commandSet{
+		hostname:    "/bin/hostname -f",
+		df:          "/bin/df -B1",
+		dfBlockSize: 1,
+		ipAddr:      "/bin/ip -o addr",
+	}
+	// BusyBox df has no -B unless built with it, hostname -f fails when
+	// the name does not resolve, and ip lives in /sbin or is left out in
+	// favour of ifconfig
+	busyBoxCommands = commandSet{
+		hostname:    "/bin/hostname",
+		df:          "/bin/df -k",
+		dfBlockSize: 1024,
+		ipAddr:      "/sbin/ip -o addr",
+	}
+)
+
+// GetCompat returns the compatibility mode of the client. In auto mode
+// BusyBox is detected by where /bin/df links to, which is only asked for
+// once; until then, or if that fails, the GNU variants are used.
+func (c *Client) GetCompat(ctx context.Context) (Compat, error) {
+	c.mu.Lock()
+	compat := c.compat
+	c.mu.Unlock()
+	if compat != CompatAuto {
+		return compat, nil
+	}
+
+	out, err := c.execute(ctx, "readlink -f /bin/df")
+	if err != nil {
+		return CompatAuto, fmt.Errorf("execute readlink -f /bin/df: %s", err)
+	}
+	compat = CompatGNU
+	if strings.HasSuffix(strings.TrimSpace(out), "/busybox") {
+		compat = CompatBusyBox
+	}
+
+	c.mu.Lock()
+	c.compat = compat
+	c.mu.Unlock()
+	return compat, nil
+}
+
+// commands returns the command variants for the compatibility mode.
+func (c *Client) commands() commandSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compat == CompatBusyBox {
+		return busyBoxCommands
+	}
+	return gnuCommands
+}
+
+// getIfconfigAddrs returns the addresses of the interfaces as reported by
+// ifconfig, for BusyBox builds without ip.
+func (c *Client) getIfconfigAddrs(ctx context.Context) (map[string]types.NetIPAddr, error) {
+	lines, err := c.execute(ctx, "/sbin/ifconfig")
+	if err != nil {
+		return nil, fmt.Errorf("execute /sbin/ifconfig: %s", err)
+	}
+	return parseIfconfig(lines), nil
+}
+
+// parseIfconfig parses the output of the BusyBox and older net-tools
+// ifconfig, in the form of:
+//
+//	eth0      Link encap:Ethernet  HWaddr 02:42:AC:11:00:02
+//	          inet addr:172.17.0.2  Bcast:172.17.255.255  Mask:255.255.0.0
+//	          inet6 addr: fe80::42:acff:fe11:2/64 Scope:Link
+func parseIfconfig(lines string) map[string]types.NetIPAddr {
+	res := make(map[string]types.NetIPAddr)
+
+	var intf string
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			intf = parts[0]
+			continue
+		}
+		if intf == "" || len(parts) < 2 {
+			continue
+		}
+
+		info := res[intf]
+		switch {
+		case parts[0] == "inet" && strings.HasPrefix(parts[1], "addr:"):
+			addr := strings.TrimPrefix(parts[1], "addr:")
+			for _, p := range parts[2:] {
+				if mask := strings.TrimPrefix(p, "Mask:"); mask != p {
+					if ip := net.ParseIP(mask).To4(); ip != nil {
+						ones, _ := net.IPMask(ip).Size()
+						addr = fmt.Sprintf("%s/%d", addr, ones)
+					}
+				}
+			}
+			info.IPv4 = addr
+		case parts[0] == "inet6" && parts[1] == "addr:" && len(parts) >= 3:
+			info.IPv6 = parts[2]
+		default:
+			continue
+		}
+		res[intf] = info
+	}
+
+	return res
+}
@@ -0,0 +1,115 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// sectorSize is the unit of the sector counts in /proc/diskstats, which is
+// always 512 bytes regardless of the device.
+const sectorSize = 512
+
+// GetDiskIO returns the I/O counters of each block device and the rates
+// since the previous call. Loop and ram devices, and devices without any
+// I/O, are left out.
+func (c *Client) GetDiskIO(ctx context.Context) ([]types.DiskIO, error) {
+	lines, err := c.execute(ctx, "/bin/cat /proc/diskstats")
+	if err != nil {
+		return nil, fmt.Errorf("execute /bin/cat /proc/diskstats: %s", err)
+	}
+	now := time.Now()
+
+	var raws []types.DiskIORaw
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		raw, ok := parseDiskStats(strings.Fields(scanner.Text()))
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(raw.Device, "loop") || strings.HasPrefix(raw.Device, "ram") {
+			continue
+		}
+		if raw.Reads == 0 && raw.Writes == 0 {
+			continue
+		}
+		raws = append(raws, raw)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make([]types.DiskIO, 0, len(raws))
+	prev := make(map[string]types.DiskIORaw, len(raws))
+	secs := now.Sub(c.prevDiskIOT).Seconds()
+	for _, raw := range raws {
+		io := types.DiskIO{DiskIORaw: raw}
+		if p, ok := c.prevDiskIO[raw.Device]; ok && secs > 0 {
+			// the kernel counts sectors, which wrap at 32 bits on some
+			// kernels; bytes would always look like a reset
+			io.ReadRate = rate(raw.ReadBytes/sectorSize, p.ReadBytes/sectorSize, secs, types.WrapByteRate/sectorSize) * sectorSize
+			io.WriteRate = rate(raw.WriteBytes/sectorSize, p.WriteBytes/sectorSize, secs, types.WrapByteRate/sectorSize) * sectorSize
+			io.ReadIOPS = rate(raw.Reads, p.Reads, secs, types.WrapOpRate)
+			io.WriteIOPS = rate(raw.Writes, p.Writes, secs, types.WrapOpRate)
+		}
+		prev[raw.Device] = raw
+		res = append(res, io)
+	}
+	c.prevDiskIO = prev
+	c.prevDiskIOT = now
+
+	return res, nil
+}
+
+// parseDiskStats parses a line of /proc/diskstats, which starts with the
+// major and minor numbers and the device name, followed by the counters.
+func parseDiskStats(fields []string) (types.DiskIORaw, bool) {
+	if len(fields) < 10 {
+		return types.DiskIORaw{}, false
+	}
+	var vals [7]uint64
+	for i := range vals {
+		v, err := strconv.ParseUint(fields[3+i], 10, 64)
+		if err != nil {
+			return types.DiskIORaw{}, false
+		}
+		vals[i] = v
+	}
+	return types.DiskIORaw{
+		Device:     fields[2],
+		Reads:      vals[0],
+		ReadBytes:  vals[2] * sectorSize,
+		Writes:     vals[4],
+		WriteBytes: vals[6] * sectorSize,
+	}, true
+}
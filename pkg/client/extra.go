@@ -0,0 +1,225 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// extraCollector collects optional metrics of the remote host, keyed by
+// metric name, which end up in the Extra map of the stats. It declares the
+// metrics it may report, for rtop schema.
+type extraCollector struct {
+	collect func(c *Client, ctx context.Context) (map[string]float64, error)
+	metrics []ExtraMetric
+}
+
+// ExtraMetric describes a metric of an optional collector. Parts of the
+// name which depend on the host are given in angle brackets, such as the
+// pid in jvm.<pid>.old_percent.
+type ExtraMetric struct {
+	Name string     `json:"name"`
+	Unit types.Unit `json:"unit,omitempty"`
+}
+
+// extraCollectors are the optional collectors, enabled by name with
+// WithCollectors.
+var extraCollectors = map[string]extraCollector{
+	"mysql": {(*Client).GetMySQLStatus, []ExtraMetric{
+		{"mysql.connections", types.UnitNone},
+		{"mysql.replication_lag_seconds", types.UnitSeconds},
+		{"mysql.replication_stopped", types.UnitFlag},
+		{"mysql.cache_hit_percent", types.UnitPercent},
+	}},
+	"postgres": {(*Client).GetPostgresStatus, []ExtraMetric{
+		{"postgres.connections", types.UnitNone},
+		{"postgres.replication_lag_seconds", types.UnitSeconds},
+		{"postgres.cache_hit_percent", types.UnitPercent},
+	}},
+	"redis": {(*Client).GetRedisInfo, []ExtraMetric{
+		{"redis.used_memory_bytes", types.UnitBytes},
+		{"redis.ops_per_second", types.UnitPerSecond},
+		{"redis.connections", types.UnitNone},
+		{"redis.hit_percent", types.UnitPercent},
+	}},
+	"memcached": {(*Client).GetMemcachedStats, []ExtraMetric{
+		{"memcached.used_memory_bytes", types.UnitBytes},
+		{"memcached.ops_per_second", types.UnitPerSecond},
+		{"memcached.connections", types.UnitNone},
+		{"memcached.hit_percent", types.UnitPercent},
+	}},
+	"listen": {(*Client).GetListenQueues, []ExtraMetric{
+		{"listen.overflows", types.UnitNone},
+		{"listen.drops", types.UnitNone},
+		{"listen.<socket>.queue", types.UnitNone},
+		{"listen.<socket>.backlog", types.UnitNone},
+		{"listen.<socket>.saturation_percent", types.UnitPercent},
+	}},
+	"jvm": {(*Client).GetJVMStats, []ExtraMetric{
+		{"jvm.<pid>.eden_percent", types.UnitPercent},
+		{"jvm.<pid>.old_percent", types.UnitPercent},
+		{"jvm.<pid>.metaspace_percent", types.UnitPercent},
+		{"jvm.<pid>.gc_time_seconds", types.UnitSeconds},
+		{"jvm.<pid>.gc_overhead_percent", types.UnitPercent},
+	}},
+	"rpi":   {(*Client).GetRPiHealth, rpiMetrics()},
+	"neigh": {(*Client).GetNeighbors, neighMetrics()},
+}
+
+// unit returns the unit of the metric with the given key.
+func (e extraCollector) unit(key string) types.Unit {
+	for _, m := range e.metrics {
+		prefix, rest, placeholder := strings.Cut(m.Name, "<")
+		if !placeholder {
+			if m.Name == key {
+				return m.Unit
+			}
+			continue
+		}
+		_, suffix, _ := strings.Cut(rest, ">")
+		if len(key) > len(prefix)+len(suffix) && strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) {
+			return m.Unit
+		}
+	}
+	return types.UnitNone
+}
+
+// ExtraMetrics returns the metrics of every optional collector, keyed by
+// the collector name.
+func ExtraMetrics() map[string][]ExtraMetric {
+	res := make(map[string][]ExtraMetric, len(extraCollectors))
+	for name, collector := range extraCollectors {
+		res[name] = append([]ExtraMetric(nil), collector.metrics...)
+	}
+	return res
+}
+
+// mysqlStatus queries the global status and, appended to it, the replica
+// status.
+const mysqlStatus = `mysql -N -B -e "` +
+	`SHOW GLOBAL STATUS WHERE Variable_name IN ` +
+	`('Threads_connected', 'Innodb_buffer_pool_reads', 'Innodb_buffer_pool_read_requests'); `
+
+// mysqlStatusCmd relies on the remote user's client configuration, e.g.
+// ~/.my.cnf, for credentials. Servers before MySQL 8.0.22 and MariaDB
+// 10.5.1 only know SHOW SLAVE STATUS, which newer MySQL versions deprecate.
+const mysqlStatusCmd = mysqlStatus + `SHOW REPLICA STATUS\G" 2>/dev/null || ` +
+	mysqlStatus + `SHOW SLAVE STATUS\G"`
+
+// GetMySQLStatus returns the connection count, buffer pool hit ratio and,
+// on replicas, the replication lag of the MySQL server on the remote host.
+// The lag is unknown while replication is stopped, which is reported by
+// the replication_stopped flag instead.
+func (c *Client) GetMySQLStatus(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, mysqlStatusCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute mysql: %s", err)
+	}
+
+	res := make(map[string]float64)
+	var reads, requests float64
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// the status rows are tab separated, the replica status is vertical
+		name, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			name, value, ok = strings.Cut(line, ": ")
+		}
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if (name == "Seconds_Behind_Source" || name == "Seconds_Behind_Master") && value == "NULL" {
+			res["mysql.replication_stopped"] = 1
+			continue
+		}
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "Threads_connected":
+			res["mysql.connections"] = val
+		case "Innodb_buffer_pool_reads":
+			reads = val
+		case "Innodb_buffer_pool_read_requests":
+			requests = val
+		case "Seconds_Behind_Source", "Seconds_Behind_Master":
+			res["mysql.replication_lag_seconds"] = val
+			res["mysql.replication_stopped"] = 0
+		}
+	}
+	if requests > 0 {
+		res["mysql.cache_hit_percent"] = (1 - reads/requests) * 100
+	}
+
+	return res, nil
+}
+
+// postgresStatusCmd relies on the remote user's client configuration, e.g.
+// PGUSER and ~/.pgpass, for credentials.
+const postgresStatusCmd = `psql -At -F ' ' -c "` +
+	`SELECT 'connections', count(*) FROM pg_stat_activity ` +
+	`UNION ALL SELECT 'replication_lag_seconds', ` +
+	`coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0) ` +
+	`UNION ALL SELECT 'cache_hit_percent', ` +
+	`coalesce(100.0 * sum(blks_hit) / nullif(sum(blks_hit) + sum(blks_read), 0), 0) ` +
+	`FROM pg_stat_database"`
+
+// GetPostgresStatus returns the connection count, cache hit ratio and the
+// replication lag of the PostgreSQL server on the remote host. The lag is
+// always zero on primaries.
+func (c *Client) GetPostgresStatus(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, postgresStatusCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute psql: %s", err)
+	}
+
+	res := make(map[string]float64)
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		val, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+		res["postgres."+parts[0]] = val
+	}
+
+	return res, nil
+}
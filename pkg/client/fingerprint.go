@@ -0,0 +1,91 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"time"
+)
+
+// Fingerprint is what a client detects about a host before collecting: its
+// operating system, the command variants it needs and the collectors it
+// lacks the files or commands for. It can be cached and handed to the next
+// client of the host with WithFingerprint to skip the detection round
+// trips.
+type Fingerprint struct {
+	OS string `json:"os"`
+	// Compat is the detected compatibility mode, empty unless it was
+	// detected rather than given with WithCompat
+	Compat Compat `json:"compat,omitempty"`
+	// Disabled are the collectors skipped after the preflight, with the
+	// reason
+	Disabled map[string]string `json:"disabled,omitempty"`
+	Detected time.Time         `json:"detected"`
+}
+
+// seed sets the detection results of the fingerprint, as if detected.
+func (c *Client) seed(fp Fingerprint) {
+	c.shellOK = true
+	c.osName = fp.OS
+	if c.autoCompat && fp.Compat != "" {
+		c.compat = fp.Compat
+	}
+	c.disabled = make(map[string]string, len(fp.Disabled))
+	for k, v := range fp.Disabled {
+		c.disabled[k] = v
+	}
+	c.fingerprinted = true
+}
+
+// reportFingerprint passes the detection results to the function given with
+// WithFingerprintFunc once they are complete.
+func (c *Client) reportFingerprint() {
+	c.mu.Lock()
+	if c.onFingerprint == nil || c.fingerprinted || !c.shellOK || c.osName == "" {
+		c.mu.Unlock()
+		return
+	}
+	fp := Fingerprint{OS: c.osName, Detected: time.Now()}
+	if c.osName != "FreeBSD" {
+		// Linux hosts are done once the compat mode and the preflight are
+		// known
+		if (c.autoCompat && c.compat == CompatAuto) || c.disabled == nil {
+			c.mu.Unlock()
+			return
+		}
+		if c.autoCompat {
+			fp.Compat = c.compat
+		}
+		fp.Disabled = make(map[string]string, len(c.disabled))
+		for k, v := range c.disabled {
+			fp.Disabled[k] = v
+		}
+	}
+	c.fingerprinted = true
+	fn := c.onFingerprint
+	c.mu.Unlock()
+
+	fn(fp)
+}
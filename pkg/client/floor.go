@@ -0,0 +1,75 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// IntervalFloorFactor is how many times the duration of a collection must
+// pass before the next one starts.
+const IntervalFloorFactor = 2
+
+// waitFloor waits until the next collection may start, or ctx is done.
+// Concurrent callers each reserve their own start time.
+func (c *Client) waitFloor(ctx context.Context) error {
+	c.floorMu.Lock()
+	now := time.Now()
+	start := c.nextStart
+	if start.Before(now) {
+		start = now
+	}
+	c.nextStart = start.Add(IntervalFloorFactor * c.collection)
+	c.floorMu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setFloor records how long the collection started at start took, and
+// returns the resulting interval floor.
+func (c *Client) setFloor(start time.Time, took time.Duration) time.Duration {
+	c.floorMu.Lock()
+	defer c.floorMu.Unlock()
+
+	c.collection = took
+	floor := IntervalFloorFactor * took
+	if next := start.Add(floor); next.After(c.nextStart) {
+		c.nextStart = next
+	}
+	return floor
+}
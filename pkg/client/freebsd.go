@@ -0,0 +1,413 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/semgroup"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// GetOS returns the name of the remote operating system as reported by
+// uname -s, such as Linux or FreeBSD. It is only asked for once.
+func (c *Client) GetOS(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	name := c.osName
+	c.mu.Unlock()
+	if name != "" {
+		return name, nil
+	}
+
+	out, err := c.execute(ctx, "uname -s")
+	if err != nil {
+		return "", fmt.Errorf("execute uname -s: %s", err)
+	}
+	name = strings.TrimSpace(out)
+
+	c.mu.Lock()
+	c.osName = name
+	c.mu.Unlock()
+	return name, nil
+}
+
+// getFreeBSDStats collects the stats of a FreeBSD host, which has no /proc,
+// using sysctl, swapinfo, df, netstat and ps instead. Stats without a
+// FreeBSD counterpart here, such as disk I/O and sensors, are left empty.
+func (c *Client) getFreeBSDStats(ctx context.Context) (types.Stats, error) {
+	s := semgroup.NewGroup(ctx, int64(c.workers))
+	before := c.runner.Timings()
+
+	var uptime time.Duration
+	var hostname string
+	var loads types.Loads
+	var mem types.MemInfo
+	var swap types.SwapActivity
+	var cpu types.CPUInfo
+	var cores []types.CPUInfo
+	var cpuRaw types.CPURaw
+	var fsInfos []types.FSInfo
+	var netInterface map[string]types.NetInterface
+	var zpools []types.ZPool
+	var procs []types.Process
+	var procSummary *types.ProcessSummary
+	var extraMu sync.Mutex
+	extra := make(map[string]float64)
+	extraUnits := make(map[string]types.Unit)
+
+	s.Go(c.measure("uptime", func() error {
+		var err error
+		uptime, err = c.getFreeBSDUptime(ctx)
+		return err
+	}))
+	s.Go(c.measure("hostname", func() error {
+		var err error
+		hostname, err = c.GetHostname(ctx)
+		return err
+	}))
+	s.Go(c.measure("load", func() error {
+		var err error
+		loads, err = c.getFreeBSDLoad(ctx)
+		return err
+	}))
+	s.Go(c.measure("mem", func() error {
+		var err error
+		mem, swap, err = c.getFreeBSDMemInfo(ctx)
+		return err
+	}))
+	s.Go(c.measure("fs", func() error {
+		lines, err := c.execute(ctx, "df -k")
+		if err != nil {
+			return fmt.Errorf("execute df -k: %s", err)
+		}
+		fsInfos = parseDF(lines, 1024)
+		if lines, err := c.execute(ctx, freeBSDMountsCmd); err == nil {
+			addFSTypes(fsInfos, lines)
+		}
+		fsInfos = c.fsFilter.apply(fsInfos)
+		return nil
+	}))
+	s.Go(c.measure("netdev", func() error {
+		var err error
+		netInterface, err = c.getFreeBSDNetInterfaces(ctx)
+		return err
+	}))
+	s.Go(c.measure("cpu", func() error {
+		raw, coreRaws, err := c.getFreeBSDCPUTimes(ctx)
+		if err != nil {
+			return err
+		}
+		cpuRaw = raw
+		cpu = c.cpuRate(raw)
+		cores = c.cpuRates(coreRaws)
+		return nil
+	}))
+	s.Go(c.measure("zfs", func() error {
+		var err error
+		zpools, err = c.GetZPools(ctx)
+		return err
+	}))
+	if c.processes > 0 {
+		s.Go(c.measure("processes", func() error {
+			lines, err := c.execute(ctx, freebsdProcessesCmd)
+			if err != nil {
+				return fmt.Errorf("execute %s: %s", freebsdProcessesCmd, err)
+			}
+			all := parseProcesses(lines)
+			procSummary = summarizeProcesses(all)
+			procs = topProcesses(all, c.processes)
+			return nil
+		}))
+	}
+	for name, collector := range c.extra {
+		collector := collector
+		s.Go(c.measure(name, func() error {
+			metrics, err := collector.collect(c, ctx)
+			extraMu.Lock()
+			for k, v := range metrics {
+				extra[k] = v
+				if u := collector.unit(k); u != types.UnitNone {
+					extraUnits[k] = u
+				}
+			}
+			extraMu.Unlock()
+			return err
+		}))
+	}
+
+	err := s.Wait()
+
+	var meta types.Meta
+	after := c.runner.Timings()
+	meta.Reconnects = after.Reconnects
+	meta.Missing = c.missingCollectors()
+	if reason, ok := meta.Missing["mem"]; ok {
+		// the swap activity is read along with the memory
+		meta.Missing["swap"] = reason
+	}
+	if n := after.Commands - before.Commands; n > 0 {
+		meta.SessionOpen = (after.SessionOpen - before.SessionOpen) / time.Duration(n)
+		meta.CommandRTT = (after.RoundTrip - before.RoundTrip) / time.Duration(n)
+	}
+
+	c.netRates(netInterface, time.Now())
+
+	return types.Stats{
+		Uptime:         uptime,
+		Hostname:       hostname,
+		Loads:          loads,
+		CPU:            cpu,
+		CPURaw:         cpuRaw,
+		Cores:          cores,
+		MEM:            mem,
+		SwapActivity:   swap,
+		FSInfos:        fsInfos,
+		NetInterface:   netInterface,
+		ZFS:            zpools,
+		Extra:          extra,
+		ExtraUnits:     extraUnits,
+		Processes:      procs,
+		ProcessSummary: procSummary,
+		Labels:         c.mergeLabels(nil),
+		Meta:           meta,
+	}, err
+}
+
+// freebsdProcessesCmd is processesCmd for FreeBSD, whose ps -e means
+// something else.
+const freebsdProcessesCmd = "ps -axo pid=,user=,pcpu=,pmem=,rss=,stat=,comm="
+
+var bootTimeRe = regexp.MustCompile(`sec = (\d+)`)
+
+func (c *Client) getFreeBSDUptime(ctx context.Context) (time.Duration, error) {
+	const cmd = "sysctl -n kern.boottime; date +%s"
+	out, err := c.execute(ctx, cmd)
+	if err != nil {
+		return 0, fmt.Errorf("execute %s: %s", cmd, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	m := bootTimeRe.FindStringSubmatch(lines[0])
+	if m == nil || len(lines) != 2 {
+		return 0, fmt.Errorf("unexpected kern.boottime format: %s", out)
+	}
+	boot, _ := strconv.ParseInt(m[1], 10, 64)
+	now, err := strconv.ParseInt(strings.TrimSpace(lines[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected date format: %s", lines[1])
+	}
+	return time.Duration(now-boot) * time.Second, nil
+}
+
+func (c *Client) getFreeBSDLoad(ctx context.Context) (types.Loads, error) {
+	const cmd = "sysctl -n vm.loadavg; ps -axo stat="
+	out, err := c.execute(ctx, cmd)
+	if err != nil {
+		return types.Loads{}, fmt.Errorf("execute %s: %s", cmd, err)
+	}
+
+	var res types.Loads
+	var running, total int
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for first := true; scanner.Scan(); first = false {
+		if first {
+			// { 0.27 0.31 0.28 }
+			parts := strings.Fields(strings.Trim(scanner.Text(), "{} "))
+			if len(parts) != 3 {
+				return types.Loads{}, fmt.Errorf("unexpected vm.loadavg format: %s", scanner.Text())
+			}
+			res.Load1, res.Load5, res.Load15 = parts[0], parts[1], parts[2]
+			continue
+		}
+		total++
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "R") {
+			running++
+		}
+	}
+	res.RunningProcs = strconv.Itoa(running)
+	res.TotalProcs = strconv.Itoa(total)
+	return res, nil
+}
+
+// freebsdMemCmd prints the memory counters as name: value lines, skipping
+// those missing on older releases, followed by the swap devices.
+const freebsdMemCmd = "sysctl hw.physmem hw.pagesize vfs.bufspace " +
+	"vm.stats.vm.v_free_count vm.stats.vm.v_inactive_count vm.stats.vm.v_cache_count " +
+	"vm.stats.vm.v_swappgsin vm.stats.vm.v_swappgsout 2>/dev/null; swapinfo -k"
+
+func (c *Client) getFreeBSDMemInfo(ctx context.Context) (types.MemInfo, types.SwapActivity, error) {
+	out, err := c.execute(ctx, freebsdMemCmd)
+	if err != nil {
+		return types.MemInfo{}, types.SwapActivity{}, fmt.Errorf("execute sysctl and swapinfo: %s", err)
+	}
+	now := time.Now()
+
+	vals := make(map[string]uint64)
+	var mem types.MemInfo
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, val, ok := strings.Cut(line, ": "); ok {
+			if v, err := strconv.ParseUint(strings.TrimSpace(val), 10, 64); err == nil {
+				vals[name] = v
+			}
+			continue
+		}
+		// Device 1K-blocks Used Avail Capacity, with a Total line for
+		// several devices
+		parts := strings.Fields(line)
+		if len(parts) < 4 || parts[0] == "Device" || parts[0] == "Total" {
+			continue
+		}
+		total, err1 := strconv.ParseUint(parts[1], 10, 64)
+		avail, err2 := strconv.ParseUint(parts[3], 10, 64)
+		if err1 == nil && err2 == nil {
+			mem.SwapTotal += total * 1024
+			mem.SwapFree += avail * 1024
+		}
+	}
+
+	page := vals["hw.pagesize"]
+	mem.Total = vals["hw.physmem"]
+	mem.Free = vals["vm.stats.vm.v_free_count"] * page
+	mem.Buffers = vals["vfs.bufspace"]
+	mem.Cached = (vals["vm.stats.vm.v_inactive_count"] + vals["vm.stats.vm.v_cache_count"]) * page
+
+	swap := c.swapRates(types.SwapActivity{
+		PagesIn:  vals["vm.stats.vm.v_swappgsin"],
+		PagesOut: vals["vm.stats.vm.v_swappgsout"],
+	}, now)
+	return mem, swap, nil
+}
+
+// getFreeBSDCPUTimes reads kern.cp_time and kern.cp_times, which hold the
+// user, nice, system, interrupt and idle ticks of all cores together and
+// of each core.
+func (c *Client) getFreeBSDCPUTimes(ctx context.Context) (types.CPURaw, []types.CPURaw, error) {
+	const cmd = "sysctl -n kern.cp_time kern.cp_times"
+	out, err := c.execute(ctx, cmd)
+	if err != nil {
+		return types.CPURaw{}, nil, fmt.Errorf("execute %s: %s", cmd, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		return types.CPURaw{}, nil, fmt.Errorf("unexpected kern.cp_time format: %s", out)
+	}
+
+	ticks := func(fields []string, core string) types.CPURaw {
+		var v [5]uint64
+		for i := range v {
+			v[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		return types.CPURaw{
+			Core:   core,
+			User:   v[0],
+			Nice:   v[1],
+			System: v[2],
+			Irq:    v[3],
+			Idle:   v[4],
+			Total:  v[0] + v[1] + v[2] + v[3] + v[4],
+		}
+	}
+
+	total := strings.Fields(lines[0])
+	if len(total) != 5 {
+		return types.CPURaw{}, nil, fmt.Errorf("unexpected kern.cp_time format: %s", lines[0])
+	}
+	var cores []types.CPURaw
+	perCore := strings.Fields(lines[1])
+	for i := 0; i+5 <= len(perCore); i += 5 {
+		cores = append(cores, ticks(perCore[i:i+5], fmt.Sprintf("cpu%d", i/5)))
+	}
+	return ticks(total, ""), cores, nil
+}
+
+// getFreeBSDNetInterfaces parses netstat -ibnW, which lists every interface
+// once with its link level counters and once per address.
+func (c *Client) getFreeBSDNetInterfaces(ctx context.Context) (map[string]types.NetInterface, error) {
+	const cmd = "netstat -ibnW"
+	out, err := c.execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute %s: %s", cmd, err)
+	}
+
+	res := make(map[string]types.NetInterface)
+	var header []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "Name" {
+			header = fields
+			continue
+		}
+		if header == nil || len(fields) < 4 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], "*")
+		info := res[name]
+
+		if strings.HasPrefix(fields[2], "<Link#") {
+			// the address may be missing, so the counters are found
+			// counting from the end
+			col := func(name string) uint64 {
+				for i, h := range header {
+					if h == name {
+						if j := len(fields) - (len(header) - i); j >= 0 {
+							v, _ := strconv.ParseUint(fields[j], 10, 64)
+							return v
+						}
+					}
+				}
+				return 0
+			}
+			info.Rx = col("Ibytes")
+			info.Tx = col("Obytes")
+		} else {
+			addr := fields[3]
+			if _, prefix, _ := strings.Cut(fields[2], "/"); prefix != "" {
+				addr += "/" + prefix
+			}
+			if strings.Contains(addr, ":") {
+				if info.IPv6 == "" && !strings.HasPrefix(addr, "fe80:") {
+					info.IPv6 = addr
+				}
+			} else if info.IPv4 == "" {
+				info.IPv4 = addr
+			}
+		}
+		res[name] = info
+	}
+	return res, nil
+}
@@ -0,0 +1,132 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// FSFilter picks the filesystems reported, to leave out the likes of tmpfs,
+// overlay and squashfs mounts. Types are filesystem types such as ext4 and
+// Mounts are globs of mount points as in path.Match, e.g. /snap/*. When
+// Types or Mounts are given only filesystems matching them are reported,
+// and those matching SkipTypes or SkipMounts never are. Filesystems whose
+// type isn't known are only filtered by their mount point.
+type FSFilter struct {
+	Types      []string
+	SkipTypes  []string
+	Mounts     []string
+	SkipMounts []string
+}
+
+func (f FSFilter) empty() bool {
+	return len(f.Types) == 0 && len(f.SkipTypes) == 0 && len(f.Mounts) == 0 && len(f.SkipMounts) == 0
+}
+
+func (f FSFilter) validate() error {
+	for _, glob := range append(append([]string(nil), f.Mounts...), f.SkipMounts...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("bad mount point glob %q: %s", glob, err)
+		}
+	}
+	return nil
+}
+
+func (f FSFilter) match(fs types.FSInfo) bool {
+	if fs.FSType != "" {
+		if len(f.Types) > 0 && !contains(f.Types, fs.FSType) {
+			return false
+		}
+		if contains(f.SkipTypes, fs.FSType) {
+			return false
+		}
+	}
+	if len(f.Mounts) > 0 && !matchGlobs(f.Mounts, fs.MountPoint) {
+		return false
+	}
+	return !matchGlobs(f.SkipMounts, fs.MountPoint)
+}
+
+// apply returns the filesystems matching the filter.
+func (f FSFilter) apply(fsInfos []types.FSInfo) []types.FSInfo {
+	if f.empty() {
+		return fsInfos
+	}
+	res := fsInfos[:0:0]
+	for _, fs := range fsInfos {
+		if f.match(fs) {
+			res = append(res, fs)
+		}
+	}
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobs(globs []string, s string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mountsCmd lists the mounted filesystems with their types, in the format
+// of fstab. FreeBSD has no /proc/mounts, but mount -p prints the same.
+const (
+	mountsCmd        = "/bin/cat /proc/mounts"
+	freeBSDMountsCmd = "mount -p"
+)
+
+// addFSTypes sets the types of the filesystems from the mount table. When a
+// mount point is mounted over, the last entry is the one visible.
+func addFSTypes(fsInfos []types.FSInfo, mounts string) {
+	fsTypes := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(mounts))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 3 {
+			continue
+		}
+		fsTypes[strings.ReplaceAll(parts[1], `\040`, " ")] = parts[2]
+	}
+	for i, fs := range fsInfos {
+		fsInfos[i].FSType = fsTypes[fs.MountPoint]
+	}
+}
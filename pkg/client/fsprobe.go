@@ -0,0 +1,85 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// fsProbeCmd writes a 4 KiB file on every mount point given as argument,
+// synced to disk, then reads it back bypassing the page cache where the
+// filesystem allows and prints both durations in nanoseconds.
+const fsProbeCmd = `for m; do f="$m/.rtop-probe.$$"; t0=$(date +%s%N); ` +
+	`if ! dd if=/dev/zero of="$f" bs=4k count=1 conv=fsync 2>/dev/null; then echo "$m error"; rm -f "$f"; continue; fi; ` +
+	`t1=$(date +%s%N); ` +
+	`dd if="$f" of=/dev/null bs=4k iflag=direct 2>/dev/null || dd if="$f" of=/dev/null bs=4k 2>/dev/null; ` +
+	`t2=$(date +%s%N); rm -f "$f"; echo "$m $((t1-t0)) $((t2-t1))"; done`
+
+// GetFSLatency times a small synced write and a read on each of the given
+// mount points. Unlike df this notices a degraded but not full volume. The
+// remote user needs write access to the mount points.
+func (c *Client) GetFSLatency(ctx context.Context, mounts []string) ([]types.FSLatency, error) {
+	args := make([]string, len(mounts))
+	for i, m := range mounts {
+		args[i] = "'" + strings.ReplaceAll(m, "'", `'\''`) + "'"
+	}
+	cmd := fmt.Sprintf("sh -c '%s' rtop %s", strings.ReplaceAll(fsProbeCmd, "'", `'\''`), strings.Join(args, " "))
+	lines, err := c.execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute filesystem probe: %s", err)
+	}
+
+	var res []types.FSLatency
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 2 && fields[1] == "error":
+			res = append(res, types.FSLatency{MountPoint: fields[0], Failed: true})
+		case len(fields) == 3:
+			write, err1 := strconv.ParseInt(fields[1], 10, 64)
+			read, err2 := strconv.ParseInt(fields[2], 10, 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			res = append(res, types.FSLatency{
+				MountPoint: fields[0],
+				Write:      time.Duration(write),
+				Read:       time.Duration(read),
+			})
+		}
+	}
+
+	return res, nil
+}
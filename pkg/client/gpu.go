@@ -0,0 +1,83 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// gpuCmd lists the NVIDIA GPUs, one per line, with the memory in MiB.
+const gpuCmd = "nvidia-smi --query-gpu=index,name,utilization.gpu,memory.used,memory.total,temperature.gpu --format=csv,noheader,nounits"
+
+// GetGPUs returns the utilization, memory and temperature of the NVIDIA
+// GPUs of the remote host, as reported by nvidia-smi. Values a card doesn't
+// support are left at 0.
+func (c *Client) GetGPUs(ctx context.Context) ([]types.GPU, error) {
+	lines, err := c.execute(ctx, gpuCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute nvidia-smi: %s", err)
+	}
+
+	var res []types.GPU
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), ",")
+		if len(parts) != 6 {
+			continue
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		index, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		// unsupported values are given as [N/A] or [Not Supported]
+		num := func(s string) float64 {
+			v, _ := strconv.ParseFloat(s, 64)
+			return v
+		}
+		res = append(res, types.GPU{
+			Index:       index,
+			Name:        parts[1],
+			Utilization: num(parts[2]),
+			MemoryUsed:  uint64(num(parts[3])) * 1024 * 1024,
+			MemoryTotal: uint64(num(parts[4])) * 1024 * 1024,
+			Temp:        num(parts[5]),
+		})
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("unexpected nvidia-smi format: %s", lines)
+	}
+
+	return res, nil
+}
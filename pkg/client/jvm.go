@@ -0,0 +1,102 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jvmStatsCmd runs jstat for every java process, printing the pid before
+// the output of each.
+const jvmStatsCmd = `for pid in $(pgrep -x java); do echo "pid $pid"; jstat -gcutil $pid; done`
+
+// GetJVMStats returns the heap occupancy and garbage collection overhead of
+// the java processes on the remote host, keyed by pid. It needs the JDK's
+// jstat on the remote host, running as the owner of the processes.
+func (c *Client) GetJVMStats(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, jvmStatsCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute jstat: %s", err)
+	}
+	now := time.Now()
+
+	res := make(map[string]float64)
+	gcTimes := make(map[string]float64)
+	var pid string
+	var header []string
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		switch {
+		case len(parts) == 2 && parts[0] == "pid":
+			pid, header = parts[1], nil
+		case pid == "" || len(parts) == 0:
+		case header == nil:
+			header = parts
+		default:
+			prefix := "jvm." + pid + "."
+			for i := 0; i < len(parts) && i < len(header); i++ {
+				val, err := strconv.ParseFloat(parts[i], 64)
+				if err != nil {
+					continue
+				}
+				switch header[i] {
+				case "E":
+					res[prefix+"eden_percent"] = val
+				case "O":
+					res[prefix+"old_percent"] = val
+				case "M":
+					res[prefix+"metaspace_percent"] = val
+				case "GCT":
+					res[prefix+"gc_time_seconds"] = val
+					gcTimes[pid] = val
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.prevJVMT.IsZero() {
+		secs := now.Sub(c.prevJVMT).Seconds()
+		for pid, gct := range gcTimes {
+			if prev, ok := c.prevJVMGCTimes[pid]; ok && gct >= prev && secs > 0 {
+				res["jvm."+pid+".gc_overhead_percent"] = (gct - prev) / secs * 100
+			}
+		}
+	}
+	c.prevJVMGCTimes = gcTimes
+	c.prevJVMT = now
+
+	return res, nil
+}
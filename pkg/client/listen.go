@@ -0,0 +1,118 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// listenQueueCmd lists the listening tcp and unix sockets, followed by the
+// kernel's tcp extension counters.
+const listenQueueCmd = "ss -ltxnH && /bin/cat /proc/net/netstat"
+
+// GetListenQueues returns the accept queue length and backlog of the
+// sockets given with WithListenSockets, along with the number of times a
+// listen queue overflowed since boot. Sockets are given as tcp ports or
+// unix socket paths.
+func (c *Client) GetListenQueues(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, listenQueueCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute %s: %s", listenQueueCmd, err)
+	}
+
+	res := make(map[string]float64)
+	var tcpExtKeys []string
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) > 0 && parts[0] == "TcpExt:" {
+			// the first TcpExt line holds the names, the second the values
+			if tcpExtKeys == nil {
+				tcpExtKeys = parts
+				continue
+			}
+			for i := 1; i < len(parts) && i < len(tcpExtKeys); i++ {
+				val, err := strconv.ParseFloat(parts[i], 64)
+				if err != nil {
+					continue
+				}
+				switch tcpExtKeys[i] {
+				case "ListenOverflows":
+					res["listen.overflows"] = val
+				case "ListenDrops":
+					res["listen.drops"] = val
+				}
+			}
+			continue
+		}
+		if len(parts) < 5 || parts[1] != "LISTEN" {
+			continue
+		}
+		socket, ok := c.matchListenSocket(parts[4])
+		if !ok {
+			continue
+		}
+		queue, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			continue
+		}
+		backlog, err := strconv.ParseFloat(parts[3], 64)
+		if err != nil {
+			continue
+		}
+		// a socket may listen on several addresses, keep the fullest one
+		key := "listen." + socket
+		if queue >= res[key+".queue"] {
+			res[key+".queue"] = queue
+			res[key+".backlog"] = backlog
+			if backlog > 0 {
+				res[key+".saturation_percent"] = queue / backlog * 100
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// matchListenSocket returns the configured socket the given local address
+// belongs to.
+func (c *Client) matchListenSocket(addr string) (string, bool) {
+	for _, socket := range c.listenSockets {
+		if strings.HasPrefix(socket, "/") {
+			if addr == socket {
+				return socket, true
+			}
+		} else if strings.HasSuffix(addr, ":"+socket) {
+			return socket, true
+		}
+	}
+	return "", false
+}
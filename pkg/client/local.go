@@ -0,0 +1,82 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/internal/ssh"
+)
+
+// runner runs the commands of the collectors on the monitored host.
+type runner interface {
+	Execute(ctx context.Context, command string) (string, error)
+	Timings() ssh.Timings
+}
+
+// localRunner runs commands on the local machine. Plain reads of files,
+// which most collectors of /proc and /sys are, skip the shell.
+type localRunner struct {
+	mu      sync.Mutex
+	timings ssh.Timings
+}
+
+func (l *localRunner) Execute(ctx context.Context, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	start := time.Now()
+
+	var out []byte
+	var err error
+	if path := strings.TrimPrefix(command, "/bin/cat "); path != command && !strings.ContainsAny(path, " \t;|&<>$`'\"*?") {
+		out, err = os.ReadFile(path)
+	} else {
+		out, err = exec.CommandContext(ctx, "/bin/sh", "-c", command).Output()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timings.Sessions++
+	if err != nil {
+		return "", err
+	}
+	l.timings.Commands++
+	l.timings.RoundTrip += time.Since(start)
+	l.timings.BytesReceived += uint64(len(out))
+	return string(out), nil
+}
+
+func (l *localRunner) Timings() ssh.Timings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timings
+}
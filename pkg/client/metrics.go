@@ -0,0 +1,137 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"time"
+)
+
+// Metrics describes the behaviour of the client itself, for programs that
+// embed it and want to keep an eye on the cost of monitoring.
+type Metrics struct {
+	// Sessions is the number of SSH sessions opened, one per remote command
+	Sessions int
+	// Commands is the number of remote commands which succeeded
+	Commands int
+	// BytesReceived is the size of the command output received
+	BytesReceived uint64
+	// Reconnects is the number of times the SSH connection was set up again
+	Reconnects int
+	// Collectors holds the metrics of each collector run by GetStats, keyed
+	// by collector name
+	Collectors map[string]CollectorMetrics
+}
+
+// CollectorMetrics describes the runs of a single collector.
+type CollectorMetrics struct {
+	Runs          int
+	Errors        int
+	LastError     string
+	LastDuration  time.Duration
+	TotalDuration time.Duration
+}
+
+// Metrics returns a snapshot of the client's own metrics.
+func (c *Client) Metrics() Metrics {
+	t := c.runner.Timings()
+
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+
+	m := Metrics{
+		Sessions:      t.Sessions,
+		Commands:      t.Commands,
+		BytesReceived: t.BytesReceived,
+		Reconnects:    t.Reconnects,
+		Collectors:    make(map[string]CollectorMetrics, len(c.collectorMetrics)),
+	}
+	for name, cm := range c.collectorMetrics {
+		m.Collectors[name] = cm
+	}
+	return m
+}
+
+// measure wraps fn so that its duration and error are recorded under the
+// given collector name. Collectors disabled by the preflight or turned off
+// are skipped. Skipped and failed collectors are noted as missing from the
+// current collection.
+func (c *Client) measure(name string, fn func() error) func() error {
+	return func() error {
+		c.mu.Lock()
+		reason, disabled := c.disabled[name]
+		off := c.off[name]
+		c.mu.Unlock()
+		if off {
+			reason = "turned off"
+		}
+		if disabled || off {
+			c.metricsMu.Lock()
+			if c.missing != nil {
+				c.missing[name] = reason
+			}
+			c.metricsMu.Unlock()
+			return nil
+		}
+
+		start := time.Now()
+		err := fn()
+		d := time.Since(start)
+
+		c.metricsMu.Lock()
+		defer c.metricsMu.Unlock()
+		if c.collectorMetrics == nil {
+			c.collectorMetrics = make(map[string]CollectorMetrics)
+		}
+		cm := c.collectorMetrics[name]
+		cm.Runs++
+		cm.LastDuration = d
+		cm.TotalDuration += d
+		if err != nil {
+			cm.Errors++
+			cm.LastError = err.Error()
+			if c.missing != nil {
+				c.missing[name] = err.Error()
+			}
+		}
+		c.collectorMetrics[name] = cm
+		return err
+	}
+}
+
+// missingCollectors returns the collectors noted as missing by measure
+// since the current collection started, nil if there are none.
+func (c *Client) missingCollectors() map[string]string {
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+	if len(c.missing) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(c.missing))
+	for k, v := range c.missing {
+		res[k] = v
+	}
+	return res
+}
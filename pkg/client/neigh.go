@@ -0,0 +1,93 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// neighCmd counts the neighbor table entries per address family, falling
+// back to /proc/net/arp for IPv4 without iproute2, and prints the garbage
+// collection thresholds the kernel limits the tables with.
+const neighCmd = `echo "ipv4.entries $( (ip -4 neigh show 2>/dev/null || tail -n +2 /proc/net/arp) | wc -l)"; ` +
+	`echo "ipv6.entries $(ip -6 neigh show 2>/dev/null | wc -l)"; ` +
+	`for v in ipv4 ipv6; do for t in 1 2 3; do ` +
+	`echo "$v.gc_thresh$t $(cat /proc/sys/net/$v/neigh/default/gc_thresh$t 2>/dev/null)"; ` +
+	`done; done`
+
+func neighMetrics() []ExtraMetric {
+	var metrics []ExtraMetric
+	for _, v := range []string{"ipv4", "ipv6"} {
+		metrics = append(metrics,
+			ExtraMetric{"neigh." + v + ".entries", types.UnitNone},
+			ExtraMetric{"neigh." + v + ".gc_thresh1", types.UnitNone},
+			ExtraMetric{"neigh." + v + ".gc_thresh2", types.UnitNone},
+			ExtraMetric{"neigh." + v + ".gc_thresh3", types.UnitNone},
+			ExtraMetric{"neigh." + v + ".used_percent", types.UnitPercent},
+		)
+	}
+	return metrics
+}
+
+// GetNeighbors returns the size of the IPv4 and IPv6 neighbor (ARP) tables
+// and their limits. When a table grows beyond gc_thresh3 the kernel drops
+// new entries with "neighbour table overflow", so the size is also reported
+// as a percentage of that limit.
+func (c *Client) GetNeighbors(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, neighCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute neighbor table count: %s", err)
+	}
+
+	res := make(map[string]float64)
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		val, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		res["neigh."+fields[0]] = val
+	}
+
+	for _, v := range []string{"ipv4", "ipv6"} {
+		if limit := res["neigh."+v+".gc_thresh3"]; limit > 0 {
+			res["neigh."+v+".used_percent"] = res["neigh."+v+".entries"] / limit * 100
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,176 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// nfsCmd prints the RPC call counters of the NFS client and server, each
+// after a "== nfs" or "== nfsd" line if the host has them, followed by the
+// statistics of the NFS mounts after a "== mountstats" line.
+const nfsCmd = `for f in nfs nfsd; do [ -r /proc/net/rpc/$f ] && echo "== $f" && grep '^rpc ' /proc/net/rpc/$f; done; ` +
+	`echo "== mountstats"; awk '/^device /{nfs = / fstype nfs4? /} nfs' /proc/self/mountstats 2>/dev/null; true`
+
+// GetNFS returns the RPC calls of the NFS client and server of the remote
+// host and the operations of each NFS mount, with their rates since the
+// previous call. Hosts neither mounting nor serving NFS return nil.
+func (c *Client) GetNFS(ctx context.Context) (*types.NFS, error) {
+	lines, err := c.execute(ctx, nfsCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute nfs stats: %s", err)
+	}
+	now := time.Now()
+	res := parseNFS(lines)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	secs := now.Sub(c.prevNFST).Seconds()
+	if prev := c.prevNFS; prev != nil && secs > 0 {
+		nfsRPCRates(res.Client, prev.Client, secs)
+		nfsRPCRates(res.Server, prev.Server, secs)
+		prevMounts := make(map[string]types.NFSMountRaw, len(prev.Mounts))
+		for _, m := range prev.Mounts {
+			prevMounts[m.MountPoint] = m.NFSMountRaw
+		}
+		for i := range res.Mounts {
+			m := &res.Mounts[i]
+			p, ok := prevMounts[m.MountPoint]
+			if !ok || m.Ops < p.Ops {
+				continue
+			}
+			m.OpRate = rate(m.Ops, p.Ops, secs, types.WrapOpRate)
+			m.RetransRate = rate(m.Retrans, p.Retrans, secs, types.WrapOpRate)
+			m.ReadRate = rate(m.ReadBytes, p.ReadBytes, secs, types.WrapByteRate)
+			m.WriteRate = rate(m.WriteBytes, p.WriteBytes, secs, types.WrapByteRate)
+			if ops := m.Ops - p.Ops; ops > 0 {
+				m.AvgRTT = (m.RTT - p.RTT) / time.Duration(ops)
+			}
+		}
+	}
+	c.prevNFS = res
+	c.prevNFST = now
+
+	if res.Server == nil && len(res.Mounts) == 0 && (res.Client == nil || res.Client.Calls == 0) {
+		return nil, nil
+	}
+	return res, nil
+}
+
+func nfsRPCRates(cur, prev *types.NFSRPC, secs float64) {
+	if cur == nil || prev == nil || cur.Calls < prev.Calls {
+		return
+	}
+	cur.CallRate = rate(cur.Calls, prev.Calls, secs, types.WrapOpRate)
+	cur.RetransRate = rate(cur.Retrans, prev.Retrans, secs, types.WrapOpRate)
+	cur.BadCallRate = rate(cur.BadCalls, prev.BadCalls, secs, types.WrapOpRate)
+}
+
+// parseNFS parses the output of nfsCmd. The rpc line of the client counts
+// the calls, retransmissions and authentication refreshes, that of the
+// server the calls and the bad calls. In mountstats, each mount starts with
+// a device line, followed by the bytes read and written and, after a
+// per-op statistics line, a line per operation:
+//
+//	device nas:/export mounted on /mnt/data with fstype nfs4 statvers=1.1
+//		bytes:	1024 2048 0 0 4096 2048 1 1
+//		per-op statistics
+//		        READ: 12 13 0 1680 4960 1 30 32 0
+//
+// which gives the operations, transmissions, major timeouts, bytes sent
+// and received, and the milliseconds spent queued, waiting for the answer
+// and in total.
+func parseNFS(lines string) *types.NFS {
+	res := &types.NFS{}
+	var section string
+	var m *types.NFSMountRaw
+	var perOp bool
+	flush := func() {
+		if m != nil {
+			res.Mounts = append(res.Mounts, types.NFSMount{NFSMountRaw: *m})
+			m = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "==" && len(fields) == 2 {
+			section = fields[1]
+			continue
+		}
+		switch section {
+		case "nfs", "nfsd":
+			if fields[0] != "rpc" || len(fields) < 3 {
+				continue
+			}
+			calls, _ := strconv.ParseUint(fields[1], 10, 64)
+			second, _ := strconv.ParseUint(fields[2], 10, 64)
+			if section == "nfs" {
+				res.Client = &types.NFSRPC{Calls: calls, Retrans: second}
+			} else {
+				res.Server = &types.NFSRPC{Calls: calls, BadCalls: second}
+			}
+		case "mountstats":
+			switch {
+			case fields[0] == "device" && len(fields) >= 8:
+				flush()
+				m = &types.NFSMountRaw{Export: fields[1], MountPoint: fields[4], FSType: fields[7]}
+				perOp = false
+			case m == nil:
+			case fields[0] == "bytes:" && len(fields) >= 7:
+				m.ReadBytes, _ = strconv.ParseUint(fields[5], 10, 64)
+				m.WriteBytes, _ = strconv.ParseUint(fields[6], 10, 64)
+			case strings.TrimSpace(line) == "per-op statistics":
+				perOp = true
+			case perOp && strings.HasSuffix(fields[0], ":") && len(fields) >= 9:
+				var v [8]uint64
+				for i := range v {
+					v[i], _ = strconv.ParseUint(fields[1+i], 10, 64)
+				}
+				m.Ops += v[0]
+				if v[1] > v[0] {
+					m.Retrans += v[1] - v[0]
+				}
+				m.Timeouts += v[2]
+				m.RTT += time.Duration(v[6]) * time.Millisecond
+			}
+		}
+	}
+	flush()
+	return res
+}
@@ -25,15 +25,42 @@ THE SOFTWARE.
 
 package client
 
-import "golang.org/x/crypto/ssh"
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
 
 type option struct {
-	user      string
-	host      string
-	port      int
-	keypath   string
-	workers   int
-	sshClient *ssh.Client
+	user          string
+	host          string
+	port          int
+	keypath       string
+	workers       int
+	collectors    []string
+	listenSockets []string
+	cloudMetadata bool
+	processes     int
+	routes        bool
+	fsProbe       []string
+	fsFilter      FSFilter
+	batch         bool
+	local         bool
+	compat        Compat
+	wrapper       string
+	nice          bool
+	timeout       time.Duration
+	skip          []string
+	labels        map[string]string
+	hostKey       ssh.HostKeyCallback
+	sshClient     *ssh.Client
+	fingerprint   *Fingerprint
+	onFingerprint func(Fingerprint)
+	budget        time.Duration
+
+	breakerFailures   int
+	breakerBackoff    time.Duration
+	breakerMaxBackoff time.Duration
 }
 
 type Option func(o *option)
@@ -62,6 +89,114 @@ func WithKeyPath(keypath string) Option {
 	}
 }
 
+// WithHostKeyCallback sets how host keys are verified. By default they are
+// checked against ~/.ssh/known_hosts.
+func WithHostKeyCallback(cb ssh.HostKeyCallback) Option {
+	return func(o *option) {
+		o.hostKey = cb
+	}
+}
+
+// WithFSProbe enables timing a small write and read on the given mount
+// points.
+func WithFSProbe(mounts ...string) Option {
+	return func(o *option) {
+		o.fsProbe = mounts
+	}
+}
+
+// WithFSFilter sets which filesystems are reported.
+func WithFSFilter(f FSFilter) Option {
+	return func(o *option) {
+		o.fsFilter = f
+	}
+}
+
+// WithBatch makes GetStats run the commands of the core collectors in a
+// single SSH session instead of one session each.
+func WithBatch(batch bool) Option {
+	return func(o *option) {
+		o.batch = batch
+	}
+}
+
+// WithLocalExecutor makes the client monitor the local machine by running
+// the collectors' commands directly instead of over ssh.
+func WithLocalExecutor() Option {
+	return func(o *option) {
+		o.local = true
+	}
+}
+
+// WithCompat sets whether the GNU or the BusyBox variants of commands are
+// run. By default BusyBox is detected.
+func WithCompat(compat Compat) Option {
+	return func(o *option) {
+		o.compat = compat
+	}
+}
+
+// WithCommandWrapper runs every command as the single argument of the
+// given wrapper, e.g. "sh -c", for restricted login shells.
+func WithCommandWrapper(wrapper string) Option {
+	return func(o *option) {
+		o.wrapper = wrapper
+	}
+}
+
+// WithNice runs the collectors one at a time, and their commands at the
+// lowest cpu and io scheduling priority, to add as little load as possible
+// to hosts which are already overloaded.
+func WithNice(nice bool) Option {
+	return func(o *option) {
+		o.nice = nice
+	}
+}
+
+// WithoutCollectors turns off the named collectors, see Collectors.
+func WithoutCollectors(names ...string) Option {
+	return func(o *option) {
+		o.skip = names
+	}
+}
+
+// WithCommandTimeout kills commands running longer than d, on the remote
+// host too if it has timeout(1). Zero disables the timeout.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(o *option) {
+		o.timeout = d
+	}
+}
+
+// WithCollectionBudget bounds every collection of GetStats by d. A
+// collection overrunning it counts as failed for WithCircuitBreaker. Zero
+// disables the budget.
+func WithCollectionBudget(d time.Duration) Option {
+	return func(o *option) {
+		o.budget = d
+	}
+}
+
+// WithCircuitBreaker makes GetStats back off from a host after failures
+// consecutive collections failed, returning a *BackoffError instead of
+// collecting for backoff, doubled with every further failure up to
+// maxBackoff. Zero failures disables the breaker.
+func WithCircuitBreaker(failures int, backoff, maxBackoff time.Duration) Option {
+	return func(o *option) {
+		o.breakerFailures = failures
+		o.breakerBackoff = backoff
+		o.breakerMaxBackoff = maxBackoff
+	}
+}
+
+// WithLabels adds the given labels to the stats, such as the role of the
+// host. Cloud metadata labels of the same name take precedence.
+func WithLabels(labels map[string]string) Option {
+	return func(o *option) {
+		o.labels = labels
+	}
+}
+
 func WithSSHClient(sshClient *ssh.Client) Option {
 	return func(o *option) {
 		o.sshClient = sshClient
@@ -73,3 +208,61 @@ func WithWorkers(workers int) Option {
 		o.workers = workers
 	}
 }
+
+// WithCollectors enables the given optional collectors, whose metrics are
+// reported in the Extra map of the stats, but for the NVIDIA GPUs of gpu
+// and the ZFS pools of zfs, which are reported in GPUs and ZFS.
+func WithCollectors(names ...string) Option {
+	return func(o *option) {
+		o.collectors = append(o.collectors, names...)
+	}
+}
+
+// WithListenSockets enables reporting the accept queues of the given
+// listening sockets, given as tcp ports or unix socket paths.
+func WithListenSockets(sockets ...string) Option {
+	return func(o *option) {
+		o.listenSockets = append(o.listenSockets, sockets...)
+	}
+}
+
+// WithCloudMetadata enables labeling the stats with the instance id, type,
+// region and zone queried from the cloud provider's metadata service.
+func WithCloudMetadata(enabled bool) Option {
+	return func(o *option) {
+		o.cloudMetadata = enabled
+	}
+}
+
+// WithProcesses enables collecting the n processes using the most CPU and
+// the n using the most memory with every refresh.
+func WithProcesses(n int) Option {
+	return func(o *option) {
+		o.processes = n
+	}
+}
+
+// WithRoutes enables reporting the route count and the default gateways,
+// which are pinged from the remote host with every refresh.
+func WithRoutes(enabled bool) Option {
+	return func(o *option) {
+		o.routes = enabled
+	}
+}
+
+// WithFingerprint skips detecting the remote environment, using the results
+// of an earlier detection instead.
+func WithFingerprint(fp Fingerprint) Option {
+	return func(o *option) {
+		o.fingerprint = &fp
+	}
+}
+
+// WithFingerprintFunc calls fn with the detection results of the remote
+// environment once they are complete, e.g. to cache them. It is not called
+// if they were given with WithFingerprint.
+func WithFingerprintFunc(fn func(Fingerprint)) Option {
+	return func(o *option) {
+		o.onFingerprint = fn
+	}
+}
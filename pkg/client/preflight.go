@@ -0,0 +1,156 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Requirement is a file a collector reads or a command it runs on the
+// remote host.
+type Requirement struct {
+	Collector string
+	// File is the path which must be readable, Command the command which
+	// must be runnable; only one of them is set. Or is a command which
+	// will do instead of Command.
+	File    string
+	Command string
+	Or      string
+	// OK reports whether the remote user has the requirement
+	OK bool
+}
+
+func (r Requirement) String() string {
+	if r.File != "" {
+		if r.OK {
+			return "can read " + r.File
+		}
+		return "cannot read " + r.File
+	}
+	command := r.Command
+	if r.Or != "" {
+		command += " or " + r.Or
+	}
+	if r.OK {
+		return "has the " + command + " command"
+	}
+	return "no " + command + " command"
+}
+
+// requirements are what the core collectors of Linux hosts need. Collectors
+// which cope with missing files or commands themselves are not listed.
+var requirements = []Requirement{
+	{Collector: "uptime", File: "/proc/uptime"},
+	{Collector: "load", File: "/proc/loadavg"},
+	{Collector: "mem", File: "/proc/meminfo"},
+	{Collector: "swap", File: "/proc/vmstat"},
+	{Collector: "fs", Command: "/bin/df"},
+	{Collector: "diskio", File: "/proc/diskstats"},
+	{Collector: "netip", Command: "ip", Or: "ifconfig"},
+	{Collector: "netdev", File: "/proc/net/dev"},
+	{Collector: "cpu", File: "/proc/stat"},
+	{Collector: "clock", Command: "/bin/date"},
+	{Collector: "processes", Command: "ps"},
+}
+
+// Preflight checks whether the remote user can read the files and run the
+// commands the collectors need. GetStats runs it once and skips the
+// collectors which cannot work instead of failing on every refresh.
+func (c *Client) Preflight(ctx context.Context) ([]Requirement, error) {
+	var script strings.Builder
+	// ip lives in /sbin on some systems, outside the PATH of most users
+	script.WriteString("PATH=$PATH:/sbin:/usr/sbin; ")
+	for i, r := range requirements {
+		if r.File != "" {
+			fmt.Fprintf(&script, "test -r %s && echo %d; ", r.File, i)
+		} else if r.Or != "" {
+			fmt.Fprintf(&script, "{ command -v %s || command -v %s; } >/dev/null && echo %d; ", r.Command, r.Or, i)
+		} else {
+			fmt.Fprintf(&script, "command -v %s >/dev/null && echo %d; ", r.Command, i)
+		}
+	}
+	script.WriteString("true")
+
+	out, err := c.execute(ctx, script.String())
+	if err != nil {
+		return nil, fmt.Errorf("execute preflight: %s", err)
+	}
+
+	res := make([]Requirement, len(requirements))
+	copy(res, requirements)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var i int
+		if _, err := fmt.Sscan(scanner.Text(), &i); err == nil && i >= 0 && i < len(res) {
+			res[i].OK = true
+		}
+	}
+	return res, nil
+}
+
+// preflight runs Preflight once and records the collectors to skip.
+func (c *Client) preflight(ctx context.Context) {
+	c.mu.Lock()
+	done := c.disabled != nil
+	c.mu.Unlock()
+	if done {
+		return
+	}
+
+	reqs, err := c.Preflight(ctx)
+	if err != nil {
+		// try again on the next refresh
+		return
+	}
+	disabled := make(map[string]string)
+	for _, r := range reqs {
+		if !r.OK {
+			disabled[r.Collector] = r.String()
+		}
+	}
+
+	c.mu.Lock()
+	c.disabled = disabled
+	c.mu.Unlock()
+}
+
+// disabledCollectors returns the collectors skipped for lack of permissions
+// or commands, with the reason.
+func (c *Client) disabledCollectors() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.disabled) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(c.disabled))
+	for k, v := range c.disabled {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// processDetailCmd prints the details of a single process, one field per
+// line prefixed by its name, so they can be fetched in one round trip.
+const processDetailCmd = `cd /proc/%d && ` +
+	`echo "cmdline $(tr '\0' ' ' < cmdline)"; ` +
+	`echo "cwd $(readlink cwd)"; ` +
+	`echo "fds $(ls fd 2>/dev/null | wc -l)"; ` +
+	`sed -n 's/^Threads:[[:space:]]*/threads /p' status; ` +
+	`sed 's/^/cgroup /' cgroup`
+
+// GetProcessDetail returns the details of the process with the given pid.
+// Fields which the remote user is not allowed to read are left empty.
+func (c *Client) GetProcessDetail(ctx context.Context, pid int) (types.ProcessDetail, error) {
+	cmd := fmt.Sprintf(processDetailCmd, pid)
+	lines, err := c.execute(ctx, cmd)
+	if err != nil {
+		return types.ProcessDetail{}, fmt.Errorf("execute process detail for pid %d: %s", pid, err)
+	}
+
+	res := types.ProcessDetail{PID: pid}
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		key, val, _ := strings.Cut(scanner.Text(), " ")
+		val = strings.TrimSpace(val)
+		switch key {
+		case "cmdline":
+			res.Cmdline = val
+		case "cwd":
+			res.Cwd = val
+		case "fds":
+			res.OpenFDs, _ = strconv.Atoi(val)
+		case "threads":
+			res.Threads, _ = strconv.Atoi(val)
+		case "cgroup":
+			res.Cgroups = append(res.Cgroups, val)
+		}
+	}
+
+	return res, nil
+}
+
+// processesCmd lists all processes without a header. The command name comes
+// last since it may contain spaces.
+const processesCmd = "ps -eo pid=,user=,pcpu=,pmem=,rss=,stat=,comm="
+
+// GetProcesses returns the n processes using the most CPU together with the
+// n processes using the most memory, ordered by CPU usage, and a summary of
+// all processes. The CPU usage is the average over the lifetime of each
+// process, as reported by ps.
+func (c *Client) GetProcesses(ctx context.Context, n int) ([]types.Process, *types.ProcessSummary, error) {
+	lines, err := c.execute(ctx, processesCmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("execute %s: %s", processesCmd, err)
+	}
+
+	procs := parseProcesses(lines)
+	summary := summarizeProcesses(procs)
+	return topProcesses(procs, n), summary, nil
+}
+
+// parseProcesses parses the output of processesCmd.
+func parseProcesses(lines string) []types.Process {
+	var procs []types.Process
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(fields[2], 64)
+		mem, _ := strconv.ParseFloat(fields[3], 64)
+		rss, _ := strconv.ParseUint(fields[4], 10, 64)
+		procs = append(procs, types.Process{
+			PID:     pid,
+			User:    fields[1],
+			CPU:     cpu,
+			Mem:     mem,
+			RSS:     rss * 1024,
+			State:   fields[5],
+			Command: strings.Join(fields[6:], " "),
+		})
+	}
+
+	return procs
+}
+
+func summarizeProcesses(procs []types.Process) *types.ProcessSummary {
+	res := &types.ProcessSummary{Total: len(procs), ByUser: make(map[string]int)}
+	for _, p := range procs {
+		res.ByUser[p.User]++
+		if strings.HasPrefix(p.State, "Z") {
+			res.Zombies++
+		}
+	}
+	return res
+}
+
+func topProcesses(procs []types.Process, n int) []types.Process {
+	if n >= len(procs) {
+		sort.SliceStable(procs, func(i, j int) bool { return procs[i].CPU > procs[j].CPU })
+		return procs
+	}
+
+	picked := make(map[int]bool, 2*n)
+	res := make([]types.Process, 0, 2*n)
+	pick := func(less func(a, b types.Process) bool) {
+		sort.SliceStable(procs, func(i, j int) bool { return less(procs[i], procs[j]) })
+		for _, p := range procs[:n] {
+			if !picked[p.PID] {
+				picked[p.PID] = true
+				res = append(res, p)
+			}
+		}
+	}
+	pick(func(a, b types.Process) bool { return a.Mem > b.Mem })
+	pick(func(a, b types.Process) bool { return a.CPU > b.CPU })
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CPU > res[j].CPU })
+	return res
+}
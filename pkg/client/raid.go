@@ -0,0 +1,165 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// mdstatCmd lists the software RAID arrays; hosts without the md driver
+// have no /proc/mdstat.
+const mdstatCmd = "/bin/cat /proc/mdstat 2>/dev/null; true"
+
+// GetRAID returns the software RAID arrays of the remote host, with their
+// state and the progress of any resync. Hosts without arrays return no
+// error.
+func (c *Client) GetRAID(ctx context.Context) ([]types.RAIDInfo, error) {
+	lines, err := c.execute(ctx, mdstatCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute mdstat: %s", err)
+	}
+	return parseMdstat(lines), nil
+}
+
+// parseMdstat parses /proc/mdstat, which lists each array as a line naming
+// its state, level and devices, followed by indented lines with its status
+// and the progress of any resync:
+//
+//	md0 : active raid5 sdc1[3] sdb1[1] sda1[0](F)
+//	      1953260544 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [_UU]
+//	      [=>...................]  recovery =  8.5% (83118080/976630272) finish=95.3min speed=156204K/sec
+func parseMdstat(lines string) []types.RAIDInfo {
+	var res []types.RAIDInfo
+	var md *types.RAIDInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			md = nil
+			name, rest, ok := strings.Cut(line, " : ")
+			if !ok || !strings.HasPrefix(name, "md") {
+				continue
+			}
+			res = append(res, parseMdstatArray(name, rest))
+			md = &res[len(res)-1]
+			continue
+		}
+		if md == nil {
+			continue
+		}
+		for _, f := range strings.Fields(line) {
+			// the number of devices of the array and of those working,
+			// as [3/2]
+			if strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]") {
+				total, active, ok := strings.Cut(f[1:len(f)-1], "/")
+				if !ok {
+					continue
+				}
+				md.Total, _ = strconv.Atoi(total)
+				md.Active, _ = strconv.Atoi(active)
+			}
+		}
+		parseMdstatSync(md, line)
+	}
+	return res
+}
+
+// parseMdstatArray parses the first line of an array, after its name.
+func parseMdstatArray(name, line string) types.RAIDInfo {
+	md := types.RAIDInfo{Name: name}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return md
+	}
+	md.State, fields = fields[0], fields[1:]
+	// read-only arrays are shown as "active (read-only)"
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "(") {
+		md.State += " " + fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && !strings.Contains(fields[0], "[") {
+		md.Level, fields = fields[0], fields[1:]
+	}
+	for _, f := range fields {
+		dev, flags, _ := strings.Cut(f, "[")
+		switch {
+		case strings.Contains(flags, "(F)"):
+			md.Failed = append(md.Failed, dev)
+		case strings.Contains(flags, "(S)"):
+			md.Spares = append(md.Spares, dev)
+		default:
+			md.Devices = append(md.Devices, dev)
+		}
+	}
+	// inactive arrays have no status line
+	if md.State == "inactive" {
+		md.Total = len(md.Devices) + len(md.Failed) + len(md.Spares)
+	}
+	return md
+}
+
+// parseMdstatSync parses the progress of a resync, as in
+// "recovery =  8.5% (83118080/976630272) finish=95.3min speed=156204K/sec",
+// or of one waiting to start, as in "resync=DELAYED".
+func parseMdstatSync(md *types.RAIDInfo, line string) {
+	for _, action := range []string{"resync", "recovery", "reshape", "check", "repair"} {
+		i := strings.Index(line, action)
+		if i < 0 {
+			continue
+		}
+		rest := strings.TrimLeft(line[i+len(action):], " ")
+		if !strings.HasPrefix(rest, "=") {
+			continue
+		}
+		md.SyncAction = action
+		rest = strings.TrimLeft(rest[1:], " ")
+		fields := strings.Fields(rest)
+		if pct, _, ok := strings.Cut(rest, "%"); ok {
+			md.SyncProgress, _ = strconv.ParseFloat(pct, 64)
+		} else if len(fields) > 0 {
+			// DELAYED or PENDING
+			md.SyncAction += " " + strings.ToLower(fields[0])
+		}
+		for _, f := range fields {
+			if !strings.HasPrefix(f, "finish=") {
+				continue
+			}
+			v := strings.TrimSuffix(strings.TrimPrefix(f, "finish="), "min")
+			if mins, err := strconv.ParseFloat(v, 64); err == nil {
+				md.SyncFinish = time.Duration(mins * float64(time.Minute))
+			}
+		}
+		return
+	}
+}
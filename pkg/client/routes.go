@@ -0,0 +1,80 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// routesCmd prints the number of routes, followed by a "gateway address
+// interface rtt" line per default gateway, where rtt is the round trip of a
+// single ping in milliseconds, - if the gateway didn't answer or ? if there
+// is no ping command.
+const routesCmd = `echo "count $(ip route show 2>/dev/null | wc -l)"; ` +
+	`ip route show default 2>/dev/null | ` +
+	`awk '{g="";d="";for(i=1;i<NF;i++){if($i=="via")g=$(i+1);if($i=="dev")d=$(i+1)} if(g!="")print g, d}' | ` +
+	`while read -r g d; do ` +
+	`if command -v ping >/dev/null; then ` +
+	`t=$(ping -c 1 -W 1 "$g" 2>/dev/null | sed -n 's/.*time=\([0-9.]*\).*/\1/p'); ` +
+	`else t="?"; fi; ` +
+	`echo "gateway $g $d ${t:--}"; ` +
+	`done`
+
+// GetRoutes returns the number of routes and the default gateways of the
+// remote host, and whether the host can ping them.
+func (c *Client) GetRoutes(ctx context.Context) (types.Routes, error) {
+	lines, err := c.execute(ctx, routesCmd)
+	if err != nil {
+		return types.Routes{}, fmt.Errorf("execute ip route: %s", err)
+	}
+
+	var res types.Routes
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 2 && fields[0] == "count":
+			res.Count, _ = strconv.Atoi(fields[1])
+		case len(fields) == 4 && fields[0] == "gateway":
+			gw := types.Gateway{Address: fields[1], Interface: fields[2], Pinged: fields[3] != "?"}
+			if ms, err := strconv.ParseFloat(fields[3], 64); err == nil {
+				gw.Reachable = true
+				gw.RTT = time.Duration(ms * float64(time.Millisecond))
+			}
+			res.Gateways = append(res.Gateways, gw)
+		}
+	}
+
+	return res, nil
+}
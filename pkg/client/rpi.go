@@ -0,0 +1,103 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+const rpiHealthCmd = "vcgencmd measure_temp && vcgencmd get_throttled"
+
+// throttledFlags maps the bits reported by vcgencmd get_throttled to metric
+// names.
+var throttledFlags = []struct {
+	bit  uint
+	name string
+}{
+	{0, "under_voltage"},
+	{1, "freq_capped"},
+	{2, "throttled"},
+	{3, "soft_temp_limit"},
+	{16, "under_voltage_occurred"},
+	{17, "freq_capped_occurred"},
+	{18, "throttled_occurred"},
+	{19, "soft_temp_limit_occurred"},
+}
+
+func rpiMetrics() []ExtraMetric {
+	metrics := []ExtraMetric{{"rpi.temp_celsius", types.UnitCelsius}}
+	for _, f := range throttledFlags {
+		metrics = append(metrics, ExtraMetric{"rpi." + f.name, types.UnitFlag})
+	}
+	return metrics
+}
+
+// GetRPiHealth returns the SoC temperature and the throttling and
+// under-voltage flags of a Raspberry Pi. Each flag is reported as 1 if set
+// and 0 otherwise.
+func (c *Client) GetRPiHealth(ctx context.Context) (map[string]float64, error) {
+	lines, err := c.execute(ctx, rpiHealthCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute vcgencmd: %s", err)
+	}
+
+	res := make(map[string]float64)
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "temp":
+			temp, err := strconv.ParseFloat(strings.TrimSuffix(value, "'C"), 64)
+			if err != nil {
+				continue
+			}
+			res["rpi.temp_celsius"] = temp
+		case "throttled":
+			flags, err := strconv.ParseUint(value, 0, 32)
+			if err != nil {
+				continue
+			}
+			for _, f := range throttledFlags {
+				res["rpi."+f.name] = float64(flags >> f.bit & 1)
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("unexpected vcgencmd format: %s", lines)
+	}
+
+	return res, nil
+}
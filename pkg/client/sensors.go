@@ -0,0 +1,126 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// sensorsCmd prints one tab separated "name millidegrees" line per
+// temperature sensor found in sysfs.
+const sensorsCmd = `for z in /sys/class/thermal/thermal_zone*; do ` +
+	`[ -r "$z/temp" ] && printf '%s\t%s\n' "$(cat "$z/type")" "$(cat "$z/temp")"; ` +
+	`done; ` +
+	`for h in /sys/class/hwmon/hwmon*; do ` +
+	`n=$(cat "$h/name" 2>/dev/null); ` +
+	`for t in "$h"/temp*_input; do ` +
+	`[ -r "$t" ] || continue; ` +
+	`l=$(cat "${t%_input}_label" 2>/dev/null || basename "${t%_input}"); ` +
+	`printf '%s %s\t%s\n' "$n" "$l" "$(cat "$t")"; ` +
+	`done; done; true`
+
+// GetSensors returns the temperatures reported by the thermal zones and
+// hwmon devices of the remote host, falling back to lm-sensors if sysfs
+// has none. Hosts without any sensors return no error.
+func (c *Client) GetSensors(ctx context.Context) ([]types.Sensor, error) {
+	lines, err := c.execute(ctx, sensorsCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute sensors: %s", err)
+	}
+
+	var res []types.Sensor
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		name, val, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		milli, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			continue
+		}
+		res = append(res, types.Sensor{Name: strings.TrimSpace(name), Temp: milli / 1000})
+	}
+	if len(res) > 0 {
+		return res, nil
+	}
+
+	lines, err = c.execute(ctx, "sensors -u")
+	if err != nil {
+		return nil, nil
+	}
+	return parseSensorsU(lines), nil
+}
+
+// parseSensorsU parses the temperature inputs from the output of
+// "sensors -u", which lists the features of each chip as:
+//
+//	coretemp-isa-0000
+//	Adapter: ISA adapter
+//	Package id 0:
+//	  temp1_input: 45.000
+func parseSensorsU(lines string) []types.Sensor {
+	var res []types.Sensor
+	var chip, feature string
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			chip = ""
+		case !strings.HasPrefix(line, " "):
+			if key, _, ok := strings.Cut(line, ":"); ok {
+				if key != "Adapter" {
+					feature = key
+				}
+			} else {
+				chip = line
+			}
+		default:
+			key, val, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if !ok || !strings.HasPrefix(key, "temp") || !strings.HasSuffix(key, "_input") {
+				continue
+			}
+			temp, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil {
+				continue
+			}
+			name := feature
+			if i := strings.Index(chip, "-"); i > 0 {
+				name = chip[:i] + " " + feature
+			}
+			res = append(res, types.Sensor{Name: name, Temp: temp})
+		}
+	}
+	return res
+}
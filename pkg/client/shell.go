@@ -0,0 +1,178 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// shellProbe is run before any collector to find out whether the login
+// shell runs arbitrary commands. Restricted shells like rbash refuse
+// command names with a slash, which the collectors all use, and appliance
+// CLIs refuse anything but their own commands.
+const shellProbe = "/bin/echo rtop-shell-ok"
+
+// CheckShell checks that the login shell of the remote user runs the
+// commands of the collectors, returning an error explaining what to do if
+// it does not. Once the check passed it is not repeated.
+func (c *Client) CheckShell(ctx context.Context) error {
+	c.mu.Lock()
+	ok := c.shellOK
+	c.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	out, err := c.runner.Execute(ctx, shellProbe)
+	var sshExit *ssh.ExitError
+	var localExit *exec.ExitError
+	if err != nil && !errors.As(err, &sshExit) && !errors.As(err, &localExit) {
+		// not an answer from the shell, so nothing to tell yet
+		return err
+	}
+	if err != nil || strings.TrimSpace(out) != "rtop-shell-ok" {
+		reason := "it failed"
+		if err != nil {
+			reason = err.Error()
+		}
+		if out = strings.TrimSpace(out); out != "" {
+			reason = fmt.Sprintf("it printed %q", firstLine(out))
+		}
+		return fmt.Errorf("restricted shell: the remote login shell did not run %q, %s; "+
+			"run commands through a wrapper the shell allows, such as \"sh -c\", or use an account with a regular shell",
+			shellProbe, reason)
+	}
+
+	c.mu.Lock()
+	c.shellOK = true
+	c.mu.Unlock()
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// nicePrefix lowers the cpu and io priority of the shell running a
+// command, which the command inherits. ionice is not always installed.
+const nicePrefix = "renice -n 19 -p $$ >/dev/null 2>&1; ionice -c 3 -p $$ >/dev/null 2>&1; "
+
+// wrapRunner runs every command after a prefix, or as a single argument of
+// a wrapper for restricted shells which allow the wrapper but not the
+// command itself, e.g. "sh -c" or "busybox sh -c".
+type wrapRunner struct {
+	runner
+	prefix  string
+	wrapper string
+}
+
+// wrapCommands runs the commands of r at a low priority if nice is set,
+// and through the wrapper if one is given. The priority is lowered inside
+// the wrapper, as the restricted shell the wrapper is for may not run
+// renice and ionice itself.
+func wrapCommands(r runner, nice bool, wrapper string) runner {
+	if !nice && wrapper == "" {
+		return r
+	}
+	w := wrapRunner{runner: r, wrapper: wrapper}
+	if nice {
+		w.prefix = nicePrefix
+	}
+	return w
+}
+
+func (w wrapRunner) Execute(ctx context.Context, command string) (string, error) {
+	command = w.prefix + command
+	if w.wrapper != "" {
+		command = w.wrapper + " " + shellQuote(command)
+	}
+	return w.runner.Execute(ctx, command)
+}
+
+// timeoutProbe checks that timeout(1) is installed and takes a signal and
+// a duration, which old BusyBox versions do not.
+const timeoutProbe = "timeout -s KILL 5 true && echo rtop-timeout-ok; true"
+
+// timeoutGrace is how much longer than the remote timeout the client waits
+// for a command, so that the remote side gives up first.
+const timeoutGrace = time.Second
+
+// timeoutRunner bounds every command by a deadline on the client and, on
+// remote hosts with timeout(1), by killing it on the remote host too, so
+// that commands abandoned after a network blip do not pile up there.
+type timeoutRunner struct {
+	runner
+	timeout time.Duration
+	remote  bool
+
+	mu sync.Mutex
+	// probed is set once it is known whether available
+	probed    bool
+	available bool
+}
+
+func (t *timeoutRunner) Execute(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout+timeoutGrace)
+	defer cancel()
+
+	if t.remote && t.remoteTimeout(ctx) {
+		secs := int(math.Ceil(t.timeout.Seconds()))
+		command = fmt.Sprintf("timeout -s KILL %d sh -c %s", secs, shellQuote(command))
+	}
+	return t.runner.Execute(ctx, command)
+}
+
+// remoteTimeout reports whether the remote host has a usable timeout(1),
+// probing it on first use. A failing probe is retried on the next command.
+func (t *timeoutRunner) remoteTimeout(ctx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.probed {
+		return t.available
+	}
+	out, err := t.runner.Execute(ctx, timeoutProbe)
+	if err != nil {
+		return false
+	}
+	t.probed = true
+	t.available = strings.TrimSpace(out) == "rtop-timeout-ok"
+	return t.available
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
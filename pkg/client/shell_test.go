@@ -0,0 +1,87 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rapidloop/rtop/internal/ssh"
+)
+
+// recordRunner records the commands it is given and answers none.
+type recordRunner struct {
+	commands []string
+}
+
+func (r *recordRunner) Execute(ctx context.Context, command string) (string, error) {
+	r.commands = append(r.commands, command)
+	return "", nil
+}
+
+func (r *recordRunner) Timings() ssh.Timings {
+	return ssh.Timings{}
+}
+
+func TestWrapCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		nice    bool
+		wrapper string
+		want    string
+	}{
+		{"none", false, "", "cat /proc/loadavg"},
+		{"nice", true, "", nicePrefix + "cat /proc/loadavg"},
+		{"wrapper", false, "sh -c", "sh -c 'cat /proc/loadavg'"},
+		{"nice and wrapper", true, "busybox sh -c", "busybox sh -c " + shellQuote(nicePrefix+"cat /proc/loadavg")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordRunner{}
+			wrapCommands(rec, tt.nice, tt.wrapper).Execute(context.Background(), "cat /proc/loadavg")
+			if len(rec.commands) != 1 || rec.commands[0] != tt.want {
+				t.Errorf("ran %q, want %q", rec.commands, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCommandsNiceInsideWrapper(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc/self/stat")
+	}
+	// the 19th field of stat is the nice value of the process
+	r := wrapCommands(&localRunner{}, true, "sh -c")
+	out, err := r.Execute(context.Background(), "cut -d ' ' -f 19 /proc/self/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out); got != "19" {
+		t.Errorf("command ran with nice value %s, want 19", got)
+	}
+}
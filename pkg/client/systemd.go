@@ -0,0 +1,73 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// systemdCmd prints the system state and one line per failed unit, or
+// nothing on hosts without systemd.
+const systemdCmd = `command -v systemctl >/dev/null || exit 0; ` +
+	`echo "state $(systemctl is-system-running 2>/dev/null)"; ` +
+	`systemctl list-units --failed --plain --no-legend 2>/dev/null | ` +
+	`awk '{u=$1; if (u=="●" || u=="*") u=$2; print "failed " u}'`
+
+// GetSystemdStatus returns the overall state of systemd, such as running or
+// degraded, and the names of the failed units. It returns nil for hosts not
+// running systemd.
+func (c *Client) GetSystemdStatus(ctx context.Context) (*types.Systemd, error) {
+	lines, err := c.execute(ctx, systemdCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute systemctl: %s", err)
+	}
+	if strings.TrimSpace(lines) == "" {
+		return nil, nil
+	}
+
+	res := &types.Systemd{}
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		key, val, _ := strings.Cut(scanner.Text(), " ")
+		val = strings.TrimSpace(val)
+		switch key {
+		case "state":
+			res.State = val
+		case "failed":
+			if val != "" {
+				res.Failed = append(res.Failed, val)
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,82 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// tasksCmd prints the number of processes and threads and the kernel limits
+// on them. The threads are counted by the kernel, as the last part of the
+// running/total field of /proc/loadavg, as listing them all takes long on
+// hosts with many.
+const tasksCmd = `echo "processes $(ls -d /proc/[0-9]* | wc -l)"; ` +
+	`echo "threads $(cut -d ' ' -f 4 /proc/loadavg)"; ` +
+	`echo "pid_max $(cat /proc/sys/kernel/pid_max)"; ` +
+	`echo "threads_max $(cat /proc/sys/kernel/threads-max)"`
+
+// GetTasks returns the number of processes and threads together with the
+// kernel.pid_max and kernel.threads-max limits. Once either is reached
+// fork fails, which is often first noticed as "Resource temporarily
+// unavailable" from a leaking service.
+func (c *Client) GetTasks(ctx context.Context) (*types.Tasks, error) {
+	lines, err := c.execute(ctx, tasksCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute task count: %s", err)
+	}
+
+	res := &types.Tasks{}
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		key, val, _ := strings.Cut(scanner.Text(), " ")
+		if key == "threads" {
+			_, val, _ = strings.Cut(val, "/")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "processes":
+			res.Processes = n
+		case "threads":
+			res.Threads = n
+		case "pid_max":
+			res.PIDMax = n
+		case "threads_max":
+			res.ThreadsMax = n
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,122 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// coreCollectors are the collectors which run unless turned off with
+// WithoutCollectors or SetCollector. The hostname and uptime are always
+// collected.
+var coreCollectors = []string{"load", "cpu", "mem", "swap", "fs", "diskio", "netip", "netdev", "sensors", "raid", "nfs", "tasks", "systemd", "clock"}
+
+// optionalCollectors are the collectors which, like the extra ones, only
+// run once enabled with WithCollectors or SetCollector, but which report
+// in a field of their own rather than in the Extra map.
+var optionalCollectors = []string{"gpu", "zfs"}
+
+// isOptional reports whether name is one of optionalCollectors.
+func isOptional(name string) bool {
+	for _, n := range optionalCollectors {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultProcesses is how many processes are listed when the process
+// collector is turned on with SetCollector without WithProcesses.
+const DefaultProcesses = 10
+
+// Collectors lists the collectors which can be turned on and off with
+// SetCollector: the core ones, those enabled by options and the extra
+// ones, in that order.
+func (c *Client) Collectors() []types.CollectorState {
+	names := append([]string(nil), coreCollectors...)
+	names = append(names, "processes", "routes", "cloud")
+	names = append(names, optionalCollectors...)
+	if len(c.fsProbe) > 0 {
+		names = append(names, "fsprobe")
+	}
+	extra := make([]string, 0, len(c.extra))
+	for name := range c.extra {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]types.CollectorState, len(names))
+	for i, name := range names {
+		res[i] = types.CollectorState{Name: name, Enabled: !c.off[name], Unavailable: c.disabled[name]}
+	}
+	return res
+}
+
+// SetCollector turns the named collector on or off, from the next call of
+// GetStats on.
+func (c *Client) SetCollector(name string, enabled bool) error {
+	for _, s := range c.Collectors() {
+		if s.Name == name {
+			c.mu.Lock()
+			c.off[name] = !enabled
+			c.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown collector %q", name)
+}
+
+// isCollector reports whether name is a collector which can be turned off.
+func isCollector(name string) bool {
+	for _, n := range coreCollectors {
+		if n == name {
+			return true
+		}
+	}
+	switch name {
+	case "processes", "routes", "cloud", "fsprobe":
+		return true
+	}
+	if isOptional(name) {
+		return true
+	}
+	_, ok := extraCollectors[name]
+	return ok
+}
+
+// isOff reports whether the collector was turned off.
+func (c *Client) isOff(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.off[name]
+}
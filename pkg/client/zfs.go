@@ -0,0 +1,135 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// zpoolListCmd lists the pools, one per tab separated line, with exact
+// sizes in bytes and percentages without a % sign.
+const zpoolListCmd = "zpool list -Hp -o name,size,alloc,free,frag,cap,health"
+
+// zpoolStatusCmd describes the pools with problems only.
+const zpoolStatusCmd = "zpool status -x"
+
+// GetZPools returns the health, capacity and fragmentation of the ZFS pools
+// of the remote host. The pools which are not ONLINE get the explanation of
+// zpool status.
+func (c *Client) GetZPools(ctx context.Context) ([]types.ZPool, error) {
+	lines, err := c.execute(ctx, zpoolListCmd)
+	if err != nil {
+		return nil, fmt.Errorf("execute zpool list: %s", err)
+	}
+	pools := parseZpoolList(lines)
+
+	for _, p := range pools {
+		if p.Healthy() {
+			continue
+		}
+		out, err := c.execute(ctx, zpoolStatusCmd)
+		if err != nil {
+			return pools, fmt.Errorf("execute zpool status: %s", err)
+		}
+		status := parseZpoolStatus(out)
+		for i := range pools {
+			pools[i].Status = status[pools[i].Name]
+		}
+		break
+	}
+	return pools, nil
+}
+
+// parseZpoolList parses the output of zpoolListCmd. Pools without a
+// fragmentation, such as those of old versions, report it as -.
+func parseZpoolList(lines string) []types.ZPool {
+	var res []types.ZPool
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "\t")
+		if len(parts) != 7 {
+			continue
+		}
+		size, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		alloc, _ := strconv.ParseUint(parts[2], 10, 64)
+		free, _ := strconv.ParseUint(parts[3], 10, 64)
+		frag, _ := strconv.ParseFloat(strings.TrimSuffix(parts[4], "%"), 64)
+		capacity, _ := strconv.ParseFloat(strings.TrimSuffix(parts[5], "%"), 64)
+		res = append(res, types.ZPool{
+			Name:          parts[0],
+			Size:          size,
+			Alloc:         alloc,
+			Free:          free,
+			Fragmentation: frag,
+			Capacity:      capacity,
+			Health:        parts[6],
+		})
+	}
+	return res
+}
+
+// parseZpoolStatus returns the status line of each pool zpool status -x
+// describes, which may continue over indented lines:
+//
+//	  pool: tank
+//	 state: DEGRADED
+//	status: One or more devices could not be used because the label is
+//		missing or invalid.
+func parseZpoolStatus(lines string) map[string]string {
+	res := make(map[string]string)
+	var pool, key string
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			if key == "status" && pool != "" {
+				res[pool] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		key = k
+		switch key {
+		case "pool":
+			pool = strings.TrimSpace(v)
+		case "status":
+			res[pool] = strings.TrimSpace(v)
+		}
+	}
+	return res
+}
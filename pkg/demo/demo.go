@@ -0,0 +1,460 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package demo makes up the stats of hosts for rtop demo, to explore the
+// TUI, try alert rules and develop sinks without a remote host. The values
+// wander randomly around typical levels, with spikes injected at random or
+// at given samples. The same seed gives the same stats, sample by sample.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// SpikeKinds are the kinds of spikes which can be injected: the CPU busy,
+// memory nearly full, the load high, network or disk traffic bursting, a
+// filesystem nearly full and a hot CPU.
+var SpikeKinds = []string{"cpu", "mem", "load", "net", "disk", "fs", "temp"}
+
+// collectors are the collectors which can be turned on and off.
+var collectors = []string{"load", "cpu", "mem", "swap", "fs", "diskio", "netdev", "sensors", "processes", "tasks"}
+
+const (
+	cores    = 4
+	memTotal = 16 << 30
+	gib      = 1 << 30
+)
+
+type option struct {
+	seed        int64
+	interval    time.Duration
+	spikeChance float64
+	spikeLength int
+	spikes      map[int][]string
+}
+
+type Option func(o *option)
+
+// WithSeed sets the seed of the random walks and spikes.
+func WithSeed(seed int64) Option {
+	return func(o *option) {
+		o.seed = seed
+	}
+}
+
+// WithInterval sets the time between samples, which the uptime, counters
+// and rates are based on. It defaults to 5s.
+func WithInterval(d time.Duration) Option {
+	return func(o *option) {
+		o.interval = d
+	}
+}
+
+// WithSpikeChance sets the probability of each kind of spike starting at
+// any sample.
+func WithSpikeChance(p float64) Option {
+	return func(o *option) {
+		o.spikeChance = p
+	}
+}
+
+// WithSpikeLength sets how many samples a spike lasts, 5 by default.
+func WithSpikeLength(n int) Option {
+	return func(o *option) {
+		o.spikeLength = n
+	}
+}
+
+// WithSpikeAt starts a spike of the given kind at the given sample,
+// counting from 0.
+func WithSpikeAt(kind string, sample int) Option {
+	return func(o *option) {
+		if o.spikes == nil {
+			o.spikes = make(map[int][]string)
+		}
+		o.spikes[sample] = append(o.spikes[sample], kind)
+	}
+}
+
+// Host is a made-up host. It has the methods of client.Client the TUI
+// uses.
+type Host struct {
+	name   string
+	opt    option
+	uptime time.Duration
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	sample int
+	off    map[string]bool
+	// spiking holds the samples left of the running spikes
+	spiking map[string]int
+
+	// the levels of the random walks, 0 to 1
+	cpu, mem, net, disk, fs float64
+	load                    [3]float64
+
+	cpuRaw        types.CPURaw
+	rx, tx        uint64
+	reads, writes uint64
+	readBytes     uint64
+	writeBytes    uint64
+	pagesOut      uint64
+}
+
+// New returns a made-up host of the given name.
+func New(name string, opts ...Option) (*Host, error) {
+	o := option{interval: 5 * time.Second, spikeLength: 5}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, kinds := range o.spikes {
+		for _, kind := range kinds {
+			if !isSpikeKind(kind) {
+				return nil, fmt.Errorf("unknown spike %q, expected one of %s", kind, strings.Join(SpikeKinds, ", "))
+			}
+		}
+	}
+	if o.interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s", o.interval)
+	}
+
+	rng := rand.New(rand.NewSource(o.seed))
+	h := &Host{
+		name:    name,
+		opt:     o,
+		uptime:  72*time.Hour + time.Duration(rng.Intn(7*24*3600))*time.Second,
+		rng:     rng,
+		off:     make(map[string]bool),
+		spiking: make(map[string]int),
+		cpu:     0.1 + 0.3*rng.Float64(),
+		mem:     0.3 + 0.3*rng.Float64(),
+		net:     0.2 * rng.Float64(),
+		disk:    0.2 * rng.Float64(),
+		fs:      0.4 + 0.3*rng.Float64(),
+	}
+	for i := range h.load {
+		h.load[i] = h.cpu * cores
+	}
+	return h, nil
+}
+
+func isSpikeKind(kind string) bool {
+	for _, k := range SpikeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Spike starts a spike of the given kind from the next sample on.
+func (h *Host) Spike(kind string) error {
+	if !isSpikeKind(kind) {
+		return fmt.Errorf("unknown spike %q, expected one of %s", kind, strings.Join(SpikeKinds, ", "))
+	}
+	h.mu.Lock()
+	h.spiking[kind] = h.opt.spikeLength
+	h.mu.Unlock()
+	return nil
+}
+
+// walk moves v randomly, pulled back towards base, within 0 and 1.
+func (h *Host) walk(v, base, step float64) float64 {
+	v += (base-v)*0.1 + h.rng.NormFloat64()*step
+	return math.Max(0, math.Min(1, v))
+}
+
+// level returns the level of a walk, or high while its kind spikes.
+func (h *Host) level(kind string, v, high float64) float64 {
+	if h.spiking[kind] > 0 {
+		return high
+	}
+	return v
+}
+
+// GetStats returns the next sample.
+func (h *Host) GetStats(ctx context.Context) (types.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Stats{}, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for kind, left := range h.spiking {
+		if left <= 1 {
+			delete(h.spiking, kind)
+		} else {
+			h.spiking[kind] = left - 1
+		}
+	}
+	for _, kind := range h.opt.spikes[h.sample] {
+		h.spiking[kind] = h.opt.spikeLength
+	}
+	if h.opt.spikeChance > 0 {
+		for _, kind := range SpikeKinds {
+			if h.rng.Float64() < h.opt.spikeChance {
+				h.spiking[kind] = h.opt.spikeLength
+			}
+		}
+	}
+
+	secs := h.opt.interval.Seconds()
+	h.cpu = h.walk(h.cpu, 0.25, 0.05)
+	h.mem = h.walk(h.mem, 0.45, 0.02)
+	h.net = h.walk(h.net, 0.1, 0.05)
+	h.disk = h.walk(h.disk, 0.1, 0.05)
+	h.fs = math.Min(0.9, h.fs+0.0005*h.rng.Float64())
+
+	busy := h.level("cpu", h.cpu, 0.97)
+	load := busy * cores
+	if h.spiking["load"] > 0 {
+		load = 6 * cores
+	}
+	for i, minutes := range []float64{1, 5, 15} {
+		h.load[i] += (load - h.load[i]) * (1 - math.Exp(-secs/60/minutes))
+	}
+	disk := h.level("disk", h.disk, 0.95)
+	iowait := 2 + 20*disk
+	cpu := types.CPUInfo{
+		User:   float32(busy * 70),
+		System: float32(busy * 22),
+		IOWait: float32(math.Min(iowait, 100-busy*92)),
+		Steal:  float32(busy * 2),
+	}
+	cpu.Idle = 100 - cpu.User - cpu.System - cpu.IOWait - cpu.Steal
+
+	ticks := secs * 100 * cores
+	h.cpuRaw.User += uint64(float64(cpu.User) * ticks / 100)
+	h.cpuRaw.System += uint64(float64(cpu.System) * ticks / 100)
+	h.cpuRaw.Iowait += uint64(float64(cpu.IOWait) * ticks / 100)
+	h.cpuRaw.Steal += uint64(float64(cpu.Steal) * ticks / 100)
+	h.cpuRaw.Idle += uint64(float64(cpu.Idle) * ticks / 100)
+	h.cpuRaw.Total = h.cpuRaw.User + h.cpuRaw.System + h.cpuRaw.Iowait + h.cpuRaw.Steal + h.cpuRaw.Idle
+
+	stats := types.Stats{
+		Uptime:   h.uptime + time.Duration(h.sample)*h.opt.interval,
+		Hostname: h.name,
+		Labels:   map[string]string{"demo": "true"},
+		Meta:     types.Meta{Collection: 20 * time.Millisecond},
+	}
+	on := func(name string) bool { return !h.off[name] }
+
+	if on("load") {
+		procs := 180 + h.rng.Intn(20)
+		stats.Loads = types.Loads{
+			Load1:        strconv.FormatFloat(h.load[0], 'f', 2, 64),
+			Load5:        strconv.FormatFloat(h.load[1], 'f', 2, 64),
+			Load15:       strconv.FormatFloat(h.load[2], 'f', 2, 64),
+			RunningProcs: strconv.Itoa(1 + int(load)),
+			TotalProcs:   strconv.Itoa(procs),
+		}
+	}
+	if on("cpu") {
+		stats.CPU = cpu
+		stats.CPURaw = h.cpuRaw
+		for i := 0; i < cores; i++ {
+			core := cpu
+			core.Core = "cpu" + strconv.Itoa(i)
+			jitter := float32(h.rng.NormFloat64() * 5)
+			core.User = float32(math.Max(0, math.Min(float64(core.User+jitter), float64(100-core.System-core.IOWait-core.Steal))))
+			core.Idle = 100 - core.User - core.System - core.IOWait - core.Steal
+			stats.Cores = append(stats.Cores, core)
+		}
+	}
+
+	mem := h.level("mem", h.mem, 0.97)
+	if on("mem") {
+		buffers := uint64(300 << 20)
+		used := uint64(mem * float64(memTotal-buffers))
+		cached := (memTotal - buffers - used) * 6 / 10
+		stats.MEM = types.MemInfo{
+			Total:     memTotal,
+			Buffers:   buffers,
+			Cached:    cached,
+			Free:      memTotal - buffers - used - cached,
+			SwapTotal: 2 * gib,
+			SwapFree:  2*gib - uint64(math.Max(0, mem-0.8)*10*gib),
+		}
+	}
+	if on("swap") {
+		var out float64
+		if mem > 0.9 {
+			out = 2000 * (mem - 0.9) * 10
+		}
+		h.pagesOut += uint64(out * secs)
+		stats.SwapActivity = types.SwapActivity{PagesOut: h.pagesOut, OutRate: out}
+	}
+
+	if on("fs") {
+		root := h.level("fs", h.fs, 0.97)
+		stats.FSInfos = []types.FSInfo{
+			fsInfo("/dev/sda1", "/", 100*gib, root),
+			fsInfo("/dev/sdb1", "/var", 500*gib, 0.3+0.2*h.fs),
+			fsInfo("tmpfs", "/run", 1600<<20, 0.01),
+		}
+	}
+
+	if on("diskio") {
+		read, write := disk*200e6, disk*120e6+2e6
+		h.reads += uint64(read / 64e3 * secs)
+		h.writes += uint64(write / 32e3 * secs)
+		h.readBytes += uint64(read * secs)
+		h.writeBytes += uint64(write * secs)
+		stats.DiskIO = []types.DiskIO{{
+			DiskIORaw: types.DiskIORaw{Device: "sda", Reads: h.reads, Writes: h.writes, ReadBytes: h.readBytes, WriteBytes: h.writeBytes},
+			ReadRate:  read,
+			WriteRate: write,
+			ReadIOPS:  read / 64e3,
+			WriteIOPS: write / 32e3,
+		}}
+	}
+
+	if on("netdev") {
+		net := h.level("net", h.net, 0.95)
+		rx, tx := net*120e6, net*30e6+5e3
+		h.rx += uint64(rx * secs)
+		h.tx += uint64(tx * secs)
+		stats.NetInterface = map[string]types.NetInterface{
+			"eth0": {
+				NetIPAddr:  types.NetIPAddr{IPv4: "10.0.0." + strconv.Itoa(10+len(h.name)%200) + "/24"},
+				NetDevInfo: types.NetDevInfo{Rx: h.rx, Tx: h.tx, RxRate: rx, TxRate: tx},
+			},
+			"lo": {NetIPAddr: types.NetIPAddr{IPv4: "127.0.0.1/8", IPv6: "::1/128"}},
+		}
+	}
+
+	if on("sensors") {
+		temp := 38 + busy*40 + h.rng.NormFloat64()
+		if h.spiking["temp"] > 0 {
+			temp = 92
+		}
+		stats.Sensors = []types.Sensor{{Name: "Package id 0", Temp: temp}}
+	}
+
+	if on("processes") {
+		stats.Processes, stats.ProcessSummary = h.processes(busy, mem)
+	}
+	if on("tasks") {
+		stats.Tasks = &types.Tasks{Processes: 190, Threads: 900 + h.rng.Intn(100), PIDMax: 4194304, ThreadsMax: 126000}
+	}
+
+	h.sample++
+	return stats, nil
+}
+
+func fsInfo(device, mount string, total uint64, used float64) types.FSInfo {
+	u := uint64(used * float64(total))
+	inodes := total / (16 << 10)
+	return types.FSInfo{
+		Device:      device,
+		MountPoint:  mount,
+		FSType:      "ext4",
+		Total:       total,
+		Used:        u,
+		Free:        total - u,
+		InodesTotal: inodes,
+		InodesUsed:  uint64(used * float64(inodes) / 4),
+		InodesFree:  inodes - uint64(used*float64(inodes)/4),
+	}
+}
+
+// programs are the processes of a made-up host, with their share of the
+// busy CPU and of the used memory.
+var programs = []struct {
+	user, command string
+	cpu, mem      float64
+}{
+	{"postgres", "postgres: checkpointer", 0.30, 0.35},
+	{"www-data", "nginx: worker process", 0.25, 0.05},
+	{"app", "java -jar /opt/app/app.jar", 0.20, 0.40},
+	{"redis", "redis-server *:6379", 0.10, 0.10},
+	{"root", "/usr/sbin/sshd -D", 0.01, 0.01},
+	{"root", "/lib/systemd/systemd-journald", 0.02, 0.02},
+	{"root", "/usr/bin/containerd", 0.03, 0.03},
+}
+
+func (h *Host) processes(busy, mem float64) ([]types.Process, *types.ProcessSummary) {
+	var procs []types.Process
+	for i, p := range programs {
+		share := math.Max(0, p.cpu+h.rng.NormFloat64()*0.03)
+		procs = append(procs, types.Process{
+			PID:     1000 + i*137,
+			User:    p.user,
+			CPU:     share * busy * 100 * cores,
+			Mem:     p.mem * mem * 100,
+			RSS:     uint64(p.mem * mem * memTotal),
+			State:   "S",
+			Command: p.command,
+		})
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].CPU > procs[j].CPU })
+
+	summary := &types.ProcessSummary{Total: 190, ByUser: make(map[string]int)}
+	for _, p := range programs {
+		summary.ByUser[p.user]++
+	}
+	summary.ByUser["root"] += 180 - len(programs)
+	return procs, summary
+}
+
+// Collectors lists the collectors which can be turned on and off.
+func (h *Host) Collectors() []types.CollectorState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := make([]types.CollectorState, len(collectors))
+	for i, name := range collectors {
+		res[i] = types.CollectorState{Name: name, Enabled: !h.off[name]}
+	}
+	return res
+}
+
+// SetCollector turns the named collector on or off.
+func (h *Host) SetCollector(name string, enabled bool) error {
+	for _, n := range collectors {
+		if n == name {
+			h.mu.Lock()
+			h.off[name] = !enabled
+			h.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown collector %q", name)
+}
+
+// Banner returns a banner telling the host is made up.
+func (h *Host) Banner(ctx context.Context) (string, error) {
+	return h.name + " is a demo host, its stats are made up by rtop demo.", nil
+}
@@ -0,0 +1,283 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package replay plays back sessions recorded with rtop record, files of
+// JSON lines in the format of the json sink, one sample per line, or of
+// the delta sink, whose lines after the first one of a host only have the
+// fields which changed.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/sink"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Session is a recorded session.
+type Session struct {
+	// Hosts are the recorded hosts in the order they first appear
+	Hosts []string
+	// Start and End are the times of the first and last sample
+	Start, End time.Time
+
+	samples map[string][]sink.HostStats
+}
+
+// Load reads the session recorded in the files at paths. The samples of
+// several files are merged by time, so that recordings of different hosts
+// play in sync. A host recorded in several files is shown once per file,
+// named after the file too.
+func Load(paths ...string) (*Session, error) {
+	s := &Session{samples: make(map[string][]sink.HostStats)}
+	for _, path := range paths {
+		if err := s.load(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, samples := range s.samples {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Time.Before(samples[j].Time)
+		})
+	}
+	return s, nil
+}
+
+// load adds the samples of a file to the session.
+func (s *Session) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// names maps the hosts of the file to their names in the session, and
+	// stats holds the stats of the previous sample of each, which the
+	// delta of the next one applies to
+	names := make(map[string]string)
+	stats := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var line struct {
+			Host  string          `json:"host"`
+			Time  time.Time       `json:"time"`
+			Stats json.RawMessage `json:"stats"`
+			Delta json.RawMessage `json:"delta"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		if line.Delta != nil {
+			prev, ok := stats[line.Host]
+			if !ok {
+				return fmt.Errorf("%s:%d: delta of %s without a previous sample", path, n, line.Host)
+			}
+			full, err := sink.ApplyDelta(prev, line.Delta)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", path, n, err)
+			}
+			line.Stats = full
+		}
+		hs := sink.HostStats{Host: line.Host, Time: line.Time}
+		if line.Stats != nil {
+			if err := json.Unmarshal(line.Stats, &hs.Stats); err != nil {
+				return fmt.Errorf("%s:%d: %s", path, n, err)
+			}
+		}
+		stats[line.Host] = line.Stats
+		name, ok := names[hs.Host]
+		if !ok {
+			name = hs.Host
+			if _, taken := s.samples[name]; taken {
+				name = fmt.Sprintf("%s (%s)", hs.Host, filepath.Base(path))
+			}
+			names[hs.Host] = name
+			s.Hosts = append(s.Hosts, name)
+		}
+		hs.Host = name
+		s.samples[name] = append(s.samples[name], hs)
+		if s.Start.IsZero() || hs.Time.Before(s.Start) {
+			s.Start = hs.Time
+		}
+		if hs.Time.After(s.End) {
+			s.End = hs.Time
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%s: no samples recorded", path)
+	}
+	return nil
+}
+
+// Interval returns the shortest of the median intervals between the
+// samples of each host, or 0 if every host has a single sample.
+func (s *Session) Interval() time.Duration {
+	var interval time.Duration
+	for _, samples := range s.samples {
+		if len(samples) < 2 {
+			continue
+		}
+		d := make([]time.Duration, 0, len(samples)-1)
+		for i := 1; i < len(samples); i++ {
+			d = append(d, samples[i].Time.Sub(samples[i-1].Time))
+		}
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		if median := d[len(d)/2]; interval == 0 || median < interval {
+			interval = median
+		}
+	}
+	return interval
+}
+
+// at returns the latest sample of the host at or before t.
+func (s *Session) at(host string, t time.Time) (sink.HostStats, bool) {
+	samples := s.samples[host]
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Time.After(t)
+	})
+	if i == 0 {
+		return sink.HostStats{}, false
+	}
+	return samples[i-1], true
+}
+
+// Player plays a session back in real time, sped up by a factor. It can be
+// paused, sped up or slowed down, and moved to any time of the session.
+type Player struct {
+	session *Session
+
+	mu     sync.Mutex
+	speed  float64
+	paused bool
+	// base is the position when the player was last started, changed or
+	// moved, at the time resumed
+	base    time.Time
+	resumed time.Time
+}
+
+// NewPlayer starts playing the session at the given speed, 1 being the
+// original speed.
+func NewPlayer(s *Session, speed float64) *Player {
+	return &Player{session: s, speed: speed, base: s.Start, resumed: time.Now()}
+}
+
+// Position returns the time of the session being played, which stops at
+// its end.
+func (p *Player) Position() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+func (p *Player) position() time.Time {
+	pos := p.base
+	if !p.paused {
+		pos = pos.Add(time.Duration(float64(time.Since(p.resumed)) * p.speed))
+	}
+	return p.clamp(pos)
+}
+
+func (p *Player) clamp(t time.Time) time.Time {
+	if t.Before(p.session.Start) {
+		return p.session.Start
+	}
+	if t.After(p.session.End) {
+		return p.session.End
+	}
+	return t
+}
+
+// rebase makes the current position the base, before the speed or pause
+// state change.
+func (p *Player) rebase() {
+	p.base = p.position()
+	p.resumed = time.Now()
+}
+
+// Bounds returns the times of the first and last sample of the session.
+func (p *Player) Bounds() (start, end time.Time) {
+	return p.session.Start, p.session.End
+}
+
+// Speed returns the playback speed.
+func (p *Player) Speed() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed
+}
+
+// SetSpeed changes the playback speed, 1 being the original speed.
+func (p *Player) SetSpeed(speed float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rebase()
+	p.speed = speed
+}
+
+// Paused reports whether the playback is paused.
+func (p *Player) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// SetPaused pauses or resumes the playback.
+func (p *Player) SetPaused(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rebase()
+	p.paused = paused
+}
+
+// Seek moves the playback to the given time, within the session.
+func (p *Player) Seek(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.base = p.clamp(t)
+	p.resumed = time.Now()
+}
+
+// GetStats returns a function returning the sample of the host being
+// played, for passing to the TUI. The stats are empty until the first
+// sample of the host.
+func (p *Player) GetStats(host string) func(context.Context) (types.Stats, error) {
+	return func(ctx context.Context) (types.Stats, error) {
+		hs, _ := p.session.at(host, p.Position())
+		return hs.Stats, nil
+	}
+}
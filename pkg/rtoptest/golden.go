@@ -0,0 +1,78 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package rtoptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// UpdateEnv is the environment variable which, set to 1, makes Golden write
+// the golden files instead of comparing with them, e.g. after a deliberate
+// change of a collector:
+//
+//	RTOP_UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "RTOP_UPDATE_GOLDEN"
+
+// Golden compares got with the content of the golden file at path, failing
+// tb if they differ or the file is missing.
+func Golden(tb testing.TB, path string, got []byte) {
+	tb.Helper()
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("read golden file: %s; set %s=1 to create it", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		tb.Errorf("%s differs from the golden file; got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// GoldenStats compares the stats, as indented JSON, with the golden file at
+// path. The metadata of the collection, such as how long it took, changes
+// from run to run and is left out.
+func GoldenStats(tb testing.TB, path string, stats types.Stats) {
+	tb.Helper()
+	stats.Meta = types.Meta{}
+	got, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	Golden(tb, path, append(got, '\n'))
+}
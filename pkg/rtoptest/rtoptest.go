@@ -0,0 +1,211 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package rtoptest runs an in-process SSH server which answers the commands
+// of rtop with the canned output of a fixture, such as one recorded on an
+// Ubuntu, CentOS, Alpine or BusyBox host, so that collectors and the code
+// using their stats can be checked against real outputs without a remote
+// host:
+//
+//	fixture, err := rtoptest.LoadFixture("alpine")
+//	srv, err := rtoptest.NewServer(fixture)
+//	defer srv.Close()
+//	c, err := srv.Client()
+//	stats, err := c.GetStats(ctx)
+//	rtoptest.GoldenStats(t, "testdata/alpine.json", stats)
+package rtoptest
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/*.txt
+var fixtures embed.FS
+
+// Fixture is the output of the commands run on a host. A command is
+// answered by the entry with the longest prefix of it, so that the long
+// scripts of some collectors are matched by their start.
+type Fixture struct {
+	Name string
+
+	mu      sync.Mutex
+	entries []entry
+}
+
+type entry struct {
+	prefix string
+	output string
+	status int
+}
+
+// FixtureNames lists the fixtures LoadFixture knows.
+func FixtureNames() []string {
+	files, _ := fixtures.ReadDir("fixtures")
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(f.Name(), ".txt"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadFixture returns a fixture shipped with rtoptest: alpine, busybox,
+// centos or ubuntu.
+func LoadFixture(name string) (*Fixture, error) {
+	data, err := fixtures.ReadFile(path.Join("fixtures", name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown fixture %q, expected one of %s", name, strings.Join(FixtureNames(), ", "))
+	}
+	return ParseFixture(name, data)
+}
+
+// fixtureHeader starts the output of a command, optionally with the exit
+// status of the command if it failed.
+var fixtureHeader = regexp.MustCompile(`^-- (.+) --(?: exit (\d+))?$`)
+
+// ParseFixture parses a fixture, which lists the output of every command
+// after a header line giving the command, or the start of it, as in:
+//
+//	# comments before the first command are skipped
+//	-- /bin/cat /proc/loadavg --
+//	0.42 0.51 0.48 2/312 48213
+//	-- /bin/df -i -- exit 1
+//	df: invalid option -- 'i'
+//
+// Commands not listed fail as not found.
+func ParseFixture(name string, data []byte) (*Fixture, error) {
+	f := &Fixture{Name: name}
+	var cur *entry
+	var out strings.Builder
+	flush := func() {
+		if cur != nil {
+			cur.output = out.String()
+			f.entries = append(f.entries, *cur)
+		}
+		out.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if m := fixtureHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			cur = &entry{prefix: m[1]}
+			if m[2] != "" {
+				cur.status, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+		if cur == nil {
+			if line != "" && !strings.HasPrefix(line, "#") {
+				return nil, fmt.Errorf("fixture %s: line %d: output before the first command", name, n)
+			}
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fixture %s: %s", name, err)
+	}
+	flush()
+	return f, nil
+}
+
+// Set answers the commands starting with prefix with the given output and
+// exit status, replacing any entry of the same prefix, e.g. to turn a
+// healthy fixture into a degraded one.
+func (f *Fixture) Set(prefix, output string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.entries {
+		if f.entries[i].prefix == prefix {
+			f.entries[i] = entry{prefix: prefix, output: output, status: status}
+			return
+		}
+	}
+	f.entries = append(f.entries, entry{prefix: prefix, output: output, status: status})
+}
+
+// batchPart matches a command of the single script GetStats sends with
+// WithBatch, followed by the printf of its marker and exit status.
+var batchPart = regexp.MustCompile(`(?s)\((.*?)\n\); printf '\\n(\S+) (\d+) %d\\n' \$\?\n`)
+
+// Run returns the output and exit status of a command on the host. Batches
+// of commands are answered command by command, the shell probe and the
+// current time are answered by the host itself, and unknown commands fail
+// with status 127, as sh does.
+func (f *Fixture) Run(cmd string) (string, int) {
+	if parts := batchPart.FindAllStringSubmatchIndex(cmd, -1); len(parts) > 0 && parts[len(parts)-1][1] == len(cmd) {
+		var b strings.Builder
+		for _, p := range parts {
+			out, status := f.Run(cmd[p[2]:p[3]])
+			fmt.Fprintf(&b, "%s\n%s %s %d\n", out, cmd[p[4]:p[5]], cmd[p[6]:p[7]], status)
+		}
+		return b.String(), 0
+	}
+
+	if out, status, ok := f.lookup(cmd); ok {
+		return out, status
+	}
+
+	switch {
+	case strings.HasPrefix(cmd, "/bin/echo "):
+		return strings.TrimPrefix(cmd, "/bin/echo ") + "\n", 0
+	case cmd == "/bin/date +%s%N":
+		return strconv.FormatInt(time.Now().UnixNano(), 10) + "\n", 0
+	}
+	name := cmd
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		name = fields[0]
+	}
+	return fmt.Sprintf("sh: %s: not found\n", name), 127
+}
+
+// lookup returns the output and exit status of the entry with the longest
+// prefix of cmd.
+func (f *Fixture) lookup(cmd string) (string, int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var best *entry
+	for i, e := range f.entries {
+		if strings.HasPrefix(cmd, e.prefix) && (best == nil || len(e.prefix) > len(best.prefix)) {
+			best = &f.entries[i]
+		}
+	}
+	if best == nil {
+		return "", 0, false
+	}
+	return best.output, best.status, true
+}
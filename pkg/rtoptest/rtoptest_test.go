@@ -0,0 +1,123 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package rtoptest_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rapidloop/rtop/pkg/client"
+	"github.com/rapidloop/rtop/pkg/rtoptest"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+func TestFixtures(t *testing.T) {
+	tests := map[string]struct {
+		hostname   string
+		cores      int
+		memTotal   uint64
+		interfaces []string
+	}{
+		"alpine":  {"edge-3", 2, 2062757888, []string{"eth0", "lo"}},
+		"busybox": {"gw-1", 1, 127315968, []string{"br-lan", "lo"}},
+		"centos":  {"db-2.example.com", 4, 67337453568, []string{"em1", "lo"}},
+		"ubuntu":  {"web-1.example.com", 4, 8332075008, []string{"ens18", "lo"}},
+	}
+	for _, name := range rtoptest.FixtureNames() {
+		want, ok := tests[name]
+		if !ok {
+			t.Errorf("fixture %s: no expected stats", name)
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			var got [2]types.Stats
+			for i, batch := range []bool{false, true} {
+				stats := collect(t, name, batch)
+				if stats.Hostname != want.hostname {
+					t.Errorf("batch=%v: hostname %q, want %q", batch, stats.Hostname, want.hostname)
+				}
+				if stats.Uptime <= 0 {
+					t.Errorf("batch=%v: uptime %v", batch, stats.Uptime)
+				}
+				if len(stats.Cores) != want.cores {
+					t.Errorf("batch=%v: %d cores, want %d", batch, len(stats.Cores), want.cores)
+				}
+				if stats.MEM.Total != want.memTotal {
+					t.Errorf("batch=%v: memory total %d, want %d", batch, stats.MEM.Total, want.memTotal)
+				}
+				if len(stats.FSInfos) == 0 {
+					t.Errorf("batch=%v: no filesystems", batch)
+				}
+				var interfaces []string
+				for iface := range stats.NetInterface {
+					interfaces = append(interfaces, iface)
+				}
+				sort.Strings(interfaces)
+				if !reflect.DeepEqual(interfaces, want.interfaces) {
+					t.Errorf("batch=%v: interfaces %v, want %v", batch, interfaces, want.interfaces)
+				}
+				for _, iface := range want.interfaces {
+					if len(stats.NetInterface[iface].IPv4) == 0 {
+						t.Errorf("batch=%v: interface %s has no IPv4 address", batch, iface)
+					}
+				}
+				if len(stats.Meta.Disabled) > 0 {
+					t.Errorf("batch=%v: collectors disabled: %v", batch, stats.Meta.Disabled)
+				}
+				stats.Meta = types.Meta{}
+				got[i] = stats
+			}
+			if !reflect.DeepEqual(got[0], got[1]) {
+				t.Errorf("stats differ with batch:\n%+v\n%+v", got[0], got[1])
+			}
+		})
+	}
+}
+
+// collect returns the stats of the fixture.
+func collect(t *testing.T, name string, batch bool) types.Stats {
+	t.Helper()
+	f, err := rtoptest.LoadFixture(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := rtoptest.NewServer(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	c, err := srv.Client(client.WithBatch(batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := c.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("batch=%v: %v", batch, err)
+	}
+	return stats
+}
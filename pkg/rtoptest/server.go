@@ -0,0 +1,206 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package rtoptest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"sync"
+
+	"github.com/rapidloop/rtop/pkg/client"
+	"golang.org/x/crypto/ssh"
+)
+
+// Server is an SSH server on the loopback interface which answers every
+// command from its fixture. It accepts any user without authentication.
+type Server struct {
+	fixture  *Fixture
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.PublicKey
+
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	commands []string
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server answering from the fixture on a free port of
+// 127.0.0.1, with a new host key.
+func NewServer(f *Fixture) (*Server, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		fixture:  f,
+		listener: l,
+		config:   config,
+		hostKey:  signer.PublicKey(),
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// HostKeyCallback accepts the host key of the server only.
+func (s *Server) HostKeyCallback() ssh.HostKeyCallback {
+	return ssh.FixedHostKey(s.hostKey)
+}
+
+// Client connects to the server and returns a client collecting the stats
+// of the fixture, with the given options added.
+func (s *Server) Client(opts ...client.Option) (*client.Client, error) {
+	conn, err := ssh.Dial("tcp", s.Addr(), &ssh.ClientConfig{
+		User:            "rtop",
+		HostKeyCallback: s.HostKeyCallback(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.New(append([]client.Option{client.WithSSHClient(conn)}, opts...)...)
+}
+
+// Commands returns the commands run so far, in the order they arrived.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Close stops the server and closes its connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// handle serves a connection, running one command per session.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.session(ch, chReqs)
+		}()
+	}
+}
+
+// session runs the command of an exec request and sends its output and
+// exit status. Other requests, such as for a pty, are refused.
+func (s *Server) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var exec struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &exec); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		s.mu.Lock()
+		s.commands = append(s.commands, exec.Command)
+		s.mu.Unlock()
+
+		out, status := s.fixture.Run(exec.Command)
+		ch.Write([]byte(out))
+		ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
@@ -0,0 +1,68 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/alert"
+)
+
+// AlertHistory is the history of the alerts served on /api/v1/alerts.
+type AlertHistory interface {
+	Since(t time.Time) ([]alert.Record, error)
+}
+
+// defaultAlertsSince is how far back /api/v1/alerts goes without since.
+const defaultAlertsSince = 24 * time.Hour
+
+// listAlerts serves the alerts fired and resolved since the time given by
+// the since parameter, as a duration back from now or an RFC 3339 time.
+func (s *Server) listAlerts(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().Add(-defaultAlertsSince)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := alert.ParseSince(v, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		since = t
+	}
+	if s.alerts == nil {
+		writeJSON(w, http.StatusOK, []alert.Record{})
+		return
+	}
+	records, err := s.alerts.Since(since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if records == nil {
+		records = []alert.Record{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
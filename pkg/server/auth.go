@@ -0,0 +1,143 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator checks the credentials of a request.
+type Authenticator interface {
+	// Authenticate returns an error unless the request carries valid
+	// credentials.
+	Authenticate(r *http.Request) error
+
+	// Challenge is the WWW-Authenticate value sent along with a 401.
+	Challenge() string
+}
+
+var errNoCredentials = errors.New("no credentials")
+
+// BasicAuth authenticates requests using HTTP basic auth against bcrypt
+// password hashes.
+type BasicAuth struct {
+	users map[string][]byte
+}
+
+// NewBasicAuth returns a basic auth authenticator for the users, given as
+// user name to bcrypt hash.
+func NewBasicAuth(users map[string]string) *BasicAuth {
+	b := &BasicAuth{users: make(map[string][]byte, len(users))}
+	for user, hash := range users {
+		b.users[user] = []byte(hash)
+	}
+	return b
+}
+
+// LoadBasicAuth reads the users of an htpasswd file with bcrypt hashes, as
+// written by 'htpasswd -B'.
+func LoadBasicAuth(path string) (*BasicAuth, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(hash, "$2") {
+			return nil, fmt.Errorf("%s:%d: expected user:bcrypt-hash", path, n)
+		}
+		users[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users", path)
+	}
+	return NewBasicAuth(users), nil
+}
+
+func (b *BasicAuth) Authenticate(r *http.Request) error {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return errNoCredentials
+	}
+	hash, ok := b.users[user]
+	if !ok {
+		// compare anyway so that unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return errors.New("invalid user or password")
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return errors.New("invalid user or password")
+	}
+	return nil
+}
+
+func (b *BasicAuth) Challenge() string {
+	return `Basic realm="rtop"`
+}
+
+// dummyHash is a bcrypt hash compared against for unknown users.
+var dummyHash = []byte("$2a$10$rEVN6ay41SFaIXzEQDJoeuG.TgQPk0uOWABpu33CWRH8.l34Zr21S")
+
+// authenticate answers with 401 unless one of the authenticators of the
+// server accepts the request.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.auths) == 0 {
+		return true
+	}
+	err := errNoCredentials
+	for _, a := range s.auths {
+		aerr := a.Authenticate(r)
+		if aerr == nil {
+			return true
+		}
+		if aerr != errNoCredentials {
+			err = aerr
+		}
+	}
+	for _, a := range s.auths {
+		w.Header().Add("WWW-Authenticate", a.Challenge())
+	}
+	writeError(w, http.StatusUnauthorized, err.Error())
+	return false
+}
@@ -0,0 +1,137 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/sink"
+)
+
+// update is sent to the subscribers of the event stream after every
+// collection of a host.
+type update struct {
+	Host   string          `json:"host"`
+	Sample *sink.HostStats `json:"sample,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// subscriberBuffer is the number of updates buffered per subscriber, further
+// ones are dropped until it catches up.
+const subscriberBuffer = 64
+
+// heartbeat is how often an idle event stream is written to, so that
+// proxies keep it open.
+const heartbeat = 15 * time.Second
+
+// hub passes the updates of the hosts on to the subscribers.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan update]struct{}
+}
+
+func (h *hub) subscribe() chan update {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = make(map[chan update]struct{})
+	}
+	ch := make(chan update, subscriberBuffer)
+	h.subs[ch] = struct{}{}
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+func (h *hub) publish(u update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- u:
+		default:
+		}
+	}
+}
+
+// streamEvents sends the latest sample of every host, and then each update
+// as it happens, as server-sent events named stats.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ch := s.hub.subscribe()
+	defer s.hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, h := range s.hosts {
+		h.mu.Lock()
+		u := update{Host: h.Name, Sample: h.latest}
+		if h.err != nil {
+			u.Error = h.err.Error()
+		}
+		h.mu.Unlock()
+		if u.Sample != nil || u.Error != "" {
+			writeEvent(w, u)
+		}
+	}
+	flusher.Flush()
+
+	t := time.NewTicker(heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case u := <-ch:
+			writeEvent(w, u)
+		case <-t.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, u update) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data)
+}
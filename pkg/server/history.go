@@ -0,0 +1,152 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistory is how long the metrics of the samples are kept for the
+// summaries by default.
+const DefaultHistory = 6 * time.Hour
+
+// Summary sums up the values of a metric in a time window.
+type Summary struct {
+	Min     float64 `json:"min"`
+	Avg     float64 `json:"avg"`
+	Max     float64 `json:"max"`
+	P95     float64 `json:"p95"`
+	Samples int     `json:"samples"`
+}
+
+// point is the metrics of a sample.
+type point struct {
+	time    time.Time
+	metrics map[string]float64
+}
+
+// history keeps the metrics of the samples of a host for a while.
+type history struct {
+	keep time.Duration
+
+	mu     sync.Mutex
+	points []point
+}
+
+// add keeps the metrics of a sample taken at t, forgetting those older than
+// keep.
+func (h *history) add(t time.Time, metrics map[string]float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.points = append(h.points, point{time: t, metrics: metrics})
+	i := sort.Search(len(h.points), func(i int) bool {
+		return h.points[i].time.After(t.Add(-h.keep))
+	})
+	if i > 0 {
+		h.points = append(h.points[:0:0], h.points[i:]...)
+	}
+}
+
+// summary sums up every metric of the samples taken since the given time.
+// Metrics missing from some samples are summed up over the others.
+func (h *history) summary(since time.Time) (map[string]Summary, int) {
+	h.mu.Lock()
+	i := sort.Search(len(h.points), func(i int) bool {
+		return !h.points[i].time.Before(since)
+	})
+	points := h.points[i:]
+	h.mu.Unlock()
+
+	vals := make(map[string][]float64)
+	for _, p := range points {
+		for name, v := range p.metrics {
+			vals[name] = append(vals[name], v)
+		}
+	}
+	res := make(map[string]Summary, len(vals))
+	for name, vs := range vals {
+		res[name] = summarize(vs)
+	}
+	return res, len(points)
+}
+
+// summarize returns the minimum, mean, maximum and 95th percentile, by the
+// nearest rank, of the values, which it sorts.
+func summarize(vals []float64) Summary {
+	sort.Float64s(vals)
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	rank := int(math.Ceil(0.95*float64(len(vals)))) - 1
+	return Summary{
+		Min:     vals[0],
+		Avg:     sum / float64(len(vals)),
+		Max:     vals[len(vals)-1],
+		P95:     vals[rank],
+		Samples: len(vals),
+	}
+}
+
+// defaultSummaryWindow is the window of a summary without window.
+const defaultSummaryWindow = time.Hour
+
+// hostWindowSummary is the summary of a host in a time window.
+type hostWindowSummary struct {
+	Host    string             `json:"host"`
+	Window  string             `json:"window"`
+	Since   time.Time          `json:"since"`
+	Samples int                `json:"samples"`
+	Metrics map[string]Summary `json:"metrics"`
+}
+
+// summarize serves the summary of the host over the duration given by the
+// window parameter back from now.
+func (s *Server) summarize(w http.ResponseWriter, r *http.Request, h *host) {
+	window := defaultSummaryWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid window %q, expected a duration like 1h", v))
+			return
+		}
+		window = d
+	}
+	since := time.Now().Add(-window)
+	metrics, n := h.history.summary(since)
+	writeJSON(w, http.StatusOK, hostWindowSummary{
+		Host:    h.Name,
+		Window:  window.String(),
+		Since:   since,
+		Samples: n,
+		Metrics: metrics,
+	})
+}
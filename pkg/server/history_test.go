@@ -0,0 +1,108 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+func TestHistorySummary(t *testing.T) {
+	now := time.Now()
+	h := &history{keep: time.Hour}
+	// an old sample past keep is forgotten
+	h.add(now.Add(-2*time.Hour), map[string]float64{"cpu.used_percent": 1000})
+	for i := 1; i <= 20; i++ {
+		m := map[string]float64{"cpu.used_percent": float64(i)}
+		if i%2 == 0 {
+			m["load.1"] = float64(i)
+		}
+		h.add(now.Add(time.Duration(i-20)*time.Minute), m)
+	}
+
+	got, n := h.summary(now.Add(-time.Hour))
+	if n != 20 {
+		t.Errorf("summed up %d samples, want 20", n)
+	}
+	want := map[string]Summary{
+		"cpu.used_percent": {Min: 1, Avg: 10.5, Max: 20, P95: 19, Samples: 20},
+		"load.1":           {Min: 2, Avg: 11, Max: 20, P95: 20, Samples: 10},
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: got %+v, want %+v", name, got[name], w)
+		}
+	}
+
+	if got, n := h.summary(now.Add(-90 * time.Second)); n != 2 || got["cpu.used_percent"].Min != 19 {
+		t.Errorf("last 90s: got %d samples, %+v", n, got["cpu.used_percent"])
+	}
+}
+
+func TestSummaryEndpoint(t *testing.T) {
+	getStats := func(context.Context) (types.Stats, error) {
+		var s types.Stats
+		s.Hostname = "web-1"
+		s.CPU.Idle = 75
+		s.CPURaw.Total = 100
+		return s, nil
+	}
+	s := New([]Host{{Name: "web-1", GetStats: getStats}}, time.Minute)
+	s.hosts[0].collect(context.Background())
+
+	for _, tt := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"?window=10m", http.StatusOK},
+		{"?window=soon", http.StatusBadRequest},
+		{"?window=-1h", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		s.serveHosts(w, httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/summary"+tt.query, nil), "/web-1/summary")
+		if w.Code != tt.status {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var res hostWindowSummary
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Samples != 1 || res.Metrics["cpu.used_percent"].Avg != 25 {
+			t.Errorf("%q: got %+v", tt.query, res)
+		}
+	}
+}
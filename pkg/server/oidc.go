@@ -0,0 +1,420 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is tolerated when checking the validity period of tokens.
+	clockSkew = time.Minute
+
+	// minKeyRefresh is the least time between fetches of the keys of the
+	// issuer, when tokens are signed with an unknown key.
+	minKeyRefresh = time.Minute
+)
+
+// OIDC authenticates requests carrying a bearer JWT issued by an OpenID
+// Connect provider. The token must be signed by one of the keys of the
+// issuer, be valid for the audience and have the required claims.
+type OIDC struct {
+	issuer   string
+	audience string
+	claims   map[string]string
+	jwksURI  string
+	client   *http.Client
+
+	// mu guards the keys and when they were last fetched, but is not held
+	// while fetching them, so that a slow issuer does not hold up requests
+	// with known keys
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewOIDC discovers the keys of the issuer and returns an authenticator
+// for its tokens. Claims maps the name of each required claim to its
+// value; for claims holding a list, such as groups, the value must be in
+// the list.
+func NewOIDC(ctx context.Context, issuer, audience string, claims map[string]string) (*OIDC, error) {
+	o := &OIDC{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		audience: audience,
+		claims:   claims,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := o.get(ctx, o.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("discover %s: %s", issuer, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != o.issuer {
+		return nil, fmt.Errorf("discover %s: provider claims to be issuer %q", issuer, discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discover %s: no jwks_uri", issuer)
+	}
+	o.jwksURI = discovery.JWKSURI
+	o.fetched = time.Now()
+	if err := o.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *OIDC) Authenticate(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return errNoCredentials
+	}
+	return o.Verify(r.Context(), strings.TrimSpace(auth[7:]))
+}
+
+func (o *OIDC) Challenge() string {
+	return `Bearer realm="rtop"`
+}
+
+// jwtHeader is the JOSE header of a token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and the claims of the token.
+func (o *OIDC) Verify(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("malformed token header: %s", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed token signature: %s", err)
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("malformed token claims: %s", err)
+	}
+	return o.checkClaims(claims, time.Now())
+}
+
+func (o *OIDC) checkClaims(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != o.issuer {
+		return fmt.Errorf("token issued by %q", iss)
+	}
+	if !claimHas(claims["aud"], o.audience) {
+		return fmt.Errorf("token not issued for %q", o.audience)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token does not expire")
+	}
+	if now.Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+	for name, want := range o.claims {
+		if !claimHas(claims[name], want) {
+			return fmt.Errorf("token lacks claim %s=%s", name, want)
+		}
+	}
+	return nil
+}
+
+// claimHas reports whether the claim is the value, or a list containing it.
+func claimHas(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if fmt.Sprint(e) == value {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	}
+	return fmt.Sprint(claim) == value
+}
+
+// key returns the key of the issuer with the given id, fetching the keys
+// again if it is unknown, as the issuer may have rotated them. Only one
+// request fetches them per minKeyRefresh, the others meanwhile fail.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	if key, ok := o.lookupKey(kid); ok {
+		o.mu.Unlock()
+		return key, nil
+	}
+	refresh := time.Since(o.fetched) >= minKeyRefresh
+	if refresh {
+		o.fetched = time.Now()
+	}
+	o.mu.Unlock()
+
+	if refresh {
+		if err := o.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+		o.mu.Lock()
+		key, ok := o.lookupKey(kid)
+		o.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("token signed with unknown key %q", kid)
+}
+
+// lookupKey returns the key with the given id, with o.mu held. Tokens
+// without a key id are accepted if the issuer has a single key.
+func (o *OIDC) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key, true
+		}
+	}
+	key, ok := o.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches the keys of the issuer and replaces the known ones.
+func (o *OIDC) refreshKeys(ctx context.Context) error {
+	keys, err := o.fetchKeys(ctx)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.keys = keys
+	return nil
+}
+
+// jwk is a key of a JSON web key set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.get(ctx, o.jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch keys of %s: %s", o.issuer, err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// keys of unsupported types are skipped, tokens signed with them
+		// are rejected as signed with an unknown key
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("fetch keys of %s: no usable signing keys", o.issuer)
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid ec key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// esCurves are the curves of the ECDSA algorithms.
+var esCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifySignature checks the signature of the signed part of a token with
+// the algorithm of its header. Only asymmetric algorithms are accepted.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	invalid := errors.New("invalid token signature")
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return invalid
+		}
+		var err error
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, nil)
+		}
+		if err != nil {
+			return invalid
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != esCurves[alg] {
+			return invalid
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return invalid
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return invalid
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported token algorithm %q", alg)
+}
+
+func (o *OIDC) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
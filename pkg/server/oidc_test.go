@@ -0,0 +1,255 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer serves the discovery document and the keys of an issuer.
+type testIssuer struct {
+	*httptest.Server
+	rsa  *rsa.PrivateKey
+	p256 *ecdsa.PrivateKey
+	p384 *ecdsa.PrivateKey
+
+	// block, if set, holds up fetches of the keys until it is closed
+	block chan struct{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	is := &testIssuer{}
+	var err error
+	if is.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if is.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if is.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   is.URL,
+			"jwks_uri": is.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		if is.block != nil {
+			<-is.block
+		}
+		enc := base64.RawURLEncoding
+		ecKey := func(kid, crv string, k *ecdsa.PrivateKey) map[string]string {
+			size := (k.Curve.Params().BitSize + 7) / 8
+			return map[string]string{
+				"kty": "EC", "kid": kid, "use": "sig", "crv": crv,
+				"x": enc.EncodeToString(k.X.FillBytes(make([]byte, size))),
+				"y": enc.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{
+			map[string]string{
+				"kty": "RSA", "kid": "rsa", "use": "sig",
+				"n": enc.EncodeToString(is.rsa.N.Bytes()),
+				"e": enc.EncodeToString(big.NewInt(int64(is.rsa.E)).Bytes()),
+			},
+			ecKey("p256", "P-256", is.p256),
+			ecKey("p384", "P-384", is.p384),
+		}})
+	})
+	is.Server = httptest.NewServer(mux)
+	t.Cleanup(is.Close)
+	return is
+}
+
+// claims returns valid claims for the audience "rtop".
+func (is *testIssuer) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    is.URL,
+		"aud":    "rtop",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"ops"},
+	}
+}
+
+// sign returns a token with the claims, signed with the key per alg.
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case nil:
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		hash := hashes[alg[2:]]
+		h := hash.New()
+		h.Write([]byte(signed))
+		if alg[:2] == "PS" {
+			sig, err = rsa.SignPSS(rand.Reader, k, hash, h.Sum(nil), nil)
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, h.Sum(nil))
+		}
+	case *ecdsa.PrivateKey:
+		h := hashes[alg[2:]].New()
+		h.Write([]byte(signed))
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, h.Sum(nil)); err == nil {
+			size := (k.Curve.Params().BitSize + 7) / 8
+			sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + enc.EncodeToString(sig)
+}
+
+func TestOIDCVerify(t *testing.T) {
+	is := newTestIssuer(t)
+	o, err := NewOIDC(context.Background(), is.URL, "rtop", map[string]string{"groups": "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := is.claims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := sign(t, "RS256", "rsa", is.rsa, is.claims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"rs256", sign(t, "RS256", "rsa", is.rsa, is.claims()), ""},
+		{"ps384", sign(t, "PS384", "rsa", is.rsa, is.claims()), ""},
+		{"es256", sign(t, "ES256", "p256", is.p256, is.claims()), ""},
+		{"es384", sign(t, "ES384", "p384", is.p384, is.claims()), ""},
+		{"aud list", sign(t, "RS256", "rsa", is.rsa, with("aud", []string{"other", "rtop"})), ""},
+		{"malformed", "a.b", "malformed token"},
+		{"tampered signature", tampered, "invalid token signature"},
+		{"other key", sign(t, "RS256", "rsa", other, is.claims()), "invalid token signature"},
+		{"unknown key", sign(t, "RS256", "gone", is.rsa, is.claims()), "unknown key"},
+		{"alg none", sign(t, "none", "rsa", nil, is.claims()), "unsupported token algorithm"},
+		{"hs256", sign(t, "HS256", "rsa", []byte("secret"), is.claims()), "unsupported token algorithm"},
+		{"rsa key with es alg", sign(t, "ES256", "rsa", is.p256, is.claims()), "invalid token signature"},
+		{"ec key with rs alg", sign(t, "RS256", "p256", is.rsa, is.claims()), "invalid token signature"},
+		{"es384 on p-256", sign(t, "ES384", "p256", is.p256, is.claims()), "invalid token signature"},
+		{"es256 on p-384", sign(t, "ES256", "p384", is.p384, is.claims()), "invalid token signature"},
+		{"expired", sign(t, "RS256", "rsa", is.rsa, with("exp", time.Now().Add(-time.Hour).Unix())), "token expired"},
+		{"no expiry", sign(t, "RS256", "rsa", is.rsa, with("exp", nil)), "token does not expire"},
+		{"not valid yet", sign(t, "RS256", "rsa", is.rsa, with("nbf", time.Now().Add(time.Hour).Unix())), "token not valid yet"},
+		{"wrong issuer", sign(t, "RS256", "rsa", is.rsa, with("iss", "https://evil.example.com")), "token issued by"},
+		{"wrong audience", sign(t, "RS256", "rsa", is.rsa, with("aud", "other")), "token not issued for"},
+		{"missing claim", sign(t, "RS256", "rsa", is.rsa, with("groups", []string{"dev"})), "token lacks claim"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := o.Verify(context.Background(), tt.token)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("Verify: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("Verify succeeded, want error %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("Verify: %v, want error %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOIDCFetchDoesNotBlock(t *testing.T) {
+	is := newTestIssuer(t)
+	o, err := NewOIDC(context.Background(), is.URL, "rtop", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is.block = make(chan struct{})
+	o.mu.Lock()
+	o.fetched = time.Time{}
+	o.mu.Unlock()
+
+	// a token with an unknown key starts a fetch that hangs
+	fetching := make(chan error)
+	go func() {
+		fetching <- o.Verify(context.Background(), sign(t, "RS256", "new", is.rsa, is.claims()))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- o.Verify(context.Background(), sign(t, "RS256", "rsa", is.rsa, is.claims()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Verify with a known key waited for the fetch of the keys")
+	}
+	close(is.block)
+	if err := <-fetching; err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("Verify with an unknown key: %v", err)
+	}
+}
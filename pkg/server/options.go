@@ -0,0 +1,84 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package server
+
+import "time"
+
+type Option func(s *Server)
+
+// WithAuth requires the requests to be accepted by one of the given
+// authenticators.
+func WithAuth(auths ...Authenticator) Option {
+	return func(s *Server) {
+		s.auths = append(s.auths, auths...)
+	}
+}
+
+// WithRateLimit limits each client, by IP address, to rate requests per
+// second on average, with bursts of up to burst requests. A rate of 0
+// disables the limit.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(s *Server) {
+		s.limiter = nil
+		if rate > 0 {
+			s.limiter = newLimiter(rate, burst)
+		}
+	}
+}
+
+// WithMaxConcurrent limits the requests handled at the same time to n,
+// answering further ones with 503. 0 disables the limit.
+func WithMaxConcurrent(n int) Option {
+	return func(s *Server) {
+		s.slots = nil
+		if n > 0 {
+			s.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithDashboard serves a web dashboard of the hosts on /, updated live from
+// the event stream.
+func WithDashboard(enabled bool) Option {
+	return func(s *Server) {
+		s.dashboard = enabled
+	}
+}
+
+// WithAlertHistory serves the alerts of the history on /api/v1/alerts.
+func WithAlertHistory(h AlertHistory) Option {
+	return func(s *Server) {
+		s.alerts = h
+	}
+}
+
+// WithHistory keeps the metrics of the samples for d, for the summaries on
+// /api/v1/hosts/<host>/summary. The default is DefaultHistory.
+func WithHistory(d time.Duration) Option {
+	return func(s *Server) {
+		s.keep = d
+	}
+}
@@ -0,0 +1,333 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package server serves the stats of the monitored hosts over an HTTP API,
+// for automation and dashboards.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/alert"
+	"github.com/rapidloop/rtop/pkg/sink"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Host is a monitored host, identified by name, whose stats are refreshed
+// using the given function.
+type Host struct {
+	Name     string
+	GetStats func(context.Context) (types.Stats, error)
+}
+
+// host is the state of a monitored host.
+type host struct {
+	Host
+
+	// collectMu serializes the collections of the host, as rates are
+	// computed from the previous one
+	collectMu sync.Mutex
+
+	mu     sync.Mutex
+	latest *sink.HostStats
+	err    error
+
+	hub     *hub
+	history *history
+}
+
+// collect refreshes the stats of the host and returns the fresh sample. A
+// sample is kept even if some collectors failed; only when nothing was
+// collected the previous one stays the latest.
+func (h *host) collect(ctx context.Context) (sink.HostStats, error) {
+	h.collectMu.Lock()
+	defer h.collectMu.Unlock()
+
+	stats, err := h.GetStats(ctx)
+	s := sink.HostStats{Host: h.Name, Time: time.Now(), Stats: stats}
+	collected := stats.Hostname != ""
+
+	h.mu.Lock()
+	h.err = err
+	if collected {
+		h.latest = &s
+	}
+	h.mu.Unlock()
+	if collected {
+		h.history.add(s.Time, alert.Metrics(stats))
+	}
+
+	u := update{Host: h.Name}
+	if collected {
+		u.Sample = &s
+	}
+	if err != nil {
+		u.Error = err.Error()
+	}
+	h.hub.publish(u)
+	return s, err
+}
+
+// Server serves the stats of the hosts over an HTTP API, collecting them
+// every interval. The endpoints are:
+//
+//	GET  /api/v1/hosts                 the hosts and when they were last collected
+//	GET  /api/v1/hosts/<host>          the latest sample of the host
+//	POST /api/v1/hosts/<host>/collect  collects the host now and returns the sample
+//	GET  /api/v1/hosts/<host>/summary?window=1h
+//	                                   min, avg, max and p95 of every metric in the window
+//	GET  /api/v1/events                server-sent events with every new sample
+//	GET  /api/v1/alerts?since=24h      the alerts fired and resolved since then
+//
+// and, with WithDashboard, a web dashboard of the hosts on /.
+//
+// Host names are path escaped, e.g. root%40web-1:22 for root@web-1:22. The
+// metrics are named as in alert rules; windows reach back at most as far as
+// the history is kept, see WithHistory.
+//
+// With WithAuth, requests must pass one of the authenticators first. Rate
+// limits, see WithRateLimit and WithMaxConcurrent, apply before that.
+type Server struct {
+	hosts    []*host
+	byName   map[string]*host
+	interval time.Duration
+	auths    []Authenticator
+	limiter  *limiter
+	slots    chan struct{}
+	hub      *hub
+	alerts   AlertHistory
+	keep     time.Duration
+
+	dashboard bool
+}
+
+// New returns a server for the given hosts, which are collected every
+// interval once Run is called.
+func New(hosts []Host, interval time.Duration, opts ...Option) *Server {
+	s := &Server{byName: make(map[string]*host, len(hosts)), interval: interval, hub: &hub{}, keep: DefaultHistory}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, h := range hosts {
+		state := &host{Host: h, hub: s.hub, history: &history{keep: s.keep}}
+		s.hosts = append(s.hosts, state)
+		s.byName[h.Name] = state
+	}
+	return s
+}
+
+// Run collects the stats of every host each interval until ctx is done.
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, h := range s.hosts {
+		wg.Add(1)
+		go func(h *host) {
+			defer wg.Done()
+			t := time.NewTicker(s.interval)
+			defer t.Stop()
+			for {
+				h.collect(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+				}
+			}
+		}(h)
+	}
+	wg.Wait()
+}
+
+// hostSummary is an entry of the host list.
+type hostSummary struct {
+	Host  string     `json:"host"`
+	Time  *time.Time `json:"time,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// Paths of the API, see Server.
+const (
+	hostsPath  = "/api/v1/hosts"
+	eventsPath = "/api/v1/events"
+	alertsPath = "/api/v1/alerts"
+)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	// the event stream is long lived and never collects, so it does not
+	// take one of the concurrent request slots
+	stream := path == eventsPath
+	done, ok := s.limit(w, r, !stream)
+	if !ok {
+		return
+	}
+	defer done()
+	if !s.authenticate(w, r) {
+		return
+	}
+
+	switch {
+	case stream:
+		if allow(w, r, http.MethodGet) {
+			s.streamEvents(w, r)
+		}
+	case path == alertsPath:
+		if allow(w, r, http.MethodGet) {
+			s.listAlerts(w, r)
+		}
+	case s.dashboard && path == "/":
+		if allow(w, r, http.MethodGet) {
+			serveDashboard(w)
+		}
+	case path == hostsPath || strings.HasPrefix(path, hostsPath+"/"):
+		s.serveHosts(w, r, strings.TrimPrefix(path, hostsPath))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveHosts serves the endpoints below /api/v1/hosts, given the rest of
+// the escaped path.
+func (s *Server) serveHosts(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case parts[0] == "":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		s.listHosts(w)
+
+	case len(parts) == 1:
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		h, ok := s.lookup(w, parts[0])
+		if !ok {
+			return
+		}
+		h.mu.Lock()
+		latest, err := h.latest, h.err
+		h.mu.Unlock()
+		if latest == nil {
+			msg := "not collected yet"
+			if err != nil {
+				msg = err.Error()
+			}
+			writeError(w, http.StatusServiceUnavailable, msg)
+			return
+		}
+		writeJSON(w, http.StatusOK, latest)
+
+	case len(parts) == 2 && parts[1] == "collect":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		h, ok := s.lookup(w, parts[0])
+		if !ok {
+			return
+		}
+		sample, err := h.collect(r.Context())
+		if sample.Stats.Hostname == "" {
+			msg := "nothing collected"
+			if err != nil {
+				msg = err.Error()
+			}
+			writeError(w, http.StatusBadGateway, msg)
+			return
+		}
+		writeJSON(w, http.StatusOK, sample)
+
+	case len(parts) == 2 && parts[1] == "summary":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		h, ok := s.lookup(w, parts[0])
+		if !ok {
+			return
+		}
+		s.summarize(w, r, h)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) listHosts(w http.ResponseWriter) {
+	res := make([]hostSummary, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		sum := hostSummary{Host: h.Name}
+		h.mu.Lock()
+		if h.latest != nil {
+			t := h.latest.Time
+			sum.Time = &t
+		}
+		if h.err != nil {
+			sum.Error = h.err.Error()
+		}
+		h.mu.Unlock()
+		res = append(res, sum)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// lookup returns the host of the escaped name, answering with 404 if there
+// is none.
+func (s *Server) lookup(w http.ResponseWriter, escaped string) (*host, bool) {
+	name, err := url.PathUnescape(escaped)
+	if err == nil {
+		if h, ok := s.byName[name]; ok {
+			return h, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "unknown host "+escaped)
+	return nil, false
+}
+
+// allow answers with 405 unless the request uses the given method.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
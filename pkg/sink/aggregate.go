@@ -0,0 +1,194 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Aggregation decides how the samples of a host are combined, see
+// WithAggregation.
+type Aggregation int
+
+const (
+	// AggregateAvg writes the mean of the samples.
+	AggregateAvg Aggregation = iota
+	// AggregateMax writes the maximum of the samples, so that short spikes
+	// are not averaged away.
+	AggregateMax
+)
+
+// ParseAggregation parses avg or max.
+func ParseAggregation(s string) (Aggregation, error) {
+	switch s {
+	case "avg":
+		return AggregateAvg, nil
+	case "max":
+		return AggregateMax, nil
+	}
+	return 0, fmt.Errorf("invalid aggregation %q, expected avg or max", s)
+}
+
+// aggregate combines the samples of a host into the latest one. The
+// percentages, temperatures and rates per second are aggregated; the other
+// values, such as counters and sizes, are those of the latest sample.
+// Values of lists are combined with those at the same position in the
+// other samples if they are of the same thing, e.g. the same core or
+// device, and values of maps with those of the same key.
+func aggregate(samples []HostStats, a Aggregation) HostStats {
+	latest := samples[len(samples)-1]
+	res := HostStats{Host: latest.Host, Time: latest.Time}
+	// a copy, as the stats are shared with the other users of the samples
+	data, err := json.Marshal(latest.Stats)
+	if err != nil || json.Unmarshal(data, &res.Stats) != nil {
+		return latest
+	}
+
+	srcs := make([]reflect.Value, len(samples))
+	for i := range samples {
+		srcs[i] = reflect.ValueOf(samples[i].Stats)
+	}
+	combine(reflect.ValueOf(&res.Stats).Elem(), srcs, "", a)
+	return res
+}
+
+// combine sets the aggregated values of dst to the aggregate of those of
+// srcs, given the unit of dst.
+func combine(dst reflect.Value, srcs []reflect.Value, unit string, a Aggregation) {
+	if len(srcs) == 0 {
+		return
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			return
+		}
+		elems := make([]reflect.Value, 0, len(srcs))
+		for _, s := range srcs {
+			if !s.IsNil() {
+				elems = append(elems, s.Elem())
+			}
+		}
+		combine(dst.Elem(), elems, unit, a)
+	case reflect.Struct:
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fields := make([]reflect.Value, len(srcs))
+			for j, s := range srcs {
+				fields[j] = s.Field(i)
+			}
+			combine(dst.Field(i), fields, f.Tag.Get("unit"), a)
+		}
+	case reflect.Slice:
+		for i := 0; i < dst.Len(); i++ {
+			d := dst.Index(i)
+			var elems []reflect.Value
+			for _, s := range srcs {
+				if i < s.Len() && identity(s.Index(i)) == identity(d) {
+					elems = append(elems, s.Index(i))
+				}
+			}
+			combine(d, elems, unit, a)
+		}
+	case reflect.Map:
+		iter := dst.MapRange()
+		for iter.Next() {
+			var elems []reflect.Value
+			for _, s := range srcs {
+				if v := s.MapIndex(iter.Key()); v.IsValid() {
+					elems = append(elems, v)
+				}
+			}
+			// map values cannot be set in place
+			v := reflect.New(dst.Type().Elem()).Elem()
+			v.Set(iter.Value())
+			combine(v, elems, unit, a)
+			dst.SetMapIndex(iter.Key(), v)
+		}
+	case reflect.Float32, reflect.Float64:
+		if unit != "percent" && unit != "celsius" && !strings.HasSuffix(unit, "/s") {
+			return
+		}
+		res := srcs[0].Float()
+		for _, s := range srcs[1:] {
+			switch a {
+			case AggregateMax:
+				if s.Float() > res {
+					res = s.Float()
+				}
+			default:
+				res += s.Float()
+			}
+		}
+		if a == AggregateAvg {
+			res /= float64(len(srcs))
+		}
+		dst.SetFloat(res)
+	}
+}
+
+// identity returns what tells the thing a value of a list is about from
+// the others, such as the name of a core or the device of a disk: its
+// strings and signed integers, including those of embedded structs.
+func identity(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return identity(v.Elem())
+	case reflect.Struct:
+		var b strings.Builder
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			switch fv := v.Field(i); {
+			case fv.Kind() == reflect.String:
+				b.WriteString(fv.String())
+			case fv.Kind() >= reflect.Int && fv.Kind() <= reflect.Int64:
+				b.WriteString(strconv.FormatInt(fv.Int(), 10))
+			case f.Anonymous:
+				b.WriteString(identity(fv))
+			default:
+				continue
+			}
+			b.WriteByte(0)
+		}
+		return b.String()
+	}
+	return ""
+}
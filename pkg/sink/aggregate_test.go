@@ -0,0 +1,120 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+func aggSample(user float32, rx uint64, rate float64, cores ...string) HostStats {
+	var s types.Stats
+	s.Hostname = "web1"
+	s.CPU.User = user
+	s.CPURaw.User = rx
+	for _, c := range cores {
+		s.Cores = append(s.Cores, types.CPUInfo{Core: c, User: user})
+	}
+	var n types.NetInterface
+	n.Rx, n.RxRate = rx, rate
+	s.NetInterface = map[string]types.NetInterface{"eth0": n}
+	return HostStats{Host: "web1", Time: time.Unix(int64(rx), 0), Stats: s}
+}
+
+func TestAggregate(t *testing.T) {
+	samples := []HostStats{
+		aggSample(10, 1, 100, "cpu0", "cpu1"),
+		aggSample(20, 2, 200, "cpu1"),
+		aggSample(60, 3, 600, "cpu0", "cpu1"),
+	}
+	for _, tt := range []struct {
+		a          Aggregation
+		user, rate float64
+		core0      float32
+	}{
+		{AggregateAvg, 30, 300, 35},
+		{AggregateMax, 60, 600, 60},
+	} {
+		got := aggregate(samples, tt.a)
+		if !got.Time.Equal(samples[2].Time) {
+			t.Errorf("%v: time %s, want that of the latest sample", tt.a, got.Time)
+		}
+		if float64(got.Stats.CPU.User) != tt.user {
+			t.Errorf("%v: cpu user %v, want %v", tt.a, got.Stats.CPU.User, tt.user)
+		}
+		// cpu0 is not at the same position in the second sample
+		if got.Stats.Cores[0].User != tt.core0 {
+			t.Errorf("%v: cpu0 user %v, want %v", tt.a, got.Stats.Cores[0].User, tt.core0)
+		}
+		n := got.Stats.NetInterface["eth0"]
+		if n.RxRate != tt.rate {
+			t.Errorf("%v: rx rate %v, want %v", tt.a, n.RxRate, tt.rate)
+		}
+		// counters are those of the latest sample
+		if n.Rx != 3 || got.Stats.CPURaw.User != 3 {
+			t.Errorf("%v: counters %d and %d, want 3", tt.a, n.Rx, got.Stats.CPURaw.User)
+		}
+	}
+	if samples[2].Stats.CPU.User != 60 || samples[2].Stats.NetInterface["eth0"].RxRate != 600 {
+		t.Errorf("the samples were changed")
+	}
+}
+
+type memSink struct {
+	mu      sync.Mutex
+	samples []HostStats
+}
+
+func (m *memSink) Write(ctx context.Context, s HostStats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, s)
+	return nil
+}
+
+func TestFanoutAggregation(t *testing.T) {
+	m := &memSink{}
+	f := NewFanout([]Sink{m}, WithAggregation(2, AggregateAvg))
+	for _, s := range []HostStats{aggSample(10, 1, 0), aggSample(20, 2, 0), aggSample(40, 3, 0)} {
+		f.Write(context.Background(), s)
+	}
+	f.Close()
+
+	if len(m.samples) != 2 {
+		t.Fatalf("wrote %d samples, want 2", len(m.samples))
+	}
+	// the last sample is written on Close on its own
+	if u := m.samples[0].Stats.CPU.User; u != 15 {
+		t.Errorf("first sample: cpu user %v, want 15", u)
+	}
+	if u := m.samples[1].Stats.CPU.User; u != 40 {
+		t.Errorf("second sample: cpu user %v, want 40", u)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns of the CSV sink. Only metrics with a fixed
+// number of values are written, so that every row has the same columns:
+// disk usage is that of the fullest filesystem and network rates are summed
+// over all interfaces but loopback. The cells of collectors which produced
+// nothing in the sample are left empty rather than written as zeros.
+var csvHeader = []string{
+	"time", "host", "hostname", "uptime_seconds",
+	"load1", "load5", "load15",
+	"cpu_user", "cpu_system", "cpu_iowait", "cpu_steal", "cpu_idle",
+	"mem_total", "mem_used", "mem_free", "mem_buffers", "mem_cached",
+	"swap_total", "swap_free", "swap_in_rate", "swap_out_rate",
+	"disk_used_percent", "net_rx_rate", "net_tx_rate",
+	"procs_running", "procs_total",
+}
+
+// CSV writes every sample as a row of comma or tab separated values, for
+// spreadsheets.
+type CSV struct {
+	path string
+	f    *os.File
+	w    *csv.Writer
+}
+
+// NewCSV appends to the file at path, creating it with a header row if
+// needed. The values are separated by comma, e.g. ',' or '\t'.
+func NewCSV(path string, comma rune) (*CSV, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(f)
+	w.Comma = comma
+
+	if fi, err := f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	} else if fi.Size() == 0 {
+		w.Write(csvHeader)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &CSV{path: path, f: f, w: w}, nil
+}
+
+func (c *CSV) Write(ctx context.Context, s HostStats) error {
+	st := s.Stats
+
+	var disk float64
+	for _, fs := range st.FSInfos {
+		if fs.Total > 0 {
+			if p := float64(fs.Used) / float64(fs.Total) * 100; p > disk {
+				disk = p
+			}
+		}
+	}
+	var rx, tx float64
+	for name, info := range st.NetInterface {
+		if name != "lo" {
+			rx += info.RxRate
+			tx += info.TxRate
+		}
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	// of returns the cells, or empty ones if the collector produced nothing
+	of := func(collector string, cells ...string) []string {
+		if !st.Collected(collector) {
+			return make([]string, len(cells))
+		}
+		return cells
+	}
+	var row []string
+	row = append(row, s.Time.UTC().Format(time.RFC3339), s.Host, st.Hostname)
+	row = append(row, of("uptime", f(st.Uptime.Seconds()))...)
+	row = append(row, st.Loads.Load1, st.Loads.Load5, st.Loads.Load15)
+	row = append(row, of("cpu", f(float64(st.CPU.User)), f(float64(st.CPU.System)), f(float64(st.CPU.IOWait)), f(float64(st.CPU.Steal)), f(float64(st.CPU.Idle)))...)
+	row = append(row, of("mem", u(st.MEM.Total), u(st.MEM.Used()), u(st.MEM.Free), u(st.MEM.Buffers), u(st.MEM.Cached), u(st.MEM.SwapTotal), u(st.MEM.SwapFree))...)
+	row = append(row, of("swap", f(st.SwapActivity.InRate), f(st.SwapActivity.OutRate))...)
+	row = append(row, of("fs", f(disk))...)
+	row = append(row, of("netdev", f(rx), f(tx))...)
+	row = append(row, st.Loads.RunningProcs, st.Loads.TotalProcs)
+	c.w.Write(row)
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSV) Close() error {
+	return c.f.Close()
+}
+
+func (c *CSV) String() string {
+	if c.w.Comma == '\t' {
+		return "tsv:" + c.path
+	}
+	return "csv:" + c.path
+}
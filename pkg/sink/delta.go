@@ -0,0 +1,229 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+)
+
+// Delta writes samples as lines of JSON like JSON does, but after the first
+// sample of a host only the fields of its stats which changed, as a JSON
+// merge patch (RFC 7396) of the stats of the previous sample under delta:
+//
+//	{"host":"web1","time":"...","delta":{"cpu":{"user":3.1},"uptime":86412}}
+//
+// Numbers are taken as changed once they differ from the value last
+// written by more than the tolerance, relative to the larger of both, so
+// that a tolerance of 0.01 leaves out changes up to 1%. ApplyDelta
+// reconstructs the stats.
+type Delta struct {
+	path      string
+	f         *os.File
+	enc       *json.Encoder
+	tolerance float64
+
+	// prev holds the stats of each host as the written lines reconstruct
+	// them
+	prev map[string]map[string]interface{}
+}
+
+// deltaSample is a line of a Delta after the first one of the host.
+type deltaSample struct {
+	Host  string                 `json:"host"`
+	Time  time.Time              `json:"time"`
+	Delta map[string]interface{} `json:"delta"`
+}
+
+// NewDelta appends to the file at path, creating it if needed.
+func NewDelta(path string, tolerance float64) (*Delta, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Delta{path: path, f: f, enc: json.NewEncoder(f), tolerance: tolerance, prev: make(map[string]map[string]interface{})}, nil
+}
+
+func (d *Delta) Write(ctx context.Context, s HostStats) error {
+	data, err := json.Marshal(s.Stats)
+	if err != nil {
+		return err
+	}
+	cur, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+	prev, ok := d.prev[s.Host]
+	if !ok {
+		d.prev[s.Host] = cur
+		return d.enc.Encode(s)
+	}
+	patch := diff(prev, cur, d.tolerance)
+	merge(prev, patch)
+	return d.enc.Encode(deltaSample{Host: s.Host, Time: s.Time, Delta: patch})
+}
+
+func (d *Delta) Close() error {
+	return d.f.Close()
+}
+
+func (d *Delta) String() string {
+	return "delta:" + d.path
+}
+
+// ApplyDelta returns the stats, encoded as JSON, with the delta of a line
+// written by Delta applied.
+func ApplyDelta(stats, delta []byte) ([]byte, error) {
+	base, err := decodeObject(stats)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := decodeObject(delta)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(merge(base, patch))
+}
+
+// decodeObject decodes a JSON object keeping numbers as written, so that
+// large counters do not lose precision.
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = make(map[string]interface{})
+	}
+	return obj, nil
+}
+
+// diff returns the merge patch turning a into b, leaving out numbers which
+// changed within the tolerance.
+func diff(a, b map[string]interface{}, tolerance float64) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for k, bv := range b {
+		av, ok := a[k]
+		if !ok {
+			if bv != nil {
+				patch[k] = bv
+			}
+			continue
+		}
+		am, aobj := av.(map[string]interface{})
+		bm, bobj := bv.(map[string]interface{})
+		if aobj && bobj {
+			if sub := diff(am, bm, tolerance); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !near(av, bv, tolerance) {
+			patch[k] = bv
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+// merge applies the merge patch to target and returns it.
+func merge(target, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		pm, ok := v.(map[string]interface{})
+		if !ok {
+			target[k] = v
+			continue
+		}
+		tm, ok := target[k].(map[string]interface{})
+		if !ok {
+			tm = make(map[string]interface{})
+		}
+		target[k] = merge(tm, pm)
+	}
+	return target
+}
+
+// near reports whether the JSON values are equal, taking numbers within the
+// tolerance of each other as equal.
+func near(a, b interface{}, tolerance float64) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		} else if tolerance == 0 {
+			// as floats, large counters could compare equal
+			return false
+		}
+		x, errx := a.Float64()
+		y, erry := b.Float64()
+		if errx != nil || erry != nil {
+			return false
+		}
+		return math.Abs(x-y) <= tolerance*math.Max(math.Abs(x), math.Abs(y))
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !near(av, bv, tolerance) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !near(a[i], b[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
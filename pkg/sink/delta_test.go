@@ -0,0 +1,122 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+func iface(ip string, rx uint64) types.NetInterface {
+	var n types.NetInterface
+	n.IPv4, n.Rx = ip, rx
+	return n
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	var samples []types.Stats
+	s := types.Stats{Hostname: "web1", Uptime: time.Hour}
+	s.CPU.User = 10
+	s.MEM.Total = 1 << 40
+	s.NetInterface = map[string]types.NetInterface{"eth0": iface("10.0.0.1", 1<<60)}
+	s.FSInfos = []types.FSInfo{{MountPoint: "/", Used: 10, Total: 100}}
+	samples = append(samples, s)
+
+	s.Uptime += time.Minute
+	s.CPU.User = 10.05 // within the tolerance
+	s.NetInterface = map[string]types.NetInterface{"eth0": iface("10.0.0.1", 1<<60+1)}
+	samples = append(samples, s)
+
+	s.CPU.User = 50
+	s.FSInfos = nil
+	s.NetInterface = map[string]types.NetInterface{"eth1": iface("10.0.0.2", 0)}
+	samples = append(samples, s)
+
+	for _, tolerance := range []float64{0, 0.01} {
+		path := filepath.Join(t.TempDir(), fmt.Sprintf("session-%g.rtop", tolerance))
+		d, err := NewDelta(path, tolerance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, st := range samples {
+			if err := d.Write(context.Background(), HostStats{Host: "web1", Time: time.Unix(int64(i), 0), Stats: st}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d.Close()
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prev []byte
+		scanner := bufio.NewScanner(f)
+		for i := 0; scanner.Scan(); i++ {
+			var line struct {
+				Stats json.RawMessage `json:"stats"`
+				Delta json.RawMessage `json:"delta"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if (i == 0) != (line.Delta == nil) {
+				t.Fatalf("tolerance %g, sample %d: got %s, want only the first one in full", tolerance, i, scanner.Bytes())
+			}
+			if line.Delta != nil {
+				if line.Stats, err = ApplyDelta(prev, line.Delta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			prev = line.Stats
+
+			var got types.Stats
+			if err := json.Unmarshal(line.Stats, &got); err != nil {
+				t.Fatal(err)
+			}
+			want := samples[i]
+			if i == 1 && tolerance > 0 {
+				// left out as within the tolerance
+				want.CPU.User = samples[0].CPU.User
+				want.NetInterface = samples[0].NetInterface
+			}
+			data, _ := json.Marshal(want)
+			json.Unmarshal(data, &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("tolerance %g, sample %d: got\n%+v\nwant\n%+v", tolerance, i, got, want)
+			}
+		}
+		f.Close()
+	}
+}
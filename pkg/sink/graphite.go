@@ -0,0 +1,359 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPrefix is the prefix of the metric paths of the Graphite and StatsD
+// sinks.
+const DefaultPrefix = "rtop.{host}"
+
+// dialTimeout bounds connecting to a Graphite or StatsD endpoint.
+const dialTimeout = 5 * time.Second
+
+// statsdPacket is the largest StatsD datagram sent, small enough not to be
+// fragmented on common networks.
+const statsdPacket = 1432
+
+// Prefixer returns the metric path prefix for a sample.
+type Prefixer func(s HostStats) string
+
+// PrefixTemplate returns a Prefixer that expands {host} to the host as given
+// to rtop, {hostname} to the hostname it reports and {key} to the value of
+// the label key of the host. The values are sanitized to single path
+// components, so that a host of db.example.com gives rtop.db_example_com
+// for the default prefix.
+func PrefixTemplate(tmpl string) Prefixer {
+	return func(s HostStats) string {
+		var b strings.Builder
+		rest := tmpl
+		for {
+			open := strings.Index(rest, "{")
+			if open == -1 {
+				break
+			}
+			end := strings.Index(rest[open:], "}")
+			if end == -1 {
+				break
+			}
+			b.WriteString(rest[:open])
+			key := rest[open+1 : open+end]
+			switch key {
+			case "host":
+				b.WriteString(component(s.Host))
+			case "hostname":
+				b.WriteString(component(s.Stats.Hostname))
+			default:
+				b.WriteString(component(s.Stats.Labels[key]))
+			}
+			rest = rest[open+end+1:]
+		}
+		b.WriteString(rest)
+		return strings.Trim(b.String(), ".")
+	}
+}
+
+// metric is a single value of a sample, named by its path below the prefix.
+type metric struct {
+	path  string
+	value float64
+}
+
+// metrics flattens a sample to dot separated paths, such as cpu.user,
+// fs.var_log.used_percent and net.eth0.rx_rate. Metrics of collectors which
+// produced nothing in the sample are left out rather than sent as zeros.
+func metrics(s HostStats) []metric {
+	st := s.Stats
+	var ms []metric
+	add := func(path string, v float64) {
+		ms = append(ms, metric{path, v})
+	}
+
+	if st.Collected("uptime") {
+		add("uptime", st.Uptime.Seconds())
+	}
+	for _, l := range []struct{ name, val string }{
+		{"load.1", st.Loads.Load1}, {"load.5", st.Loads.Load5}, {"load.15", st.Loads.Load15},
+		{"procs.running", st.Loads.RunningProcs}, {"procs.total", st.Loads.TotalProcs},
+	} {
+		if v, err := strconv.ParseFloat(l.val, 64); err == nil {
+			add(l.name, v)
+		}
+	}
+
+	if c := st.CPU; st.Collected("cpu") {
+		add("cpu.user", float64(c.User))
+		add("cpu.system", float64(c.System))
+		add("cpu.nice", float64(c.Nice))
+		add("cpu.idle", float64(c.Idle))
+		add("cpu.iowait", float64(c.IOWait))
+		add("cpu.irq", float64(c.IRQ))
+		add("cpu.softirq", float64(c.SoftIRQ))
+		add("cpu.steal", float64(c.Steal))
+		add("cpu.guest", float64(c.Guest))
+	}
+
+	if m := st.MEM; st.Collected("mem") {
+		add("mem.total", float64(m.Total))
+		add("mem.used", float64(m.Used()))
+		add("mem.free", float64(m.Free))
+		add("mem.buffers", float64(m.Buffers))
+		add("mem.cached", float64(m.Cached))
+		add("mem.used_percent", percent(m.Used(), m.Total))
+		add("swap.total", float64(m.SwapTotal))
+		add("swap.used", float64(m.SwapTotal-m.SwapFree))
+	}
+	if st.Collected("swap") {
+		add("swap.in_rate", st.SwapActivity.InRate)
+		add("swap.out_rate", st.SwapActivity.OutRate)
+	}
+
+	for _, fs := range st.FSInfos {
+		p := "fs." + mountComponent(fs.MountPoint)
+		add(p+".total", float64(fs.Total))
+		add(p+".used", float64(fs.Used))
+		add(p+".free", float64(fs.Free))
+		add(p+".used_percent", percent(fs.Used, fs.Total))
+		if fs.InodesTotal > 0 {
+			add(p+".inodes_used_percent", fs.InodeUsage())
+		}
+	}
+	for _, d := range st.DiskIO {
+		p := "disk." + component(d.Device)
+		add(p+".read_rate", d.ReadRate)
+		add(p+".write_rate", d.WriteRate)
+		add(p+".read_iops", d.ReadIOPS)
+		add(p+".write_iops", d.WriteIOPS)
+	}
+
+	// the interfaces have addresses but no counters if only netip worked
+	ifaces := make([]string, 0, len(st.NetInterface))
+	if st.Collected("netdev") {
+		for name := range st.NetInterface {
+			ifaces = append(ifaces, name)
+		}
+	}
+	sort.Strings(ifaces)
+	for _, name := range ifaces {
+		ni := st.NetInterface[name]
+		p := "net." + component(name)
+		add(p+".rx_rate", ni.RxRate)
+		add(p+".tx_rate", ni.TxRate)
+		add(p+".rx", float64(ni.Rx))
+		add(p+".tx", float64(ni.Tx))
+	}
+
+	for _, sensor := range st.Sensors {
+		add("temp."+component(sensor.Name), sensor.Temp)
+	}
+
+	for _, gpu := range st.GPUs {
+		p := "gpu." + strconv.Itoa(gpu.Index)
+		add(p+".utilization", gpu.Utilization)
+		add(p+".memory_used", float64(gpu.MemoryUsed))
+		add(p+".memory_total", float64(gpu.MemoryTotal))
+		add(p+".temp", gpu.Temp)
+	}
+
+	for _, pool := range st.ZFS {
+		p := "zfs." + component(pool.Name)
+		add(p+".size", float64(pool.Size))
+		add(p+".alloc", float64(pool.Alloc))
+		add(p+".free", float64(pool.Free))
+		add(p+".capacity", pool.Capacity)
+		add(p+".fragmentation", pool.Fragmentation)
+		healthy := 0.0
+		if pool.Healthy() {
+			healthy = 1
+		}
+		add(p+".healthy", healthy)
+	}
+
+	keys := make([]string, 0, len(st.Extra))
+	for key := range st.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		for i := range parts {
+			parts[i] = component(parts[i])
+		}
+		add(strings.Join(parts, "."), st.Extra[key])
+	}
+	return ms
+}
+
+// component makes s usable as a single component of a metric path, which
+// must not contain dots, spaces or the characters StatsD uses as separators.
+func component(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// mountComponent names a mount point as a path component, root for / and
+// var_log for /var/log.
+func mountComponent(mount string) string {
+	if mount == "/" {
+		return "root"
+	}
+	return component(strings.Trim(mount, "/"))
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Graphite sends every sample to a Graphite carbon server, using its
+// plaintext protocol over TCP. The connection is reopened on the next
+// sample after it failed.
+type Graphite struct {
+	addr   string
+	prefix Prefixer
+	conn   net.Conn
+}
+
+// NewGraphite sends to the carbon server at addr, such as localhost:2003.
+func NewGraphite(addr string, prefix Prefixer) (*Graphite, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid graphite address %q: %s", addr, err)
+	}
+	return &Graphite{addr: addr, prefix: prefix}, nil
+}
+
+func (g *Graphite) Write(ctx context.Context, s HostStats) error {
+	if g.conn == nil {
+		d := net.Dialer{Timeout: dialTimeout}
+		conn, err := d.DialContext(ctx, "tcp", g.addr)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	prefix := g.prefix(s)
+	ts := " " + strconv.FormatInt(s.Time.Unix(), 10) + "\n"
+	var b bytes.Buffer
+	for _, m := range metrics(s) {
+		b.WriteString(join(prefix, m.path))
+		b.WriteByte(' ')
+		b.WriteString(formatValue(m.value))
+		b.WriteString(ts)
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	if _, err := g.conn.Write(b.Bytes()); err != nil {
+		g.conn.Close()
+		g.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (g *Graphite) Close() error {
+	if g.conn != nil {
+		return g.conn.Close()
+	}
+	return nil
+}
+
+func (g *Graphite) String() string {
+	return "graphite:" + g.addr
+}
+
+// StatsD sends every value of a sample as a gauge to a StatsD server over
+// UDP, several per datagram.
+type StatsD struct {
+	addr   string
+	prefix Prefixer
+	conn   net.Conn
+}
+
+// NewStatsD sends to the StatsD server at addr, such as localhost:8125.
+func NewStatsD(addr string, prefix Prefixer) (*StatsD, error) {
+	conn, err := net.DialTimeout("udp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statsd address %q: %s", addr, err)
+	}
+	return &StatsD{addr: addr, prefix: prefix, conn: conn}, nil
+}
+
+func (d *StatsD) Write(ctx context.Context, s HostStats) error {
+	prefix := d.prefix(s)
+	var b bytes.Buffer
+	for _, m := range metrics(s) {
+		// a gauge with a sign is a change of the gauge, not a value
+		if m.value < 0 {
+			continue
+		}
+		line := join(prefix, m.path) + ":" + formatValue(m.value) + "|g"
+		if b.Len() > 0 && b.Len()+1+len(line) > statsdPacket {
+			if _, err := d.conn.Write(b.Bytes()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		if _, err := d.conn.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *StatsD) Close() error {
+	return d.conn.Close()
+}
+
+func (d *StatsD) String() string {
+	return "statsd:" + d.addr
+}
+
+func join(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	return prefix + "." + path
+}
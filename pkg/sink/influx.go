@@ -0,0 +1,401 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Influx writes every sample in InfluxDB line protocol, with the
+// measurements, tags and fields Telegraf uses for the same metrics, so that
+// existing dashboards work with rtop too. The lines go to stdout, a file,
+// or are pushed to the write endpoint of an InfluxDB server.
+type Influx struct {
+	target string
+	w      io.Writer
+	closer io.Closer
+
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewInflux writes to stdout for a target of -, pushes to an http or https
+// URL such as http://localhost:8086/write?db=rtop or
+// http://localhost:8086/api/v2/write?org=ops&bucket=rtop, and appends to
+// the file at target otherwise. The token, if any, is sent with the pushes.
+func NewInflux(target, token string) (*Influx, error) {
+	i := &Influx{target: target}
+	switch {
+	case target == "-":
+		i.w = os.Stdout
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		i.url = target
+		i.token = token
+		i.client = &http.Client{Timeout: 10 * time.Second}
+	default:
+		f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		i.w, i.closer = f, f
+	}
+	return i, nil
+}
+
+func (i *Influx) Write(ctx context.Context, s HostStats) error {
+	var b bytes.Buffer
+	writeLines(&b, s)
+	if i.url == "" {
+		_, err := i.w.Write(b.Bytes())
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx write: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (i *Influx) Close() error {
+	if i.closer != nil {
+		return i.closer.Close()
+	}
+	return nil
+}
+
+func (i *Influx) String() string {
+	return "influx:" + i.target
+}
+
+// point is a line of line protocol being built.
+type point struct {
+	b      *bytes.Buffer
+	fields int
+}
+
+// writeLines writes the lines of a sample. The host tag is the hostname of
+// the host, as with Telegraf, and the target tag the host as given to rtop.
+// Fields of collectors which produced nothing in the sample are left out
+// rather than written as zeros.
+func writeLines(b *bytes.Buffer, s HostStats) {
+	st := s.Stats
+	ts := strconv.FormatInt(s.Time.UnixNano(), 10)
+	hostname := st.Hostname
+	if hostname == "" {
+		hostname = s.Host
+	}
+	common := map[string]string{"host": hostname, "target": s.Host}
+	for k, v := range st.Labels {
+		if _, ok := common[k]; !ok {
+			common[k] = v
+		}
+	}
+
+	line := func(measurement string, tags map[string]string, fields func(p *point)) {
+		p := &point{b: b}
+		start := b.Len()
+		b.WriteString(escape(measurement, ", "))
+		writeTags(b, common, tags)
+		b.WriteByte(' ')
+		fields(p)
+		if p.fields == 0 {
+			b.Truncate(start)
+			return
+		}
+		b.WriteByte(' ')
+		b.WriteString(ts)
+		b.WriteByte('\n')
+	}
+
+	cpu := func(name string, c types.CPUInfo) {
+		line("cpu", map[string]string{"cpu": name}, func(p *point) {
+			p.float32("usage_user", c.User)
+			p.float32("usage_system", c.System)
+			p.float32("usage_nice", c.Nice)
+			p.float32("usage_idle", c.Idle)
+			p.float32("usage_iowait", c.IOWait)
+			p.float32("usage_irq", c.IRQ)
+			p.float32("usage_softirq", c.SoftIRQ)
+			p.float32("usage_steal", c.Steal)
+			p.float32("usage_guest", c.Guest)
+		})
+	}
+	if st.Collected("cpu") {
+		cpu("cpu-total", st.CPU)
+		for _, c := range st.Cores {
+			cpu(c.Core, c)
+		}
+	}
+
+	line("system", nil, func(p *point) {
+		for _, l := range []struct{ name, val string }{
+			{"load1", st.Loads.Load1}, {"load5", st.Loads.Load5}, {"load15", st.Loads.Load15},
+		} {
+			if v, err := strconv.ParseFloat(l.val, 64); err == nil {
+				p.float(l.name, v)
+			}
+		}
+		if st.Collected("uptime") {
+			p.int("uptime", int64(st.Uptime/time.Second))
+		}
+	})
+	line("processes", nil, func(p *point) {
+		if v, err := strconv.ParseInt(st.Loads.RunningProcs, 10, 64); err == nil {
+			p.int("running", v)
+		}
+		if v, err := strconv.ParseInt(st.Loads.TotalProcs, 10, 64); err == nil {
+			p.int("total", v)
+		}
+	})
+
+	if m := st.MEM; st.Collected("mem") {
+		line("mem", nil, func(p *point) {
+			p.uint("total", m.Total)
+			p.uint("free", m.Free)
+			p.uint("buffered", m.Buffers)
+			p.uint("cached", m.Cached)
+			p.uint("used", m.Used())
+			p.uint("available", m.Total-m.Used())
+			p.float("used_percent", percent(m.Used(), m.Total))
+			p.float("available_percent", percent(m.Total-m.Used(), m.Total))
+		})
+		line("swap", nil, func(p *point) {
+			p.uint("total", m.SwapTotal)
+			p.uint("free", m.SwapFree)
+			p.uint("used", m.SwapTotal-m.SwapFree)
+			p.float("used_percent", percent(m.SwapTotal-m.SwapFree, m.SwapTotal))
+		})
+	}
+
+	for _, fs := range st.FSInfos {
+		line("disk", map[string]string{"path": fs.MountPoint, "device": strings.TrimPrefix(fs.Device, "/dev/")}, func(p *point) {
+			p.uint("total", fs.Total)
+			p.uint("free", fs.Free)
+			p.uint("used", fs.Used)
+			p.float("used_percent", percent(fs.Used, fs.Total))
+			if fs.InodesTotal > 0 {
+				p.uint("inodes_total", fs.InodesTotal)
+				p.uint("inodes_free", fs.InodesFree)
+				p.uint("inodes_used", fs.InodesUsed)
+			}
+		})
+	}
+	for _, d := range st.DiskIO {
+		line("diskio", map[string]string{"name": d.Device}, func(p *point) {
+			p.uint("reads", d.Reads)
+			p.uint("writes", d.Writes)
+			p.uint("read_bytes", d.ReadBytes)
+			p.uint("write_bytes", d.WriteBytes)
+		})
+	}
+
+	// the interfaces have addresses but no counters if only netip worked
+	ifaces := make([]string, 0, len(st.NetInterface))
+	if st.Collected("netdev") {
+		for name := range st.NetInterface {
+			ifaces = append(ifaces, name)
+		}
+	}
+	sort.Strings(ifaces)
+	for _, name := range ifaces {
+		ni := st.NetInterface[name]
+		line("net", map[string]string{"interface": name}, func(p *point) {
+			p.uint("bytes_recv", ni.Rx)
+			p.uint("bytes_sent", ni.Tx)
+		})
+	}
+
+	for _, sensor := range st.Sensors {
+		line("temp", map[string]string{"sensor": sensor.Name}, func(p *point) {
+			p.float("temp", sensor.Temp)
+		})
+	}
+
+	for _, gpu := range st.GPUs {
+		line("gpu", map[string]string{"index": strconv.Itoa(gpu.Index), "name": gpu.Name}, func(p *point) {
+			p.float("utilization", gpu.Utilization)
+			p.uint("memory_used", gpu.MemoryUsed)
+			p.uint("memory_total", gpu.MemoryTotal)
+			p.float("temp", gpu.Temp)
+		})
+	}
+
+	for _, pool := range st.ZFS {
+		var healthy int64
+		if pool.Healthy() {
+			healthy = 1
+		}
+		line("zfs", map[string]string{"pool": pool.Name}, func(p *point) {
+			p.uint("size", pool.Size)
+			p.uint("alloc", pool.Alloc)
+			p.uint("free", pool.Free)
+			p.float("capacity", pool.Capacity)
+			p.float("fragmentation", pool.Fragmentation)
+			p.int("healthy", healthy)
+		})
+	}
+
+	// the metrics of the optional collectors, e.g. redis.used_memory, go
+	// to a measurement per collector prefixed with rtop_
+	extra := make(map[string][]string)
+	for key := range st.Extra {
+		collector, _, _ := strings.Cut(key, ".")
+		extra[collector] = append(extra[collector], key)
+	}
+	collectors := make([]string, 0, len(extra))
+	for c := range extra {
+		collectors = append(collectors, c)
+	}
+	sort.Strings(collectors)
+	for _, c := range collectors {
+		keys := extra[c]
+		sort.Strings(keys)
+		line("rtop_"+c, nil, func(p *point) {
+			for _, key := range keys {
+				p.value(strings.TrimPrefix(key, c+"."), st.ExtraValue(key))
+			}
+		})
+	}
+}
+
+func writeTags(b *bytes.Buffer, common, tags map[string]string) {
+	all := make(map[string]string, len(common)+len(tags))
+	for k, v := range common {
+		all[k] = v
+	}
+	for k, v := range tags {
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k, v := range all {
+		// empty tag values are not allowed
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(escape(k, ",= "))
+		b.WriteByte('=')
+		b.WriteString(escape(all[k], ",= "))
+	}
+}
+
+func (p *point) key(name string) {
+	if p.fields > 0 {
+		p.b.WriteByte(',')
+	}
+	p.fields++
+	p.b.WriteString(escape(name, ",= "))
+	p.b.WriteByte('=')
+}
+
+func (p *point) float(name string, v float64) {
+	p.key(name)
+	p.b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// float32 writes a float field with the precision of the value, so that
+// 12.3 is not written as 12.300000190734863.
+func (p *point) float32(name string, v float32) {
+	p.key(name)
+	p.b.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+}
+
+func (p *point) int(name string, v int64) {
+	p.key(name)
+	p.b.WriteString(strconv.FormatInt(v, 10))
+	p.b.WriteByte('i')
+}
+
+// uint writes an integer field, which Telegraf uses for counters and sizes
+// in bytes.
+func (p *point) uint(name string, v uint64) {
+	p.key(name)
+	p.b.WriteString(strconv.FormatUint(v, 10))
+	p.b.WriteByte('i')
+}
+
+// value writes values of units counting whole things, such as bytes, as
+// integers like the built-in metrics, and others as floats.
+func (p *point) value(name string, v types.Value) {
+	if v.Unit.Integral() {
+		p.int(name, int64(v.Amount))
+		return
+	}
+	p.float(name, v.Amount)
+}
+
+// escape backslash escapes the given characters, and newlines, which line
+// protocol cannot carry, are replaced with spaces.
+func escape(s, chars string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func percent(val, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(val) / float64(total) * 100
+}
@@ -0,0 +1,60 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// JSON writes every sample as a line of JSON to a file.
+type JSON struct {
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// NewJSON appends to the file at path, creating it if needed.
+func NewJSON(path string) (*JSON, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSON{path: path, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (j *JSON) Write(ctx context.Context, s HostStats) error {
+	return j.enc.Encode(s)
+}
+
+func (j *JSON) Close() error {
+	return j.f.Close()
+}
+
+func (j *JSON) String() string {
+	return "json:" + j.path
+}
@@ -0,0 +1,320 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package sink passes the stats of the monitored hosts on to outputs such
+// as files or metrics backends.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// HostStats is a sample of the stats of a single host.
+type HostStats struct {
+	Host  string      `json:"host"`
+	Time  time.Time   `json:"time"`
+	Stats types.Stats `json:"stats"`
+}
+
+// Sink receives the samples of all monitored hosts.
+type Sink interface {
+	Write(ctx context.Context, s HostStats) error
+}
+
+// DefaultBufferSize is the number of samples buffered per sink.
+const DefaultBufferSize = 64
+
+// DropPolicy decides which sample is dropped when the buffer of a sink is
+// full.
+type DropPolicy int
+
+const (
+	// DropNewest drops the sample being written, keeping the buffered ones.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest buffered sample to make room, so that a
+	// sink which catches up again continues with recent samples.
+	DropOldest
+)
+
+// ParseDropPolicy parses newest or oldest.
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch s {
+	case "newest":
+		return DropNewest, nil
+	case "oldest":
+		return DropOldest, nil
+	}
+	return 0, fmt.Errorf("invalid drop policy %q, expected newest or oldest", s)
+}
+
+type Option func(f *Fanout)
+
+// WithBufferSize sets how many samples are buffered per sink before samples
+// are dropped.
+func WithBufferSize(n int) Option {
+	return func(f *Fanout) {
+		f.bufferSize = n
+	}
+}
+
+// WithDropPolicy sets which samples are dropped for sinks which cannot keep
+// up. The default is DropNewest.
+func WithDropPolicy(p DropPolicy) Option {
+	return func(f *Fanout) {
+		f.policy = p
+	}
+}
+
+// WithAggregation makes the Fanout write one sample for every k samples of
+// a host, combining them with a, to lower the resolution written to the
+// sinks below that of the refreshes. Samples still pending are written on
+// Close. A k of 1 or less writes every sample.
+func WithAggregation(k int, a Aggregation) Option {
+	return func(f *Fanout) {
+		f.aggregateK = k
+		f.aggregation = a
+	}
+}
+
+// Metrics describes the back-pressure of a sink.
+type Metrics struct {
+	Name string
+	// Queued is the number of samples waiting in the buffer
+	Queued int
+	// Written, Failed and Dropped count the samples written successfully,
+	// those the sink returned an error for and those dropped from or not
+	// taken into the full buffer
+	Written int
+	Failed  int
+	Dropped int
+	// LastError is the most recent error of the sink, and Failing is set
+	// while the latest write failed
+	LastError string
+	Failing   bool
+	// WriteTime is the total time spent in Write
+	WriteTime time.Duration
+}
+
+// Fanout writes every sample to several sinks. Each sink has its own buffer
+// and goroutine, so a slow or failing sink neither delays nor breaks the
+// others; samples it cannot keep up with are dropped.
+type Fanout struct {
+	bufferSize int
+	policy     DropPolicy
+	outs       []*output
+	wg         sync.WaitGroup
+
+	// pending holds the samples of each host not yet aggregated into one
+	aggregateK  int
+	aggregation Aggregation
+	pendingMu   sync.Mutex
+	pending     map[string][]HostStats
+
+	// mu is held for reading while queueing samples, so that Close does
+	// not close the buffers under Write; closed is set by Close
+	mu     sync.RWMutex
+	closed bool
+}
+
+// output is a sink together with its buffer and errors.
+type output struct {
+	name string
+	sink Sink
+	ch   chan HostStats
+
+	mu       sync.Mutex
+	metrics  Metrics
+	err      error
+	reported int // dropped samples already returned by Err
+}
+
+// NewFanout starts writing to the given sinks. Sinks implementing
+// fmt.Stringer are named by it in errors.
+func NewFanout(sinks []Sink, opts ...Option) *Fanout {
+	f := &Fanout{bufferSize: DefaultBufferSize, pending: make(map[string][]HostStats)}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	for i, s := range sinks {
+		name := fmt.Sprintf("sink %d", i+1)
+		if st, ok := s.(fmt.Stringer); ok {
+			name = st.String()
+		}
+		o := &output{name: name, sink: s, ch: make(chan HostStats, f.bufferSize)}
+		o.metrics.Name = name
+		f.outs = append(f.outs, o)
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			o.run()
+		}()
+	}
+	return f
+}
+
+func (o *output) run() {
+	for s := range o.ch {
+		start := time.Now()
+		err := o.sink.Write(context.Background(), s)
+		d := time.Since(start)
+
+		o.mu.Lock()
+		o.metrics.WriteTime += d
+		o.metrics.Failing = err != nil
+		if err != nil {
+			o.metrics.Failed++
+			o.metrics.LastError = err.Error()
+			o.err = err
+		} else {
+			o.metrics.Written++
+		}
+		o.mu.Unlock()
+	}
+}
+
+// Write queues the sample, or with WithAggregation that of every k samples
+// of the host, for all sinks without waiting for them. Sinks whose buffer
+// is full lose a sample according to the drop policy. Samples written
+// after Close are discarded.
+func (f *Fanout) Write(ctx context.Context, s HostStats) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return nil
+	}
+	if f.aggregateK > 1 {
+		f.pendingMu.Lock()
+		samples := append(f.pending[s.Host], s)
+		if len(samples) < f.aggregateK {
+			f.pending[s.Host] = samples
+			f.pendingMu.Unlock()
+			return nil
+		}
+		delete(f.pending, s.Host)
+		f.pendingMu.Unlock()
+		s = aggregate(samples, f.aggregation)
+	}
+	f.queue(s)
+	return nil
+}
+
+// queue queues the sample for all sinks.
+func (f *Fanout) queue(s HostStats) {
+	for _, o := range f.outs {
+		select {
+		case o.ch <- s:
+			continue
+		default:
+		}
+
+		o.mu.Lock()
+		o.metrics.Dropped++
+		o.mu.Unlock()
+		if f.policy != DropOldest {
+			continue
+		}
+		select {
+		case <-o.ch:
+		default:
+		}
+		select {
+		case o.ch <- s:
+		default:
+		}
+	}
+}
+
+// Metrics returns the back-pressure metrics of every sink.
+func (f *Fanout) Metrics() []Metrics {
+	res := make([]Metrics, 0, len(f.outs))
+	for _, o := range f.outs {
+		o.mu.Lock()
+		m := o.metrics
+		o.mu.Unlock()
+		m.Queued = len(o.ch)
+		res = append(res, m)
+	}
+	return res
+}
+
+// Err returns the errors of the sinks since the previous call, including
+// the number of samples dropped for being too slow, or nil.
+func (f *Fanout) Err() error {
+	var msgs []string
+	for _, o := range f.outs {
+		o.mu.Lock()
+		if o.err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", o.name, o.err))
+		}
+		if n := o.metrics.Dropped - o.reported; n > 0 {
+			msgs = append(msgs, fmt.Sprintf("%s: dropped %d samples, cannot keep up", o.name, n))
+		}
+		o.err, o.reported = nil, o.metrics.Dropped
+		o.mu.Unlock()
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
+
+// Close waits for the buffered samples to be written. Sinks implementing
+// io.Closer are closed afterwards. Closing it again does nothing.
+func (f *Fanout) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.pendingMu.Lock()
+	for host, samples := range f.pending {
+		f.queue(aggregate(samples, f.aggregation))
+		delete(f.pending, host)
+	}
+	f.pendingMu.Unlock()
+	for _, o := range f.outs {
+		close(o.ch)
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+
+	var firstErr error
+	for _, o := range f.outs {
+		if c, ok := o.sink.(interface{ Close() error }); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s", o.name, err)
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,223 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tableui
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// fleet column indexes, for ranking the hosts with a single key
+const (
+	fleetCPU  = 2
+	fleetDisk = 4
+	fleetSwap = 5
+)
+
+func newFleetTable() *table {
+	return newTable("Hosts", []column{
+		{title: "#", right: true},
+		{title: "HOST"},
+		{title: "CPU%", right: true},
+		{title: "MEM%", right: true},
+		{title: "DISK%", right: true},
+		{title: "SWAP IO/s", right: true},
+		{title: "LOAD1", right: true},
+	}, fleetCPU, true).freeze(2)
+}
+
+// rankBy sorts the hosts by the given column, worst first.
+func (t *table) rankBy(col int) {
+	t.sortCol = col
+	t.desc = true
+	t.redraw()
+}
+
+// showFleet fills the hosts table with a row per host or, when grouping
+// by a label, a row per group with the aggregates of its hosts, followed by
+// the hosts of expanded groups. Hosts without stats are listed with empty
+// values, which sort last.
+func (u *ui) showFleet() {
+	if u.groupBy == "" || !u.grouped {
+		rows := make([]row, 0, len(u.hosts))
+		for i, h := range u.hosts {
+			rows = append(rows, hostRow(i, h, ""))
+		}
+		u.fleet.setRows(rows)
+		return
+	}
+
+	members := make(map[string][]int)
+	var names []string
+	for i, h := range u.hosts {
+		name := h.stats.Labels[u.groupBy]
+		if _, ok := members[name]; !ok {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], i)
+	}
+
+	var rows []row
+	for _, name := range names {
+		rows = append(rows, u.groupRow(name, members[name]))
+		if u.expanded[name] {
+			for _, i := range members[name] {
+				rows = append(rows, hostRow(i, u.hosts[i], name))
+			}
+		}
+	}
+	u.fleet.setRows(rows)
+}
+
+// hostMetrics are the values of the hosts table.
+type hostMetrics struct {
+	cpu, mem, disk, swap, load float64
+}
+
+func metricsOf(s types.Stats) hostMetrics {
+	m := hostMetrics{
+		cpu:  float64(100 - s.CPU.Idle),
+		mem:  percent(s.MEM.Used(), s.MEM.Total),
+		swap: s.SwapActivity.InRate + s.SwapActivity.OutRate,
+	}
+	for _, fs := range s.FSInfos {
+		if p := percent(fs.Used, fs.Total); p > m.disk {
+			m.disk = p
+		}
+	}
+	m.load, _ = strconv.ParseFloat(s.Loads.Load1, 64)
+	return m
+}
+
+// hostRow returns the row of the host at index i, a member of the given
+// group unless it is empty. Hosts backed off from are marked degraded.
+func hostRow(i int, h *hostState, group string) row {
+	num := strconv.Itoa(i + 1)
+	name := h.host.Name
+	if group != "" {
+		name = "  " + name
+	}
+	if _, ok := tui.BackingOff(h.err); ok {
+		name += " DEGRADED"
+	}
+	if h.stats.Hostname == "" {
+		return row{
+			cells: []string{num, name, "", "", "", "", ""},
+			keys:  []interface{}{float64(i + 1), h.host.Name, -1.0, -1.0, -1.0, -1.0, -1.0},
+			group: group,
+		}
+	}
+
+	m := metricsOf(h.stats)
+	return row{
+		cells: []string{num, name,
+			fmt.Sprintf("%.1f", m.cpu), fmt.Sprintf("%.1f", m.mem), fmt.Sprintf("%.1f", m.disk),
+			fmt.Sprintf("%.1f", m.swap), h.stats.Loads.Load1},
+		keys:  []interface{}{float64(i + 1), h.host.Name, m.cpu, m.mem, m.disk, m.swap, m.load},
+		group: group,
+	}
+}
+
+// groupRow returns the header row of a group of hosts, with the average
+// cpu, memory and load, the fullest disk and the total swap activity.
+func (u *ui) groupRow(name string, members []int) row {
+	var agg hostMetrics
+	var n int
+	for _, i := range members {
+		if u.hosts[i].stats.Hostname == "" {
+			continue
+		}
+		m := metricsOf(u.hosts[i].stats)
+		agg.cpu += m.cpu
+		agg.mem += m.mem
+		agg.load += m.load
+		agg.swap += m.swap
+		if m.disk > agg.disk {
+			agg.disk = m.disk
+		}
+		n++
+	}
+
+	marker := "▸"
+	if u.expanded[name] {
+		marker = "▾"
+	}
+	count := fmt.Sprintf("%d hosts", len(members))
+	if len(members) == 1 {
+		count = "1 host"
+	}
+	label := fmt.Sprintf("%s=%s (%s)", u.groupBy, name, count)
+	if name == "" {
+		label = fmt.Sprintf("no %s (%s)", u.groupBy, count)
+	}
+	if n == 0 {
+		return row{
+			cells:  []string{marker, label, "", "", "", "", ""},
+			keys:   []interface{}{float64(members[0] + 1), name, -1.0, -1.0, -1.0, -1.0, -1.0},
+			group:  name,
+			header: true,
+		}
+	}
+
+	agg.cpu /= float64(n)
+	agg.mem /= float64(n)
+	agg.load /= float64(n)
+	return row{
+		cells: []string{marker, label,
+			fmt.Sprintf("%.1f", agg.cpu), fmt.Sprintf("%.1f", agg.mem), fmt.Sprintf("%.1f", agg.disk),
+			fmt.Sprintf("%.1f", agg.swap), fmt.Sprintf("%.2f", agg.load)},
+		keys:   []interface{}{float64(members[0] + 1), name, agg.cpu, agg.mem, agg.disk, agg.swap, agg.load},
+		group:  name,
+		header: true,
+	}
+}
+
+// selectFleetRow selects the host of the given row of the hosts table, or
+// expands or collapses the group of a group row.
+func (u *ui) selectFleetRow(r int) {
+	row, ok := u.fleet.GetCell(r, 0).GetReference().(row)
+	if !ok {
+		return
+	}
+	if row.header {
+		u.expanded[row.group] = !u.expanded[row.group]
+		u.showFleet()
+		return
+	}
+	if i, err := strconv.Atoi(row.cells[0]); err == nil {
+		u.selectHost(i - 1)
+	}
+}
+
+func percent(val, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(val) / float64(total) * 100
+}
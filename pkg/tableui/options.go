@@ -0,0 +1,55 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tableui
+
+type Option func(u *ui)
+
+// WithHostChanges adds the hosts of the tui.AddHostMsg and removes those of
+// the tui.RemoveHostMsg received from ch while running, such as the hosts
+// found when the targets are resolved again. The hosts table is shown even
+// for a single host then.
+func WithHostChanges(ch <-chan interface{}) Option {
+	return func(u *ui) {
+		u.changes = ch
+	}
+}
+
+// WithStatus adds what status returns, unless empty, to the header, such
+// as the state of the sinks the stats are written to.
+func WithStatus(status func() string) Option {
+	return func(u *ui) {
+		u.status = status
+	}
+}
+
+// WithGroupBy groups the hosts table by the given label, showing a row
+// with the aggregates of each group which expands into its hosts.
+func WithGroupBy(label string) Option {
+	return func(u *ui) {
+		u.groupBy = label
+		u.grouped = label != ""
+	}
+}
@@ -0,0 +1,232 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tableui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// column describes a column of a table.
+type column struct {
+	title string
+	right bool
+}
+
+// row is a table row. The keys are what the columns are sorted by, either
+// float64 or string values. Rows of the same group are kept together,
+// sorted by the keys of their header row, which comes first.
+type row struct {
+	cells  []string
+	keys   []interface{}
+	group  string
+	header bool
+}
+
+// table is a tview table with a fixed header row, which can be sorted by
+// any column and keeps the selected row across updates.
+type table struct {
+	*tview.Table
+	frozen  int
+	columns []column
+	rows    []row
+	sortCol int
+	desc    bool
+}
+
+func newTable(title string, columns []column, sortCol int, desc bool) *table {
+	t := &table{
+		Table:   tview.NewTable().SetFixed(1, 1).SetSelectable(true, false),
+		frozen:  1,
+		columns: columns,
+		sortCol: sortCol,
+		desc:    desc,
+	}
+	t.SetBorder(true).SetTitle(" " + title + " ")
+	return t
+}
+
+// freeze keeps the first n columns in place when the others are scrolled
+// horizontally, by default only the first one.
+func (t *table) freeze(n int) *table {
+	t.frozen = n
+	t.SetFixed(1, n)
+	return t
+}
+
+// Draw draws the table, marking the top border with arrows on the sides
+// where columns are scrolled out of view.
+func (t *table) Draw(screen tcell.Screen) {
+	t.Table.Draw(screen)
+
+	_, offset := t.GetOffset()
+	_, _, width, _ := t.GetInnerRect()
+	var used int
+	more := false
+	for c := 0; c < t.GetColumnCount(); c++ {
+		if c >= t.frozen && c < t.frozen+offset {
+			continue
+		}
+		var w int
+		for r := 0; r < t.GetRowCount(); r++ {
+			if cw := tview.TaggedStringWidth(t.GetCell(r, c).Text); cw > w {
+				w = cw
+			}
+		}
+		if used > 0 {
+			used++
+		}
+		if used += w; used > width {
+			more = true
+			break
+		}
+	}
+
+	x, y, w, _ := t.GetRect()
+	if offset > 0 {
+		tview.Print(screen, "◀", x+1, y, 1, tview.AlignLeft, tview.Styles.TitleColor)
+	}
+	if more {
+		tview.Print(screen, "▶", x+w-2, y, 1, tview.AlignLeft, tview.Styles.TitleColor)
+	}
+}
+
+// setRows replaces the rows of the table.
+func (t *table) setRows(rows []row) {
+	t.rows = rows
+	t.redraw()
+}
+
+// sortBy sorts by the column delta columns away from the current one.
+func (t *table) sortBy(delta int) {
+	n := len(t.columns)
+	t.sortCol = ((t.sortCol+delta)%n + n) % n
+	t.redraw()
+}
+
+func (t *table) reverse() {
+	t.desc = !t.desc
+	t.redraw()
+}
+
+func (t *table) redraw() {
+	// remember the selected row across the update
+	var selected string
+	if r, _ := t.GetSelection(); r > 0 && r <= t.GetRowCount()-1 {
+		if row, ok := t.GetCell(r, 0).GetReference().(row); ok {
+			selected = row.id()
+		}
+	}
+
+	headers := make(map[string][]interface{})
+	for _, r := range t.rows {
+		if r.header {
+			headers[r.group] = r.keys
+		}
+	}
+	less := func(a, b []interface{}) bool {
+		if t.desc {
+			return lessKey(b[t.sortCol], a[t.sortCol])
+		}
+		return lessKey(a[t.sortCol], b[t.sortCol])
+	}
+	sort.SliceStable(t.rows, func(i, j int) bool {
+		a, b := t.rows[i], t.rows[j]
+		if a.group != b.group {
+			ak, bk := headers[a.group], headers[b.group]
+			if ak == nil || bk == nil {
+				ak, bk = a.keys, b.keys
+			}
+			if less(ak, bk) != less(bk, ak) {
+				return less(ak, bk)
+			}
+			return a.group < b.group
+		}
+		if a.header != b.header {
+			return a.header
+		}
+		return less(a.keys, b.keys)
+	})
+
+	t.Clear()
+	for c, col := range t.columns {
+		title := col.title
+		if c == t.sortCol {
+			if t.desc {
+				title += " ▼"
+			} else {
+				title += " ▲"
+			}
+		}
+		t.SetCell(0, c, t.cell(col, title).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+
+	sel := 1
+	for r, row := range t.rows {
+		for c, col := range t.columns {
+			t.SetCell(r+1, c, t.cell(col, row.cells[c]).SetReference(row))
+		}
+		if row.id() == selected {
+			sel = r + 1
+		}
+	}
+	if len(t.rows) > 0 {
+		t.Select(sel, 0)
+	}
+}
+
+// id identifies a row across updates, by its first cell or its group.
+func (r row) id() string {
+	if r.header {
+		return "group\x00" + r.group
+	}
+	return r.cells[0]
+}
+
+func (t *table) cell(col column, text string) *tview.TableCell {
+	cell := tview.NewTableCell(tview.Escape(text))
+	if col.right {
+		cell.SetAlign(tview.AlignRight)
+	}
+	return cell
+}
+
+func lessKey(a, b interface{}) bool {
+	switch a := a.(type) {
+	case float64:
+		b, _ := b.(float64)
+		return a < b
+	case string:
+		b, _ := b.(string)
+		return a < b
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
@@ -0,0 +1,383 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package tableui is an alternative to the tui package, showing processes,
+// filesystems and network interfaces in sortable tables with selectable
+// rows. With several hosts, a table of all hosts ranks them by cpu, disk
+// usage or swap activity.
+package tableui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rapidloop/rtop/pkg/tui"
+	"github.com/rapidloop/rtop/pkg/types"
+	"github.com/rivo/tview"
+)
+
+const helpText = " tab: next table  n/p: next/previous host  </>: sort column  r: reverse order  left/right: scroll  q: quit"
+
+// fleetHelpText is shown instead of helpText with more than one host.
+const fleetHelpText = " tab: next table  n/p/enter: select host  c/d/w: rank hosts by cpu/disk/swap  </>: sort column  r: reverse  left/right: scroll  q: quit"
+
+// groupHelpText is shown instead of fleetHelpText when grouping by a label.
+const groupHelpText = " tab: next table  n/p: select host  enter: select host or expand group  g: toggle groups  c/d/w: rank by cpu/disk/swap  r: reverse  left/right: scroll  q: quit"
+
+type hostState struct {
+	host  tui.Host
+	stats types.Stats
+	err   error
+	// done is closed once the host is removed, to stop polling it
+	done chan struct{}
+}
+
+type ui struct {
+	app    *tview.Application
+	header *tview.TextView
+	procs  *table
+	mounts *table
+	ifaces *table
+	// fleet lists all hosts, if there is more than one
+	fleet   *table
+	focus   []*table
+	hosts   []*hostState
+	current int
+
+	// groupBy is the label the hosts table is grouped by, while grouped
+	groupBy  string
+	grouped  bool
+	expanded map[string]bool
+
+	// interval is how often the hosts are polled, and changes adds and
+	// removes hosts while running, if set
+	interval time.Duration
+	changes  <-chan interface{}
+
+	// status returns more to show in the header, if set
+	status func() string
+}
+
+// Run shows the hosts, refreshed at the given interval, until the user
+// quits.
+func Run(hosts []tui.Host, interval time.Duration, opts ...Option) error {
+	u := &ui{
+		interval: interval,
+		expanded: make(map[string]bool),
+		app:      tview.NewApplication(),
+		header:   tview.NewTextView().SetDynamicColors(true),
+		procs: newTable("Processes", []column{
+			{title: "PID", right: true},
+			{title: "USER"},
+			{title: "CPU%", right: true},
+			{title: "MEM%", right: true},
+			{title: "RSS", right: true},
+			{title: "S"},
+			{title: "COMMAND"},
+		}, 2, true),
+		mounts: newTable("Filesystems", []column{
+			{title: "MOUNT"},
+			{title: "DEVICE"},
+			{title: "USED%", right: true},
+			{title: "FREE", right: true},
+			{title: "TOTAL", right: true},
+			{title: "INODE%", right: true},
+		}, 0, false),
+		ifaces: newTable("Network Interfaces", []column{
+			{title: "NAME"},
+			{title: "IPV4"},
+			{title: "IPV6"},
+			{title: "RX", right: true},
+			{title: "TX", right: true},
+			{title: "RX/S", right: true},
+			{title: "TX/S", right: true},
+		}, 0, false),
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	u.focus = []*table{u.procs, u.mounts, u.ifaces}
+	for _, h := range hosts {
+		u.hosts = append(u.hosts, &hostState{host: h, done: make(chan struct{})})
+	}
+
+	bottom := tview.NewFlex().
+		AddItem(u.mounts, 0, 1, false).
+		AddItem(u.ifaces, 0, 1, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(u.header, 4, 0, false)
+	help := helpText
+	if len(u.hosts) > 1 || u.changes != nil {
+		u.fleet = newFleetTable()
+		u.fleet.SetSelectedFunc(func(r, c int) {
+			u.selectFleetRow(r)
+		})
+		u.focus = append([]*table{u.fleet}, u.focus...)
+		root.AddItem(u.fleet, 0, 1, true)
+		help = fleetHelpText
+		if u.groupBy != "" {
+			help = groupHelpText
+		}
+	}
+	root.AddItem(u.procs, 0, 2, u.fleet == nil).
+		AddItem(bottom, 0, 1, false).
+		AddItem(tview.NewTextView().SetText(help), 1, 0, false)
+
+	u.app.SetInputCapture(u.handleKey)
+	u.app.SetRoot(root, true).EnableMouse(true)
+
+	for _, h := range u.hosts {
+		go u.poll(h)
+	}
+	if u.changes != nil {
+		go u.applyChanges()
+	}
+	u.show()
+
+	return u.app.Run()
+}
+
+// poll refreshes the stats of the host every interval until it is
+// removed.
+func (u *ui) poll(h *hostState) {
+	for {
+		stats, err := h.host.GetStats(context.Background())
+		u.app.QueueUpdateDraw(func() {
+			i := u.hostIndex(h)
+			if i < 0 {
+				return
+			}
+			h.err = err
+			if err == nil || stats.Hostname != "" {
+				h.stats = stats
+			}
+			if i == u.current {
+				u.show()
+			}
+			if u.fleet != nil {
+				u.showFleet()
+			}
+		})
+		select {
+		case <-h.done:
+			return
+		case <-time.After(u.interval):
+		}
+	}
+}
+
+// applyChanges adds the hosts of the tui.AddHostMsg and removes those of
+// the tui.RemoveHostMsg received from u.changes, keeping at least one.
+func (u *ui) applyChanges() {
+	for msg := range u.changes {
+		msg := msg
+		u.app.QueueUpdateDraw(func() {
+			switch msg := msg.(type) {
+			case tui.AddHostMsg:
+				h := &hostState{host: msg.Host, done: make(chan struct{})}
+				u.hosts = append(u.hosts, h)
+				go u.poll(h)
+			case tui.RemoveHostMsg:
+				for i, h := range u.hosts {
+					if h.host.Name != msg.Name || len(u.hosts) == 1 {
+						continue
+					}
+					close(h.done)
+					u.hosts = append(u.hosts[:i:i], u.hosts[i+1:]...)
+					if u.current > i || u.current == len(u.hosts) {
+						u.current--
+					}
+					break
+				}
+			}
+			u.show()
+			u.showFleet()
+		})
+	}
+}
+
+// hostIndex returns the index of the host, or -1 if it was removed.
+func (u *ui) hostIndex(h *hostState) int {
+	for i, other := range u.hosts {
+		if other == h {
+			return i
+		}
+	}
+	return -1
+}
+
+func (u *ui) handleKey(ev *tcell.EventKey) *tcell.EventKey {
+	focused := u.focused()
+	switch ev.Key() {
+	case tcell.KeyTab:
+		u.app.SetFocus(u.focus[(focused+1)%len(u.focus)])
+		return nil
+	case tcell.KeyBacktab:
+		u.app.SetFocus(u.focus[(focused+len(u.focus)-1)%len(u.focus)])
+		return nil
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			u.app.Stop()
+			return nil
+		case 'n':
+			u.selectHost(u.current + 1)
+			return nil
+		case 'p':
+			u.selectHost(u.current - 1)
+			return nil
+		case '<':
+			u.focus[focused].sortBy(-1)
+			return nil
+		case '>':
+			u.focus[focused].sortBy(1)
+			return nil
+		case 'r':
+			u.focus[focused].reverse()
+			return nil
+		case 'g':
+			if u.fleet == nil || u.groupBy == "" {
+				return ev
+			}
+			u.grouped = !u.grouped
+			u.showFleet()
+			return nil
+		case 'c', 'd', 'w':
+			if u.fleet == nil {
+				return ev
+			}
+			u.fleet.rankBy(map[rune]int{'c': fleetCPU, 'd': fleetDisk, 'w': fleetSwap}[ev.Rune()])
+			return nil
+		}
+	}
+	return ev
+}
+
+func (u *ui) focused() int {
+	for i, t := range u.focus {
+		if t.HasFocus() {
+			return i
+		}
+	}
+	return 0
+}
+
+func (u *ui) selectHost(i int) {
+	n := len(u.hosts)
+	u.current = (i%n + n) % n
+	u.show()
+}
+
+// show fills the header and tables with the stats of the current host.
+func (u *ui) show() {
+	h := u.hosts[u.current]
+	s := h.stats
+
+	var b strings.Builder
+	if len(u.hosts) > 1 {
+		for i, other := range u.hosts {
+			if i == u.current {
+				fmt.Fprintf(&b, "[::r] %d %s [::-]", i+1, tview.Escape(other.host.Name))
+			} else {
+				fmt.Fprintf(&b, " %d %s ", i+1, tview.Escape(other.host.Name))
+			}
+		}
+		b.WriteString("\n")
+	}
+	if h.err != nil {
+		fmt.Fprintf(&b, "[red::b]error:[-::-] %s\n", tview.Escape(h.err.Error()))
+	}
+	if u.status != nil {
+		if st := u.status(); st != "" {
+			fmt.Fprintf(&b, "[yellow]%s[-]\n", tview.Escape(st))
+		}
+	}
+	if s.Hostname != "" {
+		fmt.Fprintf(&b, "[::b]%s[::-] up %s  load %s %s %s  procs %s/%s\n",
+			tview.Escape(s.Hostname), tui.FormatUptime(s.Uptime, tui.UptimeShort),
+			s.Loads.Load1, s.Loads.Load5, s.Loads.Load15,
+			s.Loads.RunningProcs, s.Loads.TotalProcs)
+		fmt.Fprintf(&b, "cpu %.1f%% user %.1f%% sys %.1f%% iowait  mem %s used of %s  swap %s free of %s\n",
+			s.CPU.User, s.CPU.System, s.CPU.IOWait,
+			fmtBytes(s.MEM.Used()), fmtBytes(s.MEM.Total),
+			fmtBytes(s.MEM.SwapFree), fmtBytes(s.MEM.SwapTotal))
+	}
+	u.header.SetText(b.String())
+
+	procs := make([]row, 0, len(s.Processes))
+	for _, p := range s.Processes {
+		procs = append(procs, row{
+			cells: []string{strconv.Itoa(p.PID), p.User, fmt.Sprintf("%.1f", p.CPU), fmt.Sprintf("%.1f", p.Mem), fmtBytes(p.RSS), p.State, p.Command},
+			keys:  []interface{}{float64(p.PID), p.User, p.CPU, p.Mem, float64(p.RSS), p.State, p.Command},
+		})
+	}
+	u.procs.setRows(procs)
+
+	mounts := make([]row, 0, len(s.FSInfos))
+	for _, fs := range s.FSInfos {
+		used := percent(fs.Used, fs.Total)
+		inodes := ""
+		if fs.InodesTotal > 0 {
+			inodes = fmt.Sprintf("%.1f", fs.InodeUsage())
+		}
+		mounts = append(mounts, row{
+			cells: []string{fs.MountPoint, fs.Device, fmt.Sprintf("%.1f", used), fmtBytes(fs.Free), fmtBytes(fs.Total), inodes},
+			keys:  []interface{}{fs.MountPoint, fs.Device, used, float64(fs.Free), float64(fs.Total), fs.InodeUsage()},
+		})
+	}
+	u.mounts.setRows(mounts)
+
+	names := make([]string, 0, len(s.NetInterface))
+	for name := range s.NetInterface {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ifaces := make([]row, 0, len(names))
+	for _, name := range names {
+		info := s.NetInterface[name]
+		ifaces = append(ifaces, row{
+			cells: []string{name, info.IPv4, info.IPv6, fmtBytes(info.Rx), fmtBytes(info.Tx), fmtBytes(uint64(info.RxRate)), fmtBytes(uint64(info.TxRate))},
+			keys:  []interface{}{name, info.IPv4, info.IPv6, float64(info.Rx), float64(info.Tx), info.RxRate, info.TxRate},
+		})
+	}
+	u.ifaces.setRows(ifaces)
+}
+
+func fmtBytes(val uint64) string {
+	switch {
+	case val < 1024:
+		return fmt.Sprintf("%d B", val)
+	case val < 1024*1024:
+		return fmt.Sprintf("%.2f KiB", float64(val)/1024)
+	case val < 1024*1024*1024:
+		return fmt.Sprintf("%.2f MiB", float64(val)/1024/1024)
+	}
+	return fmt.Sprintf("%.2f GiB", float64(val)/1024/1024/1024)
+}
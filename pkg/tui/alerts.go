@@ -0,0 +1,102 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxAlerts is the number of alerts kept per host for the alert log.
+const maxAlerts = 100
+
+// Alert is a rule which fired on a host. Alerts on the cpu and mem metrics,
+// and on net.<interface>, are also marked on the sparkline of the metric.
+type Alert struct {
+	Host    string
+	Metric  string
+	Message string
+	Time    time.Time
+}
+
+// AlertMsg adds an alert to the alert log of its host, if the Rendering
+// shows that host.
+type AlertMsg struct {
+	Alert Alert
+}
+
+// addAlert logs the alert and marks the latest sample of its metric.
+func (h *hostState) addAlert(a Alert) {
+	h.alerts = append(h.alerts, a)
+	if len(h.alerts) > maxAlerts {
+		h.alerts = h.alerts[len(h.alerts)-maxAlerts:]
+	}
+
+	var rg *ring
+	switch {
+	case a.Metric == "cpu":
+		rg = h.history.cpuRing()
+	case a.Metric == "mem":
+		rg = h.history.memRing()
+	case strings.HasPrefix(a.Metric, "net."):
+		rg = h.history.netRing(strings.TrimPrefix(a.Metric, "net."))
+	}
+	if rg != nil {
+		rg.mark()
+	}
+}
+
+// alert adds the alert to the host it names.
+func (r *Rendering) alert(a Alert) bool {
+	for i, h := range r.hosts {
+		if h.name == a.Host {
+			h.addAlert(a)
+			if i == r.current {
+				r.setContent()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// alertLog renders the alerts of a host, newest first.
+func (r Rendering) alertLog(alerts []Alert) string {
+	var b bytes.Buffer
+	b.WriteString(r.heading("Alerts") + ":\n")
+	for i := len(alerts) - 1; i >= 0; i-- {
+		a := alerts[i]
+		fmt.Fprintf(&b, "    %s %s %s\n",
+			a.Time.Format("15:04:05"),
+			r.critical(a.Metric),
+			a.Message,
+		)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,91 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Banner returns the login banner and message of the day of a host, as
+// client.Client does.
+type Banner interface {
+	Banner(ctx context.Context) (string, error)
+}
+
+// bannerMsg carries the banner of a host, read when first shown.
+type bannerMsg struct {
+	ID   int
+	Host int
+	Text string
+	Err  error
+}
+
+// showBanner opens the banner overlay of the current host, reading the
+// banner unless it already was.
+func (r *Rendering) showBanner() tea.Cmd {
+	h := r.hosts[r.current]
+	if h.banner == nil {
+		return nil
+	}
+	r.view = viewBanner
+	if h.bannerRead {
+		return nil
+	}
+	id, key, banner := r.id, h.key, h.banner
+	return func() tea.Msg {
+		text, err := banner.Banner(context.Background())
+		return bannerMsg{ID: id, Host: key, Text: text, Err: err}
+	}
+}
+
+// bannerView renders the banner of the current host, centered over the area
+// of the viewport.
+func (r Rendering) bannerView() string {
+	h := r.hosts[r.current]
+
+	var b strings.Builder
+	b.WriteString(r.styles.Value.Render("Banner of "+h.name) + "\n\n")
+	switch {
+	case h.bannerErr != nil:
+		b.WriteString(r.critical(h.bannerErr.Error()))
+	case !h.bannerRead:
+		b.WriteString("reading...")
+	case h.bannerText == "":
+		b.WriteString("no banner or message of the day")
+	default:
+		b.WriteString(strings.ReplaceAll(h.bannerText, "\r\n", "\n"))
+	}
+	b.WriteString("\n\npress any key to close")
+
+	box := r.styles.CurrentPane.Render(b.String())
+	w, height := r.viewport.Width, r.viewport.Height
+	return lipgloss.NewStyle().MaxWidth(w).MaxHeight(height).Render(
+		lipgloss.Place(w, height, lipgloss.Center, lipgloss.Center, box))
+}
@@ -0,0 +1,124 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Collectors turns the collectors of a host on and off at runtime, as
+// client.Client does.
+type Collectors interface {
+	Collectors() []types.CollectorState
+	SetCollector(name string, enabled bool) error
+}
+
+// collectorsKey handles a key while the collectors overlay is shown,
+// reporting whether it was one of its keys.
+func (r *Rendering) collectorsKey(key string) bool {
+	states := r.hosts[r.current].collectors.Collectors()
+	switch key {
+	case "ctrl+c":
+		return !r.quitKeys
+	case "up", "k":
+		if r.collCursor > 0 {
+			r.collCursor--
+		}
+	case "down", "j":
+		if r.collCursor < len(states)-1 {
+			r.collCursor++
+		}
+	case " ", "enter", "x":
+		if r.collCursor < len(states) {
+			s := states[r.collCursor]
+			r.collMsg = ""
+			for _, h := range r.hosts {
+				if h.collectors == nil {
+					continue
+				}
+				if err := h.collectors.SetCollector(s.Name, !s.Enabled); err != nil {
+					r.collMsg = h.name + ": " + err.Error()
+				}
+			}
+		}
+	case "w":
+		if r.saveCollectors == nil {
+			r.collMsg = "saving is not supported"
+		} else if err := r.saveCollectors(states); err != nil {
+			r.collMsg = "save: " + err.Error()
+		} else {
+			r.collMsg = "saved"
+		}
+	default:
+		r.view = viewStats
+		r.collMsg = ""
+	}
+	return true
+}
+
+// collectorsView renders the collectors of the current host with
+// checkboxes, centered over the area of the viewport.
+func (r Rendering) collectorsView() string {
+	h := r.hosts[r.current]
+
+	var b strings.Builder
+	b.WriteString(r.styles.Value.Render("Collectors of "+h.name) + "\n\n")
+	for i, s := range h.collectors.Collectors() {
+		cursor := "  "
+		if i == r.collCursor {
+			cursor = "> "
+		}
+		box := "[ ]"
+		if s.Enabled {
+			box = "[x]"
+		}
+		line := fmt.Sprintf("%s%s %s", cursor, box, s.Name)
+		if s.Unavailable != "" {
+			line += "  " + r.styles.Warning.Render("unavailable: "+s.Unavailable)
+		}
+		if i == r.collCursor {
+			line = r.styles.Value.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\nspace: toggle on all hosts")
+	if r.saveCollectors != nil {
+		b.WriteString("  w: save to the config file")
+	}
+	b.WriteString("  esc: close")
+	if r.collMsg != "" {
+		b.WriteString("\n" + r.collMsg)
+	}
+
+	box := r.styles.CurrentPane.Render(b.String())
+	w, height := r.viewport.Width, r.viewport.Height
+	return lipgloss.NewStyle().MaxWidth(w).MaxHeight(height).Render(
+		lipgloss.Place(w, height, lipgloss.Center, lipgloss.Center, box))
+}
@@ -0,0 +1,193 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// controlMsg carries a command received on the control socket. The result
+// of the command is sent back on reply.
+type controlMsg struct {
+	args  []string
+	reply chan error
+}
+
+// ServeControl accepts connections on the given listener and forwards the
+// commands read from them to the program, one command per line. Each command
+// is answered with "ok" or "error: <reason>". The commands are:
+//
+//	host next|prev|<name>|<number>
+//	toggle <section>
+//	interval <duration>
+//	pause|resume
+//	layout compact|normal|wide
+//	view tabs|split
+//	sort cpu|mem|pid|command
+//	fssort used|free|mount
+//	alert <host> <metric> <message>
+//	quit
+func ServeControl(p *tea.Program, l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveControlConn(p, conn)
+	}
+}
+
+func serveControlConn(p *tea.Program, conn net.Conn) {
+	defer conn.Close()
+
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		args := strings.Fields(s.Text())
+		if len(args) == 0 {
+			continue
+		}
+
+		reply := make(chan error, 1)
+		p.Send(controlMsg{args: args, reply: reply})
+
+		if err := <-reply; err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+		} else {
+			fmt.Fprintln(conn, "ok")
+		}
+	}
+}
+
+// control executes the command of a control message.
+func (r *Rendering) control(args []string) (tea.Cmd, error) {
+	switch {
+	case args[0] == "quit":
+		return tea.Quit, nil
+
+	case args[0] == "host" && len(args) == 2:
+		switch args[1] {
+		case "next":
+			r.selectHost(r.current + 1)
+			return nil, nil
+		case "prev":
+			r.selectHost(r.current - 1)
+			return nil, nil
+		}
+		for i, h := range r.hosts {
+			if h.name == args[1] {
+				r.selectHost(i)
+				return nil, nil
+			}
+		}
+		if n, err := strconv.Atoi(args[1]); err == nil && n >= 1 && n <= len(r.hosts) {
+			r.selectHost(n - 1)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown host %q", args[1])
+
+	case args[0] == "toggle" && len(args) == 2:
+		for _, name := range sectionNames {
+			if name == args[1] {
+				r.hidden[name] = !r.hidden[name]
+				r.setContent()
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown section %q, expected one of %s", args[1], strings.Join(sectionNames, ", "))
+
+	case args[0] == "pause" && len(args) == 1:
+		return r.setPaused(true), nil
+
+	case args[0] == "resume" && len(args) == 1:
+		return r.setPaused(false), nil
+
+	case args[0] == "interval" && len(args) == 2:
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("interval must be positive")
+		}
+		r.interval = d
+		return nil, nil
+
+	case args[0] == "view" && len(args) == 2:
+		switch args[1] {
+		case "tabs":
+			r.split = false
+		case "split":
+			r.split = true
+		default:
+			return nil, fmt.Errorf("unknown view %q, expected tabs or split", args[1])
+		}
+		r.setContent()
+		return nil, nil
+
+	case args[0] == "sort" && len(args) == 2:
+		order, err := ParseProcessSort(args[1])
+		if err != nil {
+			return nil, err
+		}
+		r.procSort = order
+		r.setContent()
+		return nil, nil
+
+	case args[0] == "fssort" && len(args) == 2:
+		order, err := ParseFSSort(args[1])
+		if err != nil {
+			return nil, err
+		}
+		r.fsSort = order
+		r.setContent()
+		return nil, nil
+
+	case args[0] == "alert" && len(args) >= 4:
+		a := Alert{Host: args[1], Metric: args[2], Message: strings.Join(args[3:], " "), Time: time.Now()}
+		if !r.alert(a) {
+			return nil, fmt.Errorf("unknown host %q", args[1])
+		}
+		return nil, nil
+
+	case args[0] == "layout" && len(args) == 2:
+		layout, err := ParseLayout(args[1])
+		if err != nil {
+			return nil, err
+		}
+		r.layout = layout
+		r.setContent()
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
@@ -0,0 +1,79 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// FSSort is the order of the filesystems section.
+type FSSort int
+
+const (
+	FSSortByUsed FSSort = iota
+	FSSortByFree
+	FSSortByMount
+)
+
+var fsSortNames = []string{"used", "free", "mount"}
+
+// ParseFSSort returns the filesystem order with the given name.
+func ParseFSSort(name string) (FSSort, error) {
+	for i, n := range fsSortNames {
+		if n == name {
+			return FSSort(i), nil
+		}
+	}
+	return FSSortByUsed, fmt.Errorf("unknown filesystem order %q, expected one of %s", name, strings.Join(fsSortNames, ", "))
+}
+
+func (s FSSort) String() string {
+	return fsSortNames[s]
+}
+
+func (s FSSort) next() FSSort {
+	return (s + 1) % FSSort(len(fsSortNames))
+}
+
+// sortFS returns the filesystems in the given order: the fullest, or the
+// one with the least free space, first, or by mount point.
+func sortFS(infos []types.FSInfo, by FSSort) []types.FSInfo {
+	res := append([]types.FSInfo(nil), infos...)
+	sort.SliceStable(res, func(i, j int) bool {
+		switch by {
+		case FSSortByFree:
+			return res[i].Free < res[j].Free
+		case FSSortByMount:
+			return res[i].MountPoint < res[j].MountPoint
+		}
+		return res[i].Usage() > res[j].Usage()
+	})
+	return res
+}
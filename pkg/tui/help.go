@@ -0,0 +1,125 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// viewState is what the Rendering shows in place of the viewport.
+type viewState int
+
+const (
+	viewStats viewState = iota
+	viewHelp
+	viewCollectors
+	viewBanner
+	viewProcess
+)
+
+// keyHelp describes a key binding for the help overlay.
+type keyHelp struct {
+	keys, desc string
+}
+
+// helpKeys are the key bindings of the TUI, in the order they are listed
+// by the help overlay.
+var helpKeys = []keyHelp{
+	{"?", "show or hide this help"},
+	{"n, tab", "next host"},
+	{"p, shift+tab", "previous host"},
+	{"1-9", "select host"},
+	{"space", "pause or resume refreshing"},
+	{"s", "toggle the split view"},
+	{"l", "next layout"},
+	{"o", "next process order"},
+	{"f", "next filesystem order"},
+	{"c", "turn collectors on and off"},
+	{"b", "show the login banner and message of the day"},
+	{"left/right, [/]", "scroll the process table"},
+	{"j/k, enter", "select a process, show its details"},
+	{"up/down, pgup/pgdn", "scroll"},
+}
+
+// replayHelpKeys are the key bindings when replaying a recorded session,
+// which take precedence over helpKeys.
+var replayHelpKeys = []keyHelp{
+	{"space", "pause or resume"},
+	{"+/-", "change the speed"},
+	{"left/right", "skip back or forward"},
+	{"home/end", "go to the start or end"},
+	{"g", "jump to a time"},
+}
+
+// helpKey handles a key while the help or banner overlay is shown: ctrl+c
+// still quits, any other key closes the overlay.
+func (r *Rendering) helpKey(key string) bool {
+	if key == "ctrl+c" && r.quitKeys {
+		return false
+	}
+	r.view = viewStats
+	return true
+}
+
+// helpView renders the help overlay centered over the area of the
+// viewport.
+func (r Rendering) helpView() string {
+	h := r.hosts[r.current]
+
+	var b strings.Builder
+	title := "rtop"
+	if r.version != "" {
+		title += " " + r.version
+	}
+	b.WriteString(r.styles.Value.Render(title) + "\n\n")
+	fmt.Fprintf(&b, "host      %s (%d of %d)\n", r.styles.Value.Render(h.name), r.current+1, len(r.hosts))
+	fmt.Fprintf(&b, "refresh   every %s\n", r.styles.Value.Render(r.interval.String()))
+	fmt.Fprintf(&b, "layout    %s, processes by %s, filesystems by %s\n", r.layout, r.procSort, r.fsSort)
+
+	section := func(heading string, keys []keyHelp) {
+		b.WriteString("\n" + r.styles.Heading.Render(heading) + ":\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %-20s %s\n", k.keys, k.desc)
+		}
+	}
+	if r.player != nil {
+		section("Replay", replayHelpKeys)
+	}
+	keys := helpKeys
+	if r.quitKeys {
+		keys = append(keys[:len(keys):len(keys)], keyHelp{"q, esc, ctrl+c", "quit"})
+	}
+	section("Keys", keys)
+	b.WriteString("\npress any key to close")
+
+	box := r.styles.CurrentPane.Render(b.String())
+	w, height := r.viewport.Width, r.viewport.Height
+	return lipgloss.NewStyle().MaxWidth(w).MaxHeight(height).Render(
+		lipgloss.Place(w, height, lipgloss.Center, lipgloss.Center, box))
+}
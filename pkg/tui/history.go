@@ -0,0 +1,191 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// DefaultHistorySize is the number of samples kept for the sparklines.
+const DefaultHistorySize = 30
+
+// ring keeps the last samples of a metric, and which of them are marked
+// by alerts.
+type ring struct {
+	vals  []float64
+	marks []bool
+	next  int
+	full  bool
+}
+
+func newRing(size int) *ring {
+	return &ring{vals: make([]float64, size), marks: make([]bool, size)}
+}
+
+func (r *ring) add(v float64) {
+	if len(r.vals) == 0 {
+		return
+	}
+	r.vals[r.next] = v
+	r.marks[r.next] = false
+	r.next = (r.next + 1) % len(r.vals)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// mark marks the latest sample.
+func (r *ring) mark() {
+	if r.next == 0 && !r.full {
+		return
+	}
+	r.marks[(r.next+len(r.marks)-1)%len(r.marks)] = true
+}
+
+// values returns the samples and their marks, oldest first.
+func (r *ring) values() ([]float64, []bool) {
+	if !r.full {
+		return r.vals[:r.next], r.marks[:r.next]
+	}
+	return append(append([]float64(nil), r.vals[r.next:]...), r.vals[:r.next]...),
+		append(append([]bool(nil), r.marks[r.next:]...), r.marks[:r.next]...)
+}
+
+// history keeps the recent CPU and memory usage in percent and the network
+// throughput in bytes per second per interface of a host.
+type history struct {
+	size int
+	cpu  *ring
+	mem  *ring
+	net  map[string]*ring
+}
+
+func newHistory(size int) *history {
+	return &history{
+		size: size,
+		cpu:  newRing(size),
+		mem:  newRing(size),
+		net:  make(map[string]*ring),
+	}
+}
+
+// add records the stats.
+func (h *history) add(stats types.Stats) {
+	h.cpu.add(float64(100 - stats.CPU.Idle))
+	if stats.MEM.Total > 0 {
+		h.mem.add(float64(stats.MEM.Used()) / float64(stats.MEM.Total) * 100)
+	}
+	for name, info := range stats.NetInterface {
+		r, ok := h.net[name]
+		if !ok {
+			r = newRing(h.size)
+			h.net[name] = r
+		}
+		r.add(info.RxRate + info.TxRate)
+	}
+}
+
+// cpuRing, memRing and netRing return the rings of a history which may be
+// nil.
+func (h *history) cpuRing() *ring {
+	if h == nil {
+		return nil
+	}
+	return h.cpu
+}
+
+func (h *history) memRing() *ring {
+	if h == nil {
+		return nil
+	}
+	return h.mem
+}
+
+func (h *history) netRing(name string) *ring {
+	if h == nil {
+		return nil
+	}
+	return h.net[name]
+}
+
+// sparkline renders the values of the ring, preceded by two spaces, once
+// there are at least two of them. Samples marked by alerts are drawn with
+// the Critical style.
+func (r Rendering) sparkline(rg *ring, max float64) string {
+	if rg == nil {
+		return ""
+	}
+	vals, marks := rg.values()
+	if len(vals) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("  ")
+	blocks := []rune(sparkline(vals, max))
+	start := 0
+	for i := range blocks {
+		if i+1 == len(blocks) || marks[i+1] != marks[start] {
+			style := r.styles.Sparkline
+			if marks[start] {
+				style = r.styles.Critical
+			}
+			b.WriteString(style.Render(string(blocks[start : i+1])))
+			start = i + 1
+		}
+	}
+	return b.String()
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the values as a line of blocks, scaled to max, or to the
+// largest value if max is 0.
+func sparkline(vals []float64, max float64) string {
+	if max == 0 {
+		for _, v := range vals {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	var b strings.Builder
+	for _, v := range vals {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(sparkBlocks)-1))
+		}
+		if i < 0 {
+			i = 0
+		} else if i >= len(sparkBlocks) {
+			i = len(sparkBlocks) - 1
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AddHostMsg adds a host to the Rendering, such as one newly found when the
+// targets are resolved again.
+type AddHostMsg struct {
+	Host Host
+}
+
+// RemoveHostMsg removes the host of the given name from the Rendering,
+// unless it is the last one.
+type RemoveHostMsg struct {
+	Name string
+}
+
+// addHost adds the host after the others and returns its state.
+func (r *Rendering) addHost(h Host) *hostState {
+	state := &hostState{
+		name:           h.Name,
+		getStatsFn:     h.GetStats,
+		collectors:     h.Collectors,
+		banner:         h.Banner,
+		processDetails: h.ProcessDetails,
+		key:            r.nextKey,
+	}
+	r.nextKey++
+	if r.historySize > 0 {
+		state.history = newHistory(r.historySize)
+	}
+	r.hosts = append(r.hosts, state)
+	return state
+}
+
+// removeHost removes the host of the given name, keeping the current host
+// selected if it is another one.
+func (r *Rendering) removeHost(name string) {
+	for i, h := range r.hosts {
+		if h.name != name || len(r.hosts) == 1 {
+			continue
+		}
+		// overlays of the removed host are closed
+		if i == r.current && r.view != viewHelp {
+			r.view = viewStats
+		}
+		r.hosts = append(r.hosts[:i:i], r.hosts[i+1:]...)
+		if r.current > i || r.current == len(r.hosts) {
+			r.current--
+		}
+		r.setContent()
+		return
+	}
+}
+
+// hostIndex returns the index of the host with the given key, or -1 if it
+// was removed.
+func (r Rendering) hostIndex(key int) int {
+	for i, h := range r.hosts {
+		if h.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// downAfterFailures is the number of consecutive failed refreshes after
+// which a host is considered down.
+const downAfterFailures = 3
+
+// update records the result of a refresh of the host.
+func (h *hostState) update(msg StatsMsg) {
+	h.fetching = false
+	h.err = msg.Err
+	if msg.Err != nil {
+		h.failures++
+		return
+	}
+	h.failures = 0
+	if h.history != nil {
+		h.history.add(msg.Stats)
+	}
+	h.lastSeen = time.Now()
+	h.stats = msg.Stats
+}
+
+// down reports whether the host failed to refresh several times in a row.
+func (h *hostState) down() bool {
+	return h.failures >= downAfterFailures
+}
+
+// backoff is implemented by the errors of sources backing off from a host
+// failing repeatedly, such as *client.BackoffError.
+type backoff interface {
+	RetryAt() time.Time
+}
+
+// BackingOff reports whether err says that collecting from a host is backed
+// off, and until when.
+func BackingOff(err error) (time.Time, bool) {
+	var b backoff
+	if !errors.As(err, &b) {
+		return time.Time{}, false
+	}
+	return b.RetryAt(), true
+}
+
+// degraded reports whether collecting from the host is backed off.
+func (h *hostState) degraded() bool {
+	_, ok := BackingOff(h.err)
+	return ok
+}
+
+// downSince describes since when the host is down.
+func (h *hostState) downSince() string {
+	if h.lastSeen.IsZero() {
+		return "never seen"
+	}
+	return fmt.Sprintf("last seen %s ago", time.Since(h.lastSeen).Round(time.Second))
+}
@@ -0,0 +1,370 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Layout controls how the stats of a host are arranged on the screen.
+type Layout int
+
+const (
+	// LayoutNormal renders one section below the other.
+	LayoutNormal Layout = iota
+	// LayoutCompact squeezes the stats into a few lines for small panes.
+	LayoutCompact
+	// LayoutWide spreads the sections over multiple columns on large
+	// terminals.
+	LayoutWide
+)
+
+// wideColumnWidth is the minimum width of a column in the wide layout.
+const wideColumnWidth = 64
+
+// smallWidth and smallHeight are the terminal sizes below which the normal
+// and wide layouts do not fit and the compact layout is used instead.
+const (
+	smallWidth  = 60
+	smallHeight = 20
+)
+
+// shedOrder lists the sections of the compact layout in the order they are
+// left out when even it does not fit, least important first.
+var shedOrder = []string{"extra", "budgets", "routes", "sensors", "gpus", "cores", "io", "network", "raid", "zfs", "nfs", "filesystems", "memory", "cpu"}
+
+var layoutNames = []string{"normal", "compact", "wide"}
+
+// ParseLayout parses the given layout name: compact, normal or wide.
+func ParseLayout(name string) (Layout, error) {
+	for i, n := range layoutNames {
+		if n == name {
+			return Layout(i), nil
+		}
+	}
+	return LayoutNormal, fmt.Errorf("unknown layout %q, expected one of %s", name, strings.Join(layoutNames, ", "))
+}
+
+func (l Layout) String() string {
+	return layoutNames[l]
+}
+
+func (l Layout) next() Layout {
+	return (l + 1) % Layout(len(layoutNames))
+}
+
+// renderCompact renders the stats in roughly one line per section.
+func (r Rendering) renderCompact(b *bytes.Buffer, stats types.Stats) {
+	w, h := r.styles.Value, r.styles.Heading
+
+	var alerts string
+	if sum := stats.ProcessSummary; sum != nil && sum.Zombies > 0 {
+		alerts = "  " + r.critical(fmt.Sprintf("%d zombies", sum.Zombies))
+	}
+	if t := stats.Tasks; t != nil && math.Max(t.PIDUsage(), t.ThreadUsage()) >= 80 {
+		pct := math.Max(t.PIDUsage(), t.ThreadUsage())
+		alerts += "  " + r.mark(usageLevel(pct), "tasks "+r.locale.float(pct, 1)+"% of limit")
+	}
+	fmt.Fprintf(b, "%s up %s  load %s %s %s  procs %s/%s%s\n",
+		r.hostnameStyle(stats).Render(stats.Hostname),
+		w.Render(FormatUptime(stats.Uptime, r.uptime)),
+		w.Render(r.locale.decimal(stats.Loads.Load1)),
+		w.Render(r.locale.decimal(stats.Loads.Load5)),
+		w.Render(r.locale.decimal(stats.Loads.Load15)),
+		w.Render(stats.Loads.RunningProcs),
+		w.Render(stats.Loads.TotalProcs),
+		alerts,
+	)
+
+	if !r.hidden["cpu"] {
+		fmt.Fprintf(b, "%s  %s us %s sy %s ni %s id %s wa\n",
+			h.Render("cpu"),
+			w.Render(r.locale.float(float64(stats.CPU.User), 2)),
+			w.Render(r.locale.float(float64(stats.CPU.System), 2)),
+			w.Render(r.locale.float(float64(stats.CPU.Nice), 2)),
+			w.Render(r.locale.float(float64(stats.CPU.Idle), 2)),
+			w.Render(r.locale.float(float64(stats.CPU.IOWait), 2)),
+		)
+	}
+
+	if !r.hidden["cores"] && len(stats.Cores) > 0 {
+		b.WriteString(h.Render("core"))
+		for _, core := range stats.Cores {
+			fmt.Fprintf(b, " %s", w.Render(r.locale.float(float64(100-core.Idle), 0)))
+		}
+		b.WriteString("\n")
+	}
+
+	if !r.hidden["memory"] {
+		fmt.Fprintf(b, "%s  %s used of %s, %s free, swap %s free of %s, si %s so %s\n",
+			h.Render("mem"),
+			w.Render(strings.TrimSpace(r.locale.bytes(stats.MEM.Used()))),
+			w.Render(strings.TrimSpace(r.locale.bytes(stats.MEM.Total))),
+			w.Render(strings.TrimSpace(r.locale.bytes(stats.MEM.Free))),
+			w.Render(strings.TrimSpace(r.locale.bytes(stats.MEM.SwapFree))),
+			w.Render(strings.TrimSpace(r.locale.bytes(stats.MEM.SwapTotal))),
+			w.Render(r.locale.float(stats.SwapActivity.InRate, 1)),
+			w.Render(r.locale.float(stats.SwapActivity.OutRate, 1)),
+		)
+	}
+
+	if !r.hidden["sensors"] && len(stats.Sensors) > 0 {
+		b.WriteString(h.Render("temp"))
+		for _, sensor := range stats.Sensors {
+			fmt.Fprintf(b, " %s %s", sensor.Name, r.mark(r.tempLevel(sensor.Temp), r.locale.float(sensor.Temp, 0)))
+		}
+		b.WriteString("\n")
+	}
+
+	if !r.hidden["gpus"] && len(stats.GPUs) > 0 {
+		b.WriteString(h.Render("gpu") + " ")
+		for _, gpu := range stats.GPUs {
+			fmt.Fprintf(b, " %d %s mem %s %s",
+				gpu.Index,
+				w.Render(r.locale.float(gpu.Utilization, 0)+"%"),
+				r.mark(usageLevel(gpu.MemoryUsage()), r.locale.float(gpu.MemoryUsage(), 0)+"%"),
+				r.mark(r.tempLevel(gpu.Temp), r.locale.float(gpu.Temp, 0)),
+			)
+		}
+		b.WriteString("\n")
+	}
+
+	if !r.hidden["raid"] && len(stats.RAID) > 0 {
+		b.WriteString(h.Render("raid") + " ")
+		for _, md := range stats.RAID {
+			status := w.Render(fmt.Sprintf("%d/%d", md.Active, md.Total))
+			if md.Degraded() {
+				status = r.critical(fmt.Sprintf("%d/%d", md.Active, md.Total))
+			}
+			fmt.Fprintf(b, " %s %s", md.Name, status)
+			switch {
+			case md.SyncProgress > 0:
+				fmt.Fprintf(b, " %s", r.warning(md.SyncAction+" "+r.locale.float(md.SyncProgress, 0)+"%"))
+			case md.SyncAction != "":
+				fmt.Fprintf(b, " %s", r.warning(md.SyncAction))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if !r.hidden["zfs"] && len(stats.ZFS) > 0 {
+		b.WriteString(h.Render("zfs") + " ")
+		for _, pool := range stats.ZFS {
+			fmt.Fprintf(b, " %s %s %s",
+				pool.Name,
+				r.zpoolHealth(pool),
+				r.mark(usageLevel(pool.Capacity), r.locale.float(pool.Capacity, 0)+"%"),
+			)
+		}
+		b.WriteString("\n")
+	}
+
+	if nfs := stats.NFS; !r.hidden["nfs"] && nfs != nil && len(nfs.Mounts) > 0 {
+		b.WriteString(h.Render("nfs") + " ")
+		for _, m := range nfs.Mounts {
+			fmt.Fprintf(b, " %s %s ops/s %s %s",
+				m.MountPoint,
+				w.Render(r.locale.float(m.OpRate, 0)),
+				w.Render(fmtLatency(m.AvgRTT)),
+				r.retransRate(m.RetransRate),
+			)
+		}
+		b.WriteString("\n")
+	}
+
+	if !r.hidden["filesystems"] {
+		prefix := h.Render("fs") + "   "
+		for _, fs := range sortFS(stats.FSInfos, r.fsSort) {
+			fmt.Fprintf(b, "%s%s %s %s free of %s%s\n",
+				prefix,
+				w.Render(r.fsLabel(fs)),
+				r.mark(usageLevel(fs.Usage()), r.locale.float(fs.Usage(), 0)+"%"),
+				w.Render(strings.TrimSpace(r.locale.bytes(fs.Free))),
+				w.Render(strings.TrimSpace(r.locale.bytes(fs.Total))),
+				r.fmtInodes(fs),
+			)
+			prefix = "     "
+		}
+		for _, l := range stats.FSLatency {
+			fmt.Fprintf(b, "%s%s %s\n", prefix, w.Render(l.MountPoint), r.fmtFSLatency(l))
+			prefix = "     "
+		}
+	}
+
+	if !r.hidden["io"] {
+		prefix := h.Render("io") + "   "
+		for _, io := range stats.DiskIO {
+			fmt.Fprintf(b, "%s%s r %s/s w %s/s\n",
+				prefix,
+				w.Render(io.Device),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(io.ReadRate)))),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(io.WriteRate)))),
+			)
+			prefix = "     "
+		}
+	}
+
+	if !r.hidden["network"] {
+		prefix := h.Render("net") + "  "
+		for _, key := range sortedInterfaces(stats) {
+			info := stats.NetInterface[key]
+			fmt.Fprintf(b, "%s%s %s rx %s/s tx %s/s\n",
+				prefix,
+				w.Render(key),
+				w.Render(info.IPv4),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(info.RxRate)))),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(info.TxRate)))),
+			)
+			prefix = "     "
+		}
+	}
+
+	if !r.hidden["routes"] && stats.Routes != nil {
+		b.WriteString(h.Render("gw") + "  ")
+		if len(stats.Routes.Gateways) == 0 {
+			fmt.Fprintf(b, " %s", r.critical("none"))
+		}
+		for _, gw := range stats.Routes.Gateways {
+			fmt.Fprintf(b, " %s %s", w.Render(gw.Address), r.fmtGateway(gw))
+		}
+		fmt.Fprintf(b, "  routes %s\n", w.Render(strconv.Itoa(stats.Routes.Count)))
+	}
+
+	if !r.hidden["budgets"] {
+		prefix := h.Render("bud") + "  "
+		for _, u := range stats.Budgets {
+			fmt.Fprintf(b, "%s%s %s\n", prefix, w.Render(budgetName(u)), r.fmtBudget(u))
+			prefix = "     "
+		}
+	}
+
+	if !r.hidden["extra"] {
+		prefix := h.Render("ext") + "  "
+		for _, key := range sortedExtra(stats) {
+			fmt.Fprintf(b, "%s%s %s\n",
+				prefix,
+				key,
+				w.Render(r.locale.value(stats.ExtraValue(key))),
+			)
+			prefix = "     "
+		}
+	}
+}
+
+// tooSmall reports whether the viewport is too small for the chosen layout,
+// so that scrolling would be needed to see most of the stats.
+func (r Rendering) tooSmall() bool {
+	return r.layout != LayoutCompact && (r.viewport.Width < smallWidth || r.viewport.Height < smallHeight)
+}
+
+// renderSmall renders the compact layout into at most height lines, leaving
+// out sections in shedOrder until it fits. The header line is always kept.
+// It returns the sections left out.
+func (r Rendering) renderSmall(b *bytes.Buffer, stats types.Stats, height int) []string {
+	hidden := make(map[string]bool, len(r.hidden)+len(shedOrder))
+	for name, v := range r.hidden {
+		hidden[name] = v
+	}
+	r.hidden = hidden
+
+	fits := func() bool {
+		var c bytes.Buffer
+		r.renderCompact(&c, stats)
+		return strings.Count(c.String(), "\n") <= height
+	}
+
+	var dropped []string
+	for _, name := range shedOrder {
+		if fits() {
+			break
+		}
+		if !hidden[name] {
+			hidden[name] = true
+			dropped = append(dropped, name)
+		}
+	}
+
+	// a large section may have made room for smaller, less important ones
+	var shed []string
+	for i := len(dropped) - 1; i >= 0; i-- {
+		name := dropped[i]
+		delete(hidden, name)
+		if !fits() {
+			hidden[name] = true
+			shed = append([]string{name}, shed...)
+		}
+	}
+
+	r.renderCompact(b, stats)
+	return shed
+}
+
+// renderWide renders the header across the full width and distributes the
+// remaining sections over as many columns as fit into the given width.
+func (r Rendering) renderWide(h *hostState, width int) string {
+	secs := r.sections(h)
+	header, rest := secs[0], secs[1:]
+
+	n := width / wideColumnWidth
+	if n > len(rest) {
+		n = len(rest)
+	}
+	if n <= 1 {
+		return strings.Join(secs, "")
+	}
+
+	var total int
+	for _, s := range rest {
+		total += strings.Count(s, "\n")
+	}
+
+	// fill the columns in order, moving on to the next column once the
+	// current one holds its share of the lines.
+	columns := make([]string, n)
+	var col, lines int
+	for _, s := range rest {
+		if col < n-1 && lines > 0 && lines+strings.Count(s, "\n") > (total+n-1)/n {
+			col++
+			lines = 0
+		}
+		columns[col] += s
+		lines += strings.Count(s, "\n")
+	}
+
+	style := lipgloss.NewStyle().Width(width / n)
+	for i := range columns {
+		columns[i] = style.Render(columns[i])
+	}
+
+	return header + lipgloss.JoinHorizontal(lipgloss.Top, columns...)
+}
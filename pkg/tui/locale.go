@@ -0,0 +1,153 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// Locale controls how numbers are formatted and what the section headings
+// are called. Headings without a translation are left in English.
+type Locale struct {
+	Name    string
+	Decimal string
+	Labels  map[string]string
+}
+
+var locales = map[string]Locale{
+	"en": {Name: "en", Decimal: "."},
+	"de": {Name: "de", Decimal: ",", Labels: map[string]string{
+		"Load":               "Last",
+		"Cores":              "Kerne",
+		"Processes":          "Prozesse",
+		"Memory":             "Speicher",
+		"Temperatures":       "Temperaturen",
+		"Filesystems":        "Dateisysteme",
+		"I/O":                "E/A",
+		"Network Interfaces": "Netzwerkschnittstellen",
+		"Routes":             "Routen",
+		"Data Budgets":       "Datenkontingente",
+		"Alerts":             "Alarme",
+	}},
+	"es": {Name: "es", Decimal: ",", Labels: map[string]string{
+		"Load":               "Carga",
+		"Cores":              "Núcleos",
+		"Processes":          "Procesos",
+		"Memory":             "Memoria",
+		"Temperatures":       "Temperaturas",
+		"Filesystems":        "Sistemas de archivos",
+		"I/O":                "E/S",
+		"Network Interfaces": "Interfaces de red",
+		"Routes":             "Rutas",
+		"Data Budgets":       "Cuotas de datos",
+		"Alerts":             "Alertas",
+	}},
+	"fr": {Name: "fr", Decimal: ",", Labels: map[string]string{
+		"Load":               "Charge",
+		"Cores":              "Cœurs",
+		"Processes":          "Processus",
+		"Memory":             "Mémoire",
+		"Temperatures":       "Températures",
+		"Filesystems":        "Systèmes de fichiers",
+		"I/O":                "E/S",
+		"Network Interfaces": "Interfaces réseau",
+		"Routes":             "Routes",
+		"Data Budgets":       "Forfaits de données",
+		"Alerts":             "Alertes",
+	}},
+}
+
+// ParseLocale returns the locale with the given name. Only the language part
+// of names like "de_DE.UTF-8" is used, "C" and "POSIX" mean English.
+func ParseLocale(name string) (Locale, error) {
+	lang := strings.ToLower(name)
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" || lang == "c" || lang == "posix" {
+		lang = "en"
+	}
+	if l, ok := locales[lang]; ok {
+		return l, nil
+	}
+	names := make([]string, 0, len(locales))
+	for n := range locales {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Locale{}, fmt.Errorf("unknown locale %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// label returns the translation of a section heading.
+func (l Locale) label(s string) string {
+	if t, ok := l.Labels[s]; ok {
+		return t
+	}
+	return s
+}
+
+// heading returns the translation of a section heading in the Heading style.
+func (r Rendering) heading(s string) string {
+	return r.styles.Heading.Render(r.locale.label(s))
+}
+
+// float formats val with prec digits after the decimal separator.
+func (l Locale) float(val float64, prec int) string {
+	return l.decimal(strconv.FormatFloat(val, 'f', prec, 64))
+}
+
+// bytes is fmtBytes with the locale's decimal separator.
+func (l Locale) bytes(val uint64) string {
+	return l.decimal(fmtBytes(val))
+}
+
+// value formats a metric value according to its unit, e.g. sizes in bytes
+// as fmtBytes does.
+func (l Locale) value(v types.Value) string {
+	switch v.Unit {
+	case types.UnitBytes:
+		return strings.TrimSpace(l.bytes(uint64(v.Amount)))
+	case types.UnitBytesPerSecond:
+		return strings.TrimSpace(l.bytes(uint64(v.Amount))) + "/s"
+	case types.UnitFlag:
+		return strconv.FormatFloat(v.Amount, 'f', 0, 64)
+	case types.UnitNone, types.UnitPages, types.UnitTicks:
+		return l.float(v.Amount, 2)
+	}
+	return l.float(v.Amount, 2) + v.Unit.Suffix()
+}
+
+func (l Locale) decimal(s string) string {
+	if l.Decimal == "" || l.Decimal == "." {
+		return s
+	}
+	return strings.Replace(s, ".", l.Decimal, 1)
+}
@@ -0,0 +1,144 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+type Option func(r *Rendering)
+
+// WithLayout sets the initial layout, which can be toggled at runtime.
+func WithLayout(layout Layout) Option {
+	return func(r *Rendering) {
+		r.layout = layout
+	}
+}
+
+// WithFSByDevice labels filesystems by their device rather than their mount
+// point, listing all mount points of the device.
+func WithFSByDevice(byDevice bool) Option {
+	return func(r *Rendering) {
+		r.fsByDevice = byDevice
+	}
+}
+
+// WithFSSort sets the initial order of the filesystems, which can be
+// changed at runtime.
+func WithFSSort(by FSSort) Option {
+	return func(r *Rendering) {
+		r.fsSort = by
+	}
+}
+
+// WithSplitView starts with all hosts shown side by side instead of one tab
+// per host.
+func WithSplitView(split bool) Option {
+	return func(r *Rendering) {
+		r.split = split
+	}
+}
+
+// WithLocale formats numbers and section headings for the given locale.
+func WithLocale(l Locale) Option {
+	return func(r *Rendering) {
+		r.locale = l
+	}
+}
+
+// WithUptimeFormat sets how the uptime in the header is written.
+func WithUptimeFormat(f UptimeFormat) Option {
+	return func(r *Rendering) {
+		r.uptime = f
+	}
+}
+
+// WithStyles replaces the default styles, see DefaultStyles.
+func WithStyles(s Styles) Option {
+	return func(r *Rendering) {
+		r.styles = s
+	}
+}
+
+// WithQuitKeys sets whether q, esc and ctrl+c quit the program. Programs
+// embedding a Rendering usually handle these keys themselves.
+func WithQuitKeys(enabled bool) Option {
+	return func(r *Rendering) {
+		r.quitKeys = enabled
+	}
+}
+
+// WithTempThresholds sets the temperatures in degrees Celsius above which
+// sensors are marked as warning and critical.
+func WithTempThresholds(warn, crit float64) Option {
+	return func(r *Rendering) {
+		r.tempWarn = warn
+		r.tempCrit = crit
+	}
+}
+
+// WithHistorySize sets how many samples the sparklines show, 0 to hide
+// them. It defaults to DefaultHistorySize.
+func WithHistorySize(n int) Option {
+	return func(r *Rendering) {
+		r.historySize = n
+	}
+}
+
+// WithPlayer shows a timeline of the playback of a recorded session, whose
+// samples were taken interval apart, with keys to pause, change the speed
+// and move to another time.
+func WithPlayer(p Player, interval time.Duration) Option {
+	return func(r *Rendering) {
+		r.player = p
+		r.replayInterval = interval
+	}
+}
+
+// WithVersion sets the version of the program shown in the help overlay.
+func WithVersion(v string) Option {
+	return func(r *Rendering) {
+		r.version = v
+	}
+}
+
+// WithStatus adds what status returns, unless empty, to the status bar,
+// such as the state of the sinks the stats are written to.
+func WithStatus(status func() string) Option {
+	return func(r *Rendering) {
+		r.status = status
+	}
+}
+
+// WithCollectorsSave lets the collectors overlay save the collectors turned
+// on and off, e.g. to a configuration file.
+func WithCollectorsSave(save func([]types.CollectorState) error) Option {
+	return func(r *Rendering) {
+		r.saveCollectors = save
+	}
+}
@@ -0,0 +1,237 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// RenderPlain writes the stats of the named host without any colors or
+// styling, one explicitly labeled value per line in a fixed order, for
+// screen readers and logs.
+func RenderPlain(w io.Writer, name string, stats types.Stats, err error) {
+	line := func(label string, format string, args ...interface{}) {
+		fmt.Fprintf(w, "%s: %s\n", label, fmt.Sprintf(format, args...))
+	}
+	size := func(val uint64) string {
+		return strings.TrimSpace(fmtBytes(val))
+	}
+
+	line("host", "%s", name)
+	line("time", "%s", time.Now().Format(time.RFC3339))
+	if err != nil {
+		line("error", "%s", err)
+	}
+	if stats.Hostname == "" {
+		fmt.Fprintln(w)
+		return
+	}
+
+	line("hostname", "%s", stats.Hostname)
+	line("uptime", "%s", FormatUptime(stats.Uptime, UptimeShort))
+	for _, k := range sortedLabels(stats.Labels) {
+		line("label "+k, "%s", stats.Labels[k])
+	}
+	if len(stats.Meta.Disabled) > 0 {
+		line("collectors disabled", "%s", fmtDisabled(stats.Meta.Disabled))
+	}
+	if stats.Systemd != nil {
+		line("systemd state", "%s", stats.Systemd.State)
+		line("systemd failed units", "%d", len(stats.Systemd.Failed))
+		for _, unit := range stats.Systemd.Failed {
+			line("systemd failed unit", "%s", unit)
+		}
+	}
+
+	line("load average 1 minute", "%s", stats.Loads.Load1)
+	line("load average 5 minutes", "%s", stats.Loads.Load5)
+	line("load average 15 minutes", "%s", stats.Loads.Load15)
+
+	line("cpu user", "%.2f percent", stats.CPU.User)
+	line("cpu system", "%.2f percent", stats.CPU.System)
+	line("cpu nice", "%.2f percent", stats.CPU.Nice)
+	line("cpu idle", "%.2f percent", stats.CPU.Idle)
+	line("cpu iowait", "%.2f percent", stats.CPU.IOWait)
+	line("cpu hardware interrupts", "%.2f percent", stats.CPU.IRQ)
+	line("cpu software interrupts", "%.2f percent", stats.CPU.SoftIRQ)
+	line("cpu steal", "%.2f percent", stats.CPU.Steal)
+	line("cpu guest", "%.2f percent", stats.CPU.Guest)
+	for _, core := range stats.Cores {
+		line(core.Core+" busy", "%.2f percent", 100-core.Idle)
+	}
+
+	line("processes running", "%s", stats.Loads.RunningProcs)
+	line("processes total", "%s", stats.Loads.TotalProcs)
+	if sum := stats.ProcessSummary; sum != nil {
+		line("processes zombie", "%d", sum.Zombies)
+		users := make([]string, 0, len(sum.ByUser))
+		for user := range sum.ByUser {
+			users = append(users, user)
+		}
+		sort.Strings(users)
+		for _, user := range users {
+			line("processes of user "+user, "%d", sum.ByUser[user])
+		}
+	}
+	if t := stats.Tasks; t != nil {
+		line("threads total", "%d", t.Threads)
+		line("pid usage", "%.1f percent of %d", t.PIDUsage(), t.PIDMax)
+		line("thread usage", "%.1f percent of %d", t.ThreadUsage(), t.ThreadsMax)
+	}
+	for _, p := range stats.Processes {
+		line(fmt.Sprintf("process %d %s", p.PID, p.Command), "user %s, %.1f percent cpu, %.1f percent memory, %s resident",
+			p.User, p.CPU, p.Mem, size(p.RSS))
+	}
+
+	line("memory total", "%s", size(stats.MEM.Total))
+	line("memory free", "%s", size(stats.MEM.Free))
+	line("memory used", "%s", size(stats.MEM.Used()))
+	line("memory buffers", "%s", size(stats.MEM.Buffers))
+	line("memory cached", "%s", size(stats.MEM.Cached))
+	line("swap total", "%s", size(stats.MEM.SwapTotal))
+	line("swap free", "%s", size(stats.MEM.SwapFree))
+	line("swap in", "%.1f pages per second", stats.SwapActivity.InRate)
+	line("swap out", "%.1f pages per second", stats.SwapActivity.OutRate)
+
+	for _, sensor := range stats.Sensors {
+		line("temperature "+sensor.Name, "%.1f degrees celsius", sensor.Temp)
+	}
+
+	for _, gpu := range stats.GPUs {
+		p := fmt.Sprintf("gpu %d", gpu.Index)
+		line(p+" name", "%s", gpu.Name)
+		line(p+" utilization", "%.0f percent", gpu.Utilization)
+		line(p+" memory", "%s used of %s", size(gpu.MemoryUsed), size(gpu.MemoryTotal))
+		line(p+" temperature", "%.0f degrees celsius", gpu.Temp)
+	}
+
+	for _, md := range stats.RAID {
+		p := "raid " + md.Name
+		state := md.State
+		if md.Degraded() {
+			state += ", degraded"
+		}
+		line(p, "%s, %d of %d devices working", strings.TrimSpace(md.Level+" "+state), md.Active, md.Total)
+		if len(md.Failed) > 0 {
+			line(p+" failed devices", "%s", strings.Join(md.Failed, " "))
+		}
+		switch {
+		case md.SyncProgress > 0:
+			line(p+" "+md.SyncAction, "%.1f percent done, %s left", md.SyncProgress, md.SyncFinish.Truncate(time.Minute))
+		case md.SyncAction != "":
+			line(p+" sync", "%s", md.SyncAction)
+		}
+	}
+
+	for _, pool := range stats.ZFS {
+		p := "zfs pool " + pool.Name
+		line(p, "%s, %s used of %s, %.0f percent full, %.0f percent fragmented",
+			pool.Health, size(pool.Alloc), size(pool.Size), pool.Capacity, pool.Fragmentation)
+		if pool.Status != "" {
+			line(p+" status", "%s", pool.Status)
+		}
+	}
+
+	if nfs := stats.NFS; nfs != nil {
+		if nfs.Client != nil {
+			line("nfs client", "%.1f calls per second, %.1f retransmissions per second", nfs.Client.CallRate, nfs.Client.RetransRate)
+		}
+		if nfs.Server != nil {
+			line("nfs server", "%.1f calls per second, %.1f bad calls per second", nfs.Server.CallRate, nfs.Server.BadCallRate)
+		}
+		for _, m := range nfs.Mounts {
+			line("nfs mount "+m.MountPoint, "%s %s, %.1f operations per second, read %s per second, write %s per second, round trip %s, %.1f retransmissions per second",
+				m.FSType, m.Export, m.OpRate, size(uint64(m.ReadRate)), size(uint64(m.WriteRate)), fmtLatency(m.AvgRTT), m.RetransRate)
+		}
+	}
+
+	for _, fs := range stats.FSInfos {
+		line("filesystem "+fs.MountPoint, "%s free of %s", size(fs.Free), size(fs.Total))
+		if fs.InodesTotal > 0 {
+			line("filesystem "+fs.MountPoint+" inodes", "%.1f percent used, %d free of %d", fs.InodeUsage(), fs.InodesFree, fs.InodesTotal)
+		}
+	}
+	for _, l := range stats.FSLatency {
+		if l.Failed {
+			line("filesystem "+l.MountPoint+" latency", "probe write failed")
+		} else {
+			line("filesystem "+l.MountPoint+" latency", "write %s, read %s", fmtLatency(l.Write), fmtLatency(l.Read))
+		}
+	}
+
+	for _, io := range stats.DiskIO {
+		line("disk "+io.Device+" reads", "%s per second, %.1f operations per second", size(uint64(io.ReadRate)), io.ReadIOPS)
+		line("disk "+io.Device+" writes", "%s per second, %.1f operations per second", size(uint64(io.WriteRate)), io.WriteIOPS)
+	}
+
+	for _, key := range sortedInterfaces(stats) {
+		info := stats.NetInterface[key]
+		if info.IPv4 != "" {
+			line("interface "+key+" ipv4 address", "%s", info.IPv4)
+		}
+		if info.IPv6 != "" {
+			line("interface "+key+" ipv6 address", "%s", info.IPv6)
+		}
+		line("interface "+key+" received", "%s, %s per second", size(info.Rx), size(uint64(info.RxRate)))
+		line("interface "+key+" transmitted", "%s, %s per second", size(info.Tx), size(uint64(info.TxRate)))
+	}
+
+	if stats.Routes != nil {
+		line("routes", "%d", stats.Routes.Count)
+		if len(stats.Routes.Gateways) == 0 {
+			line("default gateway", "none")
+		}
+		for _, gw := range stats.Routes.Gateways {
+			if !gw.Pinged {
+				line("default gateway "+gw.Address, "via %s, not checked", gw.Interface)
+			} else if gw.Reachable {
+				line("default gateway "+gw.Address, "reachable via %s in %s", gw.Interface, fmtLatency(gw.RTT))
+			} else {
+				line("default gateway "+gw.Address, "unreachable via %s", gw.Interface)
+			}
+		}
+	}
+
+	for _, u := range stats.Budgets {
+		state := "ok"
+		if u.Exceeded {
+			state = "exceeded"
+		}
+		line("budget "+budgetName(u), "%s of %s, %s", size(u.Used), size(u.Limit), state)
+	}
+
+	for _, key := range sortedExtra(stats) {
+		line(key, "%s", locales["en"].value(stats.ExtraValue(key)))
+	}
+
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,122 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// ProcessDetails returns the details of a process of a host, as
+// client.Client does.
+type ProcessDetails interface {
+	GetProcessDetail(ctx context.Context, pid int) (types.ProcessDetail, error)
+}
+
+// processDetailMsg carries the details of a process, fetched when selected.
+type processDetailMsg struct {
+	ID     int
+	Host   int
+	PID    int
+	Detail types.ProcessDetail
+	Err    error
+}
+
+// moveProcCursor moves the selection of the process table by delta rows,
+// within the processes of the current host.
+func (r *Rendering) moveProcCursor(delta int) {
+	n := len(r.hosts[r.current].stats.Processes)
+	r.procCursor += delta
+	if r.procCursor >= n {
+		r.procCursor = n - 1
+	}
+	if r.procCursor < 0 {
+		r.procCursor = 0
+	}
+	r.setContent()
+}
+
+// showProcessDetail opens the process overlay for the selected process of
+// the current host, fetching its details.
+func (r *Rendering) showProcessDetail() tea.Cmd {
+	h := r.hosts[r.current]
+	procs := r.sortedProcesses(h.stats.Processes)
+	if h.processDetails == nil || r.procCursor >= len(procs) {
+		return nil
+	}
+	p := procs[r.procCursor]
+	r.view = viewProcess
+	r.procDetail, r.procDetailErr = types.ProcessDetail{PID: p.PID}, nil
+	r.procDetailRead = false
+	id, key, details := r.id, h.key, h.processDetails
+	return func() tea.Msg {
+		detail, err := details.GetProcessDetail(context.Background(), p.PID)
+		return processDetailMsg{ID: id, Host: key, PID: p.PID, Detail: detail, Err: err}
+	}
+}
+
+// processView renders the details of the selected process, centered over
+// the area of the viewport.
+func (r Rendering) processView() string {
+	d := r.procDetail
+
+	var b strings.Builder
+	b.WriteString(r.styles.Value.Render(fmt.Sprintf("Process %d on %s", d.PID, r.hosts[r.current].name)) + "\n\n")
+	switch {
+	case r.procDetailErr != nil:
+		b.WriteString(r.critical(r.procDetailErr.Error()))
+	case !r.procDetailRead:
+		b.WriteString("reading...")
+	default:
+		field := func(name, value string) {
+			if value == "" {
+				value = "-"
+			}
+			fmt.Fprintf(&b, "%-8s %s\n", name, value)
+		}
+		field("cmdline", d.Cmdline)
+		field("cwd", d.Cwd)
+		field("fds", fmt.Sprint(d.OpenFDs))
+		field("threads", fmt.Sprint(d.Threads))
+		if len(d.Cgroups) == 0 {
+			field("cgroup", "")
+		}
+		for _, cg := range d.Cgroups {
+			field("cgroup", cg)
+		}
+	}
+	b.WriteString("\npress any key to close")
+
+	box := r.styles.CurrentPane.Render(strings.TrimRight(b.String(), "\n"))
+	w, height := r.viewport.Width, r.viewport.Height
+	return lipgloss.NewStyle().MaxWidth(w).MaxHeight(height).Render(
+		lipgloss.Place(w, height, lipgloss.Center, lipgloss.Center, box))
+}
@@ -0,0 +1,221 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rapidloop/rtop/pkg/types"
+)
+
+// ProcessSort is the column the process table is ordered by.
+type ProcessSort int
+
+const (
+	SortByCPU ProcessSort = iota
+	SortByMem
+	SortByPID
+	SortByCommand
+)
+
+var processSortNames = []string{"cpu", "mem", "pid", "command"}
+
+// ParseProcessSort returns the process table order with the given name.
+func ParseProcessSort(name string) (ProcessSort, error) {
+	for i, n := range processSortNames {
+		if n == name {
+			return ProcessSort(i), nil
+		}
+	}
+	return SortByCPU, fmt.Errorf("unknown process order %q, expected one of %s", name, strings.Join(processSortNames, ", "))
+}
+
+func (s ProcessSort) String() string {
+	return processSortNames[s]
+}
+
+func (s ProcessSort) next() ProcessSort {
+	return (s + 1) % ProcessSort(len(processSortNames))
+}
+
+// maxCommandWidth is the number of characters of a command shown in the
+// process table.
+const maxCommandWidth = 40
+
+// procColumn is a column of the process table, padded to width unless it is
+// the last one.
+type procColumn struct {
+	title string
+	width int
+	left  bool
+}
+
+var procColumns = []procColumn{
+	{"PID", 7, false},
+	{"USER", 10, true},
+	{"CPU%", 6, false},
+	{"MEM%", 6, false},
+	{"RSS", 10, false},
+	{"S", 4, true},
+	{"COMMAND", 0, true},
+}
+
+// sortedProcesses returns the processes ordered by r.procSort, without
+// changing the order of the given slice.
+func (r Rendering) sortedProcesses(procs []types.Process) []types.Process {
+	sorted := append([]types.Process(nil), procs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch r.procSort {
+		case SortByMem:
+			return a.Mem > b.Mem
+		case SortByPID:
+			return a.PID < b.PID
+		case SortByCommand:
+			return a.Command < b.Command
+		}
+		return a.CPU > b.CPU
+	})
+	return sorted
+}
+
+// processTable renders the processes as a table ordered by r.procSort, with
+// the row of r.procCursor marked if their details can be shown. The PID
+// column stays in place while the others are scrolled horizontally by
+// r.procOffset columns.
+func (r Rendering) processTable(procs []types.Process) string {
+	sorted := r.sortedProcesses(procs)
+	cursor := -1
+	if r.hosts[r.current].processDetails != nil {
+		cursor = r.procCursor
+	}
+
+	titles := make([]string, len(procColumns))
+	for i, col := range procColumns {
+		titles[i] = col.title
+	}
+	rows := [][]string{titles}
+	for _, p := range sorted {
+		cmd := p.Command
+		if len(cmd) > maxCommandWidth {
+			cmd = cmd[:maxCommandWidth]
+		}
+		user := p.User
+		if len(user) > 10 {
+			user = user[:10]
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(p.PID),
+			user,
+			r.locale.float(p.CPU, 1),
+			r.locale.float(p.Mem, 1),
+			strings.TrimSpace(r.locale.bytes(p.RSS)),
+			p.State,
+			r.styles.Value.Render(cmd),
+		})
+	}
+
+	offset := r.procOffset
+	if max := len(procColumns) - 2; offset > max {
+		offset = max
+	}
+	hint := fmt.Sprintf("(o: order by %s", r.procSort.next())
+	if cursor >= 0 {
+		hint += ", j/k/enter: details"
+	}
+	if offset > 0 || 4+tableWidth(procColumns)+maxCommandWidth > r.viewport.Width {
+		hint += ", left/right: scroll"
+	}
+	hint += ")"
+
+	var b bytes.Buffer
+	for i, row := range rows {
+		if i == cursor+1 {
+			b.WriteString("  > ")
+		} else {
+			b.WriteString("    ")
+		}
+		for c, col := range procColumns {
+			if c > 0 && c <= offset {
+				continue
+			}
+			cell := row[c]
+			switch {
+			case col.width == 0:
+			case col.left:
+				cell = fmt.Sprintf("%-*s", col.width, cell)
+			default:
+				cell = fmt.Sprintf("%*s", col.width, cell)
+			}
+			if c > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(cell)
+		}
+		if i == 0 {
+			b.WriteString("   " + hint)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// tableWidth is the width of the padded columns with a space between each.
+func tableWidth(cols []procColumn) int {
+	var w int
+	for _, col := range cols {
+		w += col.width + 1
+	}
+	return w
+}
+
+// processUsers lists the n users with the most processes.
+func (r Rendering) processUsers(sum *types.ProcessSummary, n int) string {
+	users := make([]string, 0, len(sum.ByUser))
+	for user := range sum.ByUser {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if sum.ByUser[users[i]] != sum.ByUser[users[j]] {
+			return sum.ByUser[users[i]] > sum.ByUser[users[j]]
+		}
+		return users[i] < users[j]
+	})
+
+	parts := make([]string, 0, n+1)
+	for i, user := range users {
+		if i == n {
+			parts = append(parts, fmt.Sprintf("%d more", len(users)-n))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", user, r.styles.Value.Render(strconv.Itoa(sum.ByUser[user]))))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,1069 @@
+/*
+
+rtop-bot - remote system monitoring bot
+
+Copyright (c) 2015 RapidLoop
+Copyright (c) 2022 Furkan Türkal
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rapidloop/rtop/pkg/types"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type getStatsFn func(context.Context) (types.Stats, error)
+
+// TickMsg asks the Rendering with the given ID to refresh its hosts.
+type TickMsg struct {
+	ID   int
+	Time time.Time
+}
+
+// StatsMsg carries the result of a stats refresh of the host with the key
+// Host of the Rendering with the given ID. Hosts are keyed by their index
+// in New, and hosts added later with AddHostMsg by the following numbers.
+type StatsMsg struct {
+	ID    int
+	Host  int
+	Stats types.Stats
+	Err   error
+}
+
+// lastID is the last ID handed out to a Rendering, so that messages of
+// several Renderings embedded into one program can be told apart.
+var lastID int64
+
+// Host is a monitored host, identified by name, whose stats are refreshed
+// using the given function.
+type Host struct {
+	Name     string
+	GetStats getStatsFn
+	// Collectors, if set, lets the collectors be turned on and off with c
+	Collectors Collectors
+	// Banner, if set, is shown with b
+	Banner Banner
+	// ProcessDetails, if set, shows the details of the selected process
+	// with enter
+	ProcessDetails ProcessDetails
+}
+
+type hostState struct {
+	name       string
+	getStatsFn getStatsFn
+	collectors Collectors
+	banner     Banner
+	stats      types.Stats
+	err        error
+	fetching   bool
+	failures   int
+	lastSeen   time.Time
+	// history is nil if sparklines are hidden
+	history *history
+	alerts  []Alert
+	// bannerText is the banner once read, or the error reading it
+	bannerText string
+	bannerErr  error
+	bannerRead bool
+	// processDetails is nil if the details of processes cannot be shown
+	processDetails ProcessDetails
+	// key identifies the host in messages, which may arrive after hosts
+	// before it were removed
+	key int
+}
+
+// Rendering is a bubbletea model showing the stats of one or more hosts. It
+// can be run on its own with NewRenderingState or embedded into another
+// program, which then has to pass it all messages.
+type Rendering struct {
+	id       int
+	hosts    []*hostState
+	nextKey  int
+	current  int
+	interval time.Duration
+	layout   Layout
+	w, h     int
+	ready    bool
+	viewport viewport.Model
+	hidden   map[string]bool
+	split    bool
+	locale   Locale
+	uptime   UptimeFormat
+	styles   Styles
+
+	fsByDevice bool
+	quitKeys   bool
+	procSort   ProcessSort
+	fsSort     FSSort
+	procOffset int
+	tempWarn   float64
+	tempCrit   float64
+
+	historySize int
+
+	// procCursor is the selected row of the process table, whose details
+	// are procDetail, or the error fetching them, once read
+	procCursor     int
+	procDetail     types.ProcessDetail
+	procDetailErr  error
+	procDetailRead bool
+
+	// player is set when replaying a recorded session, whose samples are
+	// replayInterval apart
+	player         Player
+	replayInterval time.Duration
+	jumping        bool
+	jumpInput      string
+	jumpErr        string
+
+	// small is set when the terminal is too small for the layout, and shed
+	// lists the sections left out to fit it
+	small bool
+	shed  []string
+
+	// view is the help overlay or the stats, and version is shown in the
+	// help
+	view    viewState
+	version string
+
+	// paused is set while refreshing is suspended with space
+	paused bool
+
+	// collCursor is the selected line of the collectors overlay, collMsg
+	// the outcome of the last action in it, and saveCollectors persists
+	// the collectors turned on and off
+	collCursor     int
+	collMsg        string
+	saveCollectors func([]types.CollectorState) error
+
+	// status returns more to show in the status bar, if set
+	status func() string
+}
+
+// New returns a Rendering of the given hosts, refreshed at the interval.
+// Its size is set by tea.WindowSizeMsg or, when embedded, with SetSize.
+func New(hosts []Host, interval time.Duration, opts ...Option) Rendering {
+	rendering := Rendering{
+		id:       int(atomic.AddInt64(&lastID, 1)),
+		interval: interval,
+		hidden:   make(map[string]bool),
+		styles:   DefaultStyles(),
+		quitKeys: true,
+		tempWarn: 70,
+		tempCrit: 85,
+
+		historySize: DefaultHistorySize,
+	}
+	for _, opt := range opts {
+		opt(&rendering)
+	}
+	for _, h := range hosts {
+		rendering.addHost(h)
+	}
+	return rendering
+}
+
+// NewRenderingState returns a program running a Rendering of the given
+// hosts on the full terminal.
+func NewRenderingState(hosts []Host, interval time.Duration, opts ...Option) *tea.Program {
+	rendering := New(hosts, interval, opts...)
+	return tea.NewProgram(rendering, tea.WithAltScreen(), tea.WithMouseCellMotion())
+}
+
+// ID identifies the messages meant for this Rendering.
+func (r Rendering) ID() int {
+	return r.id
+}
+
+// SetSize sets the size of the Rendering including the status bar.
+func (r *Rendering) SetSize(width, height int) {
+	if !r.ready {
+		r.viewport = viewport.New(width, height-r.barsHeight())
+		r.viewport.HighPerformanceRendering = false
+		r.ready = true
+	} else {
+		r.viewport.Width = width
+		r.viewport.Height = height - r.barsHeight()
+	}
+	r.setContent()
+}
+
+func (r Rendering) Init() tea.Cmd {
+	return tea.Batch(r.refresh(), r.tick())
+}
+
+func (r Rendering) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var (
+		cmd  tea.Cmd
+		cmds []tea.Cmd
+	)
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if r.view == viewHelp && r.helpKey(msg.String()) {
+			return r, nil
+		}
+		if r.view == viewCollectors && r.collectorsKey(msg.String()) {
+			return r, nil
+		}
+		if (r.view == viewBanner || r.view == viewProcess) && r.helpKey(msg.String()) {
+			return r, nil
+		}
+		if r.player != nil && r.replayKey(msg) {
+			return r, r.refresh()
+		}
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			if r.quitKeys {
+				return r, tea.Quit
+			}
+		case "?":
+			r.view = viewHelp
+			return r, nil
+		case "c":
+			if r.hosts[r.current].collectors != nil {
+				r.view = viewCollectors
+			}
+			return r, nil
+		case "b":
+			return r, r.showBanner()
+		case "n", "tab":
+			r.selectHost(r.current + 1)
+			return r, nil
+		case "p", "shift+tab":
+			r.selectHost(r.current - 1)
+			return r, nil
+		case " ":
+			return r, r.setPaused(!r.paused)
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if i := int(msg.Runes[0] - '1'); i < len(r.hosts) {
+				r.selectHost(i)
+			}
+			return r, nil
+		case "s":
+			r.split = !r.split
+			r.setContent()
+			return r, nil
+		case "o":
+			r.procSort = r.procSort.next()
+			r.setContent()
+			return r, nil
+		case "j":
+			r.moveProcCursor(1)
+			return r, nil
+		case "k":
+			r.moveProcCursor(-1)
+			return r, nil
+		case "enter":
+			return r, r.showProcessDetail()
+		case "f":
+			r.fsSort = r.fsSort.next()
+			r.setContent()
+			return r, nil
+		case "left", "[":
+			if r.procOffset > 0 {
+				r.procOffset--
+				r.setContent()
+			}
+			return r, nil
+		case "right", "]":
+			if r.procOffset < len(procColumns)-2 {
+				r.procOffset++
+				r.setContent()
+			}
+			return r, nil
+		case "l":
+			r.layout = r.layout.next()
+			r.setContent()
+			return r, nil
+		}
+	case TickMsg:
+		if msg.ID != r.id {
+			return r, nil
+		}
+		if r.paused {
+			return r, r.tick()
+		}
+		return r, tea.Batch(r.refresh(), r.tick())
+
+	case bannerMsg:
+		if msg.ID != r.id {
+			return r, nil
+		}
+		i := r.hostIndex(msg.Host)
+		if i < 0 {
+			return r, nil
+		}
+		h := r.hosts[i]
+		h.bannerText, h.bannerErr, h.bannerRead = msg.Text, msg.Err, msg.Err == nil
+		return r, nil
+
+	case processDetailMsg:
+		if msg.ID != r.id || msg.Host != r.hosts[r.current].key || msg.PID != r.procDetail.PID {
+			return r, nil
+		}
+		if msg.Err == nil {
+			r.procDetail = msg.Detail
+		}
+		r.procDetailErr, r.procDetailRead = msg.Err, true
+		return r, nil
+
+	case AlertMsg:
+		r.alert(msg.Alert)
+		return r, nil
+
+	case controlMsg:
+		cmd, err := r.control(msg.args)
+		msg.reply <- err
+		return r, cmd
+
+	case AddHostMsg:
+		h := r.addHost(msg.Host)
+		r.setContent()
+		if r.paused || r.player != nil {
+			return r, nil
+		}
+		return r, r.refreshHost(h)
+
+	case RemoveHostMsg:
+		r.removeHost(msg.Name)
+		return r, nil
+
+	case StatsMsg:
+		i := r.hostIndex(msg.Host)
+		if msg.ID != r.id || i < 0 {
+			return r, nil
+		}
+		if r.paused {
+			// keep the values frozen, refreshing again once resumed
+			r.hosts[i].fetching = false
+			return r, nil
+		}
+		r.hosts[i].update(msg)
+		if i == r.current {
+			r.setContent()
+		}
+		return r, nil
+
+	case tea.WindowSizeMsg:
+		r.SetSize(msg.Width, msg.Height)
+		return r, nil
+	}
+
+	r.viewport, cmd = r.viewport.Update(msg)
+	cmds = append(cmds, cmd)
+
+	return r, tea.Batch(cmds...)
+}
+
+func (r Rendering) View() string {
+	view := r.viewport.View()
+	switch r.view {
+	case viewHelp:
+		view = r.helpView()
+	case viewCollectors:
+		view = r.collectorsView()
+	case viewBanner:
+		view = r.bannerView()
+	case viewProcess:
+		view = r.processView()
+	}
+	if r.player != nil {
+		return view + "\n" + r.timeline() + "\n" + r.statusBar()
+	}
+	return view + "\n" + r.statusBar()
+}
+
+func (r Rendering) tick() tea.Cmd {
+	id := r.id
+	return tea.Tick(r.interval, func(t time.Time) tea.Msg {
+		return TickMsg{ID: id, Time: t}
+	})
+}
+
+// refresh fetches the stats of every host, so that connections to the hosts
+// not currently displayed are kept warm. Hosts which are still busy with a
+// previous refresh are skipped.
+func (r Rendering) refresh() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, h := range r.hosts {
+		if h.fetching {
+			continue
+		}
+		cmds = append(cmds, r.refreshHost(h))
+	}
+	return tea.Batch(cmds...)
+}
+
+// refreshHost fetches the stats of the given host.
+func (r Rendering) refreshHost(h *hostState) tea.Cmd {
+	h.fetching = true
+	id, key, fn := r.id, h.key, h.getStatsFn
+	return func() tea.Msg {
+		stats, err := fn(context.Background())
+		return StatsMsg{ID: id, Host: key, Stats: stats, Err: err}
+	}
+}
+
+// setPaused suspends or resumes refreshing the hosts. The ticks go on while
+// paused, so that resuming does not start a second tick loop.
+func (r *Rendering) setPaused(paused bool) tea.Cmd {
+	if paused == r.paused {
+		return nil
+	}
+	r.paused = paused
+	if paused {
+		return nil
+	}
+	return r.refresh()
+}
+
+// selectHost makes the host at the given index current, wrapping around at
+// both ends.
+func (r *Rendering) selectHost(i int) {
+	n := len(r.hosts)
+	r.current = (i%n + n) % n
+	r.setContent()
+}
+
+func (r *Rendering) setContent() {
+	if !r.ready {
+		return
+	}
+	b := r.render()
+	r.viewport.SetContent(b.String())
+}
+
+func (r *Rendering) render() bytes.Buffer {
+	var b bytes.Buffer
+	r.small, r.shed = false, nil
+
+	if r.split && len(r.hosts) > 1 {
+		b.WriteString(r.renderSplit())
+		return b
+	}
+
+	h := r.hosts[r.current]
+	if len(r.hosts) > 1 {
+		fmt.Fprintf(&b, "%s\n\n", r.tabBar())
+	}
+	if h.down() {
+		fmt.Fprintf(&b, "%s, %s: %s\n\n", r.styles.Down.Render("host down"), h.downSince(), h.err)
+	} else if h.err != nil {
+		fmt.Fprintf(&b, "error: %s\n\n", h.err)
+	}
+	if h.stats.Hostname == "" {
+		return b
+	}
+
+	switch {
+	case r.tooSmall():
+		r.small = true
+		r.shed = r.renderSmall(&b, h.stats, r.viewport.Height-strings.Count(b.String(), "\n"))
+	case r.layout == LayoutCompact:
+		r.renderCompact(&b, h.stats)
+	case r.layout == LayoutWide:
+		b.WriteString(r.renderWide(h, r.viewport.Width))
+	default:
+		for _, section := range r.sections(h) {
+			b.WriteString(section)
+		}
+	}
+
+	return b
+}
+
+// sectionNames are the names of the sections which can be hidden.
+var sectionNames = []string{"load", "cpu", "cores", "processes", "memory", "sensors", "gpus", "raid", "zfs", "nfs", "filesystems", "io", "network", "routes", "budgets", "extra", "alerts"}
+
+// sections renders the stats as a list of sections, each one followed by an
+// empty line. The first section is always the hostname and uptime header,
+// the others are left out if hidden. The CPU, memory and network lines are
+// followed by sparklines of the history of the host, if any, and the alerts
+// of the host are listed last.
+func (r Rendering) sections(h *hostState) []string {
+	stats, hist := h.stats, h.history
+	w := r.styles.Value
+
+	var res []string
+	add := func(name, section string) {
+		if !r.hidden[name] {
+			res = append(res, section)
+		}
+	}
+
+	header := fmt.Sprintf("%s up %s\n",
+		r.hostnameStyle(stats).Render(stats.Hostname),
+		w.Render(FormatUptime(stats.Uptime, r.uptime)),
+	)
+	if len(stats.Labels) > 0 {
+		header += fmtLabels(stats.Labels) + "\n"
+	}
+	if s := stats.Systemd; s != nil && len(s.Failed) > 0 {
+		header += fmt.Sprintf("systemd %s, %s: %s\n",
+			s.State,
+			r.critical(fmt.Sprintf("%d failed units", len(s.Failed))),
+			strings.Join(s.Failed, ", "),
+		)
+	}
+	if len(stats.Meta.Disabled) > 0 {
+		header += r.styles.Warning.Render("limited:") + " " + fmtDisabled(stats.Meta.Disabled) + "\n"
+	}
+	res = append(res, header+"\n")
+
+	add("load", fmt.Sprintf("%s:\n    %s %s %s\n\n",
+		r.heading("Load"),
+		w.Render(r.locale.decimal(stats.Loads.Load1)),
+		w.Render(r.locale.decimal(stats.Loads.Load5)),
+		w.Render(r.locale.decimal(stats.Loads.Load15)),
+	))
+
+	add("cpu", fmt.Sprintf("%s:%s\n    %s user, %s sys, %s nice, %s idle, %s iowait, %s hardirq, %s softirq, %s steal, %s guest\n\n",
+		r.heading("CPU"),
+		r.sparkline(hist.cpuRing(), 100),
+		w.Render(r.locale.float(float64(stats.CPU.User), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.System), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.Nice), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.Idle), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.IOWait), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.IRQ), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.SoftIRQ), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.Steal), 2)),
+		w.Render(r.locale.float(float64(stats.CPU.Guest), 2)),
+	))
+
+	if len(stats.Cores) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Cores") + ":\n")
+		for _, core := range stats.Cores {
+			busy := 100 - core.Idle
+			fmt.Fprintf(&b, "    %-6s %s %s\n",
+				core.Core,
+				coreBar(busy),
+				w.Render(r.locale.float(float64(busy), 2)+"%"),
+			)
+		}
+		b.WriteString("\n")
+		add("cores", b.String())
+	}
+
+	procs := fmt.Sprintf("%s:\n    %s running of %s total\n",
+		r.heading("Processes"),
+		w.Render(stats.Loads.RunningProcs),
+		w.Render(stats.Loads.TotalProcs),
+	)
+	if sum := stats.ProcessSummary; sum != nil {
+		zombies := levelNormal
+		if sum.Zombies > 0 {
+			zombies = levelCritical
+		}
+		procs += fmt.Sprintf("    %s zombies\n", r.mark(zombies, strconv.Itoa(sum.Zombies)))
+		procs += fmt.Sprintf("    by user: %s\n", r.processUsers(sum, 5))
+	}
+	if t := stats.Tasks; t != nil {
+		procs += fmt.Sprintf("    %s threads, pids %s of %s, threads %s of %s\n",
+			w.Render(strconv.Itoa(t.Threads)),
+			r.mark(usageLevel(t.PIDUsage()), r.locale.float(t.PIDUsage(), 1)+"%"),
+			w.Render(strconv.Itoa(t.PIDMax)),
+			r.mark(usageLevel(t.ThreadUsage()), r.locale.float(t.ThreadUsage(), 1)+"%"),
+			w.Render(strconv.Itoa(t.ThreadsMax)),
+		)
+	}
+	if len(stats.Processes) > 0 {
+		procs += "\n" + r.processTable(stats.Processes)
+	}
+	add("processes", procs+"\n")
+
+	add("memory", fmt.Sprintf(`%s:
+    total   = %s
+    free    = %s
+    used    = %s%s
+    buffers = %s
+    cached  = %s
+    swap    = %s free of %s
+    swap io = %s in, %s out
+
+`,
+		r.heading("Memory"),
+		w.Render(r.locale.bytes(stats.MEM.Total)),
+		w.Render(r.locale.bytes(stats.MEM.Free)),
+		w.Render(r.locale.bytes(stats.MEM.Used())),
+		r.sparkline(hist.memRing(), 100),
+		w.Render(r.locale.bytes(stats.MEM.Buffers)),
+		w.Render(r.locale.bytes(stats.MEM.Cached)),
+		w.Render(r.locale.bytes(stats.MEM.SwapFree)),
+		w.Render(r.locale.bytes(stats.MEM.SwapTotal)),
+		w.Render(r.locale.float(stats.SwapActivity.InRate, 1)+" pages/s"),
+		w.Render(r.locale.float(stats.SwapActivity.OutRate, 1)+" pages/s"),
+	))
+
+	if len(stats.Sensors) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Temperatures") + ":\n")
+		for _, sensor := range stats.Sensors {
+			b.WriteString(fmt.Sprintf("    %s: %s\n",
+				sensor.Name,
+				r.mark(r.tempLevel(sensor.Temp), r.locale.float(sensor.Temp, 1)+"°C"),
+			))
+		}
+		b.WriteString("\n")
+		add("sensors", b.String())
+	}
+
+	if len(stats.GPUs) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("GPUs") + ":\n")
+		for _, gpu := range stats.GPUs {
+			b.WriteString(fmt.Sprintf("    %d %s: %s busy, %s of %s memory (%s), %s\n",
+				gpu.Index,
+				gpu.Name,
+				w.Render(r.locale.float(gpu.Utilization, 0)+"%"),
+				w.Render(r.locale.bytes(gpu.MemoryUsed)),
+				w.Render(r.locale.bytes(gpu.MemoryTotal)),
+				r.mark(usageLevel(gpu.MemoryUsage()), r.locale.float(gpu.MemoryUsage(), 1)+"%"),
+				r.mark(r.tempLevel(gpu.Temp), r.locale.float(gpu.Temp, 0)+"°C"),
+			))
+		}
+		b.WriteString("\n")
+		add("gpus", b.String())
+	}
+
+	if len(stats.RAID) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("RAID") + ":\n")
+		for _, md := range stats.RAID {
+			name := w.Render(md.Name)
+			if md.Level != "" {
+				name += " " + md.Level
+			}
+			b.WriteString(fmt.Sprintf("    %s: %s\n", name, r.fmtRAID(md)))
+		}
+		b.WriteString("\n")
+		add("raid", b.String())
+	}
+
+	if len(stats.ZFS) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("ZFS") + ":\n")
+		for _, pool := range stats.ZFS {
+			b.WriteString(fmt.Sprintf("    %s: %s, %s of %s used (%s), %s fragmented\n",
+				w.Render(pool.Name),
+				r.zpoolHealth(pool),
+				w.Render(r.locale.bytes(pool.Alloc)),
+				w.Render(r.locale.bytes(pool.Size)),
+				r.mark(usageLevel(pool.Capacity), r.locale.float(pool.Capacity, 0)+"%"),
+				w.Render(r.locale.float(pool.Fragmentation, 0)+"%"),
+			))
+			if pool.Status != "" {
+				b.WriteString("      " + pool.Status + "\n")
+			}
+		}
+		b.WriteString("\n")
+		add("zfs", b.String())
+	}
+
+	if nfs := stats.NFS; nfs != nil {
+		var b bytes.Buffer
+		b.WriteString(r.heading("NFS") + ":\n")
+		if nfs.Client != nil {
+			b.WriteString(fmt.Sprintf("    client %s calls/s, %s retrans/s\n",
+				w.Render(r.locale.float(nfs.Client.CallRate, 1)),
+				r.retransRate(nfs.Client.RetransRate),
+			))
+		}
+		if nfs.Server != nil {
+			b.WriteString(fmt.Sprintf("    server %s calls/s, %s bad calls/s\n",
+				w.Render(r.locale.float(nfs.Server.CallRate, 1)),
+				r.retransRate(nfs.Server.BadCallRate),
+			))
+		}
+		for _, m := range nfs.Mounts {
+			b.WriteString(fmt.Sprintf("    %s (%s %s): %s ops/s, read %s/s, write %s/s, rtt %s, %s retrans/s\n",
+				w.Render(m.MountPoint),
+				m.Export,
+				m.FSType,
+				w.Render(r.locale.float(m.OpRate, 1)),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(m.ReadRate)))),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(m.WriteRate)))),
+				w.Render(fmtLatency(m.AvgRTT)),
+				r.retransRate(m.RetransRate),
+			))
+		}
+		b.WriteString("\n")
+		add("nfs", b.String())
+	}
+
+	if len(stats.FSInfos) > 0 {
+		var b bytes.Buffer
+		b.WriteString(fmt.Sprintf("%s:   (f: order by %s)\n", r.heading("Filesystems"), r.fsSort.next()))
+		for _, fs := range sortFS(stats.FSInfos, r.fsSort) {
+			b.WriteString(fmt.Sprintf("    %8s: %s used, %s free of %s%s\n",
+				w.Render(r.fsLabel(fs)),
+				r.mark(usageLevel(fs.Usage()), fmt.Sprintf("%5s%%", r.locale.float(fs.Usage(), 1))),
+				w.Render(r.locale.bytes(fs.Free)),
+				w.Render(r.locale.bytes(fs.Total)),
+				r.fmtInodes(fs),
+			))
+		}
+		for _, l := range stats.FSLatency {
+			b.WriteString(fmt.Sprintf("    %8s: %s\n", w.Render(l.MountPoint), r.fmtFSLatency(l)))
+		}
+		b.WriteString("\n")
+		add("filesystems", b.String())
+	}
+
+	if len(stats.DiskIO) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("I/O") + ":\n")
+		for _, io := range stats.DiskIO {
+			b.WriteString(fmt.Sprintf("    %8s: read %s/s (%s iops), write %s/s (%s iops)\n",
+				w.Render(io.Device),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(io.ReadRate)))),
+				w.Render(r.locale.float(io.ReadIOPS, 1)),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(io.WriteRate)))),
+				w.Render(r.locale.float(io.WriteIOPS, 1)),
+			))
+		}
+		b.WriteString("\n")
+		add("io", b.String())
+	}
+
+	if len(stats.NetInterface) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Network Interfaces") + ":\n")
+
+		for _, key := range sortedInterfaces(stats) {
+			info := stats.NetInterface[key]
+
+			b.WriteString(fmt.Sprintf("    %s - %s",
+				w.Render(key),
+				w.Render(info.IPv4),
+			))
+			if len(info.IPv6) > 0 {
+				b.WriteString(fmt.Sprintf(", %s\n",
+					w.Render(info.IPv6),
+				))
+			} else {
+				b.WriteString("\n")
+			}
+			b.WriteString(fmt.Sprintf("      rx = %s (%s/s), tx = %s (%s/s)%s\n",
+				w.Render(r.locale.bytes(info.Rx)),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(info.RxRate)))),
+				w.Render(r.locale.bytes(info.Tx)),
+				w.Render(strings.TrimSpace(r.locale.bytes(uint64(info.TxRate)))),
+				r.sparkline(hist.netRing(key), 0),
+			))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+		add("network", b.String())
+	}
+
+	if stats.Routes != nil {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Routes") + ":\n")
+		b.WriteString(fmt.Sprintf("    %s routes\n", w.Render(strconv.Itoa(stats.Routes.Count))))
+		if len(stats.Routes.Gateways) == 0 {
+			b.WriteString("    " + r.critical("no default gateway") + "\n")
+		}
+		for _, gw := range stats.Routes.Gateways {
+			b.WriteString(fmt.Sprintf("    default via %s dev %s: %s\n",
+				w.Render(gw.Address),
+				w.Render(gw.Interface),
+				r.fmtGateway(gw),
+			))
+		}
+		b.WriteString("\n")
+		add("routes", b.String())
+	}
+
+	if len(stats.Budgets) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Data Budgets") + ":\n")
+		for _, u := range stats.Budgets {
+			b.WriteString(fmt.Sprintf("    %s: %s\n", w.Render(budgetName(u)), r.fmtBudget(u)))
+		}
+		b.WriteString("\n")
+		add("budgets", b.String())
+	}
+
+	if len(stats.Extra) > 0 {
+		var b bytes.Buffer
+		b.WriteString(r.heading("Extra") + ":\n")
+		for _, key := range sortedExtra(stats) {
+			b.WriteString(fmt.Sprintf("    %s = %s\n",
+				key,
+				w.Render(r.locale.value(stats.ExtraValue(key))),
+			))
+		}
+		b.WriteString("\n")
+		add("extra", b.String())
+	}
+
+	if len(h.alerts) > 0 {
+		add("alerts", r.alertLog(h.alerts))
+	}
+
+	return res
+}
+
+// tempLevel returns the level of the given temperature.
+func (r Rendering) tempLevel(temp float64) level {
+	switch {
+	case temp >= r.tempCrit:
+		return levelCritical
+	case temp >= r.tempWarn:
+		return levelWarning
+	}
+	return levelNormal
+}
+
+// fmtFSLatency formats the probe durations of a mount point, flagging
+// writes slower than 100ms or 1s.
+func (r Rendering) fmtFSLatency(l types.FSLatency) string {
+	if l.Failed {
+		return r.critical("probe write failed")
+	}
+	write := levelNormal
+	switch {
+	case l.Write >= time.Second:
+		write = levelCritical
+	case l.Write >= 100*time.Millisecond:
+		write = levelWarning
+	}
+	return fmt.Sprintf("write %s, read %s", r.mark(write, fmtLatency(l.Write)), r.styles.Value.Render(fmtLatency(l.Read)))
+}
+
+// fmtRAID describes the state, devices and resync of an array, flagging
+// degraded arrays as critical and resyncs as warnings.
+func (r Rendering) fmtRAID(md types.RAIDInfo) string {
+	w := r.styles.Value
+	state := w.Render(md.State)
+	if md.Degraded() {
+		state = r.critical(md.State + ", degraded")
+	}
+	s := fmt.Sprintf("%s, %s devices", state, w.Render(fmt.Sprintf("%d/%d", md.Active, md.Total)))
+	if len(md.Devices) > 0 {
+		s += " " + strings.Join(md.Devices, " ")
+	}
+	if len(md.Failed) > 0 {
+		s += ", failed " + r.critical(strings.Join(md.Failed, " "))
+	}
+	if len(md.Spares) > 0 {
+		s += ", spare " + strings.Join(md.Spares, " ")
+	}
+	if md.SyncAction != "" {
+		s += ", " + r.warning(md.SyncAction)
+		if md.SyncProgress > 0 {
+			s += " " + w.Render(r.locale.float(md.SyncProgress, 1)+"%")
+		}
+		if md.SyncFinish > 0 {
+			s += ", " + w.Render(FormatUptime(md.SyncFinish.Truncate(time.Minute), r.uptime)) + " left"
+		}
+	}
+	return s
+}
+
+// retransRate formats a rate of NFS calls sent again or rejected, marked as
+// a warning unless 0.
+func (r Rendering) retransRate(v float64) string {
+	if v > 0 {
+		return r.warning(r.locale.float(v, 1))
+	}
+	return r.styles.Value.Render(r.locale.float(v, 1))
+}
+
+// zpoolHealth returns the health of a pool, marked as critical unless the
+// pool is ONLINE.
+func (r Rendering) zpoolHealth(pool types.ZPool) string {
+	if pool.Healthy() {
+		return r.styles.Value.Render(pool.Health)
+	}
+	return r.critical(pool.Health)
+}
+
+// usageLevel returns the level of a percentage of a limit in use.
+func usageLevel(pct float64) level {
+	switch {
+	case pct >= 95:
+		return levelCritical
+	case pct >= 80:
+		return levelWarning
+	}
+	return levelNormal
+}
+
+// hostnameStyle returns the style of the hostname, which is shown as
+// critical if systemd units have failed.
+func (r Rendering) hostnameStyle(stats types.Stats) lipgloss.Style {
+	if stats.Systemd != nil && len(stats.Systemd.Failed) > 0 {
+		return r.styles.Critical
+	}
+	return r.styles.Value
+}
+
+// fmtGateway describes whether the gateway answered the ping.
+func (r Rendering) fmtGateway(gw types.Gateway) string {
+	if !gw.Pinged {
+		return "not checked, no ping command"
+	}
+	if !gw.Reachable {
+		return r.critical("unreachable")
+	}
+	return "reachable in " + r.styles.Value.Render(fmtLatency(gw.RTT))
+}
+
+// fsLabel returns the name a filesystem is listed under.
+func (r Rendering) fsLabel(fs types.FSInfo) string {
+	if !r.fsByDevice {
+		if len(fs.OtherMounts) > 0 {
+			return fmt.Sprintf("%s (+%d)", fs.MountPoint, len(fs.OtherMounts))
+		}
+		return fs.MountPoint
+	}
+	mounts := append([]string{fs.MountPoint}, fs.OtherMounts...)
+	return fmt.Sprintf("%s on %s", fs.Device, strings.Join(mounts, ", "))
+}
+
+// fmtLabels formats the labels as space separated key=value pairs, ordered
+// by key.
+func fmtLabels(labels map[string]string) string {
+	keys := sortedLabels(labels)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+func sortedLabels(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedExtra(stats types.Stats) []string {
+	keys := make([]string, 0, len(stats.Extra))
+	for k := range stats.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedInterfaces(stats types.Stats) []string {
+	keys := make([]string, 0, len(stats.NetInterface))
+	for k := range stats.NetInterface {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// coreBarWidth is the number of cells in a per-core usage bar.
+const coreBarWidth = 20
+
+// coreBar draws the busy percentage as a bar of coreBarWidth cells.
+func coreBar(busy float32) string {
+	n := int(busy/100*coreBarWidth + 0.5)
+	if n < 0 {
+		n = 0
+	} else if n > coreBarWidth {
+		n = coreBarWidth
+	}
+	return "[" + strings.Repeat("|", n) + strings.Repeat(" ", coreBarWidth-n) + "]"
+}
+
+func fmtBytes(val uint64) string {
+	if val < 1024 {
+		return fmt.Sprintf("%d bytes", val)
+	} else if val < 1024*1024 {
+		return fmt.Sprintf("%6.2f KiB", float64(val)/1024.0)
+	} else if val < 1024*1024*1024 {
+		return fmt.Sprintf("%6.2f MiB", float64(val)/1024.0/1024.0)
+	} else {
+		return fmt.Sprintf("%6.2f GiB", float64(val)/1024.0/1024.0/1024.0)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// fmtInodes formats the inode usage of the filesystem as a suffix, empty if
+// it has no inode counts.
+func (r Rendering) fmtInodes(fs types.FSInfo) string {
+	if fs.InodesTotal == 0 {
+		return ""
+	}
+	return ", inodes " + r.mark(usageLevel(fs.InodeUsage()), r.locale.float(fs.InodeUsage(), 1)+"%")
+}
+
+// fmtDisabled lists the disabled collectors with their reasons.
+func fmtDisabled(disabled map[string]string) string {
+	names := make([]string, 0, len(disabled))
+	for name := range disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s (%s)", name, disabled[name])
+	}
+	return strings.Join(names, ", ")
+}
+
+// budgetName names a budget by its interface and period.
+func budgetName(u types.BudgetUsage) string {
+	if u.Interface == "" {
+		return u.Period
+	}
+	return u.Interface + " " + u.Period
+}
+
+// fmtBudget formats the used part of a budget, highlighted once exceeded.
+func (r Rendering) fmtBudget(u types.BudgetUsage) string {
+	used := fmt.Sprintf("%s of %s (%s%%)",
+		strings.TrimSpace(r.locale.bytes(u.Used)),
+		strings.TrimSpace(r.locale.bytes(u.Limit)),
+		r.locale.float(float64(u.Used)/float64(u.Limit)*100, 1),
+	)
+	if u.Exceeded {
+		return r.critical(used + " exceeded")
+	}
+	return r.styles.Value.Render(used)
+}
@@ -0,0 +1,208 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Player controls the playback of a recorded session, whose hosts the
+// Rendering shows, see WithPlayer.
+type Player interface {
+	Position() time.Time
+	Bounds() (start, end time.Time)
+	Paused() bool
+	SetPaused(paused bool)
+	Speed() float64
+	SetSpeed(speed float64)
+	Seek(t time.Time)
+}
+
+// replaySpeeds are the speeds + and - step through.
+var replaySpeeds = []float64{0.5, 1, 2, 10, 60}
+
+// replaySkip is how many samples left and right skip.
+const replaySkip = 10
+
+// minReplayInterval bounds the refresh interval at high speeds.
+const minReplayInterval = 50 * time.Millisecond
+
+const replayHelp = "space pause  +/- speed  ←/→ skip  home/end  g jump"
+
+// replayKey handles the keys controlling the playback, reporting whether
+// the key was one of them.
+func (r *Rendering) replayKey(msg tea.KeyMsg) bool {
+	if r.jumping {
+		switch msg.Type {
+		case tea.KeyEnter:
+			t, err := parseJump(r.jumpInput, r.player.Position())
+			if err != nil {
+				r.jumpErr = err.Error()
+				return true
+			}
+			r.seek(t)
+			r.jumping = false
+		case tea.KeyEsc:
+			r.jumping = false
+		case tea.KeyBackspace:
+			if n := len(r.jumpInput); n > 0 {
+				r.jumpInput = r.jumpInput[:n-1]
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			r.jumpInput += string(msg.Runes)
+		default:
+			return false
+		}
+		return true
+	}
+
+	switch msg.String() {
+	case " ":
+		r.player.SetPaused(!r.player.Paused())
+	case "+", "=":
+		r.setReplaySpeed(1)
+	case "-":
+		r.setReplaySpeed(-1)
+	case "left":
+		r.seek(r.player.Position().Add(-replaySkip * r.replayInterval))
+	case "right":
+		r.seek(r.player.Position().Add(replaySkip * r.replayInterval))
+	case "home":
+		start, _ := r.player.Bounds()
+		r.seek(start)
+	case "end":
+		_, end := r.player.Bounds()
+		r.seek(end)
+	case "g":
+		r.jumping = true
+		r.jumpInput = ""
+		r.jumpErr = ""
+	default:
+		return false
+	}
+	return true
+}
+
+// setReplaySpeed steps through replaySpeeds by the given direction and
+// refreshes as often as the samples are played.
+func (r *Rendering) setReplaySpeed(dir int) {
+	speed := r.player.Speed()
+	i := 0
+	for i < len(replaySpeeds)-1 && replaySpeeds[i] < speed {
+		i++
+	}
+	i += dir
+	if i < 0 || i >= len(replaySpeeds) {
+		return
+	}
+	r.player.SetSpeed(replaySpeeds[i])
+	r.interval = time.Duration(float64(r.replayInterval) / replaySpeeds[i])
+	if r.interval < minReplayInterval {
+		r.interval = minReplayInterval
+	}
+}
+
+// seek moves the playback, dropping the history of the hosts as it no
+// longer leads up to the position.
+func (r *Rendering) seek(t time.Time) {
+	r.player.Seek(t)
+	for _, h := range r.hosts {
+		if h.history != nil {
+			h.history = newHistory(r.historySize)
+		}
+	}
+}
+
+// parseJump parses the time to jump to, given as a time of day on the day
+// of the current position, as a date and time, or as a duration relative to
+// the current position, e.g. -5m.
+func parseJump(s string, pos time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q", s)
+		}
+		return pos.Add(d), nil
+	}
+	pos = pos.Local()
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return time.Date(pos.Year(), pos.Month(), pos.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected hh:mm[:ss], yyyy-mm-dd hh:mm[:ss] or ±duration", s)
+}
+
+// timeline renders the playback state and a bar of the position within
+// the session.
+func (r Rendering) timeline() string {
+	width := r.viewport.Width
+	if r.jumping {
+		line := " jump to (hh:mm[:ss], yyyy-mm-dd hh:mm[:ss] or ±duration): " + r.jumpInput + "_"
+		if r.jumpErr != "" {
+			line += "  " + r.styles.Critical.Render(r.jumpErr)
+		}
+		return lipgloss.NewStyle().MaxWidth(width).Render(line)
+	}
+
+	start, end := r.player.Bounds()
+	pos := r.player.Position()
+	state := "▶"
+	if r.player.Paused() {
+		state = "⏸"
+	}
+	layout := "15:04:05"
+	if end.Sub(start) >= 24*time.Hour {
+		layout = "01-02 15:04:05"
+	}
+	left := fmt.Sprintf(" %s %gx %s ", state, r.player.Speed(), pos.Local().Format(layout))
+	right := " " + end.Local().Format(layout)
+	if width >= len(left)+len(right)+len(replayHelp)+24 {
+		right += "  " + replayHelp
+	}
+
+	barWidth := width - len([]rune(left)) - len([]rune(right)) - 2
+	if barWidth < 1 {
+		return left
+	}
+	at := 0
+	if total := end.Sub(start); total > 0 {
+		at = int(float64(pos.Sub(start)) / float64(total) * float64(barWidth-1))
+	}
+	bar := strings.Repeat("━", at) + "●" + strings.Repeat("─", barWidth-1-at)
+	return left + "[" + r.styles.Sparkline.Render(bar) + "]" + right
+}
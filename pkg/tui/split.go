@@ -0,0 +1,105 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// splitPaneWidth is the minimum width of a host pane in the split view.
+const splitPaneWidth = 60
+
+// tabBar renders one tab per host, numbered for selecting them with the
+// number keys.
+func (r Rendering) tabBar() string {
+	tabs := make([]string, 0, len(r.hosts))
+	for i, h := range r.hosts {
+		label := fmt.Sprintf("%d %s", i+1, h.name)
+		style := r.styles.Tab
+		if i == r.current {
+			style = r.styles.CurrentTab
+		}
+		if h.degraded() {
+			label += " DEGRADED"
+			style = style.Copy().Foreground(r.styles.Down.GetForeground())
+		} else if h.down() {
+			label += " DOWN"
+			style = style.Copy().Foreground(r.styles.Down.GetForeground())
+		}
+		tabs = append(tabs, style.Render(label))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+}
+
+// renderSplit renders all hosts at once in a grid of compact panes.
+func (r Rendering) renderSplit() string {
+	cols := r.viewport.Width / splitPaneWidth
+	if cols < 1 {
+		cols = 1
+	}
+	if cols > len(r.hosts) {
+		cols = len(r.hosts)
+	}
+	// leave room for the borders and padding of each pane
+	width := r.viewport.Width/cols - r.styles.Pane.GetHorizontalFrameSize()
+
+	var rows, row []string
+	for i, h := range r.hosts {
+		var b bytes.Buffer
+		title := r.styles.Value.Render(h.name)
+		if h.degraded() {
+			title = r.styles.Down.Render(h.name + " DEGRADED")
+		} else if h.down() {
+			title = r.styles.Down.Render(h.name + " DOWN")
+		}
+		fmt.Fprintf(&b, "%s\n", title)
+		if h.down() {
+			fmt.Fprintf(&b, "%s\n", h.downSince())
+		} else if h.err != nil {
+			fmt.Fprintf(&b, "error: %s\n", h.err)
+		}
+		if h.stats.Hostname != "" {
+			r.renderCompact(&b, h.stats)
+		}
+
+		style := r.styles.Pane
+		if i == r.current {
+			style = r.styles.CurrentPane
+		}
+		row = append(row, style.Width(width).Render(strings.TrimRight(b.String(), "\n")))
+
+		if len(row) == cols || i == len(r.hosts)-1 {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row = nil
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
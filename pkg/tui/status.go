@@ -0,0 +1,109 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const statusBarHeight = 1
+
+// barsHeight is the height of the status bar and, when replaying, the
+// timeline above it.
+func (r Rendering) barsHeight() int {
+	if r.player != nil {
+		return statusBarHeight + 1
+	}
+	return statusBarHeight
+}
+
+// statusBar renders a single line describing the connection to the current
+// host.
+func (r Rendering) statusBar() string {
+	h := r.hosts[r.current]
+
+	items := []string{h.name}
+	if until, ok := BackingOff(h.err); ok {
+		items = append(items, "degraded, next try at "+until.Format("15:04:05"))
+	} else if h.down() {
+		items = append(items, "disconnected, reconnecting")
+	} else {
+		items = append(items, "connected")
+	}
+	if r.paused {
+		items = append(items, "PAUSED, space to resume")
+	}
+	if r.small {
+		small := "small terminal, compact"
+		if len(r.shed) > 0 {
+			small += ", hiding " + strings.Join(r.shed, ", ")
+		}
+		items = append(items, small)
+	}
+	if f := h.stats.Meta.IntervalFloor; f > r.interval && r.player == nil {
+		// the status bar style would not survive a nested style
+		items = append(items, fmt.Sprintf("slow host, refreshing every %s", f.Round(time.Millisecond)))
+	}
+	if h.stats.Meta.Reconnects > 0 {
+		items = append(items, fmt.Sprintf("reconnects %d", h.stats.Meta.Reconnects))
+	}
+	if h.stats.Hostname != "" {
+		items = append(items,
+			"clock offset "+fmtOffset(h.stats.Meta.ClockOffset),
+			"latency "+fmtLatency(h.stats.Meta.Latency),
+			"session open "+fmtLatency(h.stats.Meta.SessionOpen),
+			"command rtt "+fmtLatency(h.stats.Meta.CommandRTT),
+		)
+	}
+	if r.status != nil {
+		if s := r.status(); s != "" {
+			items = append(items, s)
+		}
+	}
+	items = append(items, "?: help")
+
+	// clip instead of wrapping, which would take lines from the viewport
+	line := lipgloss.NewStyle().MaxWidth(r.viewport.Width).Render(" " + strings.Join(items, " | "))
+	return r.styles.Status.Width(r.viewport.Width).Render(line)
+}
+
+// fmtOffset formats a clock offset with an explicit sign and millisecond
+// precision.
+func fmtOffset(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	if d >= 0 {
+		return fmt.Sprintf("+%s", d)
+	}
+	return d.String()
+}
+
+func fmtLatency(d time.Duration) string {
+	return d.Round(time.Millisecond / 10).String()
+}
@@ -0,0 +1,120 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Styles is the set of styles the TUI is drawn with. Programs embedding the
+// TUI can pass their own with WithStyles to match their theme, or pick one
+// of the Themes with ParseTheme.
+type Styles struct {
+	// Value is used for the collected values
+	Value lipgloss.Style
+	// Heading is used for the section headings
+	Heading lipgloss.Style
+	// Down marks hosts which can't be reached
+	Down lipgloss.Style
+	// Warning and Critical mark values above the thresholds
+	Warning  lipgloss.Style
+	Critical lipgloss.Style
+	// WarningGlyph and CriticalGlyph are shown before the values marked as
+	// warning or critical, so their state isn't told by color alone
+	WarningGlyph  string
+	CriticalGlyph string
+	// Sparkline draws the recent history of a value
+	Sparkline lipgloss.Style
+	// Status is the status bar at the bottom
+	Status lipgloss.Style
+	// Tab and CurrentTab are the host tabs
+	Tab        lipgloss.Style
+	CurrentTab lipgloss.Style
+	// Pane and CurrentPane frame the hosts in the split view
+	Pane        lipgloss.Style
+	CurrentPane lipgloss.Style
+}
+
+// The glyphs the color-blind themes and --glyphs mark values with.
+const (
+	WarningGlyph  = "▲"
+	CriticalGlyph = "✖"
+)
+
+// DefaultStyles returns the styles used unless overridden with WithStyles.
+func DefaultStyles() Styles {
+	pane := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	tab := lipgloss.NewStyle().Padding(0, 1)
+
+	return Styles{
+		Value:       lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF")).Bold(true),
+		Heading:     lipgloss.NewStyle(),
+		Down:        lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true),
+		Warning:     lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00")).Bold(true),
+		Critical:    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true),
+		Sparkline:   lipgloss.NewStyle().Foreground(lipgloss.Color("#00AFFF")),
+		Status:      lipgloss.NewStyle().Reverse(true),
+		Tab:         tab,
+		CurrentTab:  tab.Copy().Reverse(true).Bold(true),
+		Pane:        pane,
+		CurrentPane: pane.Copy().BorderForeground(lipgloss.Color("#FFFFFF")),
+	}
+}
+
+// level is the state of a value against its thresholds.
+type level int
+
+const (
+	levelNormal level = iota
+	levelWarning
+	levelCritical
+)
+
+// mark renders s in the style of the level, preceded by its glyph if any.
+func (r Rendering) mark(l level, s string) string {
+	switch l {
+	case levelCritical:
+		return r.critical(s)
+	case levelWarning:
+		return r.warning(s)
+	}
+	return r.styles.Value.Render(s)
+}
+
+func (r Rendering) warning(s string) string {
+	return r.styles.Warning.Render(withGlyph(r.styles.WarningGlyph, s))
+}
+
+func (r Rendering) critical(s string) string {
+	return r.styles.Critical.Render(withGlyph(r.styles.CriticalGlyph, s))
+}
+
+func withGlyph(glyph, s string) string {
+	if glyph == "" {
+		return s
+	}
+	return glyph + " " + s
+}
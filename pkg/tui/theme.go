@@ -0,0 +1,193 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme maps the parts of the TUI to the colors they are drawn with, in any
+// form lipgloss.Color accepts: "#RRGGBB" or an ANSI color number. The "base"
+// key names the built-in theme the others are applied on top of, dark
+// unless given. The warning-glyph and critical-glyph keys are the text
+// shown before values above the thresholds instead of a color.
+type Theme map[string]string
+
+// themeKeys are the keys a Theme may set, besides base.
+var themeKeys = []string{
+	"value", "heading", "down", "warning", "critical", "sparkline",
+	"status", "status-background", "tab", "current-tab", "current-tab-background",
+	"border", "current-border", "warning-glyph", "critical-glyph",
+}
+
+// Themes are the built-in themes. Dark is the default look.
+var Themes = map[string]Theme{
+	"dark": {},
+	"light": {
+		"value":          "#000000",
+		"heading":        "#005FAF",
+		"down":           "#D70000",
+		"warning":        "#AF5F00",
+		"critical":       "#D70000",
+		"sparkline":      "#0087AF",
+		"current-border": "#000000",
+	},
+	"solarized": {
+		"value":                  "#93A1A1",
+		"heading":                "#268BD2",
+		"down":                   "#DC322F",
+		"warning":                "#B58900",
+		"critical":               "#DC322F",
+		"sparkline":              "#2AA198",
+		"status":                 "#93A1A1",
+		"status-background":      "#073642",
+		"tab":                    "#839496",
+		"current-tab":            "#002B36",
+		"current-tab-background": "#268BD2",
+		"border":                 "#586E75",
+		"current-border":         "#268BD2",
+	},
+	// The color-blind themes keep to the blue-yellow axis of the Okabe-Ito
+	// palette, which both deuteranopes and protanopes tell apart, and mark
+	// the values with glyphs too.
+	"deuteranopia": {
+		"heading":        "#56B4E9",
+		"down":           "#D55E00",
+		"warning":        "#F0E442",
+		"critical":       "#D55E00",
+		"sparkline":      "#56B4E9",
+		"current-border": "#56B4E9",
+		"warning-glyph":  WarningGlyph,
+		"critical-glyph": CriticalGlyph,
+	},
+	"protanopia": {
+		"heading":        "#56B4E9",
+		"down":           "#CC79A7",
+		"warning":        "#F0E442",
+		"critical":       "#CC79A7",
+		"sparkline":      "#0072B2",
+		"current-border": "#56B4E9",
+		"warning-glyph":  WarningGlyph,
+		"critical-glyph": CriticalGlyph,
+	},
+}
+
+// ParseTheme returns the styles of the named theme, looked up in custom
+// before the built-in Themes.
+func ParseTheme(name string, custom map[string]Theme) (Styles, error) {
+	if t, ok := custom[name]; ok {
+		s, err := t.Styles()
+		if err != nil {
+			return s, fmt.Errorf("theme %s: %s", name, err)
+		}
+		return s, nil
+	}
+	if t, ok := Themes[name]; ok {
+		return t.Styles()
+	}
+	var names []string
+	for n := range Themes {
+		names = append(names, n)
+	}
+	for n := range custom {
+		if _, ok := Themes[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return Styles{}, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Styles returns DefaultStyles with the colors of the theme's base and then
+// of the theme itself.
+func (t Theme) Styles() (Styles, error) {
+	s := DefaultStyles()
+	base := "dark"
+	if b, ok := t["base"]; ok {
+		base = b
+	}
+	bt, ok := Themes[base]
+	if !ok {
+		return s, fmt.Errorf("unknown base theme %q", base)
+	}
+	for _, m := range []Theme{bt, t} {
+		for k, v := range m {
+			if k == "base" {
+				continue
+			}
+			if !s.set(k, v) {
+				return s, fmt.Errorf("unknown key %q, expected base or one of %s", k, strings.Join(themeKeys, ", "))
+			}
+		}
+	}
+	return s, nil
+}
+
+// set sets the color or glyph named by key, and reports whether the key is
+// known. Setting a background turns off the reverse video the status bar and
+// current tab are drawn with by default.
+func (s *Styles) set(key, v string) bool {
+	c := lipgloss.Color(v)
+	switch key {
+	case "warning-glyph":
+		s.WarningGlyph = v
+	case "critical-glyph":
+		s.CriticalGlyph = v
+	case "value":
+		s.Value = s.Value.Copy().Foreground(c)
+	case "heading":
+		s.Heading = s.Heading.Copy().Foreground(c)
+	case "down":
+		s.Down = s.Down.Copy().Foreground(c)
+	case "warning":
+		s.Warning = s.Warning.Copy().Foreground(c)
+	case "critical":
+		s.Critical = s.Critical.Copy().Foreground(c)
+	case "sparkline":
+		s.Sparkline = s.Sparkline.Copy().Foreground(c)
+	case "status":
+		s.Status = s.Status.Copy().Foreground(c)
+	case "status-background":
+		s.Status = s.Status.Copy().Reverse(false).Background(c)
+	case "tab":
+		s.Tab = s.Tab.Copy().Foreground(c)
+	case "current-tab":
+		s.CurrentTab = s.CurrentTab.Copy().Foreground(c)
+	case "current-tab-background":
+		s.CurrentTab = s.CurrentTab.Copy().Reverse(false).Background(c)
+	case "border":
+		s.Pane = s.Pane.Copy().BorderForeground(c)
+	case "current-border":
+		s.CurrentPane = s.CurrentPane.Copy().BorderForeground(c)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UptimeFormat selects how uptimes are written.
+type UptimeFormat int
+
+const (
+	// UptimeShort counts days, e.g. "400d 3h 4m 5s".
+	UptimeShort UptimeFormat = iota
+	// UptimeLong counts years and weeks too, e.g. "1y 5w 0d 3h 4m 5s".
+	UptimeLong
+	// UptimeISO is an ISO-8601 duration, e.g. "P400DT3H4M5S".
+	UptimeISO
+)
+
+var uptimeFormatNames = []string{"short", "long", "iso"}
+
+// ParseUptimeFormat returns the uptime format with the given name.
+func ParseUptimeFormat(name string) (UptimeFormat, error) {
+	for i, n := range uptimeFormatNames {
+		if n == name {
+			return UptimeFormat(i), nil
+		}
+	}
+	return UptimeShort, fmt.Errorf("unknown uptime format %q, expected one of %s", name, strings.Join(uptimeFormatNames, ", "))
+}
+
+func (f UptimeFormat) String() string {
+	return uptimeFormatNames[f]
+}
+
+type uptimeUnit struct {
+	suffix string
+	size   time.Duration
+}
+
+var (
+	day        = 24 * time.Hour
+	shortUnits = []uptimeUnit{{"d", day}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}}
+	longUnits  = append([]uptimeUnit{{"y", 365 * day}, {"w", 7 * day}}, shortUnits...)
+)
+
+// FormatUptime formats the uptime, truncated to whole seconds. Leading units
+// which are zero are left out.
+func FormatUptime(uptime time.Duration, f UptimeFormat) string {
+	uptime = uptime.Truncate(time.Second)
+	if uptime < 0 {
+		uptime = 0
+	}
+
+	if f == UptimeISO {
+		days := uptime / day
+		rest := uptime % day
+		s := "P"
+		if days > 0 {
+			s += fmt.Sprintf("%dD", days)
+		}
+		if rest > 0 || days == 0 {
+			s += "T"
+			if h := rest / time.Hour; h > 0 {
+				s += fmt.Sprintf("%dH", h)
+			}
+			if m := rest % time.Hour / time.Minute; m > 0 {
+				s += fmt.Sprintf("%dM", m)
+			}
+			if sec := rest % time.Minute / time.Second; sec > 0 || rest == 0 {
+				s += fmt.Sprintf("%dS", sec)
+			}
+		}
+		return s
+	}
+
+	units := shortUnits
+	if f == UptimeLong {
+		units = longUnits
+	}
+	var parts []string
+	for _, u := range units {
+		n := uptime / u.size
+		uptime -= n * u.size
+		if n > 0 || len(parts) > 0 || u.size == time.Second {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.suffix))
+		}
+	}
+	return strings.Join(parts, " ")
+}
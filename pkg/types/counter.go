@@ -0,0 +1,66 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package types
+
+import "math"
+
+// WrapByteRate is the fastest, in bytes per second, a byte counter which
+// wraps around at 32 bits is taken to grow. Such counters are those of
+// 32-bit kernels and some embedded NICs, which rarely have links faster
+// than gigabit. WrapOpRate is the same for counters of operations, such as
+// disk reads or swapped pages.
+const (
+	WrapByteRate = 125e6
+	WrapOpRate   = 1e6
+)
+
+// CounterDelta returns how much a cumulative counter grew from prev to cur,
+// given that it grows by at most maxWrap if it wraps around at 32 bits.
+//
+// Older and 32-bit kernels, and some embedded NICs, report counters which
+// wrap around at 32 bits. A counter which went backwards while both values
+// fit into 32 bits is assumed to have wrapped once if it then grew by no
+// more than maxWrap, i.e. prev was close to the wrap. Otherwise it was
+// reset, e.g. by a reboot, a driver reload or an interface going down, and
+// cur is what it grew since. The counters of 64-bit kernels are reset far
+// more often than they wrap, so a maxWrap of 0 always assumes a reset.
+func CounterDelta(cur, prev, maxWrap uint64) uint64 {
+	if cur >= prev {
+		return cur - prev
+	}
+	if prev <= math.MaxUint32 {
+		if wrapped := cur + (math.MaxUint32 - prev) + 1; wrapped <= maxWrap {
+			return wrapped
+		}
+	}
+	return cur
+}
+
+// MaxWrap returns the largest growth of a counter wrapping around at 32
+// bits over secs seconds, at the given rate per second, for CounterDelta.
+func MaxWrap(rate, secs float64) uint64 {
+	return uint64(rate * secs)
+}
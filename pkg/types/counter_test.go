@@ -0,0 +1,66 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package types
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCounterDelta(t *testing.T) {
+	const gib = 1 << 30
+	// two seconds at the wrap rate of bytes
+	maxWrap := MaxWrap(WrapByteRate, 2)
+
+	tests := []struct {
+		name      string
+		cur, prev uint64
+		maxWrap   uint64
+		want      uint64
+	}{
+		{"grew", 2000, 1000, maxWrap, 1000},
+		{"unchanged", 1000, 1000, maxWrap, 0},
+		{"grew past 32 bits", 5 * gib, 3 * gib, maxWrap, 2 * gib},
+		{"wrapped at 32 bits", 100, math.MaxUint32 - 99, maxWrap, 200},
+		{"wrapped by up to the max", maxWrap - 1, math.MaxUint32, maxWrap, maxWrap},
+
+		// resets while still below 32 bits, as after a reboot, a driver
+		// reload or an interface going down and up on a 64-bit kernel,
+		// must not be taken for a wrap of nearly 4 GiB
+		{"reset below 32 bits", 10 << 20, 1 * gib, maxWrap, 10 << 20},
+		{"reset to zero", 0, 3 * gib, maxWrap, 0},
+		{"reset just too far from the wrap", 100, math.MaxUint32 - maxWrap, maxWrap, 100},
+		{"reset without wraps", 100, math.MaxUint32 - 99, 0, 100},
+		{"reset above 32 bits", 10 << 20, 6 * gib, maxWrap, 10 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CounterDelta(tt.cur, tt.prev, tt.maxWrap); got != tt.want {
+				t.Errorf("CounterDelta(%d, %d, %d) = %d, want %d", tt.cur, tt.prev, tt.maxWrap, got, tt.want)
+			}
+		})
+	}
+}
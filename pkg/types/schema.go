@@ -0,0 +1,116 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package types
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Field describes a value of the JSON encoding of Stats.
+type Field struct {
+	// Path is the JSON path of the value, with [] for the elements of lists
+	// and <placeholders> for the keys of maps, e.g. fs_infos[].used or
+	// net_interface.<interface>.rx.
+	Path string `json:"path"`
+	// Type is integer, number, string or boolean.
+	Type string `json:"type"`
+	// Unit is given by the unit tag of the struct field, and is ns for
+	// durations.
+	Unit Unit `json:"unit,omitempty"`
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Schema lists the values of Stats in the order of the fields.
+func Schema() []Field {
+	var fields []Field
+	walkSchema(reflect.TypeOf(Stats{}), "", "", &fields)
+	return fields
+}
+
+func walkSchema(t reflect.Type, path, unit string, fields *[]Field) {
+	add := func(typ, unit string) {
+		*fields = append(*fields, Field{Path: path, Type: typ, Unit: Unit(unit)})
+	}
+
+	switch t {
+	case durationType:
+		add("integer", string(UnitNanoseconds))
+		return
+	case timeType:
+		add("string", "rfc3339")
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		walkSchema(t.Elem(), path, unit, fields)
+	case reflect.Slice:
+		walkSchema(t.Elem(), path+"[]", unit, fields)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				walkSchema(f.Type, path, "", fields)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			p := name
+			if path != "" {
+				p = path + "." + name
+			}
+			if f.Type.Kind() == reflect.Map {
+				key := f.Tag.Get("key")
+				if key == "" {
+					key = "key"
+				}
+				walkSchema(f.Type.Elem(), p+".<"+key+">", f.Tag.Get("unit"), fields)
+				continue
+			}
+			walkSchema(f.Type, p, f.Tag.Get("unit"), fields)
+		}
+	case reflect.Bool:
+		add("boolean", unit)
+	case reflect.String:
+		add("string", unit)
+	case reflect.Float32, reflect.Float64:
+		add("number", unit)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		add("integer", unit)
+	}
+}
@@ -28,20 +28,129 @@ package types
 import "time"
 
 type Stats struct {
-	Uptime       time.Duration
-	Hostname     string
-	Loads        Loads
-	CPU          CPUInfo // or []CPUInfo to get all the cpu-core's stats?
-	MEM          MemInfo
-	FSInfos      []FSInfo
-	NetInterface map[string]NetInterface
+	Uptime       time.Duration           `json:"uptime"`
+	Hostname     string                  `json:"hostname"`
+	Loads        Loads                   `json:"loads"`
+	CPU          CPUInfo                 `json:"cpu"`
+	CPURaw       CPURaw                  `json:"cpu_raw"` // counters CPU is computed from
+	Cores        []CPUInfo               `json:"cores,omitempty"`
+	MEM          MemInfo                 `json:"mem"`
+	SwapActivity SwapActivity            `json:"swap_activity"`
+	FSInfos      []FSInfo                `json:"fs_infos"`
+	FSLatency    []FSLatency             `json:"fs_latency,omitempty"`
+	DiskIO       []DiskIO                `json:"disk_io,omitempty"`
+	Sensors      []Sensor                `json:"sensors,omitempty"`
+	GPUs         []GPU                   `json:"gpus,omitempty"`
+	RAID         []RAIDInfo              `json:"raid,omitempty"`
+	ZFS          []ZPool                 `json:"zfs,omitempty"`
+	NFS          *NFS                    `json:"nfs,omitempty"`
+	NetInterface map[string]NetInterface `json:"net_interface" key:"interface"`
+	Routes       *Routes                 `json:"routes,omitempty"`
+	Systemd      *Systemd                `json:"systemd,omitempty"`
+	Budgets      []BudgetUsage           `json:"budgets,omitempty"`
+	// Extra holds the metrics of the optional collectors, keyed by
+	// collector.metric names.
+	Extra map[string]float64 `json:"extra,omitempty" key:"metric"`
+	// ExtraUnits holds the units of the metrics of Extra which have one.
+	ExtraUnits map[string]Unit `json:"extra_units,omitempty" key:"metric"`
+	// Processes are the processes using the most CPU and memory, if enabled.
+	Processes []Process `json:"processes,omitempty"`
+	// ProcessSummary counts all processes, if the process list is enabled.
+	ProcessSummary *ProcessSummary `json:"process_summary,omitempty"`
+	Tasks          *Tasks          `json:"tasks,omitempty"`
+	// Labels describe the host, such as its cloud instance type and region.
+	Labels map[string]string `json:"labels,omitempty" key:"label"`
+	Meta   Meta              `json:"meta"`
+}
+
+// Meta holds information about the collection itself rather than the host.
+type Meta struct {
+	// ClockOffset is the remote clock minus the local clock, corrected by
+	// half of the round trip time.
+	ClockOffset time.Duration `json:"clock_offset"`
+	// Latency is the estimated one-way latency to the remote host.
+	Latency time.Duration `json:"latency"`
+	// SessionOpen is the average time it took to open an ssh session.
+	SessionOpen time.Duration `json:"session_open"`
+	// CommandRTT is the average time from opening a session until the
+	// output of its command was received.
+	CommandRTT time.Duration `json:"command_rtt"`
+	// Reconnects is how often the connection was lost and set up again.
+	Reconnects int `json:"reconnects"`
+	// Disabled are the collectors skipped because the remote user cannot
+	// read their files or run their commands, with the reason.
+	Disabled map[string]string `json:"disabled,omitempty" key:"collector"`
+	// Missing are the collectors which produced nothing in this sample,
+	// because they failed, were turned off or are disabled, with the
+	// reason. Their fields hold zero values rather than readings.
+	Missing map[string]string `json:"missing,omitempty" key:"collector"`
+	// Collection is how long collecting the stats took, and IntervalFloor
+	// the shortest interval the host is polled at because of it.
+	Collection    time.Duration `json:"collection"`
+	IntervalFloor time.Duration `json:"interval_floor"`
+}
+
+// Collected reports whether the named collector, such as cpu, load, mem or
+// swap, produced the fields it fills in the sample, so that their zero
+// values are not taken for readings. Samples recorded before Meta.Missing
+// existed are judged by the fields themselves where possible.
+func (s Stats) Collected(collector string) bool {
+	if _, ok := s.Meta.Missing[collector]; ok {
+		return false
+	}
+	switch collector {
+	case "cpu":
+		return s.CPURaw.Total > 0
+	case "load":
+		return s.Loads.Load1 != ""
+	case "mem":
+		return s.MEM.Total > 0
+	}
+	return true
 }
 
 type FSInfo struct {
-	MountPoint string
-	Total      uint64
-	Used       uint64
-	Free       uint64
+	Device     string `json:"device"`
+	MountPoint string `json:"mount_point"`
+	Total      uint64 `json:"total" unit:"bytes"`
+	Used       uint64 `json:"used" unit:"bytes"`
+	Free       uint64 `json:"free" unit:"bytes"`
+	// FSType is the filesystem type, e.g. ext4, empty if the mount table
+	// can't be read.
+	FSType string `json:"fstype,omitempty"`
+	// InodesTotal, InodesUsed and InodesFree count the inodes, all 0 for
+	// filesystems without a fixed number of them, like btrfs.
+	InodesTotal uint64 `json:"inodes_total"`
+	InodesUsed  uint64 `json:"inodes_used"`
+	InodesFree  uint64 `json:"inodes_free"`
+	// OtherMounts lists further mount points of the same device, such as
+	// bind mounts.
+	OtherMounts []string `json:"other_mounts,omitempty"`
+}
+
+// Usage is the percentage of the space in use.
+func (fs FSInfo) Usage() float64 {
+	if fs.Total == 0 {
+		return 0
+	}
+	return float64(fs.Used) / float64(fs.Total) * 100
+}
+
+// InodeUsage is the percentage of inodes in use.
+func (fs FSInfo) InodeUsage() float64 {
+	if fs.InodesTotal == 0 {
+		return 0
+	}
+	return float64(fs.InodesUsed) / float64(fs.InodesTotal) * 100
+}
+
+// FSLatency is the time a small synced write and a read took on a mount
+// point. Failed is set if the probe file could not be written.
+type FSLatency struct {
+	MountPoint string        `json:"mount_point"`
+	Write      time.Duration `json:"write"`
+	Read       time.Duration `json:"read"`
+	Failed     bool          `json:"failed,omitempty"`
 }
 
 type NetInterface struct {
@@ -73,57 +182,341 @@ func MergeNetInterfaces(addr map[string]NetIPAddr, dev map[string]NetDevInfo) ma
 }
 
 type NetIPAddr struct {
-	IPv4 string
-	IPv6 string
+	IPv4 string `json:"ipv4"`
+	IPv6 string `json:"ipv6"`
 }
 
 type NetDevInfo struct {
-	Rx uint64
-	Tx uint64
+	Rx uint64 `json:"rx" unit:"bytes"`
+	Tx uint64 `json:"tx" unit:"bytes"`
+	// RxRate and TxRate are the bytes per second since the previous
+	// refresh, 0 on the first one.
+	RxRate float64 `json:"rx_rate" unit:"bytes/s"`
+	TxRate float64 `json:"tx_rate" unit:"bytes/s"`
 }
 
 type CPURaw struct {
-	User    uint64 // time spent in user mode
-	Nice    uint64 // time spent in user mode with low priority (nice)
-	System  uint64 // time spent in system mode
-	Idle    uint64 // time spent in the idle task
-	Iowait  uint64 // time spent waiting for I/O to complete (since Linux 2.5.41)
-	Irq     uint64 // time spent servicing  interrupts  (since  2.6.0-test4)
-	SoftIrq uint64 // time spent servicing softirqs (since 2.6.0-test4)
-	Steal   uint64 // time spent in other OSes when running in a virtualized environment
-	Guest   uint64 // time spent running a virtual CPU for guest operating systems under the control of the Linux kernel.
-	Total   uint64 // total of all time fields
+	Core    string `json:"core,omitempty"`       // name of the core, empty for all cores together
+	User    uint64 `json:"user" unit:"ticks"`    // time spent in user mode
+	Nice    uint64 `json:"nice" unit:"ticks"`    // time spent in user mode with low priority (nice)
+	System  uint64 `json:"system" unit:"ticks"`  // time spent in system mode
+	Idle    uint64 `json:"idle" unit:"ticks"`    // time spent in the idle task
+	Iowait  uint64 `json:"iowait" unit:"ticks"`  // time spent waiting for I/O to complete (since Linux 2.5.41)
+	Irq     uint64 `json:"irq" unit:"ticks"`     // time spent servicing  interrupts  (since  2.6.0-test4)
+	SoftIrq uint64 `json:"softirq" unit:"ticks"` // time spent servicing softirqs (since 2.6.0-test4)
+	Steal   uint64 `json:"steal" unit:"ticks"`   // time spent in other OSes when running in a virtualized environment
+	Guest   uint64 `json:"guest" unit:"ticks"`   // time spent running a virtual CPU for guest operating systems under the control of the Linux kernel.
+	Total   uint64 `json:"total" unit:"ticks"`   // total of all time fields
+}
+
+// Sub returns the time spent between the prev sample and this one. Counters
+// which went backwards count as zero.
+func (c CPURaw) Sub(prev CPURaw) CPURaw {
+	sub := func(a, b uint64) uint64 {
+		if a < b {
+			return 0
+		}
+		return a - b
+	}
+	return CPURaw{
+		Core:    c.Core,
+		User:    sub(c.User, prev.User),
+		Nice:    sub(c.Nice, prev.Nice),
+		System:  sub(c.System, prev.System),
+		Idle:    sub(c.Idle, prev.Idle),
+		Iowait:  sub(c.Iowait, prev.Iowait),
+		Irq:     sub(c.Irq, prev.Irq),
+		SoftIrq: sub(c.SoftIrq, prev.SoftIrq),
+		Steal:   sub(c.Steal, prev.Steal),
+		Guest:   sub(c.Guest, prev.Guest),
+		Total:   sub(c.Total, prev.Total),
+	}
 }
 
 type CPUInfo struct {
-	User    float32
-	Nice    float32
-	System  float32
-	Idle    float32
-	IOWait  float32
-	IRQ     float32
-	SoftIRQ float32
-	Steal   float32
-	Guest   float32
+	// Core is the name of the core, such as cpu0, and empty for the
+	// aggregate of all cores.
+	Core    string  `json:"core,omitempty"`
+	User    float32 `json:"user" unit:"percent"`
+	Nice    float32 `json:"nice" unit:"percent"`
+	System  float32 `json:"system" unit:"percent"`
+	Idle    float32 `json:"idle" unit:"percent"`
+	IOWait  float32 `json:"iowait" unit:"percent"`
+	IRQ     float32 `json:"irq" unit:"percent"`
+	SoftIRQ float32 `json:"softirq" unit:"percent"`
+	Steal   float32 `json:"steal" unit:"percent"`
+	Guest   float32 `json:"guest" unit:"percent"`
 }
 
 type Loads struct {
-	Load1        string
-	Load5        string
-	Load15       string
-	RunningProcs string
-	TotalProcs   string
+	Load1        string `json:"load1"`
+	Load5        string `json:"load5"`
+	Load15       string `json:"load15"`
+	RunningProcs string `json:"running_procs"`
+	TotalProcs   string `json:"total_procs"`
 }
 
 type MemInfo struct {
-	Total     uint64
-	Free      uint64
-	Buffers   uint64
-	Cached    uint64
-	SwapTotal uint64
-	SwapFree  uint64
+	Total     uint64 `json:"total" unit:"bytes"`
+	Free      uint64 `json:"free" unit:"bytes"`
+	Buffers   uint64 `json:"buffers" unit:"bytes"`
+	Cached    uint64 `json:"cached" unit:"bytes"`
+	SwapTotal uint64 `json:"swap_total" unit:"bytes"`
+	SwapFree  uint64 `json:"swap_free" unit:"bytes"`
 }
 
 func (m MemInfo) Used() uint64 {
 	return m.Total - m.Free - m.Buffers - m.Cached
 }
+
+// SwapActivity holds the cumulative number of pages swapped in and out since
+// boot, and the rates since the previous sample in pages per second.
+type SwapActivity struct {
+	PagesIn  uint64  `json:"pages_in" unit:"pages"`
+	PagesOut uint64  `json:"pages_out" unit:"pages"`
+	InRate   float64 `json:"in_rate" unit:"pages/s"`
+	OutRate  float64 `json:"out_rate" unit:"pages/s"`
+}
+
+// DiskIORaw holds the cumulative I/O counters of a block device since boot.
+type DiskIORaw struct {
+	Device     string `json:"device"`
+	Reads      uint64 `json:"reads"`
+	Writes     uint64 `json:"writes"`
+	ReadBytes  uint64 `json:"read_bytes" unit:"bytes"`
+	WriteBytes uint64 `json:"write_bytes" unit:"bytes"`
+}
+
+// DiskIO holds the I/O counters of a block device and the rates since the
+// previous sample, in bytes and operations per second.
+type DiskIO struct {
+	DiskIORaw
+	ReadRate  float64 `json:"read_rate" unit:"bytes/s"`
+	WriteRate float64 `json:"write_rate" unit:"bytes/s"`
+	ReadIOPS  float64 `json:"read_iops" unit:"1/s"`
+	WriteIOPS float64 `json:"write_iops" unit:"1/s"`
+}
+
+// NFS holds the NFS statistics of a host: of its client, if it mounts
+// NFS exports, and of its server, if it runs nfsd.
+type NFS struct {
+	Client *NFSRPC    `json:"client,omitempty"`
+	Server *NFSRPC    `json:"server,omitempty"`
+	Mounts []NFSMount `json:"mounts,omitempty"`
+}
+
+// NFSRPC holds the RPC calls of the NFS client or server since boot, and
+// the rates since the previous sample. Retrans are the calls the client
+// sent again for lack of an answer, BadCalls those the server rejected.
+type NFSRPC struct {
+	Calls       uint64  `json:"calls"`
+	Retrans     uint64  `json:"retrans"`
+	BadCalls    uint64  `json:"bad_calls"`
+	CallRate    float64 `json:"call_rate" unit:"1/s"`
+	RetransRate float64 `json:"retrans_rate" unit:"1/s"`
+	BadCallRate float64 `json:"bad_call_rate" unit:"1/s"`
+}
+
+// NFSMountRaw holds the counters of an NFS mount since it was mounted,
+// summed over all operations.
+type NFSMountRaw struct {
+	Export     string `json:"export"` // server:/path
+	MountPoint string `json:"mount_point"`
+	FSType     string `json:"fstype"`
+	Ops        uint64 `json:"ops"`
+	Retrans    uint64 `json:"retrans"`
+	// Timeouts are the major timeouts, after which the client reports the
+	// server as not responding.
+	Timeouts   uint64 `json:"timeouts"`
+	ReadBytes  uint64 `json:"read_bytes" unit:"bytes"`
+	WriteBytes uint64 `json:"write_bytes" unit:"bytes"`
+	// RTT is the total time the server took to answer the operations.
+	RTT time.Duration `json:"rtt"`
+}
+
+// NFSMount holds the counters of an NFS mount and the rates since the
+// previous sample.
+type NFSMount struct {
+	NFSMountRaw
+	OpRate      float64 `json:"op_rate" unit:"1/s"`
+	RetransRate float64 `json:"retrans_rate" unit:"1/s"`
+	ReadRate    float64 `json:"read_rate" unit:"bytes/s"`
+	WriteRate   float64 `json:"write_rate" unit:"bytes/s"`
+	// AvgRTT is the mean time the server took to answer an operation
+	// since the previous sample.
+	AvgRTT time.Duration `json:"avg_rtt"`
+}
+
+// Sensor is a temperature sensor, such as a CPU package or thermal zone.
+type Sensor struct {
+	Name string  `json:"name"`
+	Temp float64 `json:"temp" unit:"celsius"` // degrees Celsius
+}
+
+// GPU is a graphics card, as reported by nvidia-smi.
+type GPU struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name"`
+	Utilization float64 `json:"utilization" unit:"percent"`
+	MemoryUsed  uint64  `json:"memory_used" unit:"bytes"`
+	MemoryTotal uint64  `json:"memory_total" unit:"bytes"`
+	Temp        float64 `json:"temp" unit:"celsius"` // degrees Celsius
+}
+
+// MemoryUsage is the percentage of the memory of the card in use.
+func (g GPU) MemoryUsage() float64 {
+	if g.MemoryTotal == 0 {
+		return 0
+	}
+	return float64(g.MemoryUsed) / float64(g.MemoryTotal) * 100
+}
+
+// RAIDInfo is a Linux software RAID array, as listed in /proc/mdstat.
+type RAIDInfo struct {
+	Name    string   `json:"name"`
+	Level   string   `json:"level,omitempty"` // raid1, raid5, ...; unknown while inactive
+	State   string   `json:"state"`           // active, inactive, active (auto-read-only), ...
+	Devices []string `json:"devices"`
+	Failed  []string `json:"failed,omitempty"`
+	Spares  []string `json:"spares,omitempty"`
+	// Total is the number of devices the array is made of, and Active the
+	// number of those working.
+	Total  int `json:"total"`
+	Active int `json:"active"`
+	// SyncAction is the resync, recovery, reshape or check in progress, if
+	// any, SyncProgress how far it got and SyncFinish the estimated time
+	// left.
+	SyncAction   string        `json:"sync_action,omitempty"`
+	SyncProgress float64       `json:"sync_progress,omitempty" unit:"percent"`
+	SyncFinish   time.Duration `json:"sync_finish,omitempty"`
+}
+
+// Degraded reports whether the array runs with fewer devices than it is
+// made of, or isn't running at all.
+func (r RAIDInfo) Degraded() bool {
+	return r.State == "inactive" || r.Active < r.Total || len(r.Failed) > 0
+}
+
+// ZPool is a ZFS storage pool, as reported by zpool.
+type ZPool struct {
+	Name          string  `json:"name"`
+	Size          uint64  `json:"size" unit:"bytes"`
+	Alloc         uint64  `json:"alloc" unit:"bytes"`
+	Free          uint64  `json:"free" unit:"bytes"`
+	Fragmentation float64 `json:"fragmentation" unit:"percent"`
+	Capacity      float64 `json:"capacity" unit:"percent"`
+	Health        string  `json:"health"` // ONLINE, DEGRADED, FAULTED, ...
+	// Status explains what is wrong with a pool which is not healthy.
+	Status string `json:"status,omitempty"`
+}
+
+// Healthy reports whether the pool is ONLINE.
+func (p ZPool) Healthy() bool {
+	return p.Health == "ONLINE"
+}
+
+// Routes summarizes the routing table of a host.
+type Routes struct {
+	Count    int       `json:"count"`
+	Gateways []Gateway `json:"gateways"`
+}
+
+// Gateway is a default gateway and whether it answered a ping from the host.
+// Pinged is false if the host has no ping command.
+type Gateway struct {
+	Address   string        `json:"address"`
+	Interface string        `json:"interface"`
+	Pinged    bool          `json:"pinged"`
+	Reachable bool          `json:"reachable"`
+	RTT       time.Duration `json:"rtt"`
+}
+
+// Systemd holds the state of systemd, as reported by is-system-running, and
+// the names of the failed units.
+type Systemd struct {
+	State  string   `json:"state"`
+	Failed []string `json:"failed,omitempty"`
+}
+
+// BudgetUsage is the data transferred in the current day or month against
+// a configured budget. An empty Interface stands for all interfaces.
+type BudgetUsage struct {
+	Interface string `json:"interface,omitempty"`
+	Period    string `json:"period"`
+	Used      uint64 `json:"used" unit:"bytes"`
+	Limit     uint64 `json:"limit" unit:"bytes"`
+	Exceeded  bool   `json:"exceeded"`
+}
+
+// Process is an entry of the process list.
+type Process struct {
+	PID     int     `json:"pid"`
+	User    string  `json:"user"`
+	CPU     float64 `json:"cpu" unit:"percent"` // percent, averaged over the process lifetime
+	Mem     float64 `json:"mem" unit:"percent"` // percent of physical memory
+	RSS     uint64  `json:"rss" unit:"bytes"`   // resident set size in bytes
+	State   string  `json:"state"`
+	Command string  `json:"command"`
+}
+
+// ProcessSummary counts the processes per user and those which are zombies,
+// i.e. have exited but were not reaped by their parent.
+type ProcessSummary struct {
+	Total   int            `json:"total"`
+	Zombies int            `json:"zombies"`
+	ByUser  map[string]int `json:"by_user" key:"user"`
+}
+
+// Tasks counts processes and threads against the kernel limits.
+type Tasks struct {
+	Processes  int `json:"processes"`
+	Threads    int `json:"threads"`
+	PIDMax     int `json:"pid_max"`
+	ThreadsMax int `json:"threads_max"`
+}
+
+// PIDUsage is the percentage of kernel.pid_max in use. Every thread takes up
+// a PID, so it is based on the thread count.
+func (t Tasks) PIDUsage() float64 {
+	if t.PIDMax == 0 {
+		return 0
+	}
+	return float64(t.Threads) / float64(t.PIDMax) * 100
+}
+
+// ThreadUsage is the percentage of kernel.threads-max in use.
+func (t Tasks) ThreadUsage() float64 {
+	if t.ThreadsMax == 0 {
+		return 0
+	}
+	return float64(t.Threads) / float64(t.ThreadsMax) * 100
+}
+
+// ProcessDetail holds the details of a single process, fetched on demand.
+type ProcessDetail struct {
+	PID     int      `json:"pid"`
+	Cmdline string   `json:"cmdline"`
+	Cwd     string   `json:"cwd"`
+	OpenFDs int      `json:"open_fds"`
+	Threads int      `json:"threads"`
+	Cgroups []string `json:"cgroups"`
+}
+
+// Baseline is a reference snapshot of the configuration of a host, used to
+// detect configuration drift.
+type Baseline struct {
+	Time          time.Time            `json:"time"`
+	KernelRelease string               `json:"kernel_release"`
+	Packages      int                  `json:"packages"`
+	Mounts        map[string]string    `json:"mounts"` // mount point -> device
+	Interfaces    map[string]NetIPAddr `json:"interfaces"`
+}
+
+// CollectorState tells whether a collector of a host runs, for turning
+// collectors on and off at runtime.
+type CollectorState struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Unavailable is why the host cannot run the collector, if it cannot.
+	Unavailable string `json:"unavailable,omitempty"`
+}
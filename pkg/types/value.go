@@ -0,0 +1,197 @@
+/*
+
+rtop - the remote system monitoring utility
+
+Copyright (c) 2015 RapidLoop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Unit is the unit of a metric value, as given by the unit tags of the
+// fields of Stats.
+type Unit string
+
+const (
+	UnitNone           Unit = ""
+	UnitBytes          Unit = "bytes"
+	UnitBytesPerSecond Unit = "bytes/s"
+	UnitBits           Unit = "bits"
+	UnitBitsPerSecond  Unit = "bits/s"
+	UnitPercent        Unit = "percent"
+	UnitRatio          Unit = "ratio"
+	UnitPerSecond      Unit = "1/s"
+	UnitSeconds        Unit = "seconds"
+	UnitMilliseconds   Unit = "ms"
+	UnitNanoseconds    Unit = "ns"
+	UnitCelsius        Unit = "celsius"
+	UnitFahrenheit     Unit = "fahrenheit"
+	UnitKelvin         Unit = "kelvin"
+	UnitPages          Unit = "pages"
+	UnitPagesPerSecond Unit = "pages/s"
+	UnitTicks          Unit = "ticks"
+	UnitFlag           Unit = "flag" // 1 if set, 0 otherwise
+)
+
+// unitSymbols are the short forms of the units used by String.
+var unitSymbols = map[Unit]string{
+	UnitBytes:          "B",
+	UnitBytesPerSecond: "B/s",
+	UnitBits:           "bit",
+	UnitBitsPerSecond:  "bit/s",
+	UnitPercent:        "%",
+	UnitPerSecond:      "/s",
+	UnitSeconds:        "s",
+	UnitMilliseconds:   "ms",
+	UnitNanoseconds:    "ns",
+	UnitCelsius:        "°C",
+	UnitFahrenheit:     "°F",
+	UnitKelvin:         "K",
+}
+
+// conversion converts an amount from one unit to another.
+type conversion struct {
+	from, to Unit
+}
+
+// linear are the conversions which are a multiplication, the reverse ones
+// are derived from them.
+var linear = map[conversion]float64{
+	{UnitBytes, UnitBits}:                   8,
+	{UnitBytesPerSecond, UnitBitsPerSecond}: 8,
+	{UnitRatio, UnitPercent}:                100,
+	{UnitSeconds, UnitMilliseconds}:         1e3,
+	{UnitSeconds, UnitNanoseconds}:          1e9,
+	{UnitMilliseconds, UnitNanoseconds}:     1e6,
+}
+
+// Value is a metric value with its unit, so that outputs can convert it
+// rather than guess what a bare number means.
+type Value struct {
+	Amount float64 `json:"amount"`
+	Unit   Unit    `json:"unit,omitempty"`
+}
+
+// Bytes returns a value in bytes.
+func Bytes(n uint64) Value {
+	return Value{float64(n), UnitBytes}
+}
+
+// Percent returns a value in percent.
+func Percent(p float64) Value {
+	return Value{p, UnitPercent}
+}
+
+// Rate returns a value per second.
+func Rate(r float64) Value {
+	return Value{r, UnitPerSecond}
+}
+
+// Celsius returns a temperature in degrees Celsius.
+func Celsius(t float64) Value {
+	return Value{t, UnitCelsius}
+}
+
+// Convert returns the value in the given unit. Values can be converted
+// between bytes and bits, ratios and percent, seconds, milliseconds and
+// nanoseconds, and the units of temperature.
+func (v Value) Convert(to Unit) (Value, error) {
+	if v.Unit == to {
+		return v, nil
+	}
+	if f, ok := linear[conversion{v.Unit, to}]; ok {
+		return Value{v.Amount * f, to}, nil
+	}
+	if f, ok := linear[conversion{to, v.Unit}]; ok {
+		return Value{v.Amount / f, to}, nil
+	}
+	if k, ok := kelvin(v); ok {
+		switch to {
+		case UnitKelvin:
+			return Value{k, to}, nil
+		case UnitCelsius:
+			return Value{k - 273.15, to}, nil
+		case UnitFahrenheit:
+			return Value{(k-273.15)*9/5 + 32, to}, nil
+		}
+	}
+	return v, fmt.Errorf("cannot convert %s to %s", unitName(v.Unit), unitName(to))
+}
+
+// kelvin returns a temperature in kelvin, and whether v is a temperature.
+func kelvin(v Value) (float64, bool) {
+	switch v.Unit {
+	case UnitKelvin:
+		return v.Amount, true
+	case UnitCelsius:
+		return v.Amount + 273.15, true
+	case UnitFahrenheit:
+		return (v.Amount-32)*5/9 + 273.15, true
+	}
+	return 0, false
+}
+
+// Integral reports whether the unit counts whole things, such as bytes, so
+// that outputs can write its values as integers.
+func (u Unit) Integral() bool {
+	switch u {
+	case UnitBytes, UnitBits, UnitPages, UnitTicks, UnitNanoseconds, UnitFlag:
+		return true
+	}
+	return false
+}
+
+// String writes the amount followed by the unit, e.g. 12.5%.
+func (v Value) String() string {
+	return strconv.FormatFloat(v.Amount, 'f', -1, 64) + v.Unit.Suffix()
+}
+
+// Suffix returns what is written after an amount in the unit: its symbol,
+// separated by a space unless it is a percent sign, a per second or a
+// degree.
+func (u Unit) Suffix() string {
+	sym, ok := unitSymbols[u]
+	switch {
+	case u == UnitNone:
+		return ""
+	case !ok:
+		return " " + string(u)
+	case u == UnitPercent, u == UnitPerSecond, u == UnitCelsius, u == UnitFahrenheit:
+		return sym
+	}
+	return " " + sym
+}
+
+func unitName(u Unit) string {
+	if u == UnitNone {
+		return "a plain number"
+	}
+	return string(u)
+}
+
+// ExtraValue returns the metric of an optional collector with its unit.
+func (s Stats) ExtraValue(key string) Value {
+	return Value{s.Extra[key], s.ExtraUnits[key]}
+}